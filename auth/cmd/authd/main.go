@@ -1,17 +1,81 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"os"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/signing"
 )
 
-const apiKey = "dummy-api-key"
+const (
+	apiKey = "dummy-api-key"
+
+	// actorHeader is forwarded by traefik to the service, dnsd records it
+	// in audit log
+	actorHeader = "X-Auth-Actor"
+	// actorSignatureHeader is signature of actorHeader, dnsd trusts actor
+	// only if it is signed with AUTHD_SECRET
+	actorSignatureHeader = "X-Auth-Actor-Signature"
+
+	// forwardedMethodHeader and forwardedUriHeader are method and uri of
+	// the request traefik forward auth asks about, forward auth request
+	// itself is always GET /
+	forwardedMethodHeader = "X-Forwarded-Method"
+	forwardedUriHeader    = "X-Forwarded-Uri"
+)
 
 func main() {
+	corsConfig, err := cors.FromEnv("")
+	if err != nil {
+		log.Fatalf("Invalid CORS config: %v", err)
+	}
+	corsPolicy, err := cors.New(corsConfig)
+	if err != nil {
+		log.Fatalf("Invalid CORS config: %v", err)
+	}
+
+	// actor is signed only if secret is set, dnsd records unsigned one as
+	// anonymous
+	var signer *signing.Signer
+	if secret := os.Getenv("AUTHD_SECRET"); secret != "" {
+		if signer, err = signing.NewSigner(secret); err != nil {
+			log.Fatalf("Invalid AUTHD_SECRET: %v", err)
+		}
+	} else {
+		log.Warn("AUTHD_SECRET not set, actor of requests is not signed")
+	}
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Info("Authorization header: %s\n", r.Header.Get("Authorization"))
+		// browser sends preflight without credentials, it is let through
+		// so the service behind traefik answers it with its own policy
+		if isPreflight(r) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		log.Infof("Authorization header: %s", r.Header.Get("Authorization"))
 		if r.Header.Get("Authorization") == apiKey {
+			caller := actor(apiKey)
+			w.Header().Set(actorHeader, caller)
+			if signer != nil {
+				// signature is bound to the forwarded request, so captured
+				// header can not name the actor of other request
+				signature, err := signer.SignValue(
+					r.Header.Get(forwardedMethodHeader),
+					r.Header.Get(forwardedUriHeader),
+					caller,
+				)
+				if err != nil {
+					log.Error("Error signing actor: ", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set(actorSignatureHeader, signature)
+			}
 			w.WriteHeader(http.StatusOK)
 			if _, err := fmt.Fprint(w, "Authenticated"); err != nil {
 				return
@@ -25,7 +89,22 @@ func main() {
 	})
 
 	log.Info("Starting auth daemon on port 8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	// CORS headers are set on rejected requests so browser can read 401,
+	// traefik returns them to the client as is
+	if err := http.ListenAndServe(":8080", corsPolicy.Handler(http.DefaultServeMux)); err != nil {
 		fmt.Printf("Auth daemon failed to start: %v", err)
 	}
 }
+
+// actor identifies caller by api key fingerprint so key itself is not
+// stored in audit log
+func actor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "apikey:" + hex.EncodeToString(sum[:])[:12]
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Header.Get(forwardedMethodHeader) == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
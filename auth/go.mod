@@ -1,7 +1,14 @@
 module prem-gateway/auth
 
-go 1.20
+go 1.24.0
 
-require github.com/sirupsen/logrus v1.9.3
+require (
+	github.com/sirupsen/logrus v1.9.3
+	prem-gateway/pkg v0.0.0
+)
 
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require golang.org/x/sys v0.8.0 // indirect
+
+// cors and signing are shared with dnsd and controllerd, docker build gets
+// pkg module as additional context
+replace prem-gateway/pkg => ../pkg
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"prem-gateway/controllerd/internal/provisioner"
+)
+
+// acmeOptionsFromEnv returns provisioner options of default ACME CA. CA
+// of ACME_CA_SERVER takes precedence over LETSENCRYPT_PROD, EAB and CA
+// certificates are used with it only
+func acmeOptionsFromEnv() ([]provisioner.Option, error) {
+	opts := []provisioner.Option{
+		provisioner.WithLetsEncryptProd(os.Getenv("LETSENCRYPT_PROD") != ""),
+	}
+
+	if caServer := os.Getenv("ACME_CA_SERVER"); caServer != "" {
+		opts = append(opts, provisioner.WithAcmeCaServer(caServer))
+	}
+	opts = append(opts,
+		provisioner.WithAcmeEab(os.Getenv("ACME_EAB_KID"), os.Getenv("ACME_EAB_HMAC")),
+		provisioner.WithAcmeKeyType(os.Getenv("ACME_KEY_TYPE")),
+	)
+
+	if path := os.Getenv("ACME_CA_CERTIFICATES"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ACME_CA_CERTIFICATES: %v", err)
+		}
+		opts = append(opts, provisioner.WithAcmeCaCertificates(pem))
+	}
+
+	return opts, nil
+}
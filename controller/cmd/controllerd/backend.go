@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	containerruntime "prem-gateway/controllerd/internal/container-runtime"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/provisioner"
+	"strings"
+)
+
+const (
+	backendDocker     = "docker"
+	backendKubernetes = "kubernetes"
+
+	// serviceAccountNamespace is namespace of controllerd pod
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	// defaultLockFile is shared by controllerd instances through
+	// controllerd-data volume
+	defaultLockFile = "/var/lib/controllerd/leader.lock"
+	// defaultAcmeStorage is traefik acme.json mounted into controllerd
+	defaultAcmeStorage = provisioner.DefaultAcmeStorage
+	// leaseName is Lease controllerd instances in namespace compete for
+	leaseName = "prem-gateway-controllerd"
+)
+
+// newBackend returns provisioner backend selected by CONTROLLERD_BACKEND,
+// docker if not set, and elector of instances sharing the backend
+func newBackend(identity string) (provisioner.Backend, leader.Elector, error) {
+	switch backend := os.Getenv("CONTROLLERD_BACKEND"); backend {
+	case "", backendDocker:
+		rt, err := containerruntime.NewDocker()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create docker client: %v", err)
+		}
+		acmeStorage := os.Getenv("ACME_STORAGE")
+		if acmeStorage == "" {
+			acmeStorage = defaultAcmeStorage
+		}
+		b, err := provisioner.NewDockerBackend(
+			rt, provisioner.DefaultRemoveDelay, acmeStorage,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lockFile := os.Getenv("CONTROLLERD_LOCK_FILE")
+		if lockFile == "" {
+			lockFile = defaultLockFile
+		}
+		elector, err := leader.NewFileElector(lockFile, identity, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return b, elector, nil
+	case backendKubernetes:
+		return newKubernetesBackend(identity)
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %v", backend)
+	}
+}
+
+// newKubernetesBackend uses in-cluster config, or KUBECONFIG if controllerd
+// runs outside of the cluster
+func newKubernetesBackend(identity string) (provisioner.Backend, leader.Elector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := os.Getenv("KUBECONFIG")
+		if kubeconfig == "" {
+			return nil, nil, fmt.Errorf("not running in cluster and KUBECONFIG not set: %v", err)
+		}
+		if config, err = clientcmd.BuildConfigFromFlags("", kubeconfig); err != nil {
+			return nil, nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+		}
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %v", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kubernetes client: %v", err)
+	}
+
+	namespace := os.Getenv("KUBERNETES_NAMESPACE")
+	if namespace == "" {
+		if ns, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = strings.TrimSpace(string(ns))
+		}
+	}
+
+	b, err := provisioner.NewKubernetesBackend(clientset, dynamicClient, provisioner.KubernetesConfig{
+		Namespace:            namespace,
+		IngressKind:          os.Getenv("KUBERNETES_INGRESS_KIND"),
+		ClusterIssuer:        os.Getenv("KUBERNETES_CLUSTER_ISSUER"),
+		IngressClass:         os.Getenv("KUBERNETES_INGRESS_CLASS"),
+		CertManagerNamespace: os.Getenv("KUBERNETES_CERT_MANAGER_NAMESPACE"),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if namespace == "" {
+		namespace = "default"
+	}
+	elector, err := leader.NewKubernetesElector(clientset, namespace, leaseName, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, elector, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/controllerd/internal/api"
+	containerruntime "prem-gateway/controllerd/internal/container-runtime"
+	"prem-gateway/controllerd/internal/provisioner"
+)
+
+// traefikCommand is command traefik is started with in docker-compose.yml,
+// used in dry run if docker is not reachable
+var traefikCommand = []string{
+	"--providers.docker=true",
+	"--providers.docker.exposedbydefault=false",
+	"--accesslog=true",
+	"--ping",
+	"--entrypoints.web.address=:80",
+}
+
+// runDryRun provisions domain against simulated copy of docker containers
+// and prints label and command changes it would make. Containers are only
+// read, if docker is not reachable containers as started by
+// docker-compose.yml are simulated, acme.json is not modified
+func runDryRun(domain, email, acmeCa string, services []string) error {
+	if !api.ValidDomain(domain) {
+		return fmt.Errorf("invalid domain %q, set it with -domain", domain)
+	}
+	if email != "" && !api.ValidEmail(email) {
+		return fmt.Errorf("invalid email %q", email)
+	}
+
+	ctx := context.Background()
+	sim, err := snapshotDocker(ctx)
+	if err != nil {
+		log.Warnf("Docker not reachable, simulating default containers: %v", err)
+		containers := make([]types.ContainerJSON, 0, len(services)+1)
+		for _, v := range services {
+			containers = append(containers, containerruntime.NewContainer(v, v, nil, nil))
+		}
+		containers = append(containers, containerruntime.NewContainer(
+			provisioner.TraefikService, "traefik:v2.11", nil, traefikCommand,
+		))
+		sim = containerruntime.NewSimulator(containers...)
+	}
+
+	backend, err := provisioner.NewDockerBackend(sim, 0, "")
+	if err != nil {
+		return err
+	}
+	p, err := provisioner.New(
+		backend, append(acmeOptions, provisioner.WithTraefikDelay(0))...,
+	)
+	if err != nil {
+		return err
+	}
+
+	provisionErr := p.ProvisionDomain(
+		ctx, domain, email, acmeCa, services, getPremServicesForRestart(services),
+	)
+
+	for _, v := range sim.Changes() {
+		fmt.Print(v.String())
+	}
+
+	return provisionErr
+}
+
+func snapshotDocker(ctx context.Context) (*containerruntime.Simulator, error) {
+	docker, err := containerruntime.NewDocker()
+	if err != nil {
+		return nil, err
+	}
+
+	return containerruntime.Snapshot(ctx, docker)
+}
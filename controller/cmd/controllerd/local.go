@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"prem-gateway/controllerd/internal/api"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/localca"
+	"prem-gateway/controllerd/internal/provisioner"
+	"prem-gateway/controllerd/internal/queue"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLocalCaDir keeps root of local CA in controllerd-data volume
+	defaultLocalCaDir = "/var/lib/controllerd/local-ca"
+	// localRenewCheckInterval is how often expiry of local certificate is
+	// checked
+	localRenewCheckInterval = time.Hour * 12
+)
+
+// localCa is local CA mode, gateway without provisioned domain is served on
+// private domain with certificate issued by local CA
+type localCa struct {
+	domain string
+	ips    []net.IP
+	dir    string
+
+	mu sync.Mutex
+	// notAfter is expiry of served certificate, zero until it is issued
+	notAfter time.Time
+}
+
+// localCaFromEnv returns local CA mode configured by LOCAL_CA_DOMAIN, nil
+// if it is not set
+func localCaFromEnv() (*localCa, error) {
+	domain := strings.ToLower(os.Getenv("LOCAL_CA_DOMAIN"))
+	if domain == "" {
+		return nil, nil
+	}
+	if !api.ValidDomain(domain) {
+		return nil, fmt.Errorf("invalid LOCAL_CA_DOMAIN %q", domain)
+	}
+
+	ips := make([]net.IP, 0)
+	for _, v := range strings.Split(os.Getenv("LOCAL_CA_IPS"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q in LOCAL_CA_IPS", v)
+		}
+		if err := localca.ValidateIp(ip); err != nil {
+			return nil, fmt.Errorf("invalid LOCAL_CA_IPS: %v", err)
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		log.Warn("LOCAL_CA_IPS is empty, local certificate is valid only for ", domain)
+	}
+
+	dir := os.Getenv("LOCAL_CA_DIR")
+	if dir == "" {
+		dir = defaultLocalCaDir
+	}
+
+	return &localCa{domain: domain, ips: ips, dir: dir}, nil
+}
+
+// provisionLocal issues certificate of local CA, root is created on first
+// use, and restarts prem-services, services and traefik with it
+func provisionLocal(
+	ctx context.Context,
+	p *provisioner.Provisioner,
+	local *localCa,
+	services []string,
+) error {
+	ca, err := localca.Open(local.dir, local.domain)
+	if err != nil {
+		return err
+	}
+	cert, key, err := ca.Issue(local.domain, local.ips)
+	if err != nil {
+		return fmt.Errorf("failed to issue local certificate: %v", err)
+	}
+
+	notAfter, err := localca.NotAfter(cert)
+	if err != nil {
+		return fmt.Errorf("failed to read local certificate: %v", err)
+	}
+
+	if err := p.ProvisionLocal(ctx, local.domain, provisioner.LocalCertificate{
+		Cert: cert,
+		Key:  key,
+	}, services, getPremServicesForRestart(services)); err != nil {
+		return err
+	}
+
+	local.mu.Lock()
+	local.notAfter = notAfter
+	local.mu.Unlock()
+
+	return nil
+}
+
+// renewalDue reports if served certificate expires within
+// localca.RenewBefore
+func (l *localCa) renewalDue(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return !l.notAfter.IsZero() && now.Add(localca.RenewBefore).After(l.notAfter)
+}
+
+// renewLocal issues certificate of local CA again before it expires, while
+// no domain is provisioned and instance is leader. It runs until ctx is done
+func renewLocal(
+	ctx context.Context,
+	restartQueue *queue.Queue,
+	elector leader.Elector,
+	p *provisioner.Provisioner,
+	services []string,
+	local *localCa,
+) {
+	ticker := time.NewTicker(localRenewCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !local.renewalDue(time.Now()) || !elector.Status().IsLeader {
+			continue
+		}
+
+		dnsInfo, err := dnsClient.GetExistingDomain(ctx)
+		if err != nil {
+			log.Error("Error getting existing DNS: ", err)
+			continue
+		}
+		if dnsInfo != nil && dnsInfo.Domain != "" {
+			// provisioned domain took over, its certificate is renewed by ACME
+			continue
+		}
+
+		log.Info("Renewing local CA certificate of ", local.domain)
+		j, _, err := restartQueue.Submit(
+			"renew local ca certificate "+local.domain,
+			localTarget(local.domain),
+			func(ctx context.Context) error {
+				return provisionLocal(ctx, p, local, services)
+			},
+		)
+		if err != nil {
+			log.Error("Error submitting restart: ", err)
+			return
+		}
+		<-j.Done()
+		if errors.Is(j.Err(), queue.ErrSuperseded) {
+			log.Info("Renewal of local CA certificate superseded by newer restart")
+			continue
+		}
+		if err := j.Err(); err != nil {
+			log.Error("Error renewing local CA certificate: ", err)
+			recordAudit(local.domain, services, err)
+			continue
+		}
+
+		log.Info("Local CA certificate renewed")
+		recordAudit(local.domain, services, nil)
+	}
+}
@@ -3,41 +3,59 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/api/types/strslice"
-	"github.com/docker/docker/client"
+	"errors"
+	"flag"
 	log "github.com/sirupsen/logrus"
-	"io"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"prem-gateway/controllerd/internal/api"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/provisioner"
+	"prem-gateway/controllerd/internal/queue"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/dnsclient"
+	"prem-gateway/pkg/signing"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const (
-	letsEncryptStaging = "https://acme-staging-v02.api.letsencrypt.org/directory"
-	letsEncryptProd    = "https://acme-v02.api.letsencrypt.org/directory"
+	serverAddress = ":8080"
 
-	premappService = "premapp"
-	premdService   = "premd"
+	dnsdUrl = "http://dnsd:8080"
+	// auditActor is recorded by dnsd as actor of restarts controllerd does
+	auditActor          = "controllerd"
+	auditActionRestart  = "services.restart"
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+	auditTimeout        = time.Minute
+
+	reconcileRetryInterval = time.Second * 5
 )
 
 var (
-	letEncryptProd bool
+	// acmeOptions configure default ACME CA of provisioner
+	acmeOptions []provisioner.Option
+	dnsClient   *dnsclient.Client
 )
 
-type DnsInfo struct {
-	Domain    string `json:"domain"`
-	SubDomain string `json:"sub_domain"`
-	NodeName  string `json:"node_name"`
-	Email     string `json:"email"`
-}
-
+// @title Controller Daemon API
+// @version 1.0
+// @description Controller Daemon restarts traefik, dnsd and other Docker containers with TLS and routes for domain provisioned by dnsd.
 func main() {
+	dryRun := flag.Bool(
+		"dry-run", false,
+		"run provisioning of -domain against simulated copy of containers and print changes it would make",
+	)
+	dryRunDomain := flag.String("domain", "", "domain provisioned in dry run")
+	dryRunEmail := flag.String("email", "", "acme email used in dry run")
+	dryRunAcmeCa := flag.String(
+		"acme-ca", "", "acme ca used in dry run, staging, production or directory url",
+	)
+	flag.Parse()
+
 	serviceNames := os.Getenv("SERVICES")
 	services := make([]string, 0)
 	if serviceNames != "" {
@@ -45,135 +63,281 @@ func main() {
 	}
 	services = append(services, "dnsd")
 
-	letsEncrypt := os.Getenv("LETSENCRYPT_PROD")
-	if letsEncrypt != "" {
-		letEncryptProd = true
+	corsConfig, err := cors.FromEnv("")
+	if err != nil {
+		log.Fatalf("Invalid CORS config: %v", err)
+	}
+	corsPolicy, err := cors.New(corsConfig)
+	if err != nil {
+		log.Fatalf("Invalid CORS config: %v", err)
 	}
 
-	http.HandleFunc("/domain-provisioned", func(w http.ResponseWriter, r *http.Request) {
-		go func() {
-			if r.Method != http.MethodPost {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-
-			email := r.URL.Query().Get("email")
-			domain := r.URL.Query().Get("domain")
+	// audit events are signed with the secret shared with dnsd, dry run
+	// does not record them so it runs without the secret
+	dnsOpts := []dnsclient.Option{dnsclient.WithActor(auditActor)}
+	if secret := os.Getenv("CONTROLLERD_SECRET"); secret != "" {
+		dnsOpts = append(dnsOpts, dnsclient.WithSecret(secret))
+	}
+	dnsClient, err = dnsclient.New(dnsdUrl, dnsOpts...)
+	if err != nil {
+		log.Fatalf("Failed to create dnsd client: %v", err)
+	}
 
-			premServices := getPremServicesForRestart(services)
-			if len(premServices) > 0 {
-				if err := restartServicesWithTls(domain, nil, premServices); err != nil {
-					log.Error("Error restarting containers from domainProvisioned : ", err)
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-			}
+	acmeOptions, err = acmeOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid ACME config: %v", err)
+	}
+	local, err := localCaFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid local CA config: %v", err)
+	}
 
-			if err := restartServicesWithTls(domain, services, nil); err != nil {
-				log.Error("Error restarting containers from domainProvisioned : ", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+	if *dryRun {
+		if err := runDryRun(*dryRunDomain, *dryRunEmail, *dryRunAcmeCa, services); err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		return
+	}
 
-			//TODO maybe add health check to all restarted services since services
-			//needs to be restarted before traefik can pick up the new labels
-			time.Sleep(time.Second * 3)
+	// dnsd signs requests with the shared secret, unsigned or replayed
+	// requests are rejected
+	verifier, err := signing.NewVerifier(os.Getenv("CONTROLLERD_SECRET"), 0)
+	if err != nil {
+		log.Fatalf("Invalid CONTROLLERD_SECRET: %v", err)
+	}
 
-			if err := restartTraefikWithTls(email); err != nil {
-				log.Error("Error restarting traefik from domain-provisioned : ", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}()
+	// hostname is container id in docker and pod name in kubernetes
+	identity, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to get hostname: %v", err)
+	}
 
-		if _, err := io.WriteString(w, "OK"); err != nil {
-			return
-		}
-	})
+	backend, elector, err := newBackend(identity)
+	if err != nil {
+		log.Fatalf("Failed to create backend: %v", err)
+	}
+	p, err := provisioner.New(backend, acmeOptions...)
+	if err != nil {
+		log.Fatalf("Failed to create provisioner: %v", err)
+	}
 
 	// TODO expose domainUpdated/Deleted endpoints to restart services without TLS
 	//think how to fetch AI running services
 
+	ctx, stop := signal.NotifyContext(context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+		syscall.SIGQUIT)
+	defer stop()
+
+	// lease is released only after restart queue is drained, so other
+	// instance does not start restarting while this one still does
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
 	go func() {
-		log.Info("Starting checking if dns exists")
-		for {
-			if _, err := http.Get("http://traefik:8080/ping"); err != nil {
-				log.Error("Error pinging Traefik: ", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+		defer close(electionDone)
+		elector.Run(electionCtx)
+	}()
 
-			if _, err := http.Get("http://dnsd:8080/dns/check"); err != nil {
-				log.Error("Error checking DNSd: ", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+	serverOpts := []api.Option{
+		api.WithServices(services),
+		api.WithCors(corsPolicy),
+		api.WithPremServices(func() map[string]int {
+			return getPremServicesForRestart(services)
+		}),
+	}
+	if local != nil {
+		serverOpts = append(serverOpts, api.WithLocalCaDir(local.dir))
+	}
+	srv, err := api.NewServer(
+		serverAddress, p, elector, verifier,
+		func(ctx context.Context, domain, email, acmeCa string) error {
+			return provisionDomain(ctx, p, domain, email, acmeCa, services)
+		},
+		serverOpts...,
+	)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	if err := srv.RunBackground(func() {
+		reconcileExistingDomain(ctx, srv.Queue(), elector, p, services, local)
+	}); err != nil {
+		log.Fatalf("Failed to start reconciliation: %v", err)
+	}
+	if local != nil {
+		if err := srv.RunBackground(func() {
+			renewLocal(ctx, srv.Queue(), elector, p, services, local)
+		}); err != nil {
+			log.Fatalf("Failed to start local CA renewal: %v", err)
+		}
+	}
 
-			resp, err := http.Get("http://dnsd:8080/dns/existing")
-			if err != nil {
-				log.Info("Error getting existing DNS: ", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+	err = srv.Start(ctx)
+	stopElection()
+	<-electionDone
+	if err != nil {
+		log.Fatalf("Controller daemon failed: %v", err)
+	}
+	log.Info("Controller daemon stopped")
+}
 
-			if resp.StatusCode != 200 {
-				log.Error("Got non-200 status code: ", resp.StatusCode)
-				resp.Body.Close()
-				time.Sleep(time.Second * 5)
-				continue
-			}
+// provisionDomain restarts prem-services, services and traefik with TLS for
+// domain, result is recorded in audit log
+func provisionDomain(
+	ctx context.Context,
+	p *provisioner.Provisioner,
+	domain, email, acmeCa string,
+	services []string,
+) error {
+	premServices := getPremServicesForRestart(services)
+	err := p.ProvisionDomain(ctx, domain, email, acmeCa, services, premServices)
+	if errors.Is(err, context.Canceled) {
+		// superseding restart or new leader records its own result
+		log.Info("Restart for domain-provisioned superseded or leadership lost: ", domain)
+		return err
+	}
+	if err != nil {
+		log.Error("Error restarting containers from domain-provisioned : ", err)
+	}
 
-			body, err := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				log.Error("Error reading body: ", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+	recordAudit(domain, services, err)
 
-			var dnsInfo DnsInfo
-			if err := json.Unmarshal(body, &dnsInfo); err != nil {
-				log.Error("Error unmarshaling DNS info: ", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
+	return err
+}
+
+// localTarget is state restart with certificate of local CA for domain
+// converges gateway to
+func localTarget(domain string) string {
+	return "local " + domain
+}
+
+// reconcileExistingDomain restarts services with TLS on startup if domain
+// was provisioned before, or with certificate of local CA if domain was not
+// provisioned and local is set. It waits until instance is leader and
+// retries until it succeeds or ctx is done
+func reconcileExistingDomain(
+	ctx context.Context,
+	restartQueue *queue.Queue,
+	elector leader.Elector,
+	p *provisioner.Provisioner,
+	services []string,
+	local *localCa,
+) {
+	log.Info("Starting checking if dns exists")
+	for ; ; sleepCtx(ctx, reconcileRetryInterval) {
+		if ctx.Err() != nil || elector.WaitLeader(ctx) != nil {
+			log.Info("Stopped checking if dns exists")
+			return
+		}
+
+		if _, err := http.Get("http://traefik:8080/ping"); err != nil {
+			log.Error("Error pinging Traefik: ", err)
+			continue
+		}
 
-			if dnsInfo.Domain == "" || dnsInfo.Email == "" {
+		if err := dnsClient.Check(ctx); err != nil {
+			log.Error("Error checking DNSd: ", err)
+			continue
+		}
+
+		dnsInfo, err := dnsClient.GetExistingDomain(ctx)
+		if err != nil {
+			log.Info("Error getting existing DNS: ", err)
+			continue
+		}
+
+		var (
+			domain, name, target string
+			run                  func(ctx context.Context) error
+		)
+		if (dnsInfo == nil || dnsInfo.Domain == "") && local != nil {
+			log.Info("Domain is not provisioned, serving local CA domain ", local.domain)
+			domain = local.domain
+			name = "reconcile local ca domain " + domain
+			target = localTarget(domain)
+			run = func(ctx context.Context) error {
+				return provisionLocal(ctx, p, local, services)
+			}
+		} else {
+			if dnsInfo == nil || dnsInfo.Domain == "" || dnsInfo.Email == "" {
 				log.Info("Domain or email is empty, skipping restart")
 				break
 			}
 
-			if err := restartServicesWithTls(dnsInfo.Domain, services, nil); err != nil {
-				log.Error("Error restarting containers: ", err)
-				time.Sleep(time.Second * 5)
-				continue
+			if !api.ValidDomain(dnsInfo.Domain) {
+				log.Error("Invalid existing domain, skipping restart: ", dnsInfo.Domain)
+				break
 			}
 
-			//TODO maybe add health check to all restarted services since services
-			//needs to be restarted before traefik can pick up the new labels
-			time.Sleep(time.Second * 3)
-
-			if err := restartTraefikWithTls(dnsInfo.Email); err != nil {
-				log.Error("Error restarting traefik: ", err)
-				time.Sleep(time.Second * 5)
-				continue
+			email, acmeCa := dnsInfo.Email, dnsInfo.AcmeCa
+			domain = dnsInfo.Domain
+			name = "reconcile existing domain " + domain
+			target = api.ProvisionTarget(domain, email, acmeCa)
+			run = func(ctx context.Context) error {
+				return p.ProvisionDomain(ctx, domain, email, acmeCa, services, nil)
 			}
+		}
 
-			log.Info("Containers restarted")
+		j, _, err := restartQueue.Submit(name, target, run)
+		if err != nil {
+			log.Error("Error submitting restart: ", err)
+			return
+		}
+		<-j.Done()
+		if errors.Is(j.Err(), queue.ErrSuperseded) {
+			log.Info("Reconciliation superseded by newer restart")
 			break
 		}
-		log.Info("Finished checking if dns exists")
-	}()
+		if err := j.Err(); err != nil {
+			log.Error("Error restarting containers: ", err)
+			continue
+		}
 
-	log.Info("Starting controller daemon on port 8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Errorf("Controller daemon failed to start: %v", err)
+		log.Info("Containers restarted")
+		recordAudit(domain, services, nil)
+		break
+	}
+	log.Info("Finished checking if dns exists")
+}
+
+// sleepCtx sleeps for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// recordAudit reports restart of services to dnsd audit log, failure to
+// report is only logged
+func recordAudit(domain string, services []string, restartErr error) {
+	after, err := json.Marshal(append(append([]string{}, services...), "traefik"))
+	if err != nil {
+		log.Error("Error marshaling audit event: ", err)
+		return
+	}
+
+	event := dnsclient.AuditEventRequest{
+		Action:   auditActionRestart,
+		Resource: domain,
+		After:    after,
+		Outcome:  auditOutcomeSuccess,
+	}
+	if restartErr != nil {
+		event.Outcome = auditOutcomeFailure
+		event.Error = restartErr.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+	if err := dnsClient.RecordAuditEvent(ctx, event); err != nil {
+		log.Error("Error recording audit event: ", err)
 	}
 }
 
 func getPremServicesForRestart(srvcs []string) map[string]int {
 	svcs := make(map[string]int)
-	if contains(srvcs, premdService) {
+	if contains(srvcs, provisioner.PremdService) {
 		resp, err := http.Get("http://premd:8000/v1/services/")
 		if err != nil {
 			return nil
@@ -213,177 +377,6 @@ func contains(slice []string, str string) bool {
 	return false
 }
 
-func restartContainer(
-	ctx context.Context,
-	cli *client.Client,
-	containerName string,
-	labels map[string]string,
-	cmds strslice.StrSlice,
-) error {
-	containerJson, err := cli.ContainerInspect(ctx, containerName)
-	if err != nil {
-		return err
-	}
-	newConfig := containerJson.Config
-	//TODO check duplicate labels and cmds
-	if len(labels) > 0 {
-		newLabels := make(map[string]string)
-		for k, v := range newConfig.Labels {
-			if !strings.Contains(k, "traefik") {
-				newLabels[k] = v
-			}
-		}
-		for k, v := range labels {
-			newLabels[k] = v
-		}
-		newConfig.Labels = newLabels
-	}
-	if len(cmds) > 0 {
-		newConfig.Cmd = append(newConfig.Cmd, cmds...)
-	}
-
-	noWaitTimeout := 0
-	if err := cli.ContainerStop(
-		ctx, containerName, container.StopOptions{Timeout: &noWaitTimeout},
-	); err != nil {
-		return err
-	}
-	if err := cli.ContainerRemove(
-		ctx, containerName, types.ContainerRemoveOptions{},
-	); err != nil {
-		//TODO this is workaround for restarting prem services, container removal
-		//fails because prem-services are started with --rm flag in prem-daemon
-		//and we can't remove them, so we just ignore the error
-		//TODO maybe we should check if the container is prem-service and if it is
-		//then we should just restart it without removing it
-		//sleeping for 5 seconds to wait until container is removed
-		log.Warning("Error removing container: ", err)
-		time.Sleep(time.Second * 5)
-		//return err
-	}
-
-	if _, err := cli.ContainerCreate(
-		ctx,
-		newConfig,
-		containerJson.HostConfig,
-		&network.NetworkingConfig{
-			EndpointsConfig: containerJson.NetworkSettings.Networks,
-		},
-		nil,
-		containerName,
-	); err != nil {
-		log.Error("Error creating container: ", err)
-		return err
-	}
-
-	if err := cli.ContainerStart(
-		ctx, containerName, types.ContainerStartOptions{},
-	); err != nil {
-		log.Error("Error starting container: ", err)
-		return err
-	}
-
-	return nil
-}
-
-func restartServicesWithTls(domain string, services []string, premServices map[string]int) error {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return fmt.Errorf("failed to create docker client: %v", err)
-	}
-
-	for _, v := range services {
-		switch v {
-		case premappService:
-			labels := map[string]string{
-				"traefik.enable":                                                       "true",
-				"traefik.http.routers.premapp-http.rule":                               fmt.Sprintf("PathPrefix(`/`) && Host(`%s`)", domain),
-				"traefik.http.routers.premapp-http.entrypoints":                        "web",
-				"traefik.http.routers.premapp-https.rule":                              fmt.Sprintf("PathPrefix(`/`) && Host(`%s`)", domain),
-				"traefik.http.routers.premapp-https.entrypoints":                       "websecure",
-				fmt.Sprintf("traefik.http.routers.%s-%s.tls.certresolver", v, "https"): "myresolver",
-				"traefik.http.middlewares.http-to-https.redirectscheme.scheme":         "https",
-				"traefik.http.routers.premapp-http.middlewares":                        "http-to-https",
-				"traefik.http.services.premapp.loadbalancer.server.port":               "8080",
-			}
-
-			if err := restartContainer(ctx, cli, v, labels, nil); err != nil {
-				return fmt.Errorf("failed to restart container %s: %v", v, err)
-			}
-		case premdService:
-			labels := map[string]string{
-				"traefik.enable": "true",
-				fmt.Sprintf("traefik.http.routers.%s.rule", v):             fmt.Sprintf("Host(`%s.%s`)", v, domain),
-				fmt.Sprintf("traefik.http.routers.%s.entrypoints", v):      "websecure",
-				fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", v): "myresolver",
-			}
-
-			if err := restartContainer(ctx, cli, v, labels, nil); err != nil {
-				return fmt.Errorf("failed to restart container %s: %v", v, err)
-			}
-		}
-
-		log.Infof("Restarted container %s\n", v)
-	}
-
-	for k, v := range premServices {
-		labels := map[string]string{
-			"traefik.enable": "true",
-			fmt.Sprintf("traefik.http.routers.%s-http.rule", k):                    fmt.Sprintf("Host(`%s.%s`)", k, domain),
-			fmt.Sprintf("traefik.http.routers.%s-http.entrypoints", k):             "web",
-			fmt.Sprintf("traefik.http.routers.%s-https.rule", k):                   fmt.Sprintf("Host(`%s.%s`)", k, domain),
-			fmt.Sprintf("traefik.http.routers.%s-https.entrypoints", k):            "websecure",
-			fmt.Sprintf("traefik.http.routers.%s-%s.tls.certresolver", k, "https"): "myresolver",
-			"traefik.http.middlewares.http-to-https.redirectscheme.scheme":         "https",
-			fmt.Sprintf("traefik.http.routers.%s-http.middlewares", k):             "http-to-https",
-			fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", k):    strconv.Itoa(v),
-		}
-
-		if err := restartContainer(ctx, cli, k, labels, nil); err != nil {
-			return fmt.Errorf("failed to restart container %s: %v", k, err)
-		}
-
-		log.Infof("Restarted container %s\n", k)
-	}
-
-	return nil
-}
-
-func restartTraefikWithTls(email string) error {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return fmt.Errorf("failed to create docker client: %v", err)
-	}
-
-	traefikLetsEncryptUrl := letsEncryptProd
-	if !letEncryptProd {
-		traefikLetsEncryptUrl = letsEncryptStaging
-	}
-
-	cmds := strslice.StrSlice{
-		"--providers.docker=true",
-		"--providers.docker.exposedbydefault=false",
-		"--accesslog=true",
-		"--ping",
-		"--entrypoints.web.address=:80",
-		"--certificatesresolvers.myresolver.acme.email=" + email,
-		"--certificatesresolvers.myresolver.acme.storage=/letsencrypt/acme.json",
-		"--certificatesresolvers.myresolver.acme.tlschallenge=true",
-		"--certificatesresolvers.myresolver.acme.caserver=" + traefikLetsEncryptUrl,
-		"--entrypoints.websecure.address=:443",
-	}
-
-	if err := restartContainer(ctx, cli, "traefik", nil, cmds); err != nil {
-		return fmt.Errorf("failed to restart container traefik: %v", err)
-	}
-
-	log.Info("Restarted container traefik")
-
-	return nil
-}
-
 type PremService struct {
 	Id            string `json:"id"`
 	Name          string `json:"name"`
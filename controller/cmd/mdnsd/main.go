@@ -0,0 +1,262 @@
+// mdnsd advertises gateway and its services on LAN with mDNS and DNS-SD,
+// services are polled from controllerd. It must run on host network to
+// receive multicast queries
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"prem-gateway/controllerd/internal/mdns"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultControllerdUrl = "http://127.0.0.1:8083"
+	defaultPollInterval   = time.Second * 10
+	requestTimeout        = time.Second * 10
+)
+
+// nodeRegexp is single DNS label node is advertised with
+var nodeRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// skippedInterfaces are prefixes of container network interfaces, their
+// addresses are not reachable from LAN
+var skippedInterfaces = []string{"docker", "br-", "veth", "cni", "flannel", "cali"}
+
+// servicesResponse is body of controllerd /v1/services
+type servicesResponse struct {
+	Domain   string `json:"domain"`
+	Services []struct {
+		Name  string `json:"name"`
+		Label string `json:"label"`
+		Path  string `json:"path"`
+	} `json:"services"`
+}
+
+func main() {
+	controllerdUrl := strings.TrimSuffix(os.Getenv("CONTROLLERD_URL"), "/")
+	if controllerdUrl == "" {
+		controllerdUrl = defaultControllerdUrl
+	}
+
+	pollInterval := defaultPollInterval
+	if v := os.Getenv("MDNS_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid MDNS_POLL_INTERVAL %q", v)
+		}
+		pollInterval = d
+	}
+
+	var iface *net.Interface
+	if v := os.Getenv("MDNS_INTERFACE"); v != "" {
+		i, err := net.InterfaceByName(v)
+		if err != nil {
+			log.Fatalf("Invalid MDNS_INTERFACE %q: %v", v, err)
+		}
+		iface = i
+	}
+
+	ips, err := ipsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	node, err := nodeFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// services are served with local CA certificate only in local CA mode,
+	// on plain http otherwise
+	https := os.Getenv("LOCAL_CA_DOMAIN") != ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	responder := mdns.NewResponder()
+	go poll(ctx, responder, controllerdUrl, pollInterval, iface, ips, node, https)
+
+	log.Infof("Starting mDNS responder for %v.local", node)
+	if err := responder.Serve(ctx, iface); err != nil {
+		log.Fatalf("mDNS responder failed: %v", err)
+	}
+	log.Info("mDNS responder stopped")
+}
+
+// poll updates zone of responder with services of controllerd until ctx is
+// done, zone is kept if controllerd is not reachable
+func poll(
+	ctx context.Context,
+	responder *mdns.Responder,
+	controllerdUrl string,
+	interval time.Duration,
+	iface *net.Interface,
+	ips []net.IP,
+	node string,
+	https bool,
+) {
+	for ; ; sleepCtx(ctx, interval) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		services, err := getServices(ctx, controllerdUrl)
+		if err != nil {
+			log.Warn("Error getting services from controllerd: ", err)
+			continue
+		}
+
+		zoneIps := ips
+		if len(zoneIps) == 0 {
+			if zoneIps, err = interfaceIps(iface); err != nil {
+				log.Warn("Error listing interface addresses: ", err)
+				continue
+			}
+		}
+
+		announce, goodbye := responder.Update(newZone(node, https, services, zoneIps))
+		for _, v := range announce {
+			log.Debug("Announced ", v.String())
+		}
+		for _, v := range goodbye {
+			log.Debug("Removed ", v.String())
+		}
+		if len(announce) > 0 || len(goodbye) > 0 {
+			log.Infof("Updated mDNS records, %v announced, %v removed", len(announce), len(goodbye))
+		}
+	}
+}
+
+// newZone advertises node and services of controllerd on it, independent
+// of domain services are provisioned on
+func newZone(node string, https bool, services servicesResponse, ips []net.IP) mdns.Zone {
+	nodeServices := make([]mdns.NodeService, 0, len(services.Services))
+	for _, v := range services.Services {
+		nodeServices = append(nodeServices, mdns.NodeService{
+			Name:  v.Name,
+			Label: v.Label,
+			Path:  v.Path,
+		})
+	}
+
+	return mdns.NodeZone(node, https, nodeServices, ips)
+}
+
+func getServices(ctx context.Context, controllerdUrl string) (servicesResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var services servicesResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, controllerdUrl+"/v1/services", nil)
+	if err != nil {
+		return services, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return services, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return services, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&services)
+
+	return services, err
+}
+
+// nodeFromEnv returns MDNS_NODE_NAME, first label of host name if not set
+func nodeFromEnv() (string, error) {
+	node := strings.ToLower(os.Getenv("MDNS_NODE_NAME"))
+	if node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", fmt.Errorf("error getting host name, set MDNS_NODE_NAME: %w", err)
+		}
+		node = strings.ToLower(strings.Split(hostname, ".")[0])
+	}
+	if !nodeRegexp.MatchString(node) {
+		return "", fmt.Errorf("invalid node name %q, set MDNS_NODE_NAME", node)
+	}
+
+	return node, nil
+}
+
+// ipsFromEnv returns addresses of MDNS_IPS, nil if not set
+func ipsFromEnv() ([]net.IP, error) {
+	var ips []net.IP
+	for _, v := range strings.Split(os.Getenv("MDNS_IPS"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %q in MDNS_IPS", v)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
+
+// interfaceIps returns private addresses of iface, or of every interface
+// facing LAN if iface is nil
+func interfaceIps(iface *net.Interface) ([]net.IP, error) {
+	ifaces := []net.Interface{}
+	if iface != nil {
+		ifaces = append(ifaces, *iface)
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range all {
+			if v.Flags&net.FlagUp != 0 && v.Flags&net.FlagLoopback == 0 &&
+				v.Flags&net.FlagMulticast != 0 && !skipped(v.Name) {
+				ifaces = append(ifaces, v)
+			}
+		}
+	}
+
+	ips := make([]net.IP, 0)
+	for _, v := range ifaces {
+		addrs, err := v.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.IsPrivate() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+
+	return ips, nil
+}
+
+func skipped(name string) bool {
+	for _, v := range skippedInterfaces {
+		if strings.HasPrefix(name, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sleepCtx waits for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
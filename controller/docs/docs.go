@@ -0,0 +1,10 @@
+// Package docs holds OpenAPI specification of controllerd api, swagger.json
+// and swagger.yaml are generated by swag, see controller/README.md
+package docs
+
+import _ "embed"
+
+// SwaggerJson is served by controllerd at /v1/openapi.json
+//
+//go:embed swagger.json
+var SwaggerJson []byte
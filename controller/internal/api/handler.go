@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"prem-gateway/controllerd/docs"
+	"prem-gateway/controllerd/internal/localca"
+	"prem-gateway/controllerd/internal/provisioner"
+	"prem-gateway/controllerd/internal/queue"
+	"strings"
+)
+
+// domainProvisioned godoc
+// @Summary Restart services with TLS for provisioned domain
+// @Description Called by dnsd once domain is provisioned. Services, prem-services and traefik are restarted with TLS and routes for the domain in background job, result is recorded in dnsd audit log. <br /> Request must be signed with secret shared with dnsd, see X-Prem-Timestamp, X-Prem-Nonce and X-Prem-Signature headers.
+// @Tags domain
+// @Accept json
+// @Produce json
+// @Param request body DomainProvisionedRequest true "Provisioned domain"
+// @Success 202 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 405 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Shutting down or instance is not leader"
+// @Router /v1/domain-provisioned [post]
+func (s *Server) domainProvisioned(w http.ResponseWriter, r *http.Request) {
+	var req DomainProvisionedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(
+			w, http.StatusBadRequest, codeInvalidArgument, "invalid request body", nil,
+		)
+		return
+	}
+
+	if fields := validateDomainProvisioned(req, s.provisioner); len(fields) > 0 {
+		log.Errorf("Invalid domain-provisioned request: %+v", req)
+		writeError(
+			w, http.StatusBadRequest, codeInvalidArgument, "invalid request", fields,
+		)
+		return
+	}
+
+	// dnsd retries notification so it reaches leader eventually
+	if status := s.elector.Status(); !status.IsLeader {
+		writeError(
+			w, http.StatusServiceUnavailable, codeNotLeader,
+			fmt.Sprintf("%v, leader is %q", queue.ErrNotLeader, status.Leader), nil,
+		)
+		return
+	}
+
+	domain, email, acmeCa := req.Domain, req.Email, req.AcmeCa
+	// restart outlives the request, result is only logged and recorded in
+	// audit log
+	_, coalesced, err := s.queue.Submit(
+		"domain-provisioned "+domain,
+		ProvisionTarget(domain, email, acmeCa),
+		func(ctx context.Context) error {
+			return s.provision(ctx, domain, email, acmeCa)
+		},
+	)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := codeInternal
+		if errors.Is(err, queue.ErrClosed) {
+			status = http.StatusServiceUnavailable
+			code = codeUnavailable
+		}
+		writeError(w, status, code, err.Error(), nil)
+		return
+	}
+
+	status := statusAccepted
+	if coalesced {
+		status = statusCoalesced
+	}
+	writeJson(w, http.StatusAccepted, JobResponse{
+		Status:   status,
+		Domain:   domain,
+		Services: s.opts.services,
+	})
+}
+
+func validateDomainProvisioned(
+	req DomainProvisionedRequest, p *provisioner.Provisioner,
+) []FieldError {
+	fields := make([]FieldError, 0)
+	switch {
+	case req.Domain == "":
+		fields = append(fields, FieldError{
+			Field: "domain", Code: fieldCodeRequired, Message: "domain is required",
+		})
+	case !domainRegexp.MatchString(req.Domain):
+		fields = append(fields, FieldError{
+			Field: "domain", Code: fieldCodeInvalid, Message: "invalid domain",
+		})
+	}
+	// email is optional, traefik is then restarted without acme email
+	if req.Email != "" && !emailRegexp.MatchString(req.Email) {
+		fields = append(fields, FieldError{
+			Field: "email", Code: fieldCodeInvalid, Message: "invalid email",
+		})
+	}
+	// acme ca is optional, CA selected by LETSENCRYPT_PROD is then used
+	if _, err := p.CaServer(req.AcmeCa); err != nil {
+		fields = append(fields, FieldError{
+			Field: "acmeCa", Code: fieldCodeInvalid, Message: err.Error(),
+		})
+	}
+
+	return fields
+}
+
+// queueStatus godoc
+// @Summary Restart queue
+// @Description Restarts run one by one. Restart to the same domain, email and ACME CA as queued or running one is coalesced with it, restart to other domain, email or ACME CA supersedes queued ones and cancels running one once its current container is restarted.
+// @Tags health
+// @Produce json
+// @Success 200 {object} QueueResponse
+// @Router /v1/queue [get]
+func (s *Server) queueStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.queue.Status()
+	resp := QueueResponse{
+		Depth:   status.Depth,
+		Pending: make([]OperationResponse, 0, len(status.Pending)),
+	}
+	if status.Current != nil {
+		current := newOperationResponse(*status.Current)
+		resp.Current = &current
+	}
+	for _, v := range status.Pending {
+		resp.Pending = append(resp.Pending, newOperationResponse(v))
+	}
+
+	writeJson(w, http.StatusOK, resp)
+}
+
+func newOperationResponse(op queue.Operation) OperationResponse {
+	resp := OperationResponse{
+		Name:        op.Name,
+		SubmittedAt: op.SubmittedAt,
+	}
+	if !op.StartedAt.IsZero() {
+		startedAt := op.StartedAt
+		resp.StartedAt = &startedAt
+	}
+
+	return resp
+}
+
+// leader godoc
+// @Summary Leader of controllerd instances
+// @Description Only leader performs restarts, other instances answer 503 to restart requests.
+// @Tags health
+// @Produce json
+// @Success 200 {object} LeaderResponse
+// @Router /v1/leader [get]
+func (s *Server) leader(w http.ResponseWriter, r *http.Request) {
+	status := s.elector.Status()
+	writeJson(w, http.StatusOK, LeaderResponse{
+		Identity: status.Identity,
+		Leader:   status.Leader,
+		IsLeader: status.IsLeader,
+	})
+}
+
+// gatewayServices godoc
+// @Summary Services served on provisioned domain
+// @Description Services with routes and running prem-services, with hosts on domain last provisioned by this instance. Services are listed also before domain is provisioned, without host and url. Used by mdnsd to advertise services over mDNS.
+// @Tags domain
+// @Produce json
+// @Success 200 {object} ServicesResponse
+// @Router /v1/services [get]
+func (s *Server) gatewayServices(w http.ResponseWriter, r *http.Request) {
+	resp := ServicesResponse{
+		Domain:   s.provisioner.Domain(),
+		Services: make([]ServiceResponse, 0),
+	}
+
+	routes := provisioner.ServiceRoutes(resp.Domain, s.opts.services)
+	routes = append(routes, provisioner.PremServiceRoutes(
+		resp.Domain, s.opts.premServices(),
+	)...)
+	for _, v := range routes {
+		path := v.PathPrefix
+		if path == "" {
+			path = "/"
+		}
+		service := ServiceResponse{
+			Name:  v.Service,
+			Label: strings.TrimSuffix(strings.TrimSuffix(v.Host, resp.Domain), "."),
+			Path:  path,
+		}
+		if resp.Domain != "" {
+			service.Host = v.Host
+			service.Url = "https://" + v.Host + path
+		}
+		resp.Services = append(resp.Services, service)
+	}
+
+	writeJson(w, http.StatusOK, resp)
+}
+
+// health godoc
+// @Summary Health check
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Router /v1/health [get]
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, HealthResponse{Status: statusOk})
+}
+
+// openApi godoc
+// @Summary OpenAPI specification of controllerd api
+// @Tags docs
+// @Produce json
+// @Success 200 {object} object
+// @Router /v1/openapi.json [get]
+func (s *Server) openApi(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(docs.SwaggerJson); err != nil {
+		log.Error("Error writing response: ", err)
+	}
+}
+
+// localCaRoot godoc
+// @Summary Root certificate of local CA
+// @Description LAN clients import it to trust certificates of private domain served in local CA mode. Root is name constrained to the private domain and private addresses.
+// @Tags local-ca
+// @Produce application/x-pem-file
+// @Success 200 {string} string "PEM of root certificate"
+// @Failure 404 {object} ErrorResponse "Local CA mode is disabled or root was not created yet"
+// @Router /v1/local-ca/ca.pem [get]
+func (s *Server) localCaRoot(w http.ResponseWriter, r *http.Request) {
+	if s.opts.localCaDir == "" {
+		writeError(w, http.StatusNotFound, codeNotFound, "local ca mode is disabled", nil)
+		return
+	}
+
+	root, err := localca.RootPem(s.opts.localCaDir)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, codeNotFound, "local ca not created yet", nil)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="prem-gateway-ca.pem"`)
+	if _, err := w.Write(root); err != nil {
+		log.Error("Error writing response: ", err)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("Error writing response: ", err)
+	}
+}
+
+func writeError(
+	w http.ResponseWriter, status int, code, msg string, fields []FieldError,
+) {
+	writeJson(w, status, ErrorResponse{
+		Code:   code,
+		Error:  msg,
+		Fields: fields,
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"prem-gateway/pkg/cors"
+)
+
+type Option interface {
+	apply(*options) error
+}
+
+type options struct {
+	// services are restarted with provisioned domain, dnsd included
+	services []string
+	// corsPolicy answers preflight requests, requests are served without
+	// CORS headers if nil
+	corsPolicy *cors.Cors
+	// localCaDir keeps root of local CA served at /v1/local-ca/ca.pem, local
+	// CA mode is disabled if empty
+	localCaDir string
+	// premServices returns ports of running prem-services by id
+	premServices func() map[string]int
+}
+
+func defaultOptions() options {
+	return options{
+		services: make([]string, 0),
+		premServices: func() map[string]int {
+			return nil
+		},
+	}
+}
+
+type funcOption struct {
+	f func(*options) error
+}
+
+func (fo *funcOption) apply(o *options) error {
+	return fo.f(o)
+}
+
+func newFuncOption(f func(*options) error) *funcOption {
+	return &funcOption{
+		f: f,
+	}
+}
+
+// WithServices sets services restarted with provisioned domain, those with
+// routes are listed at /v1/services
+func WithServices(services []string) Option {
+	return newFuncOption(func(o *options) error {
+		o.services = append([]string{}, services...)
+		return nil
+	})
+}
+
+// WithCors answers preflight requests and sets CORS headers of allowed
+// origins
+func WithCors(corsPolicy *cors.Cors) Option {
+	return newFuncOption(func(o *options) error {
+		o.corsPolicy = corsPolicy
+		return nil
+	})
+}
+
+// WithLocalCaDir serves root of local CA kept in dir, used in local CA mode
+func WithLocalCaDir(dir string) Option {
+	return newFuncOption(func(o *options) error {
+		o.localCaDir = dir
+		return nil
+	})
+}
+
+// WithPremServices sets source of running prem-services, their routes are
+// listed at /v1/services
+func WithPremServices(premServices func() map[string]int) Option {
+	return newFuncOption(func(o *options) error {
+		if premServices == nil {
+			return fmt.Errorf("prem services source not set")
+		}
+
+		o.premServices = premServices
+		return nil
+	})
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/provisioner"
+	"prem-gateway/controllerd/internal/queue"
+	"prem-gateway/pkg/signing"
+	"sync"
+	"time"
+)
+
+const (
+	readHeaderTimeout = time.Second * 5
+	readTimeout       = time.Second * 10
+	writeTimeout      = time.Second * 30
+	idleTimeout       = time.Minute
+	// shutdownTimeout bounds wait for in-flight restart jobs on shutdown,
+	// docker stop_grace_period of controllerd must be longer
+	shutdownTimeout = time.Minute * 2
+)
+
+// ErrShuttingDown is returned by RunBackground once shutdown begins
+var ErrShuttingDown = errors.New("controllerd is shutting down")
+
+// ProvisionFunc restarts prem-services, services and traefik with TLS for
+// provisioned domain, it runs in restart queue and outlives the request
+type ProvisionFunc func(ctx context.Context, domain, email, acmeCa string) error
+
+// Server is controllerd http api, restarts it starts run one by one in
+// restart queue, shutdown waits for them
+type Server struct {
+	httpServer  *http.Server
+	provisioner *provisioner.Provisioner
+	elector     leader.Elector
+	queue       *queue.Queue
+	verifier    *signing.Verifier
+	provision   ProvisionFunc
+	opts        options
+
+	mu           sync.Mutex
+	shuttingDown bool
+	// background are goroutines shutdown waits for before queue is closed,
+	// they may submit jobs
+	background sync.WaitGroup
+}
+
+// NewServer returns api served on addr, domain-provisioned requests must be
+// signed with secret of verifier and restart gateway with provision. Restart
+// queue is started, it is closed by Shutdown
+func NewServer(
+	addr string,
+	p *provisioner.Provisioner,
+	elector leader.Elector,
+	verifier *signing.Verifier,
+	provision ProvisionFunc,
+	opts ...Option,
+) (*Server, error) {
+	if p == nil || elector == nil || verifier == nil || provision == nil {
+		return nil, fmt.Errorf("provisioner, elector, verifier and provision must be set")
+	}
+
+	options := defaultOptions()
+	for _, o := range opts {
+		if err := o.apply(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		provisioner: p,
+		elector:     elector,
+		queue:       queue.New(elector),
+		verifier:    verifier,
+		provision:   provision,
+		opts:        options,
+	}
+	handler := s.routes()
+	if options.corsPolicy != nil {
+		handler = options.corsPolicy.Handler(handler)
+	}
+	s.queue.Start()
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	return s, nil
+}
+
+// Handler serves api routes
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// Queue is restart queue every restart of gateway runs in
+func (s *Server) Queue() *queue.Queue {
+	return s.queue
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/domain-provisioned", allowMethods(
+		s.signed(http.HandlerFunc(s.domainProvisioned)), http.MethodPost,
+	))
+	mux.Handle("/v1/queue", allowMethods(
+		http.HandlerFunc(s.queueStatus), http.MethodGet,
+	))
+	mux.Handle("/v1/leader", allowMethods(
+		http.HandlerFunc(s.leader), http.MethodGet,
+	))
+	mux.Handle("/v1/services", allowMethods(
+		http.HandlerFunc(s.gatewayServices), http.MethodGet,
+	))
+	mux.Handle("/v1/local-ca/ca.pem", allowMethods(
+		http.HandlerFunc(s.localCaRoot), http.MethodGet,
+	))
+	mux.Handle("/v1/health", allowMethods(
+		http.HandlerFunc(s.health), http.MethodGet,
+	))
+	mux.Handle("/v1/openapi.json", allowMethods(
+		http.HandlerFunc(s.openApi), http.MethodGet,
+	))
+	// deprecated, path used by dnsd before api was versioned
+	mux.Handle("/domain-provisioned", allowMethods(
+		s.signed(http.HandlerFunc(s.domainProvisioned)), http.MethodPost,
+	))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codeNotFound, "route not found", nil)
+	})
+
+	return mux
+}
+
+// Start serves api until ctx is done, then it stops accepting requests and
+// waits up to shutdownTimeout for running jobs
+func (s *Server) Start(ctx context.Context) error {
+	errC := make(chan error, 1)
+	go func() {
+		log.Infof("Starting controller daemon on %v", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil &&
+			!errors.Is(err, http.ErrServerClosed) {
+			errC <- err
+		}
+		close(errC)
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("Shutting down controller daemon")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return s.Shutdown(shutdownCtx)
+}
+
+// Shutdown stops accepting requests, waits for background tasks and then
+// for restart jobs, until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	s.mu.Unlock()
+
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.New("timed out waiting for background tasks")
+	}
+
+	if err := s.queue.Close(ctx); err != nil {
+		return err
+	}
+	log.Info("All restart jobs finished")
+
+	return nil
+}
+
+// RunBackground runs f in background, f is not started once shutdown
+// begins, shutdown waits for it to return
+func (s *Server) RunBackground(f func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return ErrShuttingDown
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		f()
+	}()
+
+	return nil
+}
+
+// signed rejects requests not signed by dnsd with shared secret
+func (s *Server) signed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.verifier.Verify(r); err != nil {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowMethods answers 405 with Allow header for other methods
+func allowMethods(next http.Handler, methods ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		for _, m := range methods {
+			w.Header().Add("Allow", m)
+		}
+		writeError(
+			w, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+			"method not allowed", nil,
+		)
+	})
+}
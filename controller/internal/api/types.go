@@ -0,0 +1,105 @@
+package api
+
+import "time"
+
+const (
+	codeInvalidArgument  = "invalid_argument"
+	codeUnauthorized     = "unauthorized"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeUnavailable      = "unavailable"
+	codeNotLeader        = "not_leader"
+	codeInternal         = "internal"
+
+	fieldCodeRequired = "required"
+	fieldCodeInvalid  = "invalid"
+
+	statusAccepted = "accepted"
+	// statusCoalesced is returned if restart to the same state is already
+	// queued or running
+	statusCoalesced = "coalesced"
+	statusOk        = "ok"
+)
+
+// DomainProvisionedRequest is body of /v1/domain-provisioned sent by dnsd
+type DomainProvisionedRequest struct {
+	Domain string `json:"domain" example:"gateway.example.com"`
+	Email  string `json:"email" example:"admin@example.com"`
+	// AcmeCa is staging, production or https url of ACME directory, CA
+	// selected by LETSENCRYPT_PROD is used if empty. Certificates of
+	// previous CA are dropped once it changes
+	AcmeCa string `json:"acmeCa,omitempty" example:"production"`
+}
+
+// JobResponse is returned for request which started restart job, job
+// result is recorded in dnsd audit log
+type JobResponse struct {
+	// Status is accepted, or coalesced if the same restart is already queued
+	// or running
+	Status string `json:"status" example:"accepted" enums:"accepted,coalesced"`
+	Domain string `json:"domain" example:"gateway.example.com"`
+	// Services are restarted in order, traefik is restarted last
+	Services []string `json:"services"`
+}
+
+// QueueResponse is state of restart queue of instance which answered
+type QueueResponse struct {
+	// Depth is number of pending restarts, current one is not counted
+	Depth int `json:"depth"`
+	// Current is running restart, omitted if queue is idle
+	Current *OperationResponse  `json:"current,omitempty"`
+	Pending []OperationResponse `json:"pending"`
+}
+
+type OperationResponse struct {
+	Name        string    `json:"name" example:"domain-provisioned gateway.example.com"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	// StartedAt is omitted for pending restart
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+}
+
+type LeaderResponse struct {
+	// Identity of instance which answered
+	Identity string `json:"identity" example:"3f2a1b9c0d4e"`
+	// Leader is identity of leader, empty if unknown
+	Leader   string `json:"leader" example:"3f2a1b9c0d4e"`
+	IsLeader bool   `json:"isLeader"`
+}
+
+// ServicesResponse is gateway services and their hosts on provisioned
+// domain, mdnsd advertises them on LAN
+type ServicesResponse struct {
+	// Domain is last provisioned domain, empty until provisioning succeeds
+	Domain   string            `json:"domain" example:"prem.local"`
+	Services []ServiceResponse `json:"services"`
+}
+
+type ServiceResponse struct {
+	Name string `json:"name" example:"premd"`
+	// Label is subdomain service is routed by, empty for service served on
+	// domain itself
+	Label string `json:"label" example:"premd"`
+	Path  string `json:"path" example:"/"`
+	// Host is empty until domain is provisioned
+	Host string `json:"host" example:"premd.prem.local"`
+	// Url is https url service is served on, empty until domain is
+	// provisioned
+	Url string `json:"url" example:"https://premd.prem.local/"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// ErrorResponse is body of every failed request, same shape as dnsd errors
+type ErrorResponse struct {
+	Code   string       `json:"code"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
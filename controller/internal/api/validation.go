@@ -0,0 +1,33 @@
+package api
+
+import "regexp"
+
+var (
+	// domainRegexp matches domain normalized by dnsd, domain is put in
+	// Traefik rules so anything else could inject rule syntax
+	domainRegexp = regexp.MustCompile(
+		`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`,
+	)
+	// emailRegexp matches email normalized by dnsd, email is put in Traefik
+	// command line
+	emailRegexp = regexp.MustCompile(
+		`^[a-zA-Z0-9._%+\-]+@([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`,
+	)
+)
+
+// ValidDomain reports if domain is normalized the way dnsd does it, only
+// such domain is safe to put in Traefik rules
+func ValidDomain(domain string) bool {
+	return domainRegexp.MatchString(domain)
+}
+
+// ValidEmail reports if email is normalized the way dnsd does it
+func ValidEmail(email string) bool {
+	return emailRegexp.MatchString(email)
+}
+
+// ProvisionTarget is state restart to domain with email and ACME CA
+// converges gateway to, restarts to the same target are coalesced
+func ProvisionTarget(domain, email, acmeCa string) string {
+	return domain + " " + email + " " + acmeCa
+}
@@ -0,0 +1,41 @@
+// Package containerruntime is subset of Docker Engine api controllerd uses
+// to recreate containers, implemented by Docker client and by in-memory
+// Simulator used in tests and dry run
+package containerruntime
+
+import (
+	"context"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Runtime methods have signatures of Docker client methods, container is
+// referenced by name
+type Runtime interface {
+	ContainerInspect(ctx context.Context, name string) (types.ContainerJSON, error)
+	ContainerStop(ctx context.Context, name string, options container.StopOptions) error
+	ContainerRemove(ctx context.Context, name string, options types.ContainerRemoveOptions) error
+	ContainerCreate(
+		ctx context.Context,
+		config *container.Config,
+		hostConfig *container.HostConfig,
+		networkingConfig *network.NetworkingConfig,
+		platform *ocispec.Platform,
+		name string,
+	) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, name string, options types.ContainerStartOptions) error
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+var _ Runtime = (*client.Client)(nil)
+
+// NewDocker returns runtime of Docker daemon configured by DOCKER_HOST and
+// other docker env variables
+func NewDocker() (Runtime, error) {
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
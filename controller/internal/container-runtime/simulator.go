@@ -0,0 +1,518 @@
+package containerruntime
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ActionCreate   = "create"
+	ActionRecreate = "recreate"
+	ActionStop     = "stop"
+	ActionRemove   = "remove"
+	ActionStart    = "start"
+
+	LabelAdded   = "+"
+	LabelRemoved = "-"
+	LabelChanged = "~"
+
+	// eventsBuffer is size of events channel, events are dropped if
+	// subscriber does not keep up
+	eventsBuffer = 64
+)
+
+// Simulator is in-memory Runtime, it records label and command changes of
+// created containers so effect of provisioning can be shown without
+// touching real containers
+type Simulator struct {
+	mu          sync.Mutex
+	containers  map[string]*types.ContainerJSON
+	removed     map[string]*types.ContainerJSON
+	changes     []Change
+	failures    map[string]error
+	subscribers map[chan events.Message]struct{}
+	nextId      int
+}
+
+// NewSimulator returns simulator with given running containers, see
+// NewContainer
+func NewSimulator(containers ...types.ContainerJSON) *Simulator {
+	s := &Simulator{
+		containers:  make(map[string]*types.ContainerJSON),
+		removed:     make(map[string]*types.ContainerJSON),
+		changes:     make([]Change, 0),
+		failures:    make(map[string]error),
+		subscribers: make(map[chan events.Message]struct{}),
+	}
+	for _, v := range containers {
+		c := copyContainer(v)
+		s.containers[containerName(c)] = &c
+	}
+
+	return s
+}
+
+// Snapshot returns simulator with copy of all containers of rt, it only
+// reads from rt
+func Snapshot(ctx context.Context, rt Runtime) (*Simulator, error) {
+	list, err := rt.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := make([]types.ContainerJSON, 0, len(list))
+	for _, v := range list {
+		c, err := rt.ContainerInspect(ctx, v.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %v: %w", v.ID, err)
+		}
+		containers = append(containers, c)
+	}
+
+	return NewSimulator(containers...), nil
+}
+
+// NewContainer returns running container with labels and cmd
+func NewContainer(
+	name, image string, labels map[string]string, cmd []string,
+) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         name,
+			Name:       "/" + name,
+			State:      &types.ContainerState{Status: "running", Running: true},
+			HostConfig: &container.HostConfig{},
+		},
+		Config: &container.Config{
+			Image:  image,
+			Labels: labels,
+			Cmd:    cmd,
+		},
+		NetworkSettings: &types.NetworkSettings{},
+	}
+}
+
+// Fail makes every following action on container fail with err, nil err
+// clears failure
+func (s *Simulator) Fail(action, name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := action + "/" + name
+	if err == nil {
+		delete(s.failures, key)
+		return
+	}
+	s.failures[key] = err
+}
+
+// Changes returns created containers with their label and cmd changes in
+// order of creation
+func (s *Simulator) Changes() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Change{}, s.changes...)
+}
+
+func (s *Simulator) ContainerInspect(
+	_ context.Context, name string,
+) (types.ContainerJSON, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.get(name)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	return copyContainer(*c), nil
+}
+
+func (s *Simulator) ContainerStop(
+	_ context.Context, name string, _ container.StopOptions,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.failure(ActionStop, name); err != nil {
+		return err
+	}
+	c, err := s.get(name)
+	if err != nil {
+		return err
+	}
+
+	c.State.Running = false
+	c.State.Status = "exited"
+	s.publish(ActionStop, c)
+
+	return nil
+}
+
+func (s *Simulator) ContainerRemove(
+	_ context.Context, name string, options types.ContainerRemoveOptions,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.failure(ActionRemove, name); err != nil {
+		return err
+	}
+	c, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	if c.State.Running && !options.Force {
+		return errdefs.Conflict(fmt.Errorf(
+			"container %v is running, stop it before removal", name,
+		))
+	}
+
+	delete(s.containers, containerName(*c))
+	s.removed[containerName(*c)] = c
+	s.publish("destroy", c)
+
+	return nil
+}
+
+func (s *Simulator) ContainerCreate(
+	_ context.Context,
+	config *container.Config,
+	hostConfig *container.HostConfig,
+	networkingConfig *network.NetworkingConfig,
+	_ *ocispec.Platform,
+	name string,
+) (container.CreateResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.failure(ActionCreate, name); err != nil {
+		return container.CreateResponse{}, err
+	}
+	if _, ok := s.containers[name]; ok {
+		return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf(
+			"container name %v is already in use", name,
+		))
+	}
+
+	s.nextId++
+	c := NewContainer(name, "", nil, nil)
+	c.ID = "simulated-" + strconv.Itoa(s.nextId)
+	c.State = &types.ContainerState{Status: "created"}
+	if config != nil {
+		c.Config = config
+	}
+	if hostConfig != nil {
+		c.HostConfig = hostConfig
+	}
+	if networkingConfig != nil {
+		c.NetworkSettings.Networks = networkingConfig.EndpointsConfig
+	}
+	c = copyContainer(c)
+
+	s.changes = append(s.changes, diff(s.removed[name], &c))
+	delete(s.removed, name)
+	s.containers[name] = &c
+	s.publish(ActionCreate, &c)
+
+	return container.CreateResponse{ID: c.ID}, nil
+}
+
+func (s *Simulator) ContainerStart(
+	_ context.Context, name string, _ types.ContainerStartOptions,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.failure(ActionStart, name); err != nil {
+		return err
+	}
+	c, err := s.get(name)
+	if err != nil {
+		return err
+	}
+
+	c.State.Running = true
+	c.State.Status = "running"
+	s.publish(ActionStart, c)
+
+	return nil
+}
+
+// ContainerList returns containers sorted by name, filters are ignored
+func (s *Simulator) ContainerList(
+	_ context.Context, options types.ContainerListOptions,
+) ([]types.Container, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]types.Container, 0, len(s.containers))
+	for name, c := range s.containers {
+		if !options.All && !c.State.Running {
+			continue
+		}
+		result = append(result, types.Container{
+			ID:     c.ID,
+			Names:  []string{"/" + name},
+			Image:  c.Config.Image,
+			Labels: copyLabels(c.Config.Labels),
+			State:  c.State.Status,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Names[0] < result[j].Names[0]
+	})
+
+	return result, nil
+}
+
+// Events streams container events until ctx is done, filters are ignored
+func (s *Simulator) Events(
+	ctx context.Context, _ types.EventsOptions,
+) (<-chan events.Message, <-chan error) {
+	msgs := make(chan events.Message, eventsBuffer)
+	errs := make(chan error, 1)
+
+	s.mu.Lock()
+	s.subscribers[msgs] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, msgs)
+		s.mu.Unlock()
+		errs <- ctx.Err()
+	}()
+
+	return msgs, errs
+}
+
+func (s *Simulator) get(name string) (*types.ContainerJSON, error) {
+	name = strings.TrimPrefix(name, "/")
+	if c, ok := s.containers[name]; ok {
+		return c, nil
+	}
+	for _, c := range s.containers {
+		if c.ID == name {
+			return c, nil
+		}
+	}
+
+	return nil, errdefs.NotFound(fmt.Errorf("no such container: %v", name))
+}
+
+func (s *Simulator) failure(action, name string) error {
+	return s.failures[action+"/"+name]
+}
+
+func (s *Simulator) publish(action string, c *types.ContainerJSON) {
+	now := time.Now()
+	msg := events.Message{
+		Type:   events.ContainerEventType,
+		Action: action,
+		Actor: events.Actor{
+			ID:         c.ID,
+			Attributes: map[string]string{"name": containerName(*c)},
+		},
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+	for sub := range s.subscribers {
+		select {
+		case sub <- msg:
+		default:
+		}
+	}
+}
+
+// Change is label, cmd and env change of created container compared to
+// removed container of the same name
+type Change struct {
+	Container string
+	// Action is ActionRecreate if container of the same name was removed
+	// before, ActionCreate otherwise
+	Action  string
+	Labels  []LabelChange
+	PrevCmd []string
+	Cmd     []string
+	PrevEnv []string
+	Env     []string
+}
+
+type LabelChange struct {
+	// Op is LabelAdded, LabelRemoved or LabelChanged
+	Op    string
+	Key   string
+	Old   string
+	Value string
+}
+
+func (c Change) String() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("%v %v\n", c.Action, c.Container))
+	for _, v := range c.Labels {
+		switch v.Op {
+		case LabelAdded:
+			b.WriteString(fmt.Sprintf("  + label %v=%v\n", v.Key, v.Value))
+		case LabelRemoved:
+			b.WriteString(fmt.Sprintf("  - label %v=%v\n", v.Key, v.Old))
+		case LabelChanged:
+			b.WriteString(fmt.Sprintf("  ~ label %v=%v (was %v)\n", v.Key, v.Value, v.Old))
+		}
+	}
+	for _, v := range missing(c.Cmd, c.PrevCmd) {
+		b.WriteString(fmt.Sprintf("  + cmd %v\n", v))
+	}
+	for _, v := range missing(c.PrevCmd, c.Cmd) {
+		b.WriteString(fmt.Sprintf("  - cmd %v\n", v))
+	}
+	for _, v := range missing(c.Env, c.PrevEnv) {
+		b.WriteString(fmt.Sprintf("  + env %v\n", v))
+	}
+	for _, v := range missing(c.PrevEnv, c.Env) {
+		b.WriteString(fmt.Sprintf("  - env %v\n", v))
+	}
+
+	return b.String()
+}
+
+// missing returns values of a which are not in b, in order of a
+func missing(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+
+	result := make([]string, 0)
+	for _, v := range a {
+		if !in[v] {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+func (c Change) CmdChanged() bool {
+	return len(c.PrevCmd) != len(c.Cmd) ||
+		commonPrefix(c.PrevCmd, c.Cmd) != len(c.Cmd)
+}
+
+func diff(prev, created *types.ContainerJSON) Change {
+	change := Change{
+		Container: containerName(*created),
+		Action:    ActionCreate,
+		Labels:    make([]LabelChange, 0),
+		Cmd:       append([]string{}, created.Config.Cmd...),
+		Env:       append([]string{}, created.Config.Env...),
+	}
+
+	prevLabels := make(map[string]string)
+	if prev != nil {
+		change.Action = ActionRecreate
+		change.PrevCmd = append([]string{}, prev.Config.Cmd...)
+		change.PrevEnv = append([]string{}, prev.Config.Env...)
+		prevLabels = prev.Config.Labels
+	}
+
+	for k, v := range created.Config.Labels {
+		old, ok := prevLabels[k]
+		switch {
+		case !ok:
+			change.Labels = append(change.Labels, LabelChange{
+				Op: LabelAdded, Key: k, Value: v,
+			})
+		case old != v:
+			change.Labels = append(change.Labels, LabelChange{
+				Op: LabelChanged, Key: k, Old: old, Value: v,
+			})
+		}
+	}
+	for k, v := range prevLabels {
+		if _, ok := created.Config.Labels[k]; !ok {
+			change.Labels = append(change.Labels, LabelChange{
+				Op: LabelRemoved, Key: k, Old: v,
+			})
+		}
+	}
+	sort.Slice(change.Labels, func(i, j int) bool {
+		return change.Labels[i].Key < change.Labels[j].Key
+	})
+
+	return change
+}
+
+func commonPrefix(a, b []string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
+
+func containerName(c types.ContainerJSON) string {
+	return strings.TrimPrefix(c.Name, "/")
+}
+
+// copyContainer copies parts of container callers may modify
+func copyContainer(c types.ContainerJSON) types.ContainerJSON {
+	base := *c.ContainerJSONBase
+	if base.State != nil {
+		state := *base.State
+		base.State = &state
+	} else {
+		base.State = &types.ContainerState{}
+	}
+	if base.HostConfig != nil {
+		hostConfig := *base.HostConfig
+		base.HostConfig = &hostConfig
+	}
+	c.ContainerJSONBase = &base
+
+	if c.Config != nil {
+		config := *c.Config
+		config.Labels = copyLabels(config.Labels)
+		config.Cmd = append([]string{}, config.Cmd...)
+		config.Env = append([]string{}, config.Env...)
+		c.Config = &config
+	} else {
+		c.Config = &container.Config{}
+	}
+
+	if c.NetworkSettings != nil {
+		settings := *c.NetworkSettings
+		networks := make(map[string]*network.EndpointSettings)
+		for k, v := range settings.Networks {
+			networks[k] = v
+		}
+		settings.Networks = networks
+		c.NetworkSettings = &settings
+	} else {
+		c.NetworkSettings = &types.NetworkSettings{}
+	}
+
+	return c
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	return result
+}
@@ -0,0 +1,117 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const DefaultRetryInterval = time.Second * 2
+
+type fileElector struct {
+	*state
+	path          string
+	retryInterval time.Duration
+}
+
+// NewFileElector returns elector holding exclusive flock on file at path,
+// instances sharing the file through volume on the same Docker host elect
+// one leader. Identity of leader is written to the file, lock is released
+// by kernel if leader crashes
+func NewFileElector(path, identity string, retryInterval time.Duration) (Elector, error) {
+	if path == "" {
+		return nil, fmt.Errorf("lock file not set")
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("identity not set")
+	}
+	if retryInterval <= 0 {
+		retryInterval = DefaultRetryInterval
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock file dir: %w", err)
+	}
+
+	return &fileElector{
+		state:         newState(identity),
+		path:          path,
+		retryInterval: retryInterval,
+	}, nil
+}
+
+func (f *fileElector) Run(ctx context.Context) {
+	for {
+		locked, err := f.tryLock(ctx)
+		if err != nil {
+			log.Errorf("Failed to acquire leader lock %v: %v", f.path, err)
+		}
+		if locked || ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.retryInterval):
+		}
+	}
+}
+
+// tryLock holds lock until ctx is done if it is acquired, otherwise it
+// records holder of the lock and returns false
+func (f *fileElector) tryLock(ctx context.Context) (bool, error) {
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, err
+		}
+
+		holder, err := os.ReadFile(f.path)
+		if err != nil {
+			return false, err
+		}
+		f.set(strings.TrimSpace(string(holder)))
+
+		return false, nil
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return false, f.unlock(file, err)
+	}
+	if _, err := file.WriteAt([]byte(f.identity+"\n"), 0); err != nil {
+		return false, f.unlock(file, err)
+	}
+
+	log.Infof("Acquired leader lock %v as %v", f.path, f.identity)
+	f.set(f.identity)
+
+	<-ctx.Done()
+
+	f.set("")
+	if err := file.Truncate(0); err != nil {
+		log.Errorf("Failed to clear leader lock %v: %v", f.path, err)
+	}
+	log.Infof("Released leader lock %v", f.path)
+
+	return true, f.unlock(file, nil)
+}
+
+func (f *fileElector) unlock(file *os.File, err error) error {
+	if unlockErr := syscall.Flock(int(file.Fd()), syscall.LOCK_UN); unlockErr != nil && err == nil {
+		return unlockErr
+	}
+
+	return err
+}
@@ -0,0 +1,81 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"time"
+)
+
+const (
+	leaseDuration = time.Second * 15
+	renewDeadline = time.Second * 10
+	retryPeriod   = time.Second * 2
+)
+
+type kubernetesElector struct {
+	*state
+	config leaderelection.LeaderElectionConfig
+}
+
+// NewKubernetesElector returns elector holding coordination Lease name in
+// namespace
+func NewKubernetesElector(
+	clientset kubernetes.Interface, namespace, name, identity string,
+) (Elector, error) {
+	if clientset == nil {
+		return nil, fmt.Errorf("kubernetes client not set")
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("identity not set")
+	}
+
+	k := &kubernetesElector{state: newState(identity)}
+	k.config = leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Client:    clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				log.Infof("Acquired lease %v/%v as %v", namespace, name, identity)
+				k.set(identity)
+			},
+			OnStoppedLeading: func() {
+				k.set("")
+			},
+			OnNewLeader: func(leader string) {
+				k.set(leader)
+			},
+		},
+	}
+	if _, err := leaderelection.NewLeaderElector(k.config); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Run campaigns again if lease is lost, eg. renewal failed
+func (k *kubernetesElector) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		le, err := leaderelection.NewLeaderElector(k.config)
+		if err != nil {
+			log.Errorf("Failed to create leader elector: %v", err)
+			return
+		}
+		le.Run(ctx)
+	}
+}
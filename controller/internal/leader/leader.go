@@ -0,0 +1,127 @@
+// Package leader elects one of controllerd instances to perform restarts,
+// so instances running side by side, eg. during upgrade, do not recreate the
+// same containers concurrently
+package leader
+
+import (
+	"context"
+	"sync"
+)
+
+type Elector interface {
+	// Run campaigns for leadership until ctx is done, lease is released
+	// before it returns
+	Run(ctx context.Context)
+	Status() Status
+	// WaitLeader blocks until this instance is leader or ctx is done
+	WaitLeader(ctx context.Context) error
+	// Leading returns context canceled once this instance stops being
+	// leader, it is already canceled if instance is not leader
+	Leading() context.Context
+}
+
+type Status struct {
+	// Identity of this instance
+	Identity string
+	// Leader is identity of current leader, empty if unknown
+	Leader   string
+	IsLeader bool
+}
+
+// state is leadership observed by elector
+type state struct {
+	identity string
+
+	mu     sync.Mutex
+	leader string
+	// changed is closed and replaced when leader changes
+	changed chan struct{}
+	// leading is canceled when this instance stops being leader
+	leading     context.Context
+	stopLeading context.CancelFunc
+}
+
+func newState(identity string) *state {
+	leading, stopLeading := context.WithCancel(context.Background())
+	stopLeading()
+
+	return &state{
+		identity:    identity,
+		changed:     make(chan struct{}),
+		leading:     leading,
+		stopLeading: stopLeading,
+	}
+}
+
+func (s *state) set(leader string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leader == leader {
+		return
+	}
+	switch {
+	case leader == s.identity:
+		s.leading, s.stopLeading = context.WithCancel(context.Background())
+	case s.leader == s.identity:
+		s.stopLeading()
+	}
+	s.leader = leader
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *state) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Status{
+		Identity: s.identity,
+		Leader:   s.leader,
+		IsLeader: s.leader == s.identity,
+	}
+}
+
+func (s *state) Leading() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.leading
+}
+
+func (s *state) WaitLeader(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		isLeader := s.leader == s.identity
+		changed := s.changed
+		s.mu.Unlock()
+
+		if isLeader {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// single is Elector of instance running alone, it is always leader
+type single struct {
+	*state
+}
+
+// NewSingle returns elector which is leader without campaigning, used if
+// leader election is disabled
+func NewSingle(identity string) Elector {
+	s := &single{state: newState(identity)}
+	s.set(identity)
+
+	return s
+}
+
+func (s *single) Run(ctx context.Context) {
+	<-ctx.Done()
+}
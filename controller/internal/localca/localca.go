@@ -0,0 +1,269 @@
+// Package localca is root CA of gateway without public domain, it issues
+// certificates for private name and LAN addresses of the box
+package localca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// CertFile is PEM of root certificate in CA dir, offered for download
+	CertFile = "ca.pem"
+	keyFile  = "ca-key.pem"
+
+	rootValidity = time.Hour * 24 * 365 * 10
+	// leafValidity is below 398 days clients accept for leaf certificates
+	leafValidity = time.Hour * 24 * 397
+	// RenewBefore is how long before expiry certificate is issued again
+	RenewBefore = time.Hour * 24 * 30
+)
+
+// permittedIpRanges are addresses root can issue certificates for, root
+// can not be abused for public addresses
+var permittedIpRanges = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"127.0.0.0/8",
+	"fc00::/7",
+	"fe80::/10",
+	"::1/128",
+}
+
+type CA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Open loads root CA from dir or creates it if dir has none. Root is name
+// constrained to domain and its subdomains, so root of other domain is an
+// error
+func Open(dir, domain string) (*CA, error) {
+	ca, err := load(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return create(dir, domain)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if permitsDomain(ca.cert, domain) {
+		return ca, nil
+	}
+
+	return nil, fmt.Errorf(
+		"root CA in %v is constrained to %v, remove it to create root for %v",
+		dir, strings.Join(ca.cert.PermittedDNSDomains, ","), domain,
+	)
+}
+
+// RootPem reads PEM of root certificate from dir, os.ErrNotExist is returned
+// if root was not created yet
+func RootPem(dir string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(dir, CertFile))
+}
+
+// ValidateIp returns error if ip is not private, loopback or link-local
+// address root can issue certificate for
+func ValidateIp(ip net.IP) error {
+	for _, v := range permittedIpRanges {
+		_, ipNet, _ := net.ParseCIDR(v)
+		if ipNet.Contains(ip) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%v is not private address", ip)
+}
+
+// Issue returns PEM of certificate, followed by root, and of its key, for
+// domain, its subdomains and ips
+func (c *CA) Issue(domain string, ips []net.IP) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain, "*." + domain},
+		IPAddresses:  ips,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: c.cert.Raw,
+	})...)
+
+	return chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
+
+// NotAfter returns expiry of first certificate in PEM chain issued by Issue
+func NotAfter(chain []byte) (time.Time, error) {
+	block, _ := pem.Decode(chain)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, errors.New("no certificate in pem")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// Root is root certificate
+func (c *CA) Root() *x509.Certificate {
+	return c.cert
+}
+
+func load(dir string) (*CA, error) {
+	certPem, err := os.ReadFile(filepath.Join(dir, CertFile))
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := os.ReadFile(filepath.Join(dir, keyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPem)
+	keyBlock, _ := pem.Decode(keyPem)
+	if certBlock == nil || keyBlock == nil {
+		return nil, fmt.Errorf("invalid root CA in %v", dir)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root CA in %v: %v", dir, err)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root CA key in %v: %v", dir, err)
+	}
+
+	return &CA{cert: cert, key: key}, nil
+}
+
+func create(dir, domain string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	ipRanges := make([]*net.IPNet, 0, len(permittedIpRanges))
+	for _, v := range permittedIpRanges {
+		_, ipNet, _ := net.ParseCIDR(v)
+		ipRanges = append(ipRanges, ipNet)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "prem-gateway local CA " + domain,
+			Organization: []string{"prem-gateway"},
+		},
+		NotBefore:                   now.Add(-time.Hour),
+		NotAfter:                    now.Add(rootValidity),
+		IsCA:                        true,
+		BasicConstraintsValid:       true,
+		MaxPathLenZero:              true,
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         []string{domain},
+		PermittedIPRanges:           ipRanges,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// certificate is written last, root without it is created again
+	if err := writeFile(
+		filepath.Join(dir, keyFile),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		0600,
+	); err != nil {
+		return nil, err
+	}
+	if err := writeFile(
+		filepath.Join(dir, CertFile),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		0644,
+	); err != nil {
+		return nil, err
+	}
+
+	return &CA{cert: cert, key: key}, nil
+}
+
+func permitsDomain(cert *x509.Certificate, domain string) bool {
+	for _, v := range cert.PermittedDNSDomains {
+		if domain == v || strings.HasSuffix(domain, "."+v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeFile replaces file at path, readers never see partial file
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
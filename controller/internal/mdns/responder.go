@@ -0,0 +1,315 @@
+package mdns
+
+import (
+	"context"
+	"errors"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Port is mDNS port, queries from other ports are legacy unicast
+	Port = 5353
+
+	// unicastResponse is QU bit of question class
+	unicastResponse = 1 << 15
+	// legacyTtl is maximal TTL in response to legacy unicast query
+	legacyTtl = 10
+
+	readTimeout = time.Second
+	maxPacket   = 9000
+)
+
+var groupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: Port}
+
+// Responder answers mDNS queries for records of zone, it announces records
+// once zone changes and sends goodbye for removed ones
+type Responder struct {
+	mu      sync.RWMutex
+	records []dns.RR
+	conn    *net.UDPConn
+}
+
+func NewResponder() *Responder {
+	return &Responder{}
+}
+
+// Update replaces zone of responder, it returns records to announce and
+// goodbye records, with TTL 0, of removed ones. Both are sent if responder
+// is serving
+func (r *Responder) Update(zone Zone) ([]dns.RR, []dns.RR) {
+	records := Records(zone)
+
+	r.mu.Lock()
+	prev := r.records
+	r.records = records
+	conn := r.conn
+	r.mu.Unlock()
+
+	announce := missing(records, prev)
+	goodbye := goodbyes(missing(prev, records))
+	if conn != nil {
+		if len(goodbye) > 0 {
+			send(conn, newResponse(goodbye), groupAddr)
+		}
+		if len(announce) > 0 {
+			send(conn, newResponse(records), groupAddr)
+		}
+	}
+
+	return announce, goodbye
+}
+
+// Answer returns response to query, nil if responder has no record asked
+// for or query is response of other responder
+func (r *Responder) Answer(query *dns.Msg) *dns.Msg {
+	if query.Response || query.Opcode != dns.OpcodeQuery {
+		return nil
+	}
+
+	r.mu.RLock()
+	records := r.records
+	r.mu.RUnlock()
+
+	answers := make([]dns.RR, 0)
+	for _, q := range query.Question {
+		for _, rr := range records {
+			if matches(q, rr) && !contains(answers, rr) && !known(query.Answer, rr) {
+				answers = append(answers, rr)
+			}
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+
+	extra := make([]dns.RR, 0)
+	for _, rr := range answers {
+		for _, v := range additional(rr, records) {
+			if !contains(answers, v) && !contains(extra, v) {
+				extra = append(extra, v)
+			}
+		}
+	}
+
+	resp := newResponse(answers)
+	resp.Extra = extra
+
+	return resp
+}
+
+// Serve answers queries on interface, nil iface is default multicast
+// interface of the system. Zone is announced once listening, goodbye for all
+// records is sent when ctx is done
+func (r *Responder) Serve(ctx context.Context, iface *net.Interface) error {
+	conn, err := net.ListenMulticastUDP("udp4", iface, groupAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r.mu.Lock()
+	r.conn = conn
+	records := r.records
+	r.mu.Unlock()
+	if len(records) > 0 {
+		send(conn, newResponse(records), groupAddr)
+	}
+
+	defer func() {
+		r.mu.Lock()
+		r.conn = nil
+		records := r.records
+		r.mu.Unlock()
+		if len(records) > 0 {
+			send(conn, newResponse(goodbyes(records)), groupAddr)
+		}
+	}()
+
+	buf := make([]byte, maxPacket)
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+			return err
+		}
+		n, src, err := conn.ReadFromUDP(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		query := new(dns.Msg)
+		if err := query.Unpack(buf[:n]); err != nil {
+			log.Debugf("Invalid mDNS message from %v: %v", src, err)
+			continue
+		}
+		resp := r.Answer(query)
+		if resp == nil {
+			continue
+		}
+
+		switch {
+		case src.Port != Port:
+			send(conn, legacyResponse(query, resp), src)
+		case unicastRequested(query):
+			send(conn, resp, src)
+		default:
+			send(conn, resp, groupAddr)
+		}
+	}
+}
+
+func send(conn *net.UDPConn, msg *dns.Msg, addr *net.UDPAddr) {
+	packed, err := msg.Pack()
+	if err != nil {
+		log.Errorf("Failed to pack mDNS response: %v", err)
+		return
+	}
+	if _, err := conn.WriteToUDP(packed, addr); err != nil {
+		log.Warnf("Failed to send mDNS response to %v: %v", addr, err)
+	}
+}
+
+func newResponse(answers []dns.RR) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Authoritative = true
+	msg.Answer = answers
+
+	return msg
+}
+
+// legacyResponse is response to query sent from other port than mDNS one,
+// such querier is plain DNS resolver, see RFC 6762 section 6.7
+func legacyResponse(query, resp *dns.Msg) *dns.Msg {
+	msg := resp.Copy()
+	msg.Id = query.Id
+	msg.Question = query.Question
+	for _, rr := range append(msg.Answer, msg.Extra...) {
+		hdr := rr.Header()
+		hdr.Class &^= cacheFlush
+		if hdr.Ttl > legacyTtl {
+			hdr.Ttl = legacyTtl
+		}
+	}
+
+	return msg
+}
+
+func unicastRequested(query *dns.Msg) bool {
+	for _, q := range query.Question {
+		if q.Qclass&unicastResponse == 0 {
+			return false
+		}
+	}
+
+	return len(query.Question) > 0
+}
+
+func matches(q dns.Question, rr dns.RR) bool {
+	hdr := rr.Header()
+	class := q.Qclass &^ unicastResponse
+	if class != dns.ClassINET && class != dns.ClassANY {
+		return false
+	}
+	if q.Qtype != dns.TypeANY && q.Qtype != hdr.Rrtype {
+		return false
+	}
+
+	return strings.EqualFold(q.Name, hdr.Name)
+}
+
+// additional returns records resolving target of rr, SRV and TXT of PTR
+// instance and addresses of SRV target
+func additional(rr dns.RR, records []dns.RR) []dns.RR {
+	var names []string
+	switch v := rr.(type) {
+	case *dns.PTR:
+		names = append(names, v.Ptr)
+		for _, srv := range records {
+			if s, ok := srv.(*dns.SRV); ok && strings.EqualFold(s.Hdr.Name, v.Ptr) {
+				names = append(names, s.Target)
+			}
+		}
+	case *dns.SRV:
+		names = append(names, v.Target)
+	default:
+		return nil
+	}
+
+	extra := make([]dns.RR, 0)
+	for _, name := range names {
+		for _, v := range records {
+			hdr := v.Header()
+			if hdr.Rrtype != dns.TypePTR && strings.EqualFold(hdr.Name, name) {
+				extra = append(extra, v)
+			}
+		}
+	}
+
+	return extra
+}
+
+// known reports if querier already has rr with at least half of its TTL,
+// known-answer suppression of RFC 6762
+func known(answers []dns.RR, rr dns.RR) bool {
+	for _, v := range answers {
+		if equal(v, rr) && v.Header().Ttl >= rr.Header().Ttl/2 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// missing returns records of a not in b
+func missing(a, b []dns.RR) []dns.RR {
+	res := make([]dns.RR, 0)
+	for _, v := range a {
+		if !contains(b, v) {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+func contains(records []dns.RR, rr dns.RR) bool {
+	for _, v := range records {
+		if equal(v, rr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equal compares records ignoring TTL and cache-flush bit
+func equal(a, b dns.RR) bool {
+	a, b = dns.Copy(a), dns.Copy(b)
+	a.Header().Class &^= cacheFlush
+	b.Header().Class &^= cacheFlush
+
+	return dns.IsDuplicate(a, b)
+}
+
+func goodbyes(records []dns.RR) []dns.RR {
+	res := make([]dns.RR, 0, len(records))
+	for _, v := range records {
+		rr := dns.Copy(v)
+		rr.Header().Ttl = 0
+		res = append(res, rr)
+	}
+
+	return res
+}
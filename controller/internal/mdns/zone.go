@@ -0,0 +1,205 @@
+// Package mdns answers mDNS queries for gateway host names on the LAN and
+// advertises gateway services with DNS-SD
+package mdns
+
+import (
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	// ServiceTypeHttps is DNS-SD type of services served with local CA
+	// certificate
+	ServiceTypeHttps = "_https._tcp"
+	// ServiceTypeHttp is DNS-SD type of services served on plain http
+	ServiceTypeHttp = "_http._tcp"
+
+	httpsPort = 443
+	httpPort  = 80
+
+	// hostTtl and serviceTtl are TTLs recommended by RFC 6762
+	hostTtl    = 120
+	serviceTtl = 4500
+
+	// cacheFlush marks records this responder is the only owner of
+	cacheFlush = 1 << 15
+
+	servicesEnumeration = "_services._dns-sd._udp.local."
+	localSuffix         = ".local"
+)
+
+// Service is gateway service reachable on host in .local domain
+type Service struct {
+	// Name is DNS-SD instance name, unique on LAN if host is
+	Name string
+	// Host is .local host name service is routed by
+	Host string
+	// Type is DNS-SD service type, ServiceTypeHttps if empty
+	Type string
+	Port int
+	// Path is advertised in TXT record, eg. /
+	Path string
+}
+
+// NodeService is gateway service advertised on node
+type NodeService struct {
+	Name string
+	// Label is subdomain of node service is routed by, empty for node itself
+	Label string
+	Path  string
+}
+
+// Zone is names responder answers, host names resolve to Ips
+type Zone struct {
+	// Hosts are .local host names, host of every service is added
+	Hosts    []string
+	Ips      []net.IP
+	Services []Service
+}
+
+// NodeZone advertises node on <node>.local and every service on
+// <label>.<node>.local, instance names include node so that gateways on the
+// same LAN do not conflict. Services are advertised as https if node is
+// served with local CA certificate, as http otherwise
+func NodeZone(node string, https bool, services []NodeService, ips []net.IP) Zone {
+	host := strings.ToLower(node) + localSuffix
+	serviceType, port := ServiceTypeHttp, httpPort
+	if https {
+		serviceType, port = ServiceTypeHttps, httpsPort
+	}
+
+	zone := Zone{Hosts: []string{host}, Ips: ips}
+	for _, v := range services {
+		serviceHost := host
+		if v.Label != "" {
+			serviceHost = strings.ToLower(v.Label) + "." + host
+		}
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		zone.Services = append(zone.Services, Service{
+			Name: v.Name + " on " + node,
+			Host: serviceHost,
+			Type: serviceType,
+			Port: port,
+			Path: path,
+		})
+	}
+
+	return zone
+}
+
+// IsLocal reports if host is in .local domain mDNS answers for
+func IsLocal(host string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(strings.ToLower(host), "."), localSuffix)
+}
+
+// Records returns resource records of zone, hosts outside of .local
+// domain are skipped
+func Records(zone Zone) []dns.RR {
+	hosts := make(map[string]bool)
+	for _, v := range zone.Hosts {
+		if IsLocal(v) {
+			hosts[dns.Fqdn(strings.ToLower(v))] = true
+		}
+	}
+	for _, v := range zone.Services {
+		if IsLocal(v.Host) {
+			hosts[dns.Fqdn(strings.ToLower(v.Host))] = true
+		}
+	}
+
+	names := make([]string, 0, len(hosts))
+	for k := range hosts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	records := make([]dns.RR, 0)
+	for _, name := range names {
+		for _, ip := range zone.Ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				records = append(records, &dns.A{
+					Hdr: header(name, dns.TypeA, hostTtl, true),
+					A:   ip4,
+				})
+				continue
+			}
+			records = append(records, &dns.AAAA{
+				Hdr:  header(name, dns.TypeAAAA, hostTtl, true),
+				AAAA: ip,
+			})
+		}
+	}
+
+	// enumerated service types in order of first service of the type
+	serviceTypes := make([]string, 0)
+	enumerated := make(map[string]bool)
+	for _, v := range zone.Services {
+		if !IsLocal(v.Host) {
+			continue
+		}
+
+		serviceType := v.Type
+		if serviceType == "" {
+			serviceType = ServiceTypeHttps
+		}
+		serviceType += localSuffix + "."
+		if !enumerated[serviceType] {
+			enumerated[serviceType] = true
+			serviceTypes = append(serviceTypes, serviceType)
+		}
+
+		instance := escapeLabel(v.Name) + "." + serviceType
+		records = append(records,
+			&dns.PTR{
+				Hdr: header(serviceType, dns.TypePTR, serviceTtl, false),
+				Ptr: instance,
+			},
+			&dns.SRV{
+				Hdr:    header(instance, dns.TypeSRV, hostTtl, true),
+				Port:   uint16(v.Port),
+				Target: dns.Fqdn(strings.ToLower(v.Host)),
+			},
+			&dns.TXT{
+				Hdr: header(instance, dns.TypeTXT, serviceTtl, true),
+				Txt: []string{"path=" + v.Path},
+			},
+		)
+	}
+	for _, v := range serviceTypes {
+		records = append(records, &dns.PTR{
+			Hdr: header(servicesEnumeration, dns.TypePTR, serviceTtl, false),
+			Ptr: v,
+		})
+	}
+
+	return records
+}
+
+func header(name string, rrtype uint16, ttl uint32, unique bool) dns.RR_Header {
+	class := uint16(dns.ClassINET)
+	if unique {
+		class |= cacheFlush
+	}
+
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: class, Ttl: ttl}
+}
+
+// escapeLabel escapes instance name so it stays one label, DNS-SD instance
+// names may contain dots and spaces
+func escapeLabel(name string) string {
+	b := strings.Builder{}
+	for _, c := range name {
+		switch c {
+		case '.', ' ', '\\', '(', ')', ';', '"', '@', '$':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
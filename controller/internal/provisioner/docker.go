@@ -0,0 +1,458 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	containerruntime "prem-gateway/controllerd/internal/container-runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	certResolver        = "myresolver"
+	httpToHttps         = "http-to-https"
+	entrypointWeb       = "web"
+	entrypointWebsecure = "websecure"
+
+	caServerFlag = "--certificatesresolvers.myresolver.acme.caserver"
+
+	DefaultAcmeStorage = "/letsencrypt/acme.json"
+	// caCertificatesFile is written next to acme.json, traefik reads it
+	// from LEGO_CA_CERTIFICATES
+	caCertificatesFile = "ca-certificates.pem"
+	caCertificatesEnv  = "LEGO_CA_CERTIFICATES"
+
+	// certificate of local CA is served through file provider
+	fileProviderFlag = "--providers.file.filename"
+	localTlsFile     = "local-tls.yml"
+	localCertFile    = "local-cert.pem"
+	localKeyFile     = "local-key.pem"
+)
+
+type dockerBackend struct {
+	rt containerruntime.Runtime
+	// removeDelay is wait after container removal failed, see
+	// restartContainer
+	removeDelay time.Duration
+	// acmeStorage is path of traefik acme.json as seen by controllerd,
+	// certificates of resolver are removed from it once CA changes and CA
+	// certificates are written next to it
+	acmeStorage string
+}
+
+// containerUpdate is change restartContainer makes to container
+type containerUpdate struct {
+	// labels replace traefik labels of container
+	labels map[string]string
+	// cmd flags are set in container command, flag with empty value is
+	// removed
+	cmd strslice.StrSlice
+	// env variables are set in container environment, variable with empty
+	// value is removed
+	env map[string]string
+	// beforeCreate is called with previous command once container is removed
+	beforeCreate func(prevCmd strslice.StrSlice) error
+}
+
+// NewDockerBackend returns backend recreating containers of rt with Traefik
+// labels and traefik container with ACME command line. Empty acmeStorage
+// keeps certificates of previous CA in acme.json and does not write CA
+// certificates
+func NewDockerBackend(
+	rt containerruntime.Runtime, removeDelay time.Duration, acmeStorage string,
+) (Backend, error) {
+	if rt == nil {
+		return nil, fmt.Errorf("container runtime not set")
+	}
+	if removeDelay < 0 {
+		return nil, fmt.Errorf("remove delay can not be negative")
+	}
+
+	return &dockerBackend{
+		rt:          rt,
+		removeDelay: removeDelay,
+		acmeStorage: acmeStorage,
+	}, nil
+}
+
+func (d *dockerBackend) ApplyRoutes(ctx context.Context, routes []Route) error {
+	for _, v := range routes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.restartContainer(
+			ctx, v.Service, containerUpdate{labels: Labels(v)},
+		); err != nil {
+			return fmt.Errorf("failed to restart container %s: %v", v.Service, err)
+		}
+
+		log.Infof("Restarted container %s\n", v.Service)
+	}
+
+	return nil
+}
+
+// ApplyTls restarts traefik with ACME resolver, or with file provider
+// serving certificate of local CA as default certificate
+func (d *dockerBackend) ApplyTls(ctx context.Context, tls TlsConfig) error {
+	cmds := strslice.StrSlice{
+		"--providers.docker=true",
+		"--providers.docker.exposedbydefault=false",
+		"--accesslog=true",
+		"--ping",
+		"--entrypoints.web.address=:80",
+		"--entrypoints.websecure.address=:443",
+	}
+	env := map[string]string{caCertificatesEnv: ""}
+	// files are written next to acme.json, traefik reads them from its
+	// certificate dir
+	files := make(map[string][]byte)
+	if tls.Local != nil {
+		cmds = append(cmds, acmeFlags(nil)...)
+		cmds = append(cmds, fileProviderFlag+"="+traefikPath(localTlsFile))
+		files[localCertFile] = tls.Local.Cert
+		files[localKeyFile] = tls.Local.Key
+		files[localTlsFile] = []byte(localTlsConfig())
+	} else {
+		cmds = append(cmds, acmeFlags(&tls)...)
+		cmds = append(cmds, fileProviderFlag+"=")
+		if len(tls.CaCertificates) > 0 {
+			env[caCertificatesEnv] = traefikPath(caCertificatesFile)
+			files[caCertificatesFile] = tls.CaCertificates
+		}
+	}
+
+	beforeCreate := func(prevCmd strslice.StrSlice) error {
+		if d.acmeStorage == "" {
+			return nil
+		}
+		for name, data := range files {
+			perm := os.FileMode(0644)
+			if name == localKeyFile {
+				perm = 0600
+			}
+			if err := os.WriteFile(
+				filepath.Join(filepath.Dir(d.acmeStorage), name), data, perm,
+			); err != nil {
+				return fmt.Errorf("failed to write %v: %v", name, err)
+			}
+		}
+
+		// traefik keeps serving certificates of previous CA from
+		// acme.json, they are removed while traefik is stopped. They are
+		// kept while local CA is used
+		prevCaServer := flagValue(prevCmd, caServerFlag)
+		if tls.Local != nil || prevCaServer == "" || prevCaServer == tls.CaServer {
+			return nil
+		}
+
+		log.Infof(
+			"ACME CA changed from %v to %v, removing certificates of %v from %v",
+			prevCaServer, tls.CaServer, certResolver, d.acmeStorage,
+		)
+		return clearAcmeResolver(d.acmeStorage, certResolver)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.restartContainer(ctx, TraefikService, containerUpdate{
+		cmd:          cmds,
+		env:          env,
+		beforeCreate: beforeCreate,
+	}); err != nil {
+		return fmt.Errorf("failed to restart container traefik: %v", err)
+	}
+
+	log.Info("Restarted container traefik")
+
+	return nil
+}
+
+// acmeFlags returns traefik flags of ACME resolver of tls, nil tls removes
+// them
+func acmeFlags(tls *TlsConfig) strslice.StrSlice {
+	flags := [][2]string{
+		{"--certificatesresolvers.myresolver.acme.email", ""},
+		{"--certificatesresolvers.myresolver.acme.storage", DefaultAcmeStorage},
+		{"--certificatesresolvers.myresolver.acme.tlschallenge", "true"},
+		{caServerFlag, ""},
+		{"--certificatesresolvers.myresolver.acme.keytype", ""},
+		{"--certificatesresolvers.myresolver.acme.eab.kid", ""},
+		{"--certificatesresolvers.myresolver.acme.eab.hmacencoded", ""},
+	}
+	if tls != nil {
+		flags[0][1] = tls.Email
+		flags[3][1] = tls.CaServer
+		flags[4][1] = tls.KeyType
+		if tls.Eab != nil {
+			flags[5][1] = tls.Eab.Kid
+			flags[6][1] = tls.Eab.Hmac
+		}
+	}
+
+	cmds := make(strslice.StrSlice, 0, len(flags))
+	for _, v := range flags {
+		value := v[1]
+		if tls == nil {
+			value = ""
+		}
+		cmds = append(cmds, v[0]+"="+value)
+	}
+
+	return cmds
+}
+
+// localTlsConfig is traefik dynamic configuration serving certificate of
+// local CA as default certificate
+func localTlsConfig() string {
+	return fmt.Sprintf(`tls:
+  stores:
+    default:
+      defaultCertificate:
+        certFile: %v
+        keyFile: %v
+`, traefikPath(localCertFile), traefikPath(localKeyFile))
+}
+
+// traefikPath is path of file in certificate dir of traefik container
+func traefikPath(name string) string {
+	return filepath.Join(filepath.Dir(DefaultAcmeStorage), name)
+}
+
+// Labels returns Traefik docker provider labels of route
+func Labels(r Route) map[string]string {
+	s := r.Service
+	labels := map[string]string{
+		"traefik.enable": "true",
+	}
+
+	if r.HttpRedirect {
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.rule", s)] = r.Rule()
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.entrypoints", s)] = entrypointWeb
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.middlewares", s)] = httpToHttps
+		labels[fmt.Sprintf("traefik.http.routers.%s-https.rule", s)] = r.Rule()
+		labels[fmt.Sprintf("traefik.http.routers.%s-https.entrypoints", s)] = entrypointWebsecure
+		setTlsLabel(labels, s+"-https", r.LocalTls)
+		labels["traefik.http.middlewares.http-to-https.redirectscheme.scheme"] = "https"
+	} else {
+		labels[fmt.Sprintf("traefik.http.routers.%s.rule", s)] = r.Rule()
+		labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", s)] = entrypointWebsecure
+		setTlsLabel(labels, s, r.LocalTls)
+	}
+
+	if r.Port > 0 {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", s)] =
+			strconv.Itoa(r.Port)
+	}
+
+	return labels
+}
+
+// setTlsLabel serves router with certificate of ACME resolver, or with
+// default certificate if local
+func setTlsLabel(labels map[string]string, router string, local bool) {
+	if local {
+		labels[fmt.Sprintf("traefik.http.routers.%s.tls", router)] = "true"
+		return
+	}
+
+	labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", router)] = certResolver
+}
+
+// restartContainer recreates container with update applied to its config
+func (d *dockerBackend) restartContainer(
+	ctx context.Context, containerName string, update containerUpdate,
+) error {
+	// canceled restart finishes container it started, removed container
+	// could not be recreated by the next restart
+	ctx = context.WithoutCancel(ctx)
+
+	containerJson, err := d.rt.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return err
+	}
+	newConfig := containerJson.Config
+	prevCmd := newConfig.Cmd
+	if len(update.labels) > 0 {
+		newLabels := make(map[string]string)
+		for k, v := range newConfig.Labels {
+			if !strings.Contains(k, "traefik") {
+				newLabels[k] = v
+			}
+		}
+		for k, v := range update.labels {
+			newLabels[k] = v
+		}
+		newConfig.Labels = newLabels
+	}
+	if len(update.cmd) > 0 {
+		newConfig.Cmd = mergeCmd(prevCmd, update.cmd)
+	}
+	if len(update.env) > 0 {
+		newConfig.Env = mergeEnv(newConfig.Env, update.env)
+	}
+
+	noWaitTimeout := 0
+	if err := d.rt.ContainerStop(
+		ctx, containerName, container.StopOptions{Timeout: &noWaitTimeout},
+	); err != nil {
+		return err
+	}
+	if err := d.rt.ContainerRemove(
+		ctx, containerName, types.ContainerRemoveOptions{},
+	); err != nil {
+		//TODO this is workaround for restarting prem services, container removal
+		//fails because prem-services are started with --rm flag in prem-daemon
+		//and we can't remove them, so we just ignore the error
+		//TODO maybe we should check if the container is prem-service and if it is
+		//then we should just restart it without removing it
+		//sleeping for 5 seconds to wait until container is removed
+		log.Warning("Error removing container: ", err)
+		sleep(ctx, d.removeDelay)
+		//return err
+	}
+
+	if update.beforeCreate != nil {
+		if err := update.beforeCreate(prevCmd); err != nil {
+			return err
+		}
+	}
+
+	if _, err := d.rt.ContainerCreate(
+		ctx,
+		newConfig,
+		containerJson.HostConfig,
+		&network.NetworkingConfig{
+			EndpointsConfig: containerJson.NetworkSettings.Networks,
+		},
+		nil,
+		containerName,
+	); err != nil {
+		log.Error("Error creating container: ", err)
+		return err
+	}
+
+	if err := d.rt.ContainerStart(
+		ctx, containerName, types.ContainerStartOptions{},
+	); err != nil {
+		log.Error("Error starting container: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// mergeCmd returns cmd with flags of cmds set, flag already in cmd is
+// replaced in place so repeated restarts do not grow command line. Flag with
+// empty value removes it
+func mergeCmd(cmd, cmds strslice.StrSlice) strslice.StrSlice {
+	merged := make(strslice.StrSlice, 0, len(cmd)+len(cmds))
+	index := make(map[string]int)
+	for _, v := range append(cmd[:len(cmd):len(cmd)], cmds...) {
+		name, _, _ := strings.Cut(v, "=")
+		if !strings.HasPrefix(name, "--") {
+			merged = append(merged, v)
+			continue
+		}
+		if i, ok := index[name]; ok {
+			merged[i] = v
+			continue
+		}
+
+		index[name] = len(merged)
+		merged = append(merged, v)
+	}
+
+	// removed flags are dropped last so index stays valid while merging
+	result := merged[:0]
+	for _, v := range merged {
+		if name, value, ok := strings.Cut(v, "="); ok && value == "" &&
+			strings.HasPrefix(name, "--") {
+			continue
+		}
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// mergeEnv returns env with variables of vars set, variable with empty value
+// is removed
+func mergeEnv(env []string, vars map[string]string) []string {
+	merged := make([]string, 0, len(env)+len(vars))
+	for _, v := range env {
+		name, _, _ := strings.Cut(v, "=")
+		if _, ok := vars[name]; !ok {
+			merged = append(merged, v)
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if vars[k] != "" {
+			merged = append(merged, k+"="+vars[k])
+		}
+	}
+
+	return merged
+}
+
+// flagValue returns value of the last flag in cmd, empty if not set
+func flagValue(cmd strslice.StrSlice, flag string) string {
+	value := ""
+	for _, v := range cmd {
+		if name, val, ok := strings.Cut(v, "="); ok && name == flag {
+			value = val
+		}
+	}
+
+	return value
+}
+
+// clearAcmeResolver removes certificates and account of resolver from
+// traefik acme.json at path, missing file is left as is
+func clearAcmeResolver(path, resolver string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+
+	stored := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse %v: %v", path, err)
+	}
+	if _, ok := stored[resolver]; !ok {
+		return nil
+	}
+	delete(stored, resolver)
+
+	data, err = json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// traefik refuses acme.json readable by others
+	return os.WriteFile(path, data, 0600)
+}
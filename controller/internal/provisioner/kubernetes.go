@@ -0,0 +1,541 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"strconv"
+)
+
+const (
+	// IngressKindIngressRoute exposes routes with Traefik IngressRoute and
+	// cert-manager Certificate objects
+	IngressKindIngressRoute = "ingressroute"
+	// IngressKindIngress exposes routes with standard Ingress annotated for
+	// Traefik and cert-manager, routes are https only
+	IngressKindIngress = "ingress"
+
+	defaultNamespace     = "default"
+	defaultClusterIssuer = "prem-gateway"
+	defaultIngressClass  = "traefik"
+	// defaultCertManagerNamespace is cluster resource namespace of
+	// cert-manager installed with its defaults
+	defaultCertManagerNamespace = "cert-manager"
+	eabSecretKey                = "secret"
+	// localTlsSecret holds certificate of local CA used by every route
+	localTlsSecret = "prem-gateway-local-tls"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "prem-gateway-controllerd"
+
+	clusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+	issuerNameAnnotation    = "cert-manager.io/issuer-name"
+	issuerKindAnnotation    = "cert-manager.io/issuer-kind"
+	keyAlgorithmAnnotation  = "cert-manager.io/private-key-algorithm"
+	keySizeAnnotation       = "cert-manager.io/private-key-size"
+
+	traefikApiVersion     = "traefik.containo.us/v1alpha1"
+	certManagerApiVersion = "cert-manager.io/v1"
+)
+
+var (
+	IngressRouteGvr = schema.GroupVersionResource{
+		Group: "traefik.containo.us", Version: "v1alpha1", Resource: "ingressroutes",
+	}
+	MiddlewareGvr = schema.GroupVersionResource{
+		Group: "traefik.containo.us", Version: "v1alpha1", Resource: "middlewares",
+	}
+	CertificateGvr = schema.GroupVersionResource{
+		Group: "cert-manager.io", Version: "v1", Resource: "certificates",
+	}
+	ClusterIssuerGvr = schema.GroupVersionResource{
+		Group: "cert-manager.io", Version: "v1", Resource: "clusterissuers",
+	}
+)
+
+type KubernetesConfig struct {
+	// Namespace of gateway Services, default is default
+	Namespace string
+	// IngressKind is IngressKindIngressRoute, default, or IngressKindIngress
+	IngressKind string
+	// ClusterIssuer is name of cert-manager ClusterIssuer controllerd
+	// manages, default is prem-gateway
+	ClusterIssuer string
+	// IngressClass is class of Ingress and of ACME http01 solver, default
+	// is traefik
+	IngressClass string
+	// CertManagerNamespace is namespace cert-manager reads ClusterIssuer
+	// secrets from, EAB secret is created there, default is cert-manager
+	CertManagerNamespace string
+}
+
+type kubernetesBackend struct {
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	cfg       KubernetesConfig
+}
+
+// NewKubernetesBackend returns backend applying Traefik and cert-manager
+// objects for Services of routes, Services are not restarted
+func NewKubernetesBackend(
+	clientset kubernetes.Interface, dynamicClient dynamic.Interface, cfg KubernetesConfig,
+) (Backend, error) {
+	if clientset == nil || dynamicClient == nil {
+		return nil, fmt.Errorf("kubernetes clients not set")
+	}
+
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.ClusterIssuer == "" {
+		cfg.ClusterIssuer = defaultClusterIssuer
+	}
+	if cfg.IngressClass == "" {
+		cfg.IngressClass = defaultIngressClass
+	}
+	if cfg.CertManagerNamespace == "" {
+		cfg.CertManagerNamespace = defaultCertManagerNamespace
+	}
+	switch cfg.IngressKind {
+	case "":
+		cfg.IngressKind = IngressKindIngressRoute
+	case IngressKindIngressRoute, IngressKindIngress:
+	default:
+		return nil, fmt.Errorf("unknown ingress kind %v", cfg.IngressKind)
+	}
+
+	return &kubernetesBackend{
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		cfg:       cfg,
+	}, nil
+}
+
+func (k *kubernetesBackend) ApplyRoutes(ctx context.Context, routes []Route) error {
+	for _, v := range routes {
+		port, err := k.servicePort(ctx, v)
+		if err != nil {
+			return err
+		}
+
+		switch k.cfg.IngressKind {
+		case IngressKindIngressRoute:
+			err = k.applyIngressRoute(ctx, v, port)
+		case IngressKindIngress:
+			err = k.applyIngress(ctx, v, port)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to expose service %s: %v", v.Service, err)
+		}
+
+		log.Infof("Exposed service %s on %s", v.Service, v.Host)
+	}
+
+	return nil
+}
+
+// ApplyTls creates or updates ClusterIssuer requesting certificates from
+// ACME CA with http01 challenge served through ingress class, EAB HMAC is
+// stored in Secret of cert-manager namespace. Once CA changes certificate
+// Secrets issued by ClusterIssuer are deleted so cert-manager issues them
+// again from new CA
+func (k *kubernetesBackend) ApplyTls(ctx context.Context, tls TlsConfig) error {
+	if tls.Local != nil {
+		return k.applyLocalTls(ctx, *tls.Local)
+	}
+
+	prevCaServer := ""
+	existing, err := k.dynamic.Resource(ClusterIssuerGvr).Get(
+		ctx, k.cfg.ClusterIssuer, metav1.GetOptions{},
+	)
+	switch {
+	case err == nil:
+		prevCaServer, _, _ = unstructured.NestedString(
+			existing.Object, "spec", "acme", "server",
+		)
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("failed to get cluster issuer: %v", err)
+	}
+
+	acme := map[string]interface{}{
+		"server": tls.CaServer,
+		"privateKeySecretRef": map[string]interface{}{
+			"name": k.cfg.ClusterIssuer + "-account-key",
+		},
+		"solvers": []interface{}{
+			map[string]interface{}{
+				"http01": map[string]interface{}{
+					"ingress": map[string]interface{}{
+						"class": k.cfg.IngressClass,
+					},
+				},
+			},
+		},
+	}
+	if tls.Email != "" {
+		acme["email"] = tls.Email
+	}
+	if len(tls.CaCertificates) > 0 {
+		acme["caBundle"] = base64.StdEncoding.EncodeToString(tls.CaCertificates)
+	}
+	if tls.Eab != nil {
+		secretName := k.cfg.ClusterIssuer + "-eab"
+		if err := k.applySecret(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: k.cfg.CertManagerNamespace,
+				Labels:    map[string]string{managedByLabel: managedBy},
+			},
+			StringData: map[string]string{eabSecretKey: tls.Eab.Hmac},
+		}); err != nil {
+			return fmt.Errorf("failed to apply eab secret: %v", err)
+		}
+		acme["externalAccountBinding"] = map[string]interface{}{
+			"keyID": tls.Eab.Kid,
+			"keySecretRef": map[string]interface{}{
+				"name": secretName,
+				"key":  eabSecretKey,
+			},
+		}
+	}
+
+	issuer := newObject(certManagerApiVersion, "ClusterIssuer", k.cfg.ClusterIssuer, "")
+	issuer.Object["spec"] = map[string]interface{}{"acme": acme}
+
+	if err := k.apply(ctx, ClusterIssuerGvr, issuer); err != nil {
+		return fmt.Errorf("failed to apply cluster issuer: %v", err)
+	}
+
+	log.Infof("Applied cluster issuer %s", k.cfg.ClusterIssuer)
+
+	if prevCaServer == "" || prevCaServer == tls.CaServer {
+		return nil
+	}
+
+	log.Infof(
+		"ACME CA changed from %v to %v, deleting certificates of %v",
+		prevCaServer, tls.CaServer, k.cfg.ClusterIssuer,
+	)
+	return k.deleteIssuedSecrets(ctx)
+}
+
+// applyLocalTls stores certificate of local CA in Secret routes are served
+// with, ClusterIssuer is left as is
+func (k *kubernetesBackend) applyLocalTls(ctx context.Context, cert LocalCertificate) error {
+	if err := k.applySecret(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      localTlsSecret,
+			Namespace: k.cfg.Namespace,
+			Labels:    map[string]string{managedByLabel: managedBy},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       cert.Cert,
+			corev1.TLSPrivateKeyKey: cert.Key,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to apply local tls secret: %v", err)
+	}
+
+	log.Infof("Applied local tls secret %s", localTlsSecret)
+
+	return nil
+}
+
+// applySecret creates secret or replaces existing secret of the same name
+func (k *kubernetesBackend) applySecret(ctx context.Context, secret *corev1.Secret) error {
+	secrets := k.clientset.CoreV1().Secrets(secret.Namespace)
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	secret.ResourceVersion = existing.ResourceVersion
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+
+	return err
+}
+
+// deleteIssuedSecrets deletes TLS Secrets in namespace issued by
+// ClusterIssuer
+func (k *kubernetesBackend) deleteIssuedSecrets(ctx context.Context) error {
+	secrets := k.clientset.CoreV1().Secrets(k.cfg.Namespace)
+	list, err := secrets.List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + string(corev1.SecretTypeTLS),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %v", err)
+	}
+
+	for _, v := range list.Items {
+		if v.Annotations[issuerNameAnnotation] != k.cfg.ClusterIssuer ||
+			v.Annotations[issuerKindAnnotation] != "ClusterIssuer" {
+			continue
+		}
+
+		err := secrets.Delete(ctx, v.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete secret %s: %v", v.Name, err)
+		}
+
+		log.Infof("Deleted certificate secret %s", v.Name)
+	}
+
+	return nil
+}
+
+// servicePort returns port of Service of route, port matching route port
+// or its target port is preferred, first port is used otherwise
+func (k *kubernetesBackend) servicePort(ctx context.Context, r Route) (int32, error) {
+	svc, err := k.clientset.CoreV1().Services(k.cfg.Namespace).Get(
+		ctx, r.Service, metav1.GetOptions{},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get service %s: %v", r.Service, err)
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return 0, fmt.Errorf("service %s has no ports", r.Service)
+	}
+
+	for _, v := range svc.Spec.Ports {
+		if r.Port > 0 && (int(v.Port) == r.Port || v.TargetPort.IntValue() == r.Port) {
+			return v.Port, nil
+		}
+	}
+
+	return svc.Spec.Ports[0].Port, nil
+}
+
+// applyCertificate requests certificate of route from ClusterIssuer
+func (k *kubernetesBackend) applyCertificate(
+	ctx context.Context, r Route, secretName string,
+) error {
+	cert := newObject(certManagerApiVersion, "Certificate", secretName, k.cfg.Namespace)
+	spec := map[string]interface{}{
+		"secretName": secretName,
+		"dnsNames":   []interface{}{r.Host},
+		"issuerRef": map[string]interface{}{
+			"name": k.cfg.ClusterIssuer,
+			"kind": "ClusterIssuer",
+		},
+	}
+	if algorithm, size := keyAlgorithm(r.KeyType); algorithm != "" {
+		spec["privateKey"] = map[string]interface{}{
+			"algorithm": algorithm,
+			"size":      int64(size),
+			// key is regenerated once key type changes
+			"rotationPolicy": "Always",
+		}
+	}
+	cert.Object["spec"] = spec
+
+	return k.apply(ctx, CertificateGvr, cert)
+}
+
+func (k *kubernetesBackend) applyIngressRoute(
+	ctx context.Context, r Route, port int32,
+) error {
+	secretName := r.Service + "-tls"
+	services := []interface{}{
+		map[string]interface{}{"name": r.Service, "port": int64(port)},
+	}
+
+	if r.LocalTls {
+		// certificate requested for previous domain is not renewed
+		err := k.dynamic.Resource(CertificateGvr).Namespace(k.cfg.Namespace).Delete(
+			ctx, secretName, metav1.DeleteOptions{},
+		)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		secretName = localTlsSecret
+	} else if err := k.applyCertificate(ctx, r, secretName); err != nil {
+		return err
+	}
+
+	httpsRoute := newObject(traefikApiVersion, "IngressRoute", r.Service, k.cfg.Namespace)
+	httpsRoute.Object["spec"] = map[string]interface{}{
+		"entryPoints": []interface{}{entrypointWebsecure},
+		"routes": []interface{}{
+			map[string]interface{}{
+				"match":    r.Rule(),
+				"kind":     "Rule",
+				"services": services,
+			},
+		},
+		"tls": map[string]interface{}{"secretName": secretName},
+	}
+	if err := k.apply(ctx, IngressRouteGvr, httpsRoute); err != nil {
+		return err
+	}
+
+	if !r.HttpRedirect {
+		return nil
+	}
+
+	middleware := newObject(traefikApiVersion, "Middleware", httpToHttps, k.cfg.Namespace)
+	middleware.Object["spec"] = map[string]interface{}{
+		"redirectScheme": map[string]interface{}{
+			"scheme":    "https",
+			"permanent": true,
+		},
+	}
+	if err := k.apply(ctx, MiddlewareGvr, middleware); err != nil {
+		return err
+	}
+
+	httpRoute := newObject(traefikApiVersion, "IngressRoute", r.Service+"-http", k.cfg.Namespace)
+	httpRoute.Object["spec"] = map[string]interface{}{
+		"entryPoints": []interface{}{entrypointWeb},
+		"routes": []interface{}{
+			map[string]interface{}{
+				"match":    r.Rule(),
+				"kind":     "Rule",
+				"services": services,
+				"middlewares": []interface{}{
+					map[string]interface{}{"name": httpToHttps},
+				},
+			},
+		},
+	}
+
+	return k.apply(ctx, IngressRouteGvr, httpRoute)
+}
+
+func (k *kubernetesBackend) applyIngress(
+	ctx context.Context, r Route, port int32,
+) error {
+	path := r.PathPrefix
+	if path == "" {
+		path = "/"
+	}
+	pathType := networkingv1.PathTypePrefix
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Service,
+			Namespace: k.cfg.Namespace,
+			Labels:    map[string]string{managedByLabel: managedBy},
+			Annotations: map[string]string{
+				clusterIssuerAnnotation:                            k.cfg.ClusterIssuer,
+				"traefik.ingress.kubernetes.io/router.entrypoints": entrypointWebsecure,
+				"traefik.ingress.kubernetes.io/router.tls":         "true",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &k.cfg.IngressClass,
+			TLS: []networkingv1.IngressTLS{{
+				Hosts:      []string{r.Host},
+				SecretName: r.Service + "-tls",
+			}},
+			Rules: []networkingv1.IngressRule{{
+				Host: r.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     path,
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: r.Service,
+									Port: networkingv1.ServiceBackendPort{Number: port},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+
+	if r.LocalTls {
+		// cert-manager does not manage secret of local CA
+		delete(ingress.Annotations, clusterIssuerAnnotation)
+		ingress.Spec.TLS[0].SecretName = localTlsSecret
+	} else if algorithm, size := keyAlgorithm(r.KeyType); algorithm != "" {
+		ingress.Annotations[keyAlgorithmAnnotation] = algorithm
+		ingress.Annotations[keySizeAnnotation] = strconv.Itoa(size)
+	}
+
+	ingresses := k.clientset.NetworkingV1().Ingresses(k.cfg.Namespace)
+	existing, err := ingresses.Get(ctx, ingress.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = ingresses.Create(ctx, ingress, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	ingress.ResourceVersion = existing.ResourceVersion
+	_, err = ingresses.Update(ctx, ingress, metav1.UpdateOptions{})
+
+	return err
+}
+
+// apply creates obj or replaces existing object of the same name
+func (k *kubernetesBackend) apply(
+	ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured,
+) error {
+	var resource dynamic.ResourceInterface = k.dynamic.Resource(gvr)
+	if obj.GetNamespace() != "" {
+		resource = k.dynamic.Resource(gvr).Namespace(obj.GetNamespace())
+	}
+
+	existing, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = resource.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = resource.Update(ctx, obj, metav1.UpdateOptions{})
+
+	return err
+}
+
+// keyAlgorithm returns cert-manager private key algorithm and size of key
+// type, empty algorithm for empty key type
+func keyAlgorithm(keyType string) (string, int) {
+	switch keyType {
+	case KeyTypeEc256:
+		return "ECDSA", 256
+	case KeyTypeEc384:
+		return "ECDSA", 384
+	case KeyTypeRsa2048:
+		return "RSA", 2048
+	case KeyTypeRsa4096:
+		return "RSA", 4096
+	case KeyTypeRsa8192:
+		return "RSA", 8192
+	}
+
+	return "", 0
+}
+
+func newObject(apiVersion, kind, name, namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	obj.SetLabels(map[string]string{managedByLabel: managedBy})
+
+	return obj
+}
@@ -0,0 +1,147 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	LetsEncryptStaging = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	LetsEncryptProd    = "https://acme-v02.api.letsencrypt.org/directory"
+
+	defaultTraefikDelay = time.Second * 3
+	// DefaultRemoveDelay is wait of docker backend after container removal
+	// failed
+	DefaultRemoveDelay = time.Second * 5
+)
+
+type Option interface {
+	apply(*options) error
+}
+
+type options struct {
+	acmeCaServer string
+	// acmeEab and acmeCaCertificates are used with acmeCaServer only, CA
+	// selected for domain can differ
+	acmeEab            *Eab
+	acmeCaCertificates []byte
+	acmeKeyType        string
+	// traefikDelay is wait between restart of services and traefik so
+	// traefik picks up new labels
+	traefikDelay time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		acmeCaServer: LetsEncryptStaging,
+		traefikDelay: defaultTraefikDelay,
+	}
+}
+
+type funcOption struct {
+	f func(*options) error
+}
+
+func (fo *funcOption) apply(o *options) error {
+	return fo.f(o)
+}
+
+func newFuncOption(f func(*options) error) *funcOption {
+	return &funcOption{
+		f: f,
+	}
+}
+
+// WithLetsEncryptProd makes traefik request certificates from Let's
+// Encrypt production instead of staging for domains without ACME CA
+func WithLetsEncryptProd(prod bool) Option {
+	return newFuncOption(func(o *options) error {
+		o.acmeCaServer = LetsEncryptStaging
+		if prod {
+			o.acmeCaServer = LetsEncryptProd
+		}
+		return nil
+	})
+}
+
+// WithAcmeCaServer makes traefik request certificates from ACME directory at
+// caServer for domains without ACME CA, eg. ZeroSSL or step-ca
+func WithAcmeCaServer(caServer string) Option {
+	return newFuncOption(func(o *options) error {
+		if err := validateCaServer(caServer); err != nil {
+			return err
+		}
+
+		o.acmeCaServer = caServer
+		return nil
+	})
+}
+
+// WithAcmeEab sets external account binding used with CA of
+// WithAcmeCaServer, hmac is base64url encoded key issued by CA with kid
+func WithAcmeEab(kid, hmac string) Option {
+	return newFuncOption(func(o *options) error {
+		if kid == "" && hmac == "" {
+			o.acmeEab = nil
+			return nil
+		}
+		if kid == "" || hmac == "" {
+			return fmt.Errorf("eab needs both key id and hmac")
+		}
+		// ends up in Traefik command line
+		if strings.ContainsAny(kid, " \t\"'`=") {
+			return fmt.Errorf("invalid eab key id")
+		}
+		if _, err := base64.RawURLEncoding.DecodeString(
+			strings.TrimRight(hmac, "="),
+		); err != nil {
+			return fmt.Errorf("eab hmac must be base64url encoded: %v", err)
+		}
+
+		o.acmeEab = &Eab{Kid: kid, Hmac: hmac}
+		return nil
+	})
+}
+
+// WithAcmeCaCertificates sets PEM bundle of roots trusted for CA of
+// WithAcmeCaServer, eg. root of internal step-ca
+func WithAcmeCaCertificates(pem []byte) Option {
+	return newFuncOption(func(o *options) error {
+		if len(pem) > 0 && !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in ca bundle")
+		}
+
+		o.acmeCaCertificates = pem
+		return nil
+	})
+}
+
+// WithAcmeKeyType sets key type of certificates, one of KeyType constants
+func WithAcmeKeyType(keyType string) Option {
+	return newFuncOption(func(o *options) error {
+		switch keyType {
+		case "", KeyTypeEc256, KeyTypeEc384, KeyTypeRsa2048, KeyTypeRsa4096, KeyTypeRsa8192:
+		default:
+			return fmt.Errorf("unknown key type %v", keyType)
+		}
+
+		o.acmeKeyType = keyType
+		return nil
+	})
+}
+
+// WithTraefikDelay sets wait between applying routes and TLS, 0 disables
+// wait, used with simulated runtime or Kubernetes backend
+func WithTraefikDelay(d time.Duration) Option {
+	return newFuncOption(func(o *options) error {
+		if d < 0 {
+			return fmt.Errorf("traefik delay can not be negative")
+		}
+
+		o.traefikDelay = d
+		return nil
+	})
+}
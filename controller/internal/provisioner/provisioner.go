@@ -0,0 +1,204 @@
+// Package provisioner exposes gateway services through Traefik with TLS for
+// provisioned domain, by recreating Docker containers with Traefik labels or
+// by applying Kubernetes objects
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PremappService = "premapp"
+	PremdService   = "premd"
+	TraefikService = "traefik"
+
+	// AcmeCaStaging and AcmeCaProduction select Let's Encrypt directory,
+	// ACME CA of domain can also be https url of custom directory
+	AcmeCaStaging    = "staging"
+	AcmeCaProduction = "production"
+)
+
+// Backend applies routes and TLS configuration to the platform gateway
+// services run on
+type Backend interface {
+	// ApplyRoutes exposes services of routes through traefik with TLS
+	ApplyRoutes(ctx context.Context, routes []Route) error
+	// ApplyTls configures ACME certificate resolver used by routes
+	ApplyTls(ctx context.Context, tls TlsConfig) error
+}
+
+type Provisioner struct {
+	backend Backend
+	opts    options
+
+	mu sync.Mutex
+	// domain is last provisioned domain
+	domain string
+}
+
+func New(backend Backend, opts ...Option) (*Provisioner, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("backend not set")
+	}
+
+	options := defaultOptions()
+	for _, o := range opts {
+		if err := o.apply(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Provisioner{
+		backend: backend,
+		opts:    options,
+	}, nil
+}
+
+// CaServer returns ACME directory url of acmeCa, empty acmeCa is CA selected
+// by options
+func (p *Provisioner) CaServer(acmeCa string) (string, error) {
+	switch acmeCa {
+	case "":
+		return p.opts.acmeCaServer, nil
+	case AcmeCaStaging:
+		return LetsEncryptStaging, nil
+	case AcmeCaProduction:
+		return LetsEncryptProd, nil
+	}
+
+	if err := validateCaServer(acmeCa); err != nil {
+		return "", fmt.Errorf(
+			"acme ca must be %v, %v or https url of ACME directory",
+			AcmeCaStaging, AcmeCaProduction,
+		)
+	}
+
+	return acmeCa, nil
+}
+
+func validateCaServer(caServer string) error {
+	// url ends up in Traefik command line
+	parsed, err := url.Parse(caServer)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" ||
+		parsed.User != nil || strings.ContainsAny(caServer, " \t\"'`") {
+		return fmt.Errorf("invalid acme ca server %q", caServer)
+	}
+
+	return nil
+}
+
+// ProvisionDomain exposes prem-services and services on domain with TLS,
+// then configures ACME resolver with email and CA of acmeCa, see CaServer.
+// EAB and CA certificates of options are used only if CA is the one of
+// options. Canceled ctx stops provisioning between services, ctx error is
+// returned
+func (p *Provisioner) ProvisionDomain(
+	ctx context.Context,
+	domain, email, acmeCa string,
+	services []string,
+	premServices map[string]int,
+) error {
+	caServer, err := p.CaServer(acmeCa)
+	if err != nil {
+		return err
+	}
+
+	tls := TlsConfig{
+		Email:    email,
+		CaServer: caServer,
+		KeyType:  p.opts.acmeKeyType,
+	}
+	if caServer == p.opts.acmeCaServer {
+		tls.Eab = p.opts.acmeEab
+		tls.CaCertificates = p.opts.acmeCaCertificates
+	}
+
+	return p.provision(ctx, domain, services, premServices, tls)
+}
+
+// ProvisionLocal exposes prem-services and services on private domain, eg.
+// prem.local, with TLS certificate issued by local CA instead of ACME.
+// Canceled ctx stops provisioning between services, ctx error is returned
+func (p *Provisioner) ProvisionLocal(
+	ctx context.Context,
+	domain string,
+	cert LocalCertificate,
+	services []string,
+	premServices map[string]int,
+) error {
+	return p.provision(ctx, domain, services, premServices, TlsConfig{Local: &cert})
+}
+
+func (p *Provisioner) provision(
+	ctx context.Context,
+	domain string,
+	services []string,
+	premServices map[string]int,
+	tls TlsConfig,
+) error {
+	if len(premServices) > 0 {
+		if err := p.backend.ApplyRoutes(
+			ctx, p.withTls(PremServiceRoutes(domain, premServices), tls),
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := p.backend.ApplyRoutes(
+		ctx, p.withTls(ServiceRoutes(domain, services), tls),
+	); err != nil {
+		return err
+	}
+
+	//TODO maybe add health check to all restarted services since services
+	//needs to be restarted before traefik can pick up the new labels
+	sleep(ctx, p.opts.traefikDelay)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := p.backend.ApplyTls(ctx, tls); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.domain = domain
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Domain returns domain services were last provisioned on, empty if
+// provisioning did not succeed yet
+func (p *Provisioner) Domain() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.domain
+}
+
+func (p *Provisioner) withTls(routes []Route, tls TlsConfig) []Route {
+	for i := range routes {
+		routes[i].KeyType = p.opts.acmeKeyType
+		routes[i].LocalTls = tls.Local != nil
+	}
+
+	return routes
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	if d == 0 {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
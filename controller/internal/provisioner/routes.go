@@ -0,0 +1,124 @@
+package provisioner
+
+import (
+	"sort"
+)
+
+// key types of certificates, as named by Traefik
+const (
+	KeyTypeEc256   = "EC256"
+	KeyTypeEc384   = "EC384"
+	KeyTypeRsa2048 = "RSA2048"
+	KeyTypeRsa4096 = "RSA4096"
+	KeyTypeRsa8192 = "RSA8192"
+)
+
+// Route exposes service on host through traefik with TLS, backends render
+// it as container labels or Kubernetes objects
+type Route struct {
+	// Service is container or Kubernetes Service name
+	Service string
+	Host    string
+	// PathPrefix is added to host rule if set
+	PathPrefix string
+	// Port of service traffic is sent to, 0 leaves it to traefik or to
+	// port of Kubernetes Service
+	Port int
+	// HttpRedirect serves route also on http entrypoint, redirected to
+	// https, otherwise route is https only
+	HttpRedirect bool
+	// KeyType of route certificate, used by backends requesting certificate
+	// per route, empty is default of the backend
+	KeyType string
+	// LocalTls serves route with certificate of TlsConfig.Local instead of
+	// certificate requested from ACME CA
+	LocalTls bool
+}
+
+// TlsConfig is ACME configuration of certificate resolver, backends drop
+// certificates issued by previous CA once CaServer changes
+type TlsConfig struct {
+	Email    string
+	CaServer string
+	// Eab is external account binding, nil if CA does not require it
+	Eab *Eab
+	// CaCertificates is PEM bundle of roots trusted for CaServer, system
+	// roots are used if empty
+	CaCertificates []byte
+	// KeyType of certificates, empty is default of the backend
+	KeyType string
+	// Local is certificate issued by local CA, ACME is not used if set
+	Local *LocalCertificate
+}
+
+// LocalCertificate is certificate served for every route without ACME
+type LocalCertificate struct {
+	// Cert is PEM chain of certificate
+	Cert []byte
+	Key  []byte
+}
+
+// Eab is external account binding of ACME account, issued by CA like ZeroSSL
+// or Google Trust Services
+type Eab struct {
+	Kid string
+	// Hmac is base64url encoded HMAC key
+	Hmac string
+}
+
+// ServiceRoutes returns routes of services, services without routes, eg.
+// dnsd, are skipped
+func ServiceRoutes(domain string, services []string) []Route {
+	routes := make([]Route, 0, len(services))
+	for _, v := range services {
+		switch v {
+		case PremappService:
+			routes = append(routes, Route{
+				Service:      v,
+				Host:         domain,
+				PathPrefix:   "/",
+				Port:         8080,
+				HttpRedirect: true,
+			})
+		case PremdService:
+			routes = append(routes, Route{
+				Service: v,
+				Host:    v + "." + domain,
+			})
+		}
+	}
+
+	return routes
+}
+
+// PremServiceRoutes returns routes of prem-services running on given ports
+// in stable order, each prem-service is exposed on its subdomain
+func PremServiceRoutes(domain string, premServices map[string]int) []Route {
+	names := make([]string, 0, len(premServices))
+	for k := range premServices {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	routes := make([]Route, 0, len(names))
+	for _, k := range names {
+		routes = append(routes, Route{
+			Service:      k,
+			Host:         k + "." + domain,
+			Port:         premServices[k],
+			HttpRedirect: true,
+		})
+	}
+
+	return routes
+}
+
+// Rule is traefik router rule of route
+func (r Route) Rule() string {
+	rule := "Host(`" + r.Host + "`)"
+	if r.PathPrefix != "" {
+		rule = "PathPrefix(`" + r.PathPrefix + "`) && " + rule
+	}
+
+	return rule
+}
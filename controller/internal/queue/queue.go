@@ -0,0 +1,290 @@
+// Package queue runs restarts of gateway containers one by one. Every restart
+// converges gateway to one target state, so restart submitted for the same
+// target as queued or running one is coalesced with it and restart for other
+// target supersedes queued and running ones
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/controllerd/internal/leader"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed     = errors.New("restart queue is closed")
+	ErrNotLeader  = errors.New("controllerd instance is not leader")
+	ErrSuperseded = errors.New("restart superseded by newer one")
+)
+
+// Job is restart operation, jobs coalesced with it share its result
+type Job struct {
+	name        string
+	target      string
+	run         func(ctx context.Context) error
+	submittedAt time.Time
+
+	// set by worker
+	startedAt time.Time
+	// leading is canceled once instance stops being leader
+	leading    context.Context
+	cancel     context.CancelFunc
+	superseded bool
+
+	done chan struct{}
+	err  error
+}
+
+func (j *Job) Name() string {
+	return j.name
+}
+
+// Done is closed when job finishes
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Err is result of job, valid after Done is closed
+func (j *Job) Err() error {
+	return j.err
+}
+
+// Wait waits until job finishes or ctx is done
+func (j *Job) Wait(ctx context.Context) error {
+	select {
+	case <-j.done:
+		return j.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *Job) finish(err error) {
+	j.err = err
+	close(j.done)
+}
+
+// Operation is job reported by Status
+type Operation struct {
+	Name        string
+	SubmittedAt time.Time
+	// StartedAt is zero for pending job
+	StartedAt time.Time
+}
+
+type Status struct {
+	// Depth is number of pending jobs, running one is not counted
+	Depth   int
+	Current *Operation
+	Pending []Operation
+}
+
+// Queue runs jobs in order they were submitted, jobs only run while instance
+// is leader and running job is canceled once leadership is lost
+type Queue struct {
+	elector leader.Elector
+
+	mu      sync.Mutex
+	pending []*Job
+	current *Job
+	closed  bool
+	// wake is signaled when job is submitted or queue is closed
+	wake    chan struct{}
+	stopped chan struct{}
+}
+
+func New(elector leader.Elector) *Queue {
+	return &Queue{
+		elector: elector,
+		pending: make([]*Job, 0),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (q *Queue) Start() {
+	go q.work()
+}
+
+// Submit queues run restarting gateway to target state. If job for the same
+// target is pending or running it is returned instead and coalesced is true,
+// otherwise pending jobs are dropped and running job is canceled
+func (q *Queue) Submit(
+	name, target string, run func(ctx context.Context) error,
+) (job *Job, coalesced bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, false, ErrClosed
+	}
+
+	for _, j := range q.pending {
+		if j.target == target {
+			log.Infof("Coalesced job %v with pending %v", name, j.name)
+			return j, true, nil
+		}
+	}
+	// running job is only reused while nothing newer is pending, otherwise
+	// the pending job would undo it
+	if c := q.current; c != nil && c.target == target && !c.superseded &&
+		len(q.pending) == 0 {
+		log.Infof("Coalesced job %v with running %v", name, c.name)
+		return c, true, nil
+	}
+
+	for _, j := range q.pending {
+		log.Infof("Job %v superseded by %v", j.name, name)
+		j.finish(ErrSuperseded)
+	}
+	q.pending = q.pending[:0]
+
+	if c := q.current; c != nil && !c.superseded {
+		log.Infof("Canceling job %v superseded by %v", c.name, name)
+		c.superseded = true
+		c.cancel()
+	}
+
+	j := &Job{
+		name:        name,
+		target:      target,
+		run:         run,
+		submittedAt: time.Now(),
+		done:        make(chan struct{}),
+	}
+	q.pending = append(q.pending, j)
+	q.signal()
+
+	return j, false, nil
+}
+
+// Status returns running and pending jobs
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := Status{
+		Depth:   len(q.pending),
+		Pending: make([]Operation, 0, len(q.pending)),
+	}
+	if q.current != nil {
+		status.Current = &Operation{
+			Name:        q.current.name,
+			SubmittedAt: q.current.submittedAt,
+			StartedAt:   q.current.startedAt,
+		}
+	}
+	for _, j := range q.pending {
+		status.Pending = append(status.Pending, Operation{
+			Name:        j.name,
+			SubmittedAt: j.submittedAt,
+		})
+	}
+
+	return status
+}
+
+// Close stops accepting jobs and waits until pending jobs finish or ctx is
+// done, jobs are not canceled so containers are not left stopped
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.signal()
+	q.mu.Unlock()
+
+	select {
+	case <-q.stopped:
+		return nil
+	case <-ctx.Done():
+		return errors.New("timed out waiting for restart jobs")
+	}
+}
+
+func (q *Queue) work() {
+	defer close(q.stopped)
+
+	for {
+		j, ctx, closed := q.next()
+		if j == nil {
+			if closed {
+				return
+			}
+			<-q.wake
+			continue
+		}
+
+		// leadership may be lost while job waits in queue, other instance
+		// may be restarting the same containers already
+		if ctx == nil {
+			log.Warnf("Dropping job %v, %v", j.name, ErrNotLeader)
+			q.finish(j, ErrNotLeader)
+			continue
+		}
+
+		log.Infof("Starting job %v", j.name)
+		err := j.run(ctx)
+		j.cancel()
+		log.Infof("Finished job %v", j.name)
+
+		// other instance took over and converges gateway on its own
+		if err != nil && j.leading.Err() != nil {
+			log.Warnf("Job %v canceled, %v", j.name, ErrNotLeader)
+			err = fmt.Errorf("%w: %v", ErrNotLeader, err)
+		}
+
+		q.finish(j, err)
+	}
+}
+
+// next dequeues job and makes it current in one critical section, so job
+// submitted meanwhile sees it and is coalesced with it or cancels it. ctx of
+// the job is canceled with leadership, it is nil if instance is not leader
+// and job must not run then
+func (q *Queue) next() (*Job, context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, nil, q.closed
+	}
+
+	j := q.pending[0]
+	q.pending = q.pending[1:]
+
+	leading := q.elector.Leading()
+	if leading.Err() != nil {
+		return j, nil, q.closed
+	}
+
+	ctx, cancel := context.WithCancel(leading)
+	j.leading = leading
+	j.cancel = cancel
+	j.startedAt = time.Now()
+	q.current = j
+
+	return j, ctx, q.closed
+}
+
+func (q *Queue) finish(j *Job, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == j {
+		q.current = nil
+	}
+	// job canceled after its last step still succeeded
+	if j.superseded && err != nil {
+		err = ErrSuperseded
+	}
+	j.finish(err)
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
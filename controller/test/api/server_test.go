@@ -0,0 +1,348 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"prem-gateway/controllerd/internal/api"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/provisioner"
+	"prem-gateway/pkg/signing"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "3f1c0a9e7b5d4c2a1908f7e6d5c4b3a2"
+
+// blockingBackend blocks ApplyRoutes until release is closed, so restart
+// job stays running
+type blockingBackend struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingBackend() *blockingBackend {
+	return &blockingBackend{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blockingBackend) ApplyRoutes(ctx context.Context, routes []provisioner.Route) error {
+	b.once.Do(func() { close(b.started) })
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *blockingBackend) ApplyTls(ctx context.Context, tls provisioner.TlsConfig) error {
+	return nil
+}
+
+// follower is elector of instance which is not leader
+type follower struct{}
+
+func (follower) Run(ctx context.Context) {
+	<-ctx.Done()
+}
+
+func (follower) Status() leader.Status {
+	return leader.Status{Identity: "b", Leader: "a"}
+}
+
+func (follower) WaitLeader(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (follower) Leading() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+type testServer struct {
+	*api.Server
+	url         string
+	provisioner *provisioner.Provisioner
+	backend     *blockingBackend
+	// provisioned receives results of restarts of provisioned domain
+	provisioned chan error
+}
+
+func newTestServer(t *testing.T, elector leader.Elector) *testServer {
+	backend := newBlockingBackend()
+	p, err := provisioner.New(backend, provisioner.WithTraefikDelay(0))
+	require.NoError(t, err)
+	verifier, err := signing.NewVerifier(testSecret, 0)
+	require.NoError(t, err)
+
+	provisioned := make(chan error, 10)
+	srv, err := api.NewServer(
+		"", p, elector, verifier,
+		func(ctx context.Context, domain, email, acmeCa string) error {
+			err := p.ProvisionDomain(ctx, domain, email, acmeCa, []string{"dnsd"}, nil)
+			provisioned <- err
+			return err
+		},
+		api.WithServices([]string{"dnsd"}),
+	)
+	require.NoError(t, err)
+	server := httptest.NewServer(srv.Handler())
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		// job left running by failed test must not block other tests
+		select {
+		case <-backend.release:
+		default:
+			close(backend.release)
+		}
+	})
+
+	return &testServer{
+		Server:      srv,
+		url:         server.URL,
+		provisioner: p,
+		backend:     backend,
+		provisioned: provisioned,
+	}
+}
+
+func (s *testServer) do(
+	t *testing.T, method, path string, body string, signed bool,
+) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, s.url+path, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	if signed {
+		signer, err := signing.NewSigner(testSecret)
+		require.NoError(t, err)
+		require.NoError(t, signer.Sign(req, []byte(body)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, got
+}
+
+func decode[T any](t *testing.T, body []byte) T {
+	var v T
+	require.NoError(t, json.Unmarshal(body, &v))
+	return v
+}
+
+func TestServerStatusCodes(t *testing.T) {
+	s := newTestServer(t, leader.NewSingle("a"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		signed bool
+		status int
+		code   string
+	}{
+		{name: "health", method: http.MethodGet, path: "/v1/health", status: http.StatusOK},
+		{name: "leader", method: http.MethodGet, path: "/v1/leader", status: http.StatusOK},
+		{name: "queue", method: http.MethodGet, path: "/v1/queue", status: http.StatusOK},
+		{name: "services", method: http.MethodGet, path: "/v1/services", status: http.StatusOK},
+		{
+			name: "unknown route", method: http.MethodGet, path: "/v1/nope",
+			status: http.StatusNotFound, code: "not_found",
+		},
+		{
+			name: "local ca disabled", method: http.MethodGet, path: "/v1/local-ca/ca.pem",
+			status: http.StatusNotFound, code: "not_found",
+		},
+		{
+			name: "wrong method", method: http.MethodGet, path: "/v1/domain-provisioned",
+			status: http.StatusMethodNotAllowed, code: "method_not_allowed",
+		},
+		{
+			name: "unsigned", method: http.MethodPost, path: "/v1/domain-provisioned",
+			body:   `{"domain":"gateway.me"}`,
+			status: http.StatusUnauthorized, code: "unauthorized",
+		},
+		{
+			name: "unsigned deprecated path", method: http.MethodPost, path: "/domain-provisioned",
+			body:   `{"domain":"gateway.me"}`,
+			status: http.StatusUnauthorized, code: "unauthorized",
+		},
+		{
+			name: "invalid body", method: http.MethodPost, path: "/v1/domain-provisioned",
+			body: `{`, signed: true,
+			status: http.StatusBadRequest, code: "invalid_argument",
+		},
+		{
+			name: "invalid domain", method: http.MethodPost, path: "/v1/domain-provisioned",
+			body: `{"domain":"gateway.me\") || Host(\"evil.org"}`, signed: true,
+			status: http.StatusBadRequest, code: "invalid_argument",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := s.do(t, tt.method, tt.path, tt.body, tt.signed)
+			require.Equal(t, tt.status, resp.StatusCode, string(body))
+			if tt.code != "" {
+				require.Equal(t, tt.code, decode[api.ErrorResponse](t, body).Code)
+			}
+		})
+	}
+
+	resp, _ := s.do(t, http.MethodPost, "/v1/health", "", false)
+	require.Equal(t, http.MethodGet, resp.Header.Get("Allow"))
+	// no job was submitted by rejected requests
+	require.Equal(t, 0, s.Queue().Status().Depth)
+	require.Nil(t, s.Queue().Status().Current)
+}
+
+func TestServerDomainProvisioned(t *testing.T) {
+	s := newTestServer(t, leader.NewSingle("a"))
+	body := `{"domain":"gateway.me","email":"admin@gateway.me"}`
+
+	resp, got := s.do(t, http.MethodPost, "/v1/domain-provisioned", body, true)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(got))
+	require.Equal(t, api.JobResponse{
+		Status: "accepted", Domain: "gateway.me", Services: []string{"dnsd"},
+	}, decode[api.JobResponse](t, got))
+	<-s.backend.started
+
+	// restart to the same state joins running one, deprecated path is
+	// still served
+	resp, got = s.do(t, http.MethodPost, "/domain-provisioned", body, true)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(got))
+	require.Equal(t, "coalesced", decode[api.JobResponse](t, got).Status)
+
+	resp, got = s.do(t, http.MethodGet, "/v1/queue", "", false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	queue := decode[api.QueueResponse](t, got)
+	require.NotNil(t, queue.Current)
+	require.Equal(t, "domain-provisioned gateway.me", queue.Current.Name)
+
+	close(s.backend.release)
+	require.NoError(t, <-s.provisioned)
+	require.Equal(t, "gateway.me", s.provisioner.Domain())
+
+	resp, got = s.do(t, http.MethodGet, "/v1/services", "", false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "gateway.me", decode[api.ServicesResponse](t, got).Domain)
+}
+
+func TestServerNotLeader(t *testing.T) {
+	s := newTestServer(t, follower{})
+
+	resp, got := s.do(
+		t, http.MethodPost, "/v1/domain-provisioned", `{"domain":"gateway.me"}`, true,
+	)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	errResp := decode[api.ErrorResponse](t, got)
+	require.Equal(t, "not_leader", errResp.Code)
+	require.Contains(t, errResp.Error, `leader is "a"`)
+
+	resp, got = s.do(t, http.MethodGet, "/v1/leader", "", false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, api.LeaderResponse{
+		Identity: "b", Leader: "a", IsLeader: false,
+	}, decode[api.LeaderResponse](t, got))
+}
+
+func TestServerShutdownWaitsForJob(t *testing.T) {
+	s := newTestServer(t, leader.NewSingle("a"))
+
+	resp, got := s.do(
+		t, http.MethodPost, "/v1/domain-provisioned", `{"domain":"gateway.me"}`, true,
+	)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(got))
+	<-s.backend.started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned while job was running: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.ErrorIs(t, s.RunBackground(func() {}), api.ErrShuttingDown)
+
+	close(s.backend.release)
+	require.NoError(t, <-shutdownErr)
+	// job was not canceled by shutdown
+	require.NoError(t, <-s.provisioned)
+
+	// queue is closed once shutdown returns
+	resp, got = s.do(
+		t, http.MethodPost, "/v1/domain-provisioned", `{"domain":"other.me"}`, true,
+	)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "unavailable", decode[api.ErrorResponse](t, got).Code)
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	s := newTestServer(t, leader.NewSingle("a"))
+
+	resp, got := s.do(
+		t, http.MethodPost, "/v1/domain-provisioned", `{"domain":"gateway.me"}`, true,
+	)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(got))
+	<-s.backend.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorContains(t, s.Shutdown(ctx), "timed out waiting for restart jobs")
+}
+
+func TestServerServices(t *testing.T) {
+	p, err := provisioner.New(newBlockingBackend(), provisioner.WithTraefikDelay(0))
+	require.NoError(t, err)
+	verifier, err := signing.NewVerifier(testSecret, 0)
+	require.NoError(t, err)
+	srv, err := api.NewServer(
+		"", p, leader.NewSingle("a"), verifier,
+		func(ctx context.Context, domain, email, acmeCa string) error {
+			return nil
+		},
+		api.WithServices([]string{"premapp", "premd", "dnsd"}),
+		api.WithPremServices(func() map[string]int {
+			return map[string]int{"llama": 8000}
+		}),
+	)
+	require.NoError(t, err)
+	server := httptest.NewServer(srv.Handler())
+	defer server.Close()
+	s := &testServer{Server: srv, url: server.URL}
+
+	// services are listed without hosts before domain is provisioned
+	resp, got := s.do(t, http.MethodGet, "/v1/services", "", false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, api.ServicesResponse{
+		Services: []api.ServiceResponse{
+			{Name: "premapp", Label: "", Path: "/"},
+			{Name: "premd", Label: "premd", Path: "/"},
+			{Name: "llama", Label: "llama", Path: "/"},
+		},
+	}, decode[api.ServicesResponse](t, got))
+
+	// local CA root is not served without local CA mode
+	resp, _ = s.do(t, http.MethodGet, "/v1/local-ca/ca.pem", "", false)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = api.NewServer("", p, leader.NewSingle("a"), verifier, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package leadertest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"prem-gateway/controllerd/internal/leader"
+	"testing"
+	"time"
+)
+
+const retryInterval = time.Millisecond * 10
+
+func TestFileElector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "controllerd", "leader.lock")
+	first, err := leader.NewFileElector(path, "first", retryInterval)
+	require.NoError(t, err)
+	second, err := leader.NewFileElector(path, "second", retryInterval)
+	require.NoError(t, err)
+
+	require.Error(t, first.Leading().Err())
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		first.Run(firstCtx)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, first.WaitLeader(ctx))
+	leading := first.Leading()
+	require.NoError(t, leading.Err())
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+
+	require.Eventually(t, func() bool {
+		return second.Status().Leader == "first"
+	}, time.Second*5, retryInterval)
+	require.False(t, second.Status().IsLeader)
+
+	// lock is released once first stops, second takes over
+	stopFirst()
+	<-firstDone
+	require.False(t, first.Status().IsLeader)
+	require.ErrorIs(t, leading.Err(), context.Canceled)
+	require.Error(t, first.Leading().Err())
+
+	require.NoError(t, second.WaitLeader(ctx))
+	require.Equal(t, leader.Status{
+		Identity: "second",
+		Leader:   "second",
+		IsLeader: true,
+	}, second.Status())
+}
+
+func TestWaitLeaderCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	first, err := leader.NewFileElector(path, "first", retryInterval)
+	require.NoError(t, err)
+	second, err := leader.NewFileElector(path, "second", retryInterval)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go first.Run(ctx)
+	require.NoError(t, first.WaitLeader(ctx))
+	go second.Run(ctx)
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer waitCancel()
+	require.ErrorIs(t, second.WaitLeader(waitCtx), context.DeadlineExceeded)
+}
+
+func TestSingle(t *testing.T) {
+	elector := leader.NewSingle("only")
+	require.True(t, elector.Status().IsLeader)
+	require.NoError(t, elector.WaitLeader(context.Background()))
+	require.NoError(t, elector.Leading().Err())
+
+	_, err := leader.NewFileElector("", "first", 0)
+	require.Error(t, err)
+	_, err = leader.NewFileElector(filepath.Join(t.TempDir(), "leader.lock"), "", 0)
+	require.Error(t, err)
+}
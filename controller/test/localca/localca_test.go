@@ -0,0 +1,74 @@
+package localcatest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"net"
+	"os"
+	"path/filepath"
+	"prem-gateway/controllerd/internal/localca"
+	"testing"
+	"time"
+)
+
+func TestLocalCa(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "local-ca")
+
+	_, err := localca.RootPem(dir)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	ca, err := localca.Open(dir, "prem.local")
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "ca-key.pem"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// root is loaded on next start
+	reopened, err := localca.Open(dir, "prem.local")
+	require.NoError(t, err)
+	require.True(t, ca.Root().Equal(reopened.Root()))
+
+	_, err = localca.Open(dir, "other.local")
+	require.ErrorContains(t, err, "constrained to prem.local")
+
+	rootPem, err := localca.RootPem(dir)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(rootPem))
+
+	ip := net.ParseIP("192.168.1.20")
+	certPem, keyPem, err := reopened.Issue("prem.local", []net.IP{ip})
+	require.NoError(t, err)
+	block, _ := pem.Decode(keyPem)
+	require.NotNil(t, block)
+	block, _ = pem.Decode(certPem)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	notAfter, err := localca.NotAfter(certPem)
+	require.NoError(t, err)
+	require.True(t, notAfter.Equal(cert.NotAfter))
+	require.True(t, time.Until(notAfter) > localca.RenewBefore)
+	_, err = localca.NotAfter(keyPem)
+	require.Error(t, err)
+
+	for _, v := range []string{"prem.local", "premd.prem.local", "192.168.1.20"} {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: v, Roots: roots})
+		require.NoError(t, err, v)
+	}
+
+	// root constraints reject names out of private domain
+	foreign, _, err := reopened.Issue("example.com", nil)
+	require.NoError(t, err)
+	block, _ = pem.Decode(foreign)
+	cert, err = x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots})
+	require.Error(t, err)
+
+	require.NoError(t, localca.ValidateIp(ip))
+	require.NoError(t, localca.ValidateIp(net.ParseIP("fd00::1")))
+	require.Error(t, localca.ValidateIp(net.ParseIP("8.8.8.8")))
+}
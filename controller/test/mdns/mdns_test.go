@@ -0,0 +1,178 @@
+package mdnstest
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"net"
+	"prem-gateway/controllerd/internal/mdns"
+	"testing"
+)
+
+var zone = mdns.Zone{
+	Hosts: []string{"prem.local"},
+	Ips:   []net.IP{net.ParseIP("192.168.1.20"), net.ParseIP("fd00::20")},
+	Services: []mdns.Service{
+		{Name: "premapp on prem", Host: "prem.local", Port: 443, Path: "/"},
+		{Name: "premd on prem", Host: "premd.prem.local", Port: 443, Path: "/"},
+		{Name: "public", Host: "premd.example.com", Port: 443, Path: "/"},
+	},
+}
+
+func query(name string, qtype uint16) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = false
+
+	return msg
+}
+
+func TestAnswerHost(t *testing.T) {
+	r := mdns.NewResponder()
+	r.Update(zone)
+
+	resp := r.Answer(query("premd.prem.local.", dns.TypeA))
+	require.NotNil(t, resp)
+	require.True(t, resp.Response)
+	require.True(t, resp.Authoritative)
+	require.Len(t, resp.Answer, 1)
+	a := resp.Answer[0].(*dns.A)
+	require.Equal(t, "192.168.1.20", a.A.String())
+	// responder is the only owner of host name
+	require.NotZero(t, a.Hdr.Class&(1<<15))
+
+	resp = r.Answer(query("PREM.local.", dns.TypeAAAA))
+	require.NotNil(t, resp)
+	require.Equal(t, "fd00::20", resp.Answer[0].(*dns.AAAA).AAAA.String())
+
+	// hosts outside of .local are not answered
+	require.Nil(t, r.Answer(query("premd.example.com.", dns.TypeA)))
+	require.Nil(t, r.Answer(query("other.local.", dns.TypeA)))
+
+	// responses of other responders are not answered
+	resp = query("prem.local.", dns.TypeA)
+	resp.Response = true
+	require.Nil(t, r.Answer(resp))
+}
+
+func TestAnswerBrowse(t *testing.T) {
+	r := mdns.NewResponder()
+	r.Update(zone)
+
+	resp := r.Answer(query("_services._dns-sd._udp.local.", dns.TypePTR))
+	require.NotNil(t, resp)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, "_https._tcp.local.", resp.Answer[0].(*dns.PTR).Ptr)
+
+	resp = r.Answer(query("_https._tcp.local.", dns.TypePTR))
+	require.NotNil(t, resp)
+	instances := make([]string, 0)
+	for _, v := range resp.Answer {
+		instances = append(instances, v.(*dns.PTR).Ptr)
+	}
+	require.ElementsMatch(t, []string{
+		`premapp\ on\ prem._https._tcp.local.`,
+		`premd\ on\ prem._https._tcp.local.`,
+	}, instances)
+
+	// SRV, TXT and addresses of targets are sent along
+	var srv *dns.SRV
+	addresses := 0
+	for _, v := range resp.Extra {
+		switch rr := v.(type) {
+		case *dns.SRV:
+			if rr.Hdr.Name == `premd\ on\ prem._https._tcp.local.` {
+				srv = rr
+			}
+		case *dns.A, *dns.AAAA:
+			addresses++
+		}
+	}
+	require.NotNil(t, srv)
+	require.Equal(t, uint16(443), srv.Port)
+	require.Equal(t, "premd.prem.local.", srv.Target)
+	require.Equal(t, 4, addresses)
+
+	// known answers are suppressed
+	q := query("_https._tcp.local.", dns.TypePTR)
+	q.Answer = []dns.RR{&dns.PTR{
+		Hdr: dns.RR_Header{
+			Name: "_https._tcp.local.", Rrtype: dns.TypePTR,
+			Class: dns.ClassINET, Ttl: 4500,
+		},
+		Ptr: `premd\ on\ prem._https._tcp.local.`,
+	}}
+	resp = r.Answer(q)
+	require.NotNil(t, resp)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, `premapp\ on\ prem._https._tcp.local.`, resp.Answer[0].(*dns.PTR).Ptr)
+}
+
+func TestUpdate(t *testing.T) {
+	r := mdns.NewResponder()
+	announce, goodbye := r.Update(zone)
+	require.Len(t, goodbye, 0)
+	require.Len(t, announce, len(mdns.Records(zone)))
+
+	announce, goodbye = r.Update(zone)
+	require.Len(t, announce, 0)
+	require.Len(t, goodbye, 0)
+
+	// stopped service gets goodbye records, host of gateway is kept
+	stopped := zone
+	stopped.Services = zone.Services[:1]
+	announce, goodbye = r.Update(stopped)
+	require.Len(t, announce, 0)
+	// PTR, SRV, TXT and two addresses of premd.prem.local
+	require.Len(t, goodbye, 5)
+	for _, v := range goodbye {
+		require.Zero(t, v.Header().Ttl)
+	}
+	require.Nil(t, r.Answer(query("premd.prem.local.", dns.TypeA)))
+	require.NotNil(t, r.Answer(query("prem.local.", dns.TypeA)))
+
+	// domain outside of .local is not advertised
+	announce, goodbye = r.Update(mdns.Zone{
+		Hosts: []string{"gateway.example.com"},
+		Ips:   zone.Ips,
+	})
+	require.Len(t, announce, 0)
+	require.Len(t, goodbye, len(mdns.Records(stopped)))
+}
+
+func TestNodeZone(t *testing.T) {
+	services := []mdns.NodeService{
+		{Name: "premapp", Path: "/"},
+		{Name: "premd", Label: "premd"},
+	}
+
+	// node is advertised on plain http without local CA certificate
+	r := mdns.NewResponder()
+	r.Update(mdns.NodeZone("Box", false, services, zone.Ips))
+	require.NotNil(t, r.Answer(query("box.local.", dns.TypeA)))
+	require.NotNil(t, r.Answer(query("premd.box.local.", dns.TypeA)))
+	require.Nil(t, r.Answer(query("_https._tcp.local.", dns.TypePTR)))
+
+	resp := r.Answer(query("_services._dns-sd._udp.local.", dns.TypePTR))
+	require.NotNil(t, resp)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, "_http._tcp.local.", resp.Answer[0].(*dns.PTR).Ptr)
+
+	resp = r.Answer(query(`premd\ on\ Box._http._tcp.local.`, dns.TypeSRV))
+	require.NotNil(t, resp)
+	srv := resp.Answer[0].(*dns.SRV)
+	require.Equal(t, uint16(80), srv.Port)
+	require.Equal(t, "premd.box.local.", srv.Target)
+
+	resp = r.Answer(query(`premd\ on\ Box._http._tcp.local.`, dns.TypeTXT))
+	require.NotNil(t, resp)
+	require.Equal(t, []string{"path=/"}, resp.Answer[0].(*dns.TXT).Txt)
+
+	// local CA certificate serves the same names over https
+	r.Update(mdns.NodeZone("Box", true, services, zone.Ips))
+	require.Nil(t, r.Answer(query("_http._tcp.local.", dns.TypePTR)))
+	resp = r.Answer(query(`premapp\ on\ Box._https._tcp.local.`, dns.TypeSRV))
+	require.NotNil(t, resp)
+	srv = resp.Answer[0].(*dns.SRV)
+	require.Equal(t, uint16(443), srv.Port)
+	require.Equal(t, "box.local.", srv.Target)
+}
@@ -0,0 +1,310 @@
+package provisionertest
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"prem-gateway/controllerd/internal/provisioner"
+	"testing"
+)
+
+const namespace = "prem"
+
+func newService(name string, port, targetPort int) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Port:       int32(port),
+				TargetPort: intstr.FromInt(targetPort),
+			}},
+		},
+	}
+}
+
+func newFakeClients() (*fake.Clientset, *dynamicfake.FakeDynamicClient) {
+	clientset := fake.NewClientset(
+		newService("premapp", 80, 8080),
+		newService("premd", 8000, 8000),
+		newService("llama", 9000, 8000),
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			provisioner.IngressRouteGvr:  "IngressRouteList",
+			provisioner.MiddlewareGvr:    "MiddlewareList",
+			provisioner.CertificateGvr:   "CertificateList",
+			provisioner.ClusterIssuerGvr: "ClusterIssuerList",
+		},
+	)
+
+	return clientset, dynamicClient
+}
+
+func getObject(
+	t *testing.T,
+	dynamicClient *dynamicfake.FakeDynamicClient,
+	gvr schema.GroupVersionResource,
+	ns, name string,
+) *unstructured.Unstructured {
+	obj, err := dynamicClient.Resource(gvr).Namespace(ns).Get(
+		context.Background(), name, metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+
+	return obj
+}
+
+func TestKubernetesIngressRoute(t *testing.T) {
+	ctx := context.Background()
+	clientset, dynamicClient := newFakeClients()
+	backend, err := provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{Namespace: namespace},
+	)
+	require.NoError(t, err)
+	p, err := provisioner.New(
+		backend,
+		provisioner.WithLetsEncryptProd(true),
+		provisioner.WithTraefikDelay(0),
+	)
+	require.NoError(t, err)
+
+	// second run updates objects created by first one
+	for i := 0; i < 2; i++ {
+		require.NoError(t, p.ProvisionDomain(
+			ctx,
+			"gateway.me",
+			"admin@gateway.me",
+			"",
+			[]string{"premapp", "premd", "dnsd"},
+			map[string]int{"llama": 8000},
+		))
+	}
+
+	premapp := getObject(t, dynamicClient, provisioner.IngressRouteGvr, namespace, "premapp")
+	routes, _, _ := unstructured.NestedSlice(premapp.Object, "spec", "routes")
+	require.Len(t, routes, 1)
+	route := routes[0].(map[string]interface{})
+	require.Equal(t, "PathPrefix(`/`) && Host(`gateway.me`)", route["match"])
+	// route port is target port, traefik is pointed to Service port
+	require.Equal(t, int64(80), route["services"].([]interface{})[0].(map[string]interface{})["port"])
+	secretName, _, _ := unstructured.NestedString(premapp.Object, "spec", "tls", "secretName")
+	require.Equal(t, "premapp-tls", secretName)
+
+	getObject(t, dynamicClient, provisioner.IngressRouteGvr, namespace, "premapp-http")
+	getObject(t, dynamicClient, provisioner.MiddlewareGvr, namespace, "http-to-https")
+
+	llama := getObject(t, dynamicClient, provisioner.IngressRouteGvr, namespace, "llama")
+	routes, _, _ = unstructured.NestedSlice(llama.Object, "spec", "routes")
+	require.Equal(t, int64(9000), routes[0].(map[string]interface{})["services"].([]interface{})[0].(map[string]interface{})["port"])
+
+	// premd is https only as in docker
+	_, err = dynamicClient.Resource(provisioner.IngressRouteGvr).Namespace(namespace).Get(
+		ctx, "premd-http", metav1.GetOptions{},
+	)
+	require.Error(t, err)
+
+	cert := getObject(t, dynamicClient, provisioner.CertificateGvr, namespace, "premd-tls")
+	dnsNames, _, _ := unstructured.NestedStringSlice(cert.Object, "spec", "dnsNames")
+	require.Equal(t, []string{"premd.gateway.me"}, dnsNames)
+
+	issuer := getObject(t, dynamicClient, provisioner.ClusterIssuerGvr, "", "prem-gateway")
+	server, _, _ := unstructured.NestedString(issuer.Object, "spec", "acme", "server")
+	require.Equal(t, "https://acme-v02.api.letsencrypt.org/directory", server)
+	email, _, _ := unstructured.NestedString(issuer.Object, "spec", "acme", "email")
+	require.Equal(t, "admin@gateway.me", email)
+}
+
+func TestKubernetesIngress(t *testing.T) {
+	ctx := context.Background()
+	clientset, dynamicClient := newFakeClients()
+	backend, err := provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{
+			Namespace:     namespace,
+			IngressKind:   provisioner.IngressKindIngress,
+			ClusterIssuer: "letsencrypt",
+		},
+	)
+	require.NoError(t, err)
+
+	routes := provisioner.ServiceRoutes("gateway.me", []string{"premapp", "premd"})
+	for i := 0; i < 2; i++ {
+		require.NoError(t, backend.ApplyRoutes(ctx, routes))
+	}
+
+	ingress, err := clientset.NetworkingV1().Ingresses(namespace).Get(
+		ctx, "premapp", metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "letsencrypt", ingress.Annotations["cert-manager.io/cluster-issuer"])
+	require.Equal(t, "gateway.me", ingress.Spec.Rules[0].Host)
+	require.Equal(t, []string{"gateway.me"}, ingress.Spec.TLS[0].Hosts)
+	backendSvc := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	require.Equal(t, "premapp", backendSvc.Name)
+	require.Equal(t, int32(80), backendSvc.Port.Number)
+
+	ingress, err = clientset.NetworkingV1().Ingresses(namespace).Get(
+		ctx, "premd", metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "premd.gateway.me", ingress.Spec.Rules[0].Host)
+
+	// service must exist
+	err = backend.ApplyRoutes(ctx, provisioner.PremServiceRoutes(
+		"gateway.me", map[string]int{"missing": 8000},
+	))
+	require.ErrorContains(t, err, "missing")
+
+	_, err = provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{IngressKind: "gateway"},
+	)
+	require.Error(t, err)
+}
+
+func TestKubernetesAcmeCaChange(t *testing.T) {
+	ctx := context.Background()
+	clientset, dynamicClient := newFakeClients()
+	newSecret := func(name, issuer string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"cert-manager.io/issuer-name": issuer,
+					"cert-manager.io/issuer-kind": "ClusterIssuer",
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+	}
+	backend, err := provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{Namespace: namespace},
+	)
+	require.NoError(t, err)
+	p, err := provisioner.New(backend, provisioner.WithTraefikDelay(0))
+	require.NoError(t, err)
+
+	secrets := func() []string {
+		list, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		names := make([]string, 0, len(list.Items))
+		for _, v := range list.Items {
+			names = append(names, v.Name)
+		}
+		return names
+	}
+
+	require.NoError(t, p.ProvisionDomain(ctx, "gateway.me", "", "staging", nil, nil))
+	for _, v := range []*corev1.Secret{
+		newSecret("premapp-tls", "prem-gateway"),
+		newSecret("other-tls", "other"),
+	} {
+		_, err := clientset.CoreV1().Secrets(namespace).Create(ctx, v, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// the same CA keeps certificates
+	require.NoError(t, p.ProvisionDomain(ctx, "gateway.me", "", "", nil, nil))
+	require.ElementsMatch(t, []string{"premapp-tls", "other-tls"}, secrets())
+
+	// certificates of cluster issuer are dropped once CA changes
+	require.NoError(t, p.ProvisionDomain(ctx, "gateway.me", "", "production", nil, nil))
+	require.Equal(t, []string{"other-tls"}, secrets())
+	issuer := getObject(t, dynamicClient, provisioner.ClusterIssuerGvr, "", "prem-gateway")
+	server, _, _ := unstructured.NestedString(issuer.Object, "spec", "acme", "server")
+	require.Equal(t, provisioner.LetsEncryptProd, server)
+}
+
+func TestKubernetesCustomCa(t *testing.T) {
+	ctx := context.Background()
+	clientset, dynamicClient := newFakeClients()
+	caBundle := newCaBundle(t)
+	backend, err := provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{Namespace: namespace},
+	)
+	require.NoError(t, err)
+	p, err := provisioner.New(
+		backend,
+		provisioner.WithTraefikDelay(0),
+		provisioner.WithAcmeCaServer(stepCa),
+		provisioner.WithAcmeEab("kid-1", "c2VjcmV0LWhtYWMta2V5"),
+		provisioner.WithAcmeCaCertificates(caBundle),
+		provisioner.WithAcmeKeyType(provisioner.KeyTypeEc384),
+	)
+	require.NoError(t, err)
+
+	// second run updates eab secret created by first one
+	for i := 0; i < 2; i++ {
+		require.NoError(t, p.ProvisionDomain(
+			ctx, "gateway.me", "admin@gateway.me", "", []string{"premd"}, nil,
+		))
+	}
+
+	issuer := getObject(t, dynamicClient, provisioner.ClusterIssuerGvr, "", "prem-gateway")
+	bundle, _, _ := unstructured.NestedString(issuer.Object, "spec", "acme", "caBundle")
+	require.Equal(t, base64.StdEncoding.EncodeToString(caBundle), bundle)
+	eab, _, _ := unstructured.NestedMap(issuer.Object, "spec", "acme", "externalAccountBinding")
+	require.Equal(t, map[string]interface{}{
+		"keyID": "kid-1",
+		"keySecretRef": map[string]interface{}{
+			"name": "prem-gateway-eab",
+			"key":  "secret",
+		},
+	}, eab)
+
+	secret, err := clientset.CoreV1().Secrets("cert-manager").Get(
+		ctx, "prem-gateway-eab", metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "c2VjcmV0LWhtYWMta2V5", secret.StringData["secret"])
+
+	cert := getObject(t, dynamicClient, provisioner.CertificateGvr, namespace, "premd-tls")
+	algorithm, _, _ := unstructured.NestedString(cert.Object, "spec", "privateKey", "algorithm")
+	require.Equal(t, "ECDSA", algorithm)
+	size, _, _ := unstructured.NestedInt64(cert.Object, "spec", "privateKey", "size")
+	require.Equal(t, int64(384), size)
+}
+
+func TestKubernetesLocal(t *testing.T) {
+	ctx := context.Background()
+	clientset, dynamicClient := newFakeClients()
+	backend, err := provisioner.NewKubernetesBackend(
+		clientset, dynamicClient, provisioner.KubernetesConfig{Namespace: namespace},
+	)
+	require.NoError(t, err)
+	p, err := provisioner.New(backend, provisioner.WithTraefikDelay(0))
+	require.NoError(t, err)
+
+	require.NoError(t, p.ProvisionDomain(ctx, "gateway.me", "", "", []string{"premd"}, nil))
+	getObject(t, dynamicClient, provisioner.CertificateGvr, namespace, "premd-tls")
+
+	cert := provisioner.LocalCertificate{Cert: []byte("cert"), Key: []byte("key")}
+	require.NoError(t, p.ProvisionLocal(ctx, "prem.local", cert, []string{"premd"}, nil))
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(
+		ctx, "prem-gateway-local-tls", metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	require.Equal(t, []byte("key"), secret.Data[corev1.TLSPrivateKeyKey])
+
+	premd := getObject(t, dynamicClient, provisioner.IngressRouteGvr, namespace, "premd")
+	secretName, _, _ := unstructured.NestedString(premd.Object, "spec", "tls", "secretName")
+	require.Equal(t, "prem-gateway-local-tls", secretName)
+	routes, _, _ := unstructured.NestedSlice(premd.Object, "spec", "routes")
+	require.Equal(t, "Host(`premd.prem.local`)", routes[0].(map[string]interface{})["match"])
+
+	// certificate of previous domain is not renewed
+	_, err = dynamicClient.Resource(provisioner.CertificateGvr).Namespace(namespace).Get(
+		ctx, "premd-tls", metav1.GetOptions{},
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package queuetest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"prem-gateway/controllerd/internal/leader"
+	"prem-gateway/controllerd/internal/queue"
+	"sync"
+	"testing"
+	"time"
+)
+
+const timeout = time.Second * 5
+
+// blocking returns job run which signals started and blocks until release is
+// closed or job is canceled
+func blocking(started chan<- string, name string, release <-chan struct{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		started <- name
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func newQueue(t *testing.T, elector leader.Elector) *queue.Queue {
+	q := queue.New(elector)
+	q.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		require.NoError(t, q.Close(ctx))
+	})
+
+	return q
+}
+
+func wait(t *testing.T, j *queue.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	select {
+	case <-j.Done():
+		return j.Err()
+	case <-ctx.Done():
+		require.FailNow(t, "job did not finish", j.Name())
+		return nil
+	}
+}
+
+func TestQueueSerializes(t *testing.T) {
+	q := newQueue(t, leader.NewSingle("first"))
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	// first job finishes its current step although it is superseded
+	first, _, err := q.Submit("first", "a.me", func(ctx context.Context) error {
+		started <- "first"
+		<-release
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+	require.Equal(t, "first", <-started)
+
+	second, _, err := q.Submit("second", "b.me", blocking(started, "second", nil))
+	require.NoError(t, err)
+	require.Equal(t, 1, q.Status().Depth)
+	require.Equal(t, "second", q.Status().Pending[0].Name)
+	require.True(t, q.Status().Pending[0].StartedAt.IsZero())
+
+	time.Sleep(time.Millisecond * 50)
+	require.Empty(t, started)
+
+	close(release)
+	require.ErrorIs(t, wait(t, first), queue.ErrSuperseded)
+	require.Equal(t, "second", <-started)
+	require.Equal(t, "second", q.Status().Current.Name)
+
+	// superseding job is canceled in turn, it must not outlive the test
+	third, _, err := q.Submit("third", "c.me", func(ctx context.Context) error {
+		return nil
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, wait(t, second), queue.ErrSuperseded)
+	require.NoError(t, wait(t, third))
+}
+
+func TestQueueCoalescesAndSupersedes(t *testing.T) {
+	q := newQueue(t, leader.NewSingle("first"))
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	first, _, err := q.Submit("first", "a.me", blocking(started, "first", release))
+	require.NoError(t, err)
+	require.Equal(t, "first", <-started)
+
+	// the same target as running job
+	same, coalesced, err := q.Submit("same", "a.me", blocking(started, "same", release))
+	require.NoError(t, err)
+	require.True(t, coalesced)
+	require.Same(t, first, same)
+
+	status := q.Status()
+	require.Equal(t, 0, status.Depth)
+	require.Equal(t, "first", status.Current.Name)
+	require.False(t, status.Current.StartedAt.IsZero())
+
+	// other target cancels running job
+	second, coalesced, err := q.Submit("second", "b.me", blocking(started, "second", release))
+	require.NoError(t, err)
+	require.False(t, coalesced)
+	require.ErrorIs(t, wait(t, first), queue.ErrSuperseded)
+	require.Equal(t, "second", <-started)
+
+	// queued job for third target is superseded by fourth before it starts,
+	// fifth for the fourth target is coalesced with it
+	third, _, err := q.Submit("third", "c.me", blocking(started, "third", release))
+	require.NoError(t, err)
+	fourth, _, err := q.Submit("fourth", "d.me", blocking(started, "fourth", release))
+	require.NoError(t, err)
+	fifth, coalesced, err := q.Submit("fifth", "d.me", blocking(started, "fifth", release))
+	require.NoError(t, err)
+	require.True(t, coalesced)
+	require.Same(t, fourth, fifth)
+	require.ErrorIs(t, wait(t, third), queue.ErrSuperseded)
+	require.ErrorIs(t, wait(t, second), queue.ErrSuperseded)
+
+	require.Equal(t, "fourth", <-started)
+	status = q.Status()
+	require.Equal(t, 0, status.Depth)
+	require.Equal(t, "fourth", status.Current.Name)
+
+	close(release)
+	require.NoError(t, wait(t, fourth))
+	require.Empty(t, started)
+	require.Nil(t, q.Status().Current)
+}
+
+// dequeueElector is leader which signals dequeuing once queue asks for
+// leadership of job it starts and stalls the dequeue for a while
+type dequeueElector struct {
+	leader.Elector
+	dequeuing chan struct{}
+	once      sync.Once
+}
+
+func (d *dequeueElector) Leading() context.Context {
+	d.once.Do(func() {
+		close(d.dequeuing)
+		time.Sleep(time.Millisecond * 100)
+	})
+
+	return d.Elector.Leading()
+}
+
+func TestQueueSubmitWhileDequeuing(t *testing.T) {
+	elector := &dequeueElector{
+		Elector:   leader.NewSingle("first"),
+		dequeuing: make(chan struct{}),
+	}
+	q := newQueue(t, elector)
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	first, _, err := q.Submit("first", "a.me", blocking(started, "first", release))
+	require.NoError(t, err)
+	<-elector.dequeuing
+
+	// job being dequeued is already current for submit racing with it
+	same, coalesced, err := q.Submit("same", "a.me", blocking(started, "same", release))
+	require.NoError(t, err)
+	require.True(t, coalesced)
+	require.Same(t, first, same)
+	require.Equal(t, "first", <-started)
+
+	second, _, err := q.Submit("second", "b.me", blocking(started, "second", release))
+	require.NoError(t, err)
+	require.ErrorIs(t, wait(t, first), queue.ErrSuperseded)
+	require.Equal(t, "second", <-started)
+
+	close(release)
+	require.NoError(t, wait(t, second))
+	require.Empty(t, started)
+}
+
+func TestQueueNotLeader(t *testing.T) {
+	elector, err := leader.NewFileElector(t.TempDir()+"/leader.lock", "first", 0)
+	require.NoError(t, err)
+	q := newQueue(t, elector)
+
+	j, _, err := q.Submit("job", "target", func(ctx context.Context) error {
+		require.FailNow(t, "job of not leader must not run")
+		return nil
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, wait(t, j), queue.ErrNotLeader)
+}
+
+func TestQueueLeadershipLost(t *testing.T) {
+	elector, err := leader.NewFileElector(
+		filepath.Join(t.TempDir(), "leader.lock"), "first", time.Millisecond*10,
+	)
+	require.NoError(t, err)
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	defer stopElection()
+	electionDone := make(chan struct{})
+	go func() {
+		defer close(electionDone)
+		elector.Run(electionCtx)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	require.NoError(t, elector.WaitLeader(ctx))
+
+	q := newQueue(t, elector)
+	started := make(chan string, 1)
+	j, _, err := q.Submit("job", "target", blocking(started, "job", nil))
+	require.NoError(t, err)
+	require.Equal(t, "job", <-started)
+
+	// running job is canceled once lease is released
+	stopElection()
+	<-electionDone
+	require.ErrorIs(t, wait(t, j), queue.ErrNotLeader)
+	require.Nil(t, q.Status().Current)
+}
+
+func TestQueueClose(t *testing.T) {
+	q := queue.New(leader.NewSingle("first"))
+	q.Start()
+	started := make(chan string, 1)
+	release := make(chan struct{})
+
+	j, _, err := q.Submit("job", "target", blocking(started, "job", release))
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	// running job is not canceled on close
+	require.Error(t, q.Close(ctx))
+	_, _, err = q.Submit("job", "other", blocking(started, "job", release))
+	require.ErrorIs(t, err, queue.ErrClosed)
+
+	close(release)
+	require.NoError(t, wait(t, j))
+	require.NoError(t, q.Close(context.Background()))
+}
@@ -2,39 +2,158 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"os"
 	"os/signal"
 	_ "prem-gateway/dns/docs"
 	"prem-gateway/dns/internal/config"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
+	dnsprovider "prem-gateway/dns/internal/infrastructure/dns-provider"
+	dnsresolver "prem-gateway/dns/internal/infrastructure/dns-resolver"
+	httpclients "prem-gateway/dns/internal/infrastructure/http-clients"
+	ipprovider "prem-gateway/dns/internal/infrastructure/ip-provider"
+	boltdb "prem-gateway/dns/internal/infrastructure/storage/bolt"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
 	pgdb "prem-gateway/dns/internal/infrastructure/storage/pg"
+	dnsserver "prem-gateway/dns/internal/interface/dns"
 	dnsdhttp "prem-gateway/dns/internal/interface/http"
 	"syscall"
 )
 
 // @title Dns Daemon API
+// @version 1.0
 // @description     DNS Daemon is designed to manage Domain Name System (DNS) records. <br />It exposes a RESTful API that allows for the creation, modification, retrieval, and deletion of DNS information, as well as checking the status of a DNS entry. <br /> The DNS information includes attributes such as domain, subdomain, A records, and node names.
 func main() {
-	if err := config.LoadConfig(); err != nil {
+	if err := config.LoadConfig(os.Args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			os.Exit(0)
+		}
+
 		log.Fatalf("failed to load config: %s", err)
 	}
 
-	svc, err := pgdb.NewDBService(pgdb.DbConfig{
-		DbUser:             config.GetString(config.DbUserKey),
-		DbPassword:         config.GetString(config.DbPassKey),
-		DbHost:             config.GetString(config.DbHostKey),
-		DbPort:             config.GetInt(config.DbPortKey),
-		DbName:             config.GetString(config.DbNameKey),
-		MigrationSourceURL: config.GetString(config.DbMigrationPathKey),
+	svc, err := newRepositoryService(config.GetString(config.DbTypeKey))
+	if err != nil {
+		log.Fatalf("failed to create db service: %s", err)
+	}
+
+	ipProviders, err := ipprovider.FromNames(
+		config.GetStringSlice(config.IpProvidersKey),
+		ipprovider.Config{
+			StaticIp:    config.GetString(config.StaticIpKey),
+			EchoUrls:    config.GetStringSlice(config.IpEchoUrlsKey),
+			StunServers: config.GetStringSlice(config.IpStunServersKey),
+		},
+	)
+	if err != nil {
+		log.Fatalf("failed to create ip providers: %s", err)
+	}
+
+	ipSvc := httpclients.NewIpService(
+		ipProviders,
+		config.GetDuration(config.IpProviderTimeoutKey),
+		config.GetDuration(config.IpCacheTTLKey),
+	)
+
+	dnsProvider, err := dnsprovider.FromConfig(dnsprovider.Config{
+		Provider:             config.GetString(config.DnsProviderKey),
+		Zone:                 config.GetString(config.DnsProviderZoneKey),
+		Ttl:                  config.GetInt(config.DnsProviderTtlKey),
+		Rfc2136Server:        config.GetString(config.Rfc2136ServerKey),
+		Rfc2136TsigKey:       config.GetString(config.Rfc2136TsigKeyKey),
+		Rfc2136TsigSecret:    config.GetString(config.Rfc2136TsigSecretKey),
+		Rfc2136TsigAlgorithm: config.GetString(config.Rfc2136TsigAlgorithmKey),
+		CloudflareApiToken:   config.GetString(config.CloudflareApiTokenKey),
+		DigitalOceanApiToken: config.GetString(config.DigitalOceanApiTokenKey),
 	})
 	if err != nil {
-		log.Fatalf("failed to create pgdb service: %s", err)
+		log.Fatalf("failed to create dns provider: %s", err)
+	}
+
+	if config.GetString(config.ControllerDaemonSecretKey) == "" {
+		log.Warnf(
+			"%v not set, controllerd will reject notifications and audit events are not accepted",
+			config.ControllerDaemonSecretKey,
+		)
+	}
+
+	opts := []dnsdhttp.ServerOption{
+		dnsdhttp.WithIpService(ipSvc),
+		dnsdhttp.WithDnsProvider(dnsProvider),
+		dnsdhttp.WithNotificationDispatchInterval(
+			config.GetDuration(config.NotificationDispatchIntervalKey),
+		),
+		dnsdhttp.WithEffectiveConfig(config.Effective()),
+		dnsdhttp.WithPreflight(
+			dnsresolver.NewResolver(
+				config.GetStringSlice(config.PreflightResolversKey), 0,
+			),
+			config.GetString(config.PreflightCaaIssuerKey),
+		),
+		dnsdhttp.WithControllerdSecret(
+			config.GetString(config.ControllerDaemonSecretKey),
+		),
+		dnsdhttp.WithCors(
+			config.GetCorsConfig(),
+			config.GetBool(config.CorsAllowProvisionedDomainKey),
+		),
+	}
+	if config.GetBool(config.DynamicDnsEnabledKey) {
+		opts = append(opts, dnsdhttp.WithDynamicDns(
+			config.GetDuration(config.DynamicDnsIntervalKey),
+		))
+	}
+	if secret := config.GetString(config.AuthdSecretKey); secret != "" {
+		opts = append(opts, dnsdhttp.WithAuthdSecret(secret))
+	} else {
+		log.Warnf(
+			"%v not set, actor of requests is recorded as anonymous",
+			config.AuthdSecretKey,
+		)
+	}
+	var authDns dnsserver.Server
+	if config.GetBool(config.AuthDnsEnabledKey) {
+		zoneSvc, err := application.NewZoneService(
+			svc,
+			config.GetString(config.AuthDnsNsNameKey),
+			config.GetString(config.AuthDnsCaaIssuerKey),
+		)
+		if err != nil {
+			log.Fatalf("failed to create zone service: %s", err)
+		}
+
+		authDns = dnsserver.NewServer(
+			config.GetString(config.AuthDnsAddressKey),
+			config.GetInt(config.AuthDnsTtlKey),
+			zoneSvc,
+		)
+		opts = append(opts, dnsdhttp.WithZoneService(zoneSvc))
+		if secret := config.GetString(config.AuthDnsAcmeSecretKey); secret != "" {
+			opts = append(opts, dnsdhttp.WithAcmeSecret(secret))
+		} else {
+			log.Warnf(
+				"%v not set, ACME DNS-01 endpoints are disabled",
+				config.AuthDnsAcmeSecretKey,
+			)
+		}
+	}
+	if !config.GetBool(config.ReachabilityCheckEnabledKey) {
+		log.Info(
+			"reachability check disabled, set PREM_GATEWAY_DNS_REACHABILITY_CHECK_ENABLED=true " +
+				"to enable it if router supports hairpin NAT",
+		)
+		opts = append(opts, dnsdhttp.WithReachabilityChecker(nil))
 	}
 
 	premgd, err := dnsdhttp.NewServer(
 		config.GetServerAddress(),
 		svc,
 		config.GetString(config.ControllerDaemonUrlKey),
+		opts...,
 	)
 	if err != nil {
 		log.Errorf("failed to create prem-gateway dns daemon: %s", err)
@@ -45,8 +164,50 @@ func main() {
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
+	if authDns != nil {
+		dnsErrC := authDns.Start(ctx)
+		go func() {
+			if err := <-dnsErrC; err != nil {
+				log.Errorf("authoritative dns server failed: %s", err)
+				stop()
+			}
+		}()
+	}
+
 	errC := premgd.Start(ctx, stop)
 	if err := <-errC; err != nil {
 		log.Panicf("prem-gateway dns daemon noticed error while running: %s", err)
 	}
 }
+
+func newRepositoryService(dbType string) (domain.RepositoryService, error) {
+	switch dbType {
+	case config.DbTypePostgres:
+		return pgdb.NewDBService(pgdb.DbConfig{
+			Url:                 config.GetString(config.DbUrlKey),
+			DbUser:              config.GetString(config.DbUserKey),
+			DbPassword:          config.GetString(config.DbPassKey),
+			DbHost:              config.GetString(config.DbHostKey),
+			DbPort:              config.GetInt(config.DbPortKey),
+			DbName:              config.GetString(config.DbNameKey),
+			SslMode:             config.GetString(config.DbSslModeKey),
+			SslRootCert:         config.GetString(config.DbSslRootCertKey),
+			SslCert:             config.GetString(config.DbSslCertKey),
+			SslKey:              config.GetString(config.DbSslKeyKey),
+			MigrationSourceURL:  config.GetString(config.DbMigrationPathKey),
+			MaxConns:            int32(config.GetInt(config.DbMaxConnsKey)),
+			MinConns:            int32(config.GetInt(config.DbMinConnsKey)),
+			ConnectTimeout:      config.GetDuration(config.DbConnectTimeoutKey),
+			ConnectRetryTimeout: config.GetDuration(config.DbConnectRetryTimeoutKey),
+		})
+	case config.DbTypeBolt:
+		return boltdb.NewDBService(boltdb.DbConfig{
+			Datadir: config.GetString(config.DatadirKey),
+		})
+	case config.DbTypeInMemory:
+		log.Warn("in memory storage used, state is lost on restart")
+		return inmemory.NewDBService(), nil
+	default:
+		return nil, fmt.Errorf("unknown db type: %v", dbType)
+	}
+}
@@ -16,6 +16,342 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/prem-gateway/{nonce}": {
+            "get": {
+                "description": "This endpoint is fetched by dnsd itself, through the provisioned domain, to prove that ports 80/443 reach this gateway",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Serves one-time reachability token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Challenge nonce",
+                        "name": "nonce",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Returns the token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Returns error message for unknown or expired nonce",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/acme/cleanup": {
+            "post": {
+                "description": "This endpoint removes TXT record served by built-in authoritative DNS server. It is served only if authoritative DNS server is enabled and requires basic auth of user acme and AUTH_DNS_ACME_SECRET",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acme"
+                ],
+                "summary": "Removes ACME DNS-01 challenge TXT record",
+                "parameters": [
+                    {
+                        "description": "challenge record",
+                        "name": "TxtRecord",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.TxtRecord"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Returns error message for missing or invalid basic auth",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/acme/present": {
+            "post": {
+                "description": "This endpoint adds TXT record served by built-in authoritative DNS server. It is served only if authoritative DNS server is enabled and requires basic auth of user acme and AUTH_DNS_ACME_SECRET",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acme"
+                ],
+                "summary": "Adds ACME DNS-01 challenge TXT record",
+                "parameters": [
+                    {
+                        "description": "challenge record",
+                        "name": "TxtRecord",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.TxtRecord"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Returns error message for missing or invalid basic auth",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config": {
+            "get": {
+                "description": "This endpoint retrieves configuration dnsd runs with, after defaults, config file, env vars and flags are applied, secrets are redacted",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Retrieves effective configuration",
+                "responses": {
+                    "200": {
+                        "description": "Returns configuration by key",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Returns error message when caller is not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit": {
+            "get": {
+                "description": "This endpoint retrieves audit events, oldest first, as JSON array or as JSON Lines export if format=jsonl or Accept is application/x-ndjson",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Retrieves audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC3339 time, events created at or after",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 time, events created before",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Actions to include, repeated or comma separated",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of events",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json(default) or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Returns audit events",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httphandler.AuditEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Returns error message for invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Returns error message when caller is not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Returns error message when storage is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "This endpoint is used by other gateway daemons, eg. controllerd, to record changes they made. Request must be signed with secret shared with dnsd (X-Prem-Timestamp, X-Prem-Nonce and X-Prem-Signature headers), actor is taken from X-Auth-Actor header",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Records audit event",
+                "parameters": [
+                    {
+                        "description": "audit event",
+                        "name": "AuditEventRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.AuditEventRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Returns error message for malformed request",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Returns error message when request is not signed",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Returns error message when storage is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/dns": {
             "post": {
                 "description": "This endpoint creates a new DNS record based on the provided information",
@@ -41,26 +377,163 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/httphandler.SuccessResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Returns error message for malformed request or invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Returns error message when domain already exists",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Returns error message when A record or reachability check fails",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Returns error message when dns provider, resolver or controllerd fails",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Returns error message when storage or controllerd is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dns/check": {
+            "get": {
+                "description": "This endpoint checks if the service is up and running",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Check if the service is up and running",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/dns/existing": {
+            "get": {
+                "description": "This endpoint retrieves the existing DNS record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Retrieves the existing DNS record",
+                "responses": {
+                    "200": {
+                        "description": "Returns the existing DNS record",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.DnsInfo"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dns/ip": {
+            "get": {
+                "description": "This endpoint retrieves the public IP address of the Gateway",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Retrieves the IP address of the Gateway",
+                "responses": {
+                    "200": {
+                        "description": "Returns IP address of the Gateway",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Returns error message when no ip provider succeeded",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dns/ip/details": {
+            "get": {
+                "description": "This endpoint retrieves the public IP address of the Gateway together with the provider it was discovered by",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Retrieves the IP address of the Gateway with its source",
+                "responses": {
+                    "200": {
+                        "description": "Returns IP address of the Gateway",
                         "schema": {
-                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                            "$ref": "#/definitions/httphandler.GatewayIp"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "500": {
+                        "description": "Returns error message for server error",
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "502": {
+                        "description": "Returns error message when no ip provider succeeded",
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
@@ -68,9 +541,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/dns/check": {
+        "/dns/ip/history": {
             "get": {
-                "description": "This endpoint checks if the service is up and running",
+                "description": "This endpoint retrieves IP changes of the existing domain detected by dynamic DNS, newest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -80,17 +553,43 @@ const docTemplate = `{
                 "tags": [
                     "dns"
                 ],
-                "summary": "Check if the service is up and running",
+                "summary": "Retrieves history of gateway IP changes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Max number of events, defaults to 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "Returns IP change events",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httphandler.IpChangeEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Returns error message for invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/dns/existing": {
+        "/dns/notifications": {
             "get": {
-                "description": "This endpoint retrieves the existing DNS record",
+                "description": "This endpoint retrieves notifications waiting in outbox, oldest first, together with number of failed attempts and last error. Notification which failed 10 times or which controllerd can not handle is dead, it has dead_at set and is not retried",
                 "consumes": [
                     "application/json"
                 ],
@@ -100,12 +599,15 @@ const docTemplate = `{
                 "tags": [
                     "dns"
                 ],
-                "summary": "Retrieves the existing DNS record",
+                "summary": "Retrieves controllerd notifications not yet delivered",
                 "responses": {
                     "200": {
-                        "description": "Returns the existing DNS record",
+                        "description": "Returns pending notifications",
                         "schema": {
-                            "$ref": "#/definitions/httphandler.DnsInfo"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httphandler.Notification"
+                            }
                         }
                     },
                     "500": {
@@ -113,13 +615,19 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Returns error message when storage is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/dns/ip": {
+        "/dns/preflight/{domain}": {
             "get": {
-                "description": "This endpoint retrieves the IP address of the Gateway",
+                "description": "This endpoint checks A/AAAA/wildcard records, CAA, DNSSEC, conflicting records and TTLs and returns checklist of pass/warn/fail items with remediation",
                 "consumes": [
                     "application/json"
                 ],
@@ -129,12 +637,27 @@ const docTemplate = `{
                 "tags": [
                     "dns"
                 ],
-                "summary": "Retrieves the IP address of the Gateway",
+                "summary": "Checks public DNS of the domain before certificate is requested",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domain name",
+                        "name": "domain",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Returns IP address of the Gateway",
+                        "description": "Returns preflight report",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/httphandler.PreflightReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Returns error message for invalid domain",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
                     },
                     "500": {
@@ -142,6 +665,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Returns error message when gateway ip can not be discovered",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -170,7 +699,7 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Returns true if the DNS record is valid, false otherwise",
+                        "description": "Returns true if the DNS record is valid",
                         "schema": {
                             "type": "boolean"
                         }
@@ -182,7 +711,13 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Returns error message for record not found",
+                        "description": "Returns error message for unknown domain",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Returns error message when A record does not point to the gateway",
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
@@ -192,6 +727,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Returns error message for dns lookup failure",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -284,15 +825,166 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/httphandler.ErrorResponse"
                         }
+                    },
+                    "502": {
+                        "description": "Returns error message when dns provider fails",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dns/{domain}/acme-ca": {
+            "put": {
+                "description": "This endpoint switches domain between Let's Encrypt staging and production or custom ACME directory. If CA changed controllerd is notified to reconfigure Traefik and to drop certificates issued by previous CA",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dns"
+                ],
+                "summary": "Selects ACME CA of domain certificates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domain Name",
+                        "name": "domain",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ACME CA",
+                        "name": "AcmeCaRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.AcmeCaRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Returns the updated DNS record",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.DnsInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Returns error message for malformed request or invalid fields",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Returns error message for record not found",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Returns error message for server error",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Returns error message when storage is unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httphandler.ErrorResponse"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "httphandler.AcmeCaRequest": {
+            "type": "object",
+            "properties": {
+                "acme_ca": {
+                    "description": "AcmeCa is staging, production or https url of custom ACME directory,\nempty leaves CA to LETSENCRYPT_PROD of controllerd",
+                    "type": "string",
+                    "example": "production"
+                }
+            }
+        },
+        "httphandler.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                }
+            }
+        },
+        "httphandler.AuditEventRequest": {
+            "type": "object",
+            "required": [
+                "action",
+                "outcome"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "outcome": {
+                    "type": "string",
+                    "enum": [
+                        "success",
+                        "failure"
+                    ]
+                },
+                "resource": {
+                    "type": "string"
+                }
+            }
+        },
         "httphandler.DnsInfo": {
             "type": "object",
             "properties": {
+                "acme_ca": {
+                    "description": "AcmeCa is staging, production or https url of custom ACME directory,\nempty leaves CA to LETSENCRYPT_PROD of controllerd",
+                    "type": "string",
+                    "example": "production"
+                },
                 "domain": {
                     "type": "string"
                 },
@@ -310,8 +1002,154 @@ const docTemplate = `{
         "httphandler.ErrorResponse": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
                 "error": {
                     "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httphandler.FieldError"
+                    }
+                }
+            }
+        },
+        "httphandler.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "httphandler.GatewayIp": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "httphandler.IpChangeEvent": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "new_ip": {
+                    "type": "string"
+                },
+                "old_ip": {
+                    "type": "string"
+                },
+                "provider_error": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "httphandler.Notification": {
+            "type": "object",
+            "properties": {
+                "acme_ca": {
+                    "type": "string"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "dead_at": {
+                    "description": "DeadAt is set once delivery is given up after max attempts or for\nnotification controllerd can not handle, it is not retried anymore",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "httphandler.PreflightCheck": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "remediation": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pass",
+                        "warn",
+                        "fail"
+                    ]
+                }
+            }
+        },
+        "httphandler.PreflightReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httphandler.PreflightCheck"
+                    }
+                },
+                "created_at": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "expected_ip": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pass",
+                        "warn",
+                        "fail"
+                    ]
                 }
             }
         },
@@ -322,13 +1160,28 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "httphandler.TxtRecord": {
+            "type": "object",
+            "required": [
+                "fqdn",
+                "value"
+            ],
+            "properties": {
+                "fqdn": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
-	Version:          "",
+	Version:          "1.0",
 	Host:             "",
 	BasePath:         "",
 	Schemes:          []string{},
@@ -336,6 +1189,8 @@ var SwaggerInfo = &swag.Spec{
 	Description:      "DNS Daemon is designed to manage Domain Name System (DNS) records. <br />It exposes a RESTful API that allows for the creation, modification, retrieval, and deletion of DNS information, as well as checking the status of a DNS entry. <br /> The DNS information includes attributes such as domain, subdomain, A records, and node names.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
+	LeftDelim:        "{{",
+	RightDelim:       "}}",
 }
 
 func init() {
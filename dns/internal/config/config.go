@@ -1,9 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"github.com/btcsuite/btcd/btcutil"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"net/url"
+	"os"
+	"prem-gateway/pkg/cors"
+	"strings"
+	"time"
 )
 
 const (
@@ -13,6 +20,8 @@ const (
 	LogLevelKey = "LOG_LEVEL"
 	// DatadirKey is the local data directory to store the internal state of daemon
 	DatadirKey = "DATADIR"
+	// DbTypeKey is storage backend used by dnsd(postgres, bolt, inmemory)
+	DbTypeKey = "DB_TYPE"
 	// DbUserKey is the user name to connect to the database
 	DbUserKey = "DB_USER"
 	// DbPassKey is the password to connect to the database
@@ -23,35 +32,351 @@ const (
 	DbPortKey = "DB_PORT"
 	// DbNameKey is the name of the database
 	DbNameKey = "DB_NAME"
+	// DbUrlKey is full database connection url, it takes precedence over
+	// other connection and TLS settings, DATABASE_URL is read too
+	DbUrlKey = "DB_URL"
+	// DbSslModeKey is libpq sslmode(disable, require, verify-ca, verify-full)
+	DbSslModeKey = "DB_SSL_MODE"
+	// DbSslRootCertKey is path to CA certificate used to verify database
+	DbSslRootCertKey = "DB_SSL_ROOT_CERT"
+	// DbSslCertKey is path to client certificate
+	DbSslCertKey = "DB_SSL_CERT"
+	// DbSslKeyKey is path to client certificate key
+	DbSslKeyKey = "DB_SSL_KEY"
+	// DbMaxConnsKey is max size of connection pool, pgx default if 0
+	DbMaxConnsKey = "DB_MAX_CONNS"
+	// DbMinConnsKey is number of connections kept open
+	DbMinConnsKey = "DB_MIN_CONNS"
+	// DbConnectTimeoutKey limits single connection attempt
+	DbConnectTimeoutKey = "DB_CONNECT_TIMEOUT"
+	// DbConnectRetryTimeoutKey is for how long connecting to database is
+	// retried on startup, 0 disables retry
+	DbConnectRetryTimeoutKey = "DB_CONNECT_RETRY_TIMEOUT"
 	// DbMigrationPathKey is the path to the database migration files
 	DbMigrationPathKey     = "DB_MIGRATION_PATH"
 	ControllerDaemonUrlKey = "CONTROLLER_DAEMON_URL"
+	// ControllerDaemonSecretKey is secret shared with controllerd used to
+	// sign requests to it, controllerd rejects unsigned requests
+	ControllerDaemonSecretKey = "CONTROLLER_DAEMON_SECRET"
+	// AuthdSecretKey is secret authd signs X-Auth-Actor header with, actor
+	// of requests is recorded as anonymous if it is not set
+	AuthdSecretKey = "AUTHD_SECRET"
+	// IpProvidersKey is comma separated, ordered list of providers used to
+	// discover public ip of the gateway(static, http, stun, interface)
+	IpProvidersKey = "IP_PROVIDERS"
+	// StaticIpKey is public ip of the gateway used by static ip provider
+	StaticIpKey = "STATIC_IP"
+	// IpEchoUrlsKey is comma separated list of http echo services
+	IpEchoUrlsKey = "IP_ECHO_URLS"
+	// IpStunServersKey is comma separated list of stun servers(host:port)
+	IpStunServersKey = "IP_STUN_SERVERS"
+	// IpProviderTimeoutKey is timeout applied to each ip provider
+	IpProviderTimeoutKey = "IP_PROVIDER_TIMEOUT"
+	// IpCacheTTLKey is for how long discovered ip is cached
+	IpCacheTTLKey = "IP_CACHE_TTL"
+	// ReachabilityCheckEnabledKey enables check, before provisioning, that
+	// domain is served by this gateway, disabled by default
+	ReachabilityCheckEnabledKey = "REACHABILITY_CHECK_ENABLED"
+	// DnsProviderKey is provider used to manage domain records(none,
+	// rfc2136, cloudflare, digitalocean)
+	DnsProviderKey = "DNS_PROVIDER"
+	// DnsProviderZoneKey is zone hosting the domain, discovered if not set
+	DnsProviderZoneKey = "DNS_PROVIDER_ZONE"
+	// DnsProviderTtlKey is ttl of records created by dns provider
+	DnsProviderTtlKey = "DNS_PROVIDER_TTL"
+	// Rfc2136ServerKey is address(host:port) of server accepting updates
+	Rfc2136ServerKey = "RFC2136_SERVER"
+	// Rfc2136TsigKeyKey is name of the TSIG key used to sign updates
+	Rfc2136TsigKeyKey = "RFC2136_TSIG_KEY"
+	// Rfc2136TsigSecretKey is base64 encoded TSIG secret
+	Rfc2136TsigSecretKey = "RFC2136_TSIG_SECRET"
+	// Rfc2136TsigAlgorithmKey is TSIG algorithm, eg. hmac-sha256
+	Rfc2136TsigAlgorithmKey = "RFC2136_TSIG_ALGORITHM"
+	// CloudflareApiTokenKey is Cloudflare API token with DNS edit permission
+	CloudflareApiTokenKey = "CLOUDFLARE_API_TOKEN"
+	// DigitalOceanApiTokenKey is DigitalOcean API token with write scope
+	DigitalOceanApiTokenKey = "DIGITALOCEAN_API_TOKEN"
+	// DynamicDnsEnabledKey enables periodic check of gateway public ip
+	DynamicDnsEnabledKey = "DYNAMIC_DNS_ENABLED"
+	// DynamicDnsIntervalKey is interval of gateway public ip check
+	DynamicDnsIntervalKey = "DYNAMIC_DNS_INTERVAL"
+	// AuthDnsEnabledKey enables built-in authoritative dns server
+	AuthDnsEnabledKey = "AUTH_DNS_ENABLED"
+	// AuthDnsAddressKey is address on which authoritative dns server listens
+	// for both udp and tcp
+	AuthDnsAddressKey = "AUTH_DNS_ADDRESS"
+	// AuthDnsNsNameKey is name server advertised in NS/SOA records, defaults
+	// to ns1.<domain>
+	AuthDnsNsNameKey = "AUTH_DNS_NS_NAME"
+	// AuthDnsCaaIssuerKey is CA allowed to issue certificates in CAA records
+	AuthDnsCaaIssuerKey = "AUTH_DNS_CAA_ISSUER"
+	// AuthDnsAcmeSecretKey is password of ACME endpoints, they are disabled
+	// if it is not set
+	AuthDnsAcmeSecretKey = "AUTH_DNS_ACME_SECRET"
+	// AuthDnsTtlKey is ttl of records served by authoritative dns server
+	AuthDnsTtlKey = "AUTH_DNS_TTL"
+	// NotificationDispatchIntervalKey is how often undelivered controllerd
+	// notifications are retried
+	NotificationDispatchIntervalKey = "NOTIFICATION_DISPATCH_INTERVAL"
+	// PreflightResolversKey is comma separated list of recursive resolvers
+	// (host:port) used by preflight checks, resolv.conf ones if empty
+	PreflightResolversKey = "PREFLIGHT_RESOLVERS"
+	// PreflightCaaIssuerKey is CA which CAA records of the domain must allow
+	// if domain leaves ACME CA to controllerd
+	PreflightCaaIssuerKey = "PREFLIGHT_CAA_ISSUER"
+	// CorsAllowedOriginsKey is comma separated list of origins allowed to
+	// call dnsd from browser, eg. https://*.example.com, * allows any
+	CorsAllowedOriginsKey = "CORS_ALLOWED_ORIGINS"
+	// CorsAllowProvisionedDomainKey allows origins of provisioned domain
+	// and its subdomains
+	CorsAllowProvisionedDomainKey = "CORS_ALLOW_PROVISIONED_DOMAIN"
+	// CorsAllowedHeadersKey is comma separated list of request headers
+	// allowed in CORS requests
+	CorsAllowedHeadersKey = "CORS_ALLOWED_HEADERS"
+	// CorsExposedHeadersKey is comma separated list of response headers
+	// exposed to browser
+	CorsExposedHeadersKey = "CORS_EXPOSED_HEADERS"
+	// CorsAllowCredentialsKey allows cookies and Authorization in CORS
+	// requests, it can not be combined with * origin
+	CorsAllowCredentialsKey = "CORS_ALLOW_CREDENTIALS"
+	// CorsMaxAgeKey is for how long browser caches preflight response
+	CorsMaxAgeKey = "CORS_MAX_AGE"
 )
 
+const (
+	DbTypePostgres = "postgres"
+	DbTypeBolt     = "bolt"
+	DbTypeInMemory = "inmemory"
+)
+
+const (
+	// ConfigFileFlag is path to YAML, TOML or JSON config file, keys are the
+	// same as env vars without prefix, eg. db_host
+	ConfigFileFlag = "config"
+	// ConfigFileKey is env var alternative to config flag
+	ConfigFileKey = "CONFIG_FILE"
+
+	envPrefix = "PREM_GATEWAY_DNS"
+	redacted  = "******"
+)
+
+// setting is exposed as command line flag, named after key in lower case
+// with dashes, eg. --db-host
+type setting struct {
+	key    string
+	usage  string
+	secret bool
+}
+
 var (
-	vip *viper.Viper
+	vip        *viper.Viper
+	configFile string
+
+	settings = []setting{
+		{key: PortKey, usage: "port on which http server listens"},
+		{key: LogLevelKey, usage: "log level, name(info, debug...) or number 0-6"},
+		{key: DatadirKey, usage: "data directory, created if missing"},
+		{key: DbTypeKey, usage: "storage backend(postgres, bolt, inmemory)"},
+		{key: DbUrlKey, usage: "postgres connection url, overrides other db connection settings", secret: true},
+		{key: DbUserKey, usage: "postgres user"},
+		{key: DbPassKey, usage: "postgres password", secret: true},
+		{key: DbHostKey, usage: "postgres host"},
+		{key: DbPortKey, usage: "postgres port"},
+		{key: DbNameKey, usage: "postgres database"},
+		{key: DbSslModeKey, usage: "postgres sslmode"},
+		{key: DbSslRootCertKey, usage: "CA certificate used to verify postgres"},
+		{key: DbSslCertKey, usage: "postgres client certificate"},
+		{key: DbSslKeyKey, usage: "postgres client certificate key"},
+		{key: DbMaxConnsKey, usage: "max size of postgres connection pool"},
+		{key: DbMinConnsKey, usage: "min size of postgres connection pool"},
+		{key: DbConnectTimeoutKey, usage: "postgres connection attempt timeout"},
+		{key: DbConnectRetryTimeoutKey, usage: "for how long postgres connection is retried on startup"},
+		{key: DbMigrationPathKey, usage: "postgres migrations source url"},
+		{key: ControllerDaemonUrlKey, usage: "controllerd url"},
+		{key: ControllerDaemonSecretKey, usage: "secret shared with controllerd to sign requests", secret: true},
+		{key: AuthdSecretKey, usage: "secret authd signs actor of requests with", secret: true},
+		{key: IpProvidersKey, usage: "ordered public ip providers(static, http, stun, interface)"},
+		{key: StaticIpKey, usage: "public ip used by static ip provider"},
+		{key: IpEchoUrlsKey, usage: "http ip echo services"},
+		{key: IpStunServersKey, usage: "stun servers(host:port)"},
+		{key: IpProviderTimeoutKey, usage: "timeout of single ip provider"},
+		{key: IpCacheTTLKey, usage: "for how long discovered ip is cached"},
+		{key: ReachabilityCheckEnabledKey, usage: "check domain is served by gateway before provisioning, needs hairpin NAT"},
+		{key: DnsProviderKey, usage: "dns provider(none, rfc2136, cloudflare, digitalocean)"},
+		{key: DnsProviderZoneKey, usage: "zone hosting the domain"},
+		{key: DnsProviderTtlKey, usage: "ttl of records created by dns provider"},
+		{key: Rfc2136ServerKey, usage: "rfc2136 server(host:port)"},
+		{key: Rfc2136TsigKeyKey, usage: "rfc2136 TSIG key name"},
+		{key: Rfc2136TsigSecretKey, usage: "rfc2136 TSIG secret", secret: true},
+		{key: Rfc2136TsigAlgorithmKey, usage: "rfc2136 TSIG algorithm"},
+		{key: CloudflareApiTokenKey, usage: "Cloudflare API token", secret: true},
+		{key: DigitalOceanApiTokenKey, usage: "DigitalOcean API token", secret: true},
+		{key: DynamicDnsEnabledKey, usage: "follow public ip changes"},
+		{key: DynamicDnsIntervalKey, usage: "public ip check interval"},
+		{key: AuthDnsEnabledKey, usage: "enable authoritative dns server"},
+		{key: AuthDnsAddressKey, usage: "authoritative dns server address"},
+		{key: AuthDnsNsNameKey, usage: "name server advertised in NS/SOA records"},
+		{key: AuthDnsCaaIssuerKey, usage: "CA allowed in served CAA records"},
+		{key: AuthDnsTtlKey, usage: "ttl of records served by authoritative dns server"},
+		{key: AuthDnsAcmeSecretKey, usage: "password of ACME DNS-01 endpoints", secret: true},
+		{key: NotificationDispatchIntervalKey, usage: "controllerd notification retry interval"},
+		{key: PreflightResolversKey, usage: "resolvers(host:port) used by preflight checks"},
+		{key: PreflightCaaIssuerKey, usage: "CA which CAA records must allow if domain has no ACME CA"},
+		{key: CorsAllowedOriginsKey, usage: "origins allowed in CORS requests"},
+		{key: CorsAllowProvisionedDomainKey, usage: "allow CORS requests from provisioned domain and its subdomains"},
+		{key: CorsAllowedHeadersKey, usage: "request headers allowed in CORS requests"},
+		{key: CorsExposedHeadersKey, usage: "response headers exposed in CORS requests"},
+		{key: CorsAllowCredentialsKey, usage: "allow credentials in CORS requests"},
+		{key: CorsMaxAgeKey, usage: "for how long CORS preflight response is cached"},
+	}
 )
 
-func LoadConfig() error {
+// LoadConfig layers, from lowest to highest precedence, defaults, config
+// file, PREM_GATEWAY_DNS_* env vars and command line flags, validates the
+// result and applies log level and data dir
+func LoadConfig(args []string) error {
 	vip = viper.New()
-	vip.SetEnvPrefix("PREM_GATEWAY_DNS")
+	vip.SetEnvPrefix(envPrefix)
 	vip.AutomaticEnv()
+	if err := vip.BindEnv(
+		DbUrlKey, envPrefix+"_"+DbUrlKey, "DATABASE_URL",
+	); err != nil {
+		return err
+	}
 	defaultDataDir := btcutil.AppDataDir("dnsd", false)
 
 	vip.SetDefault(PortKey, 8080)
 	vip.SetDefault(LogLevelKey, int(log.DebugLevel))
 	vip.SetDefault(DatadirKey, defaultDataDir)
+	vip.SetDefault(DbTypeKey, DbTypePostgres)
 	vip.SetDefault(DbUserKey, "root")
 	vip.SetDefault(DbPassKey, "secret")
 	vip.SetDefault(DbHostKey, "127.0.0.1")
 	vip.SetDefault(DbPortKey, 5432)
 	vip.SetDefault(DbNameKey, "dnsd-db")
+	vip.SetDefault(DbSslModeKey, "disable")
+	vip.SetDefault(DbMaxConnsKey, 0)
+	vip.SetDefault(DbMinConnsKey, 0)
+	vip.SetDefault(DbConnectTimeoutKey, "5s")
+	vip.SetDefault(DbConnectRetryTimeoutKey, "1m")
 	vip.SetDefault(DbMigrationPathKey, "file://dns/internal/infrastructure/storage/pg/migration")
 	vip.SetDefault(ControllerDaemonUrlKey, "http://controllerd:8080")
+	vip.SetDefault(IpProvidersKey, "static,http,stun,interface")
+	vip.SetDefault(IpEchoUrlsKey, "https://ifconfig.io,https://api.ipify.org,https://icanhazip.com")
+	vip.SetDefault(IpStunServersKey, "stun.l.google.com:19302,stun.cloudflare.com:3478")
+	vip.SetDefault(IpProviderTimeoutKey, "5s")
+	vip.SetDefault(IpCacheTTLKey, "5m")
+	// check needs hairpin NAT, box behind router without it can not reach
+	// its own public ip and would reject every domain
+	vip.SetDefault(ReachabilityCheckEnabledKey, false)
+	vip.SetDefault(DnsProviderKey, "none")
+	vip.SetDefault(DnsProviderTtlKey, 300)
+	vip.SetDefault(DynamicDnsEnabledKey, false)
+	vip.SetDefault(DynamicDnsIntervalKey, "5m")
+	vip.SetDefault(AuthDnsEnabledKey, false)
+	vip.SetDefault(AuthDnsAddressKey, ":53")
+	vip.SetDefault(AuthDnsCaaIssuerKey, "letsencrypt.org")
+	vip.SetDefault(AuthDnsTtlKey, 300)
+	vip.SetDefault(NotificationDispatchIntervalKey, "5s")
+	vip.SetDefault(PreflightCaaIssuerKey, "letsencrypt.org")
+	vip.SetDefault(CorsAllowedOriginsKey, "http://localhost:1420,http://localhost:8085")
+	vip.SetDefault(CorsAllowProvisionedDomainKey, true)
+	vip.SetDefault(CorsAllowedHeadersKey, "Content-Type,Authorization")
+	vip.SetDefault(CorsAllowCredentialsKey, false)
+	vip.SetDefault(CorsMaxAgeKey, "10m")
+
+	flags := pflag.NewFlagSet("dnsd", pflag.ContinueOnError)
+	flags.String(ConfigFileFlag, "", "path to YAML, TOML or JSON config file")
+	for _, v := range settings {
+		flags.String(flagName(v.key), "", v.usage)
+		if err := vip.BindPFlag(v.key, flags.Lookup(flagName(v.key))); err != nil {
+			return err
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	configFile, _ = flags.GetString(ConfigFileFlag)
+	if configFile == "" {
+		configFile = os.Getenv(envPrefix + "_" + ConfigFileKey)
+	}
+	if configFile != "" {
+		vip.SetConfigFile(configFile)
+		if err := vip.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %v: %w", configFile, err)
+		}
+	}
+
+	if err := validate(); err != nil {
+		return err
+	}
+
+	log.SetLevel(GetLogLevel())
+
+	if err := os.MkdirAll(GetString(DatadirKey), 0700); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
 
 	return nil
 }
 
+// GetLogLevel returns configured log level, it is valid after LoadConfig
+func GetLogLevel() log.Level {
+	level, _ := parseLogLevel(vip.Get(LogLevelKey))
+	return level
+}
+
+// Effective returns configuration in use, secrets are redacted
+func Effective() map[string]interface{} {
+	result := make(map[string]interface{}, len(settings)+1)
+	result[ConfigFileKey] = configFile
+	for _, v := range settings {
+		value := vip.Get(v.key)
+		switch {
+		case v.key == DbUrlKey && GetString(v.key) != "":
+			value = redactUrl(GetString(v.key))
+		case v.secret && GetString(v.key) != "":
+			value = redacted
+		}
+
+		result[v.key] = value
+	}
+
+	return result
+}
+
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// redactUrl hides password, url which can not be parsed is hidden whole
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		// placeholder set directly, url.UserPassword would escape it
+		return strings.Replace(u.Redacted(), ":xxxxx@", ":"+redacted+"@", 1)
+	}
+
+	return u.String()
+}
+
+// GetCorsConfig returns CORS policy of dnsd, origins of provisioned domain
+// are handled by server, see CorsAllowProvisionedDomainKey
+func GetCorsConfig() cors.Config {
+	return cors.Config{
+		AllowedOrigins:   GetStringSlice(CorsAllowedOriginsKey),
+		AllowedHeaders:   GetStringSlice(CorsAllowedHeadersKey),
+		ExposedHeaders:   GetStringSlice(CorsExposedHeadersKey),
+		AllowCredentials: GetBool(CorsAllowCredentialsKey),
+		MaxAge:           GetDuration(CorsMaxAgeKey),
+	}
+}
+
 func GetString(key string) string {
 	return vip.GetString(key)
 }
@@ -60,6 +385,27 @@ func GetInt(key string) int {
 	return vip.GetInt(key)
 }
 
+func GetBool(key string) bool {
+	return vip.GetBool(key)
+}
+
+func GetDuration(key string) time.Duration {
+	return vip.GetDuration(key)
+}
+
+// GetStringSlice returns comma separated value as slice, empty elements are
+// omitted
+func GetStringSlice(key string) []string {
+	result := make([]string, 0)
+	for _, v := range strings.Split(vip.GetString(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
 func GetServerAddress() string {
 	return ":" + GetString(PortKey)
 }
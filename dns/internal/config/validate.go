@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"net"
+	"net/url"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/signing"
+	"strings"
+)
+
+var (
+	dbTypes    = []string{DbTypePostgres, DbTypeBolt, DbTypeInMemory}
+	dbSslModes = []string{
+		"disable", "allow", "prefer", "require", "verify-ca", "verify-full",
+	}
+)
+
+// validate checks every value which has a known format, all invalid values
+// are reported together
+func validate() error {
+	errs := make([]error, 0)
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %v: %w", key, err))
+		}
+	}
+
+	_, err := parseLogLevel(vip.Get(LogLevelKey))
+	check(LogLevelKey, err)
+
+	check(PortKey, validatePort(PortKey))
+	check(DbPortKey, validatePort(DbPortKey))
+	check(DbTypeKey, validateOneOf(DbTypeKey, dbTypes))
+	check(DbSslModeKey, validateOneOf(DbSslModeKey, dbSslModes))
+	check(DatadirKey, validateRequired(DatadirKey))
+	check(ControllerDaemonUrlKey, validateHttpUrl(ControllerDaemonUrlKey))
+	check(StaticIpKey, validateIp(StaticIpKey))
+
+	if v := GetString(ControllerDaemonSecretKey); v != "" && len(v) < signing.MinSecretLength {
+		check(ControllerDaemonSecretKey, fmt.Errorf(
+			"must be at least %v characters", signing.MinSecretLength,
+		))
+	}
+
+	if v := GetString(DbUrlKey); v != "" {
+		u, err := url.Parse(v)
+		if err == nil && u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			err = fmt.Errorf("scheme must be postgres or postgresql")
+		}
+		if err != nil {
+			// url may hold password so it is not part of the error
+			err = errors.New("not a valid postgres url")
+		}
+		check(DbUrlKey, err)
+	}
+
+	for _, v := range []string{
+		DbMaxConnsKey, DbMinConnsKey, DnsProviderTtlKey, AuthDnsTtlKey,
+	} {
+		check(v, validateInt(v, 0))
+	}
+	if maxConns, minConns := GetInt(DbMaxConnsKey), GetInt(DbMinConnsKey); maxConns > 0 && minConns > maxConns {
+		check(DbMinConnsKey, fmt.Errorf("%v is greater than %v", minConns, DbMaxConnsKey))
+	}
+
+	// zero disables or means no limit
+	for _, v := range []string{
+		IpCacheTTLKey, DbConnectTimeoutKey, DbConnectRetryTimeoutKey,
+		CorsMaxAgeKey,
+	} {
+		check(v, validateDuration(v, false))
+	}
+	for _, v := range []string{
+		IpProviderTimeoutKey, DynamicDnsIntervalKey, NotificationDispatchIntervalKey,
+	} {
+		check(v, validateDuration(v, true))
+	}
+
+	for _, v := range []string{
+		ReachabilityCheckEnabledKey, DynamicDnsEnabledKey, AuthDnsEnabledKey,
+		CorsAllowProvisionedDomainKey, CorsAllowCredentialsKey,
+	} {
+		_, err := cast.ToBoolE(vip.Get(v))
+		check(v, err)
+	}
+
+	if len(errs) == 0 {
+		_, err := cors.New(GetCorsConfig())
+		check(CorsAllowedOriginsKey, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseLogLevel accepts logrus level name or its number
+func parseLogLevel(value interface{}) (log.Level, error) {
+	if level, err := cast.ToUint32E(value); err == nil {
+		if level > uint32(log.TraceLevel) {
+			return 0, fmt.Errorf("%v is out of range 0-%v", level, log.TraceLevel)
+		}
+
+		return log.Level(level), nil
+	}
+
+	return log.ParseLevel(cast.ToString(value))
+}
+
+func validatePort(key string) error {
+	port, err := cast.ToIntE(vip.Get(key))
+	if err != nil {
+		return err
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%v is out of range 1-65535", port)
+	}
+
+	return nil
+}
+
+func validateInt(key string, min int) error {
+	v, err := cast.ToIntE(vip.Get(key))
+	if err != nil {
+		return err
+	}
+	if v < min {
+		return fmt.Errorf("%v is less than %v", v, min)
+	}
+
+	return nil
+}
+
+func validateDuration(key string, positive bool) error {
+	d, err := cast.ToDurationE(vip.Get(key))
+	if err != nil {
+		return err
+	}
+	if d < 0 || (positive && d == 0) {
+		return fmt.Errorf("%v must be positive", d)
+	}
+
+	return nil
+}
+
+func validateOneOf(key string, values []string) error {
+	v := GetString(key)
+	for _, allowed := range values {
+		if v == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q must be one of %v", v, strings.Join(values, ", "))
+}
+
+func validateRequired(key string) error {
+	if strings.TrimSpace(GetString(key)) == "" {
+		return errors.New("value is required")
+	}
+
+	return nil
+}
+
+func validateHttpUrl(key string) error {
+	u, err := url.Parse(GetString(key))
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be http(s) url", GetString(key))
+	}
+
+	return nil
+}
+
+func validateIp(key string) error {
+	v := GetString(key)
+	if v != "" && net.ParseIP(v) == nil {
+		return fmt.Errorf("%q is not an ip address", v)
+	}
+
+	return nil
+}
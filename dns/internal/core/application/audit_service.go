@@ -0,0 +1,126 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/dns/internal/core/domain"
+)
+
+const (
+	// ActorSystem is actor of changes dnsd makes on its own
+	ActorSystem = "system"
+
+	AuditActionDomainCreate   = "domain.create"
+	AuditActionDomainDelete   = "domain.delete"
+	AuditActionDomainIpChange = "domain.ip_change"
+	AuditActionDomainAcmeCa   = "domain.acme_ca"
+)
+
+type actorCtxKey struct{}
+
+// ContextWithActor returns ctx carrying identity of the caller, it is
+// recorded in audit events created while handling the request
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext returns actor set by ContextWithActor, ActorSystem if
+// there is none
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorCtxKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return ActorSystem
+}
+
+// AuditService appends audit events of domain and gateway configuration
+// changes
+type AuditService interface {
+	// Record stores outcome of action performed by actor from ctx, before
+	// and after are json encoded, failure to store event is only logged so
+	// it does not affect the action
+	Record(
+		ctx context.Context,
+		action, resource string,
+		before, after interface{},
+		actionErr error,
+	)
+	// RecordEvent stores event reported by other daemon, eg. controllerd
+	RecordEvent(ctx context.Context, event AuditEvent) error
+	GetEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+type auditService struct {
+	repositorySvc domain.RepositoryService
+}
+
+func NewAuditService(repositorySvc domain.RepositoryService) AuditService {
+	return &auditService{
+		repositorySvc: repositorySvc,
+	}
+}
+
+func (a *auditService) Record(
+	ctx context.Context,
+	action, resource string,
+	before, after interface{},
+	actionErr error,
+) {
+	event := AuditEvent{
+		Action:   action,
+		Resource: resource,
+		Before:   toAuditValue(before),
+		After:    toAuditValue(after),
+		Outcome:  domain.AuditOutcomeSuccess,
+	}
+	if actionErr != nil {
+		event.Outcome = domain.AuditOutcomeFailure
+		event.Error = actionErr.Error()
+	}
+
+	if err := a.RecordEvent(ctx, event); err != nil {
+		log.Errorf("failed to record audit event %v of %v: %v", action, resource, err)
+	}
+}
+
+func (a *auditService) RecordEvent(ctx context.Context, event AuditEvent) error {
+	domainEvent := FromAppAuditEventToDomainAuditEvent(event)
+	domainEvent.Actor = ActorFromContext(ctx)
+
+	return a.repositorySvc.AuditEventRepository().Add(ctx, domainEvent)
+}
+
+func (a *auditService) GetEvents(
+	ctx context.Context, filter AuditFilter,
+) ([]AuditEvent, error) {
+	events, err := a.repositorySvc.AuditEventRepository().GetAll(
+		ctx, domain.AuditFilter(filter),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AuditEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, FromDomainAuditEventToAppAuditEvent(v))
+	}
+
+	return result, nil
+}
+
+// toAuditValue json encodes v, nil is stored as empty value
+func toAuditValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Warnf("failed to encode audit value: %v", err)
+		return ""
+	}
+
+	return string(b)
+}
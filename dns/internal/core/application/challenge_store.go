@@ -0,0 +1,92 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	challengeTTL = time.Minute
+)
+
+type challenge struct {
+	token     string
+	expiresAt time.Time
+}
+
+// challengeStore keeps one-time reachability tokens, token is removed as
+// soon as it is served or expired
+type challengeStore struct {
+	mtx        sync.Mutex
+	challenges map[string]challenge
+}
+
+func newChallengeStore() *challengeStore {
+	return &challengeStore{
+		challenges: make(map[string]challenge),
+	}
+}
+
+func (c *challengeStore) add() (nonce, token string, err error) {
+	nonce, err = randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	token, err = randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	for k, v := range c.challenges {
+		if now.After(v.expiresAt) {
+			delete(c.challenges, k)
+		}
+	}
+
+	c.challenges[nonce] = challenge{
+		token:     token,
+		expiresAt: now.Add(challengeTTL),
+	}
+
+	return nonce, token, nil
+}
+
+func (c *challengeStore) pop(nonce string) (string, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	v, ok := c.challenges[nonce]
+	if !ok {
+		return "", false
+	}
+	delete(c.challenges, nonce)
+
+	if time.Now().After(v.expiresAt) {
+		return "", false
+	}
+
+	return v.token, true
+}
+
+func (c *challengeStore) remove(nonce string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.challenges, nonce)
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
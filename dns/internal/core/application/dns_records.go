@@ -0,0 +1,118 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"time"
+)
+
+const (
+	dnsPropagationTimeout  = 2 * time.Minute
+	dnsPropagationInterval = 5 * time.Second
+	// dnsCleanupTimeout bounds removal of records of domain which failed
+	// to be created, request ctx may be already canceled
+	dnsCleanupTimeout = 30 * time.Second
+)
+
+// domainRecords returns apex and wildcard records pointing to the gateway
+func domainRecords(domainName, ip string) []port.DnsRecord {
+	recordType := recordTypeForIp(ip)
+
+	return []port.DnsRecord{
+		{
+			Name:  domainName,
+			Type:  recordType,
+			Value: ip,
+		},
+		{
+			Name:  fmt.Sprintf("*.%s", domainName),
+			Type:  recordType,
+			Value: ip,
+		},
+	}
+}
+
+func recordTypeForIp(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
+
+func upsertRecords(
+	ctx context.Context, dnsProvider port.DnsProvider, domainName, ip string,
+) error {
+	for _, v := range domainRecords(domainName, ip) {
+		if err := dnsProvider.UpsertRecord(ctx, v); err != nil {
+			return domain.ErrDnsProviderFailed.Wrap(fmt.Errorf(
+				"failed to set %v record %v via %v: %v",
+				v.Type, v.Name, dnsProvider.Name(), err,
+			))
+		}
+
+		log.Infof("%v record %v set to %v", v.Type, v.Name, v.Value)
+	}
+
+	return nil
+}
+
+func deleteRecords(
+	ctx context.Context, dnsProvider port.DnsProvider, domainName, ip string,
+) error {
+	for _, v := range domainRecords(domainName, ip) {
+		if err := dnsProvider.DeleteRecord(ctx, v); err != nil {
+			return domain.ErrDnsProviderFailed.Wrap(fmt.Errorf(
+				"failed to delete %v record %v via %v: %v",
+				v.Type, v.Name, dnsProvider.Name(), err,
+			))
+		}
+
+		log.Infof("%v record %v deleted", v.Type, v.Name)
+	}
+
+	return nil
+}
+
+// cleanupRecords removes records upserted for domain which was not stored,
+// failure is only logged so error of domain creation is returned
+func (d *dnsService) cleanupRecords(domainName, ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCleanupTimeout)
+	defer cancel()
+
+	if err := deleteRecords(ctx, d.dnsProvider, domainName, ip); err != nil {
+		log.Warnf("failed to clean up records of %v: %v", domainName, err)
+	}
+}
+
+// waitForDnsRecord polls resolver until record set through dns provider
+// becomes visible
+func (d *dnsService) waitForDnsRecord(
+	ctx context.Context, ip, domainName string,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, dnsPropagationTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(dnsPropagationInterval)
+	defer ticker.Stop()
+
+	for {
+		valid, err := d.ipSvc.VerifyDnsRecord(ctx, ip, domainName)
+		if err == nil && valid {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return domain.ErrDnsRecordNotPropagated.Wrap(fmt.Errorf(
+				"record for %v not visible within %v: %v",
+				domainName, dnsPropagationTimeout, err,
+			))
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,78 +3,185 @@ package application
 import (
 	"context"
 	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
 	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/core/port"
-	"strings"
 )
 
 type DnsService interface {
 	CreateDomain(ctx context.Context, dnsInfo DnsInfo) error
 	DeleteDomain(ctx context.Context, domainName string) error
 	GetDomain(ctx context.Context, domainName string) (DnsInfo, error)
-	GetGatewayIp(ctx context.Context) (string, error)
+	// UpdateAcmeCa selects ACME CA of domain certificates, controllerd is
+	// notified to reconfigure Traefik if CA changed
+	UpdateAcmeCa(ctx context.Context, domainName, acmeCa string) (DnsInfo, error)
+	GetGatewayIp(ctx context.Context) (GatewayIp, error)
 	CheckDnsRecordStatus(ctx context.Context, domainName string) (bool, error)
 	GetExistingDomain(ctx context.Context) (*DnsInfo, error)
+	GetChallengeToken(ctx context.Context, nonce string) (string, error)
 }
 
 type dnsService struct {
-	repositorySvc      domain.RepositoryService
-	ipSvc              port.IpService
-	controllerdWrapper port.ControllerdWrapper
+	repositorySvc domain.RepositoryService
+	ipSvc         port.IpService
+	dispatcher    NotificationDispatcher
+	// reachabilityChecker is optional, if nil reachability check is skipped
+	reachabilityChecker port.ReachabilityChecker
+	// dnsProvider is optional, if set dnsd manages domain records itself
+	dnsProvider port.DnsProvider
+	auditSvc    AuditService
+	challenges  *challengeStore
 }
 
 func NewDnsService(
 	repositorySvc domain.RepositoryService,
 	ipSvc port.IpService,
-	controllerdWrapper port.ControllerdWrapper,
+	dispatcher NotificationDispatcher,
+	reachabilityChecker port.ReachabilityChecker,
+	dnsProvider port.DnsProvider,
+	auditSvc AuditService,
 ) (DnsService, error) {
 	return &dnsService{
-		repositorySvc:      repositorySvc,
-		ipSvc:              ipSvc,
-		controllerdWrapper: controllerdWrapper,
+		repositorySvc:       repositorySvc,
+		ipSvc:               ipSvc,
+		dispatcher:          dispatcher,
+		reachabilityChecker: reachabilityChecker,
+		dnsProvider:         dnsProvider,
+		auditSvc:            auditSvc,
+		challenges:          newChallengeStore(),
 	}, nil
 }
 
-func (d *dnsService) CreateDomain(ctx context.Context, dnsInfo DnsInfo) error {
+func (d *dnsService) CreateDomain(
+	ctx context.Context, dnsInfo DnsInfo,
+) (err error) {
+	dnsInfo, err = normalizeDnsInfo(dnsInfo)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		d.auditSvc.Record(
+			ctx, AuditActionDomainCreate, dnsInfo.Domain, nil, dnsInfo, err,
+		)
+	}()
+
 	//assumption is that there should be only one domain
-	dnsDomain, _ := d.repositorySvc.DnsRepository().Get(ctx, dnsInfo.Domain)
+	dnsDomain, err := d.repositorySvc.DnsRepository().Get(ctx, dnsInfo.Domain)
+	if err != nil && !errors.Is(err, domain.ErrEntityNotFound) {
+		return err
+	}
 	if dnsDomain != nil {
 		return domain.ErrAlreadyExists
 	}
 
-	valid, err := d.ipSvc.VerifyDnsRecord(ctx, dnsInfo.Ip, dnsInfo.Domain)
-	if err != nil {
-		return err
-	}
+	if d.dnsProvider != nil {
+		if dnsInfo.Ip == "" {
+			hostIp, err := d.ipSvc.GetHostIp(ctx)
+			if err != nil {
+				return domain.ErrIpDiscoveryFailed.Wrap(err)
+			}
+			dnsInfo.Ip = hostIp.Ip
+		}
 
-	if !valid {
-		return errors.New("dns record not found, check if A record is set correctly")
+		// records, also partially upserted ones, are not left at the
+		// provider for domain which is not stored
+		defer func() {
+			if err != nil {
+				d.cleanupRecords(dnsInfo.Domain, dnsInfo.Ip)
+			}
+		}()
+		if err := upsertRecords(
+			ctx, d.dnsProvider, dnsInfo.Domain, dnsInfo.Ip,
+		); err != nil {
+			return err
+		}
+
+		if err := d.waitForDnsRecord(ctx, dnsInfo.Ip, dnsInfo.Domain); err != nil {
+			return err
+		}
+	} else {
+		valid, err := d.ipSvc.VerifyDnsRecord(ctx, dnsInfo.Ip, dnsInfo.Domain)
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return domain.ErrDnsRecordNotFound
+		}
 	}
 
-	if err := d.repositorySvc.DnsRepository().Create(
-		ctx, FromAppDnsInfoToDomainDnsInfo(dnsInfo),
-	); err != nil {
+	//matching A record does not prove that ports 80/443 reach this gateway
+	if err := d.checkReachability(ctx, dnsInfo.Domain); err != nil {
 		return err
 	}
 
 	//on initial docker-compose up(main one in proj root) services are
 	//started without tls and real subdomains, this will invoke contoller daemon
-	//to restart treafik and services with tls/subdomains set
-	if err := d.controllerdWrapper.DomainProvisioned(
-		ctx, dnsInfo.Email, dnsInfo.Domain,
+	//to restart treafik and services with tls/subdomains set, notification
+	//is stored together with domain so it is not lost if controllerd is down
+	if err := d.repositorySvc.DnsRepository().Create(
+		ctx,
+		FromAppDnsInfoToDomainDnsInfo(dnsInfo),
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: dnsInfo.Domain,
+			Email:  dnsInfo.Email,
+			AcmeCa: dnsInfo.AcmeCa,
+		},
 	); err != nil {
 		return err
 	}
 
+	d.dispatch(ctx)
+
 	return nil
 }
 
-func (d *dnsService) DeleteDomain(ctx context.Context, domainName string) error {
-	// TODO invoke controllerd to restart services
+func (d *dnsService) DeleteDomain(
+	ctx context.Context, domainName string,
+) (err error) {
+	domainName, err = domain.NormalizeDomain(domainName)
+	if err != nil {
+		return err
+	}
+
+	dnsInfo, err := d.repositorySvc.DnsRepository().Get(ctx, domainName)
+	if err != nil && !errors.Is(err, domain.ErrEntityNotFound) {
+		return err
+	}
+
+	var before *DnsInfo
+	if dnsInfo != nil {
+		info := FromDomainDnsInfoToAppDnsInfo(*dnsInfo)
+		before = &info
+	}
+	defer func() {
+		d.auditSvc.Record(
+			ctx, AuditActionDomainDelete, domainName, before, nil, err,
+		)
+	}()
+
+	if d.dnsProvider != nil && dnsInfo != nil {
+		if err := deleteRecords(
+			ctx, d.dnsProvider, dnsInfo.Domain, dnsInfo.Ip,
+		); err != nil {
+			return err
+		}
+	}
+
+	// controllerd can not deprovision domain yet, deletion is not announced
+	// to it, pending notifications of the domain are dropped with it
 	return d.repositorySvc.DnsRepository().Delete(ctx, domainName)
 }
 
 func (d *dnsService) GetDomain(ctx context.Context, domainName string) (DnsInfo, error) {
+	domainName, err := domain.NormalizeDomain(domainName)
+	if err != nil {
+		return DnsInfo{}, err
+	}
+
 	dnsInfo, err := d.repositorySvc.DnsRepository().Get(ctx, domainName)
 	if err != nil {
 		return DnsInfo{}, err
@@ -83,18 +190,76 @@ func (d *dnsService) GetDomain(ctx context.Context, domainName string) (DnsInfo,
 	return FromDomainDnsInfoToAppDnsInfo(*dnsInfo), nil
 }
 
-func (d *dnsService) GetGatewayIp(ctx context.Context) (string, error) {
-	ip, err := d.ipSvc.GetHostIp(ctx)
+func (d *dnsService) UpdateAcmeCa(
+	ctx context.Context, domainName, acmeCa string,
+) (_ DnsInfo, err error) {
+	var fields []domain.FieldError
+	if domainName, err = domain.NormalizeDomain(domainName); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+	if acmeCa, err = domain.NormalizeAcmeCa(acmeCa); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+	if err := domain.NewInvalidArgumentError(fields...); err != nil {
+		return DnsInfo{}, err
+	}
+
+	dnsInfo, err := d.repositorySvc.DnsRepository().Get(ctx, domainName)
+	if err != nil {
+		return DnsInfo{}, err
+	}
+
+	before := FromDomainDnsInfoToAppDnsInfo(*dnsInfo)
+	if dnsInfo.AcmeCa == acmeCa {
+		return before, nil
+	}
+
+	dnsInfo.AcmeCa = acmeCa
+	after := FromDomainDnsInfoToAppDnsInfo(*dnsInfo)
+	defer func() {
+		d.auditSvc.Record(
+			ctx, AuditActionDomainAcmeCa, domainName, before, after, err,
+		)
+	}()
+
+	if err := d.repositorySvc.DnsRepository().Update(
+		ctx,
+		*dnsInfo,
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: dnsInfo.Domain,
+			Email:  dnsInfo.Email,
+			AcmeCa: dnsInfo.AcmeCa,
+		},
+	); err != nil {
+		return DnsInfo{}, err
+	}
+
+	d.dispatch(ctx)
+
+	return after, nil
+}
+
+func (d *dnsService) GetGatewayIp(ctx context.Context) (GatewayIp, error) {
+	hostIp, err := d.ipSvc.GetHostIp(ctx)
 	if err != nil {
-		return "", err
+		return GatewayIp{}, domain.ErrIpDiscoveryFailed.Wrap(err)
 	}
 
-	return strings.Replace(ip, "\n", "", -1), nil
+	return GatewayIp{
+		Ip:     hostIp.Ip,
+		Source: hostIp.Source,
+	}, nil
 }
 
 func (d *dnsService) CheckDnsRecordStatus(
 	ctx context.Context, domainName string,
 ) (bool, error) {
+	domainName, err := domain.NormalizeDomain(domainName)
+	if err != nil {
+		return false, err
+	}
+
 	dnsInfo, err := d.repositorySvc.DnsRepository().Get(ctx, domainName)
 	if err != nil {
 		return false, err
@@ -108,7 +273,7 @@ func (d *dnsService) GetExistingDomain(
 ) (*DnsInfo, error) {
 	dnsInfo, err := d.repositorySvc.DnsRepository().GetExistingDomain(ctx)
 	if err != nil {
-		if err == domain.ErrEntityNotFound {
+		if errors.Is(err, domain.ErrEntityNotFound) {
 			return nil, nil
 		}
 
@@ -119,3 +284,71 @@ func (d *dnsService) GetExistingDomain(
 
 	return &dns, nil
 }
+
+func (d *dnsService) GetChallengeToken(
+	ctx context.Context, nonce string,
+) (string, error) {
+	token, ok := d.challenges.pop(nonce)
+	if !ok {
+		return "", domain.ErrEntityNotFound
+	}
+
+	return token, nil
+}
+
+// dispatch tries to deliver stored notifications right away, on failure
+// dispatcher retries them in background
+func (d *dnsService) dispatch(ctx context.Context) {
+	if err := d.dispatcher.Dispatch(ctx); err != nil {
+		log.Warnf("controllerd notification not delivered, will retry: %v", err)
+	}
+}
+
+func (d *dnsService) checkReachability(
+	ctx context.Context, domainName string,
+) error {
+	if d.reachabilityChecker == nil {
+		return nil
+	}
+
+	nonce, token, err := d.challenges.add()
+	if err != nil {
+		return err
+	}
+	defer d.challenges.remove(nonce)
+
+	if err := d.reachabilityChecker.CheckReachability(
+		ctx, domainName, nonce, token,
+	); err != nil {
+		return fmt.Errorf(
+			"%w: %v, check that ports 80 and 443 are forwarded to this gateway",
+			domain.ErrDomainNotReachable, err,
+		)
+	}
+
+	return nil
+}
+
+// normalizeDnsInfo validates user input and brings it to form which is
+// stored and later used in Traefik rules, all invalid fields are reported
+func normalizeDnsInfo(dnsInfo DnsInfo) (DnsInfo, error) {
+	var (
+		fields []domain.FieldError
+		err    error
+	)
+
+	if dnsInfo.Domain, err = domain.NormalizeDomain(dnsInfo.Domain); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+	if dnsInfo.Email, err = domain.NormalizeEmail(dnsInfo.Email); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+	if dnsInfo.Ip, err = domain.NormalizeIp(dnsInfo.Ip); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+	if dnsInfo.AcmeCa, err = domain.NormalizeAcmeCa(dnsInfo.AcmeCa); err != nil {
+		fields = append(fields, domain.FieldsOf(err)...)
+	}
+
+	return dnsInfo, domain.NewInvalidArgumentError(fields...)
+}
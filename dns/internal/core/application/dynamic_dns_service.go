@@ -0,0 +1,185 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"time"
+)
+
+const (
+	defaultIpHistoryLimit = 50
+)
+
+// DynamicDnsService keeps stored ip, and records managed by dns provider,
+// in sync with public ip of the gateway
+type DynamicDnsService interface {
+	// Start runs periodic ip check until ctx is done
+	Start(ctx context.Context)
+	CheckIp(ctx context.Context) error
+	GetIpHistory(ctx context.Context, limit int) ([]IpChangeEvent, error)
+}
+
+type dynamicDnsService struct {
+	repositorySvc domain.RepositoryService
+	ipSvc         port.IpService
+	// dnsProvider is optional, if nil only stored record is updated
+	dnsProvider port.DnsProvider
+	auditSvc    AuditService
+	interval    time.Duration
+}
+
+func NewDynamicDnsService(
+	repositorySvc domain.RepositoryService,
+	ipSvc port.IpService,
+	dnsProvider port.DnsProvider,
+	auditSvc AuditService,
+	interval time.Duration,
+) (DynamicDnsService, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid dynamic dns interval: %v", interval)
+	}
+
+	return &dynamicDnsService{
+		repositorySvc: repositorySvc,
+		ipSvc:         ipSvc,
+		dnsProvider:   dnsProvider,
+		auditSvc:      auditSvc,
+		interval:      interval,
+	}, nil
+}
+
+func (d *dynamicDnsService) Start(ctx context.Context) {
+	go func() {
+		log.Infof("dynamic dns started, checking ip every %v", d.interval)
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.CheckIp(ctx); err != nil {
+				log.Errorf("dynamic dns ip check failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Info("dynamic dns stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (d *dynamicDnsService) CheckIp(ctx context.Context) error {
+	dnsInfo, err := d.repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrEntityNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	hostIp, err := d.ipSvc.GetHostIp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if hostIp.Ip == dnsInfo.Ip {
+		return nil
+	}
+
+	log.Infof(
+		"gateway ip changed from %v to %v(%v)",
+		dnsInfo.Ip, hostIp.Ip, hostIp.Source,
+	)
+
+	oldIp := dnsInfo.Ip
+	event := domain.IpChangeEvent{
+		Domain: dnsInfo.Domain,
+		OldIp:  oldIp,
+		NewIp:  hostIp.Ip,
+		Source: hostIp.Source,
+	}
+	before := map[string]string{"ip": oldIp}
+	after := map[string]string{"ip": hostIp.Ip, "source": hostIp.Source}
+	auditCtx := ContextWithActor(ctx, ActorSystem)
+
+	// stored ip is updated only once dns provider has the new ip, otherwise
+	// next check would see no change and the push would never be retried
+	if err := d.pushToDnsProvider(ctx, dnsInfo.Domain, oldIp, hostIp.Ip); err != nil {
+		err = fmt.Errorf("failed to push new ip to dns provider: %w", err)
+		event.ProviderError = err.Error()
+		d.auditSvc.Record(
+			auditCtx, AuditActionDomainIpChange, dnsInfo.Domain, before, after, err,
+		)
+		if addErr := d.repositorySvc.IpChangeEventRepository().Add(ctx, event); addErr != nil {
+			log.Errorf("failed to record ip change event: %v", addErr)
+		}
+
+		return err
+	}
+
+	dnsInfo.Ip = hostIp.Ip
+	err = d.repositorySvc.DnsRepository().Update(ctx, *dnsInfo)
+	d.auditSvc.Record(
+		auditCtx, AuditActionDomainIpChange, dnsInfo.Domain, before, after, err,
+	)
+	if err != nil {
+		return err
+	}
+
+	return d.repositorySvc.IpChangeEventRepository().Add(ctx, event)
+}
+
+func (d *dynamicDnsService) GetIpHistory(
+	ctx context.Context, limit int,
+) ([]IpChangeEvent, error) {
+	if limit <= 0 {
+		limit = defaultIpHistoryLimit
+	}
+
+	dnsInfo, err := d.repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrEntityNotFound) {
+			return []IpChangeEvent{}, nil
+		}
+
+		return nil, err
+	}
+
+	events, err := d.repositorySvc.IpChangeEventRepository().GetAll(
+		ctx, dnsInfo.Domain, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]IpChangeEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, FromDomainIpChangeEventToAppIpChangeEvent(v))
+	}
+
+	return result, nil
+}
+
+func (d *dynamicDnsService) pushToDnsProvider(
+	ctx context.Context, domainName, oldIp, newIp string,
+) error {
+	if d.dnsProvider == nil {
+		return nil
+	}
+
+	// switching between ipv4 and ipv6 leaves stale records of the old type
+	if oldIp != "" && recordTypeForIp(oldIp) != recordTypeForIp(newIp) {
+		if err := deleteRecords(ctx, d.dnsProvider, domainName, oldIp); err != nil {
+			return err
+		}
+	}
+
+	return upsertRecords(ctx, d.dnsProvider, domainName, newIp)
+}
@@ -0,0 +1,192 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"sync"
+	"time"
+)
+
+const (
+	dispatchBatchSize = 20
+	minRetryBackoff   = 5 * time.Second
+	maxRetryBackoff   = 10 * time.Minute
+	// maxDeliveryAttempts is number of failed deliveries after which
+	// notification is dead, with backoff it is retried for about 30 minutes
+	maxDeliveryAttempts = 10
+)
+
+// errUnknownNotification is returned for notification type dispatcher can
+// not deliver, it is dead right away since retry can not help
+var errUnknownNotification = errors.New("unknown notification type")
+
+// NotificationDispatcher delivers notifications stored in outbox to
+// controllerd, failed deliveries are retried with exponential backoff until
+// controllerd acknowledges them or maxDeliveryAttempts is reached, then
+// notification is dead and later ones are delivered
+type NotificationDispatcher interface {
+	// Start runs dispatch loop until ctx is done
+	Start(ctx context.Context)
+	// Dispatch delivers due notifications in order they were stored, it
+	// stops at first failure so later notification does not overtake
+	// earlier one, dead notifications are skipped
+	Dispatch(ctx context.Context) error
+	// GetPendingNotifications returns notifications not yet acknowledged by
+	// controllerd, including dead ones
+	GetPendingNotifications(ctx context.Context) ([]Notification, error)
+}
+
+type notificationDispatcher struct {
+	repositorySvc      domain.RepositoryService
+	controllerdWrapper port.ControllerdWrapper
+	interval           time.Duration
+	// mtx serializes dispatch from the loop and from request handlers
+	mtx sync.Mutex
+}
+
+func NewNotificationDispatcher(
+	repositorySvc domain.RepositoryService,
+	controllerdWrapper port.ControllerdWrapper,
+	interval time.Duration,
+) (NotificationDispatcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid dispatch interval: %v", interval)
+	}
+
+	return &notificationDispatcher{
+		repositorySvc:      repositorySvc,
+		controllerdWrapper: controllerdWrapper,
+		interval:           interval,
+	}, nil
+}
+
+func (n *notificationDispatcher) Start(ctx context.Context) {
+	go func() {
+		log.Infof("notification dispatcher started, polling every %v", n.interval)
+
+		ticker := time.NewTicker(n.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := n.Dispatch(ctx); err != nil {
+				log.Warnf("notification dispatch failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Info("notification dispatcher stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (n *notificationDispatcher) Dispatch(ctx context.Context) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	repo := n.repositorySvc.NotificationRepository()
+
+	notifications, err := repo.GetUndelivered(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	dispatched := 0
+	for _, v := range notifications {
+		if v.DeadAt != nil {
+			continue
+		}
+		// oldest notification in backoff blocks the rest
+		if dispatched >= dispatchBatchSize || v.NextAttemptAt.After(now) {
+			return nil
+		}
+		dispatched++
+
+		if err := n.deliver(ctx, v); err != nil {
+			if errors.Is(err, errUnknownNotification) ||
+				v.Attempts+1 >= maxDeliveryAttempts {
+				if markErr := repo.MarkDead(ctx, v.Id, err.Error()); markErr != nil {
+					return markErr
+				}
+
+				log.Errorf(
+					"%v notification for %v is dead after %v attempts: %v",
+					v.Type, v.Domain, v.Attempts+1, err,
+				)
+				continue
+			}
+
+			nextAttemptAt := time.Now().Add(retryBackoff(v.Attempts))
+			if markErr := repo.MarkFailed(
+				ctx, v.Id, err.Error(), nextAttemptAt,
+			); markErr != nil {
+				return markErr
+			}
+
+			return fmt.Errorf(
+				"%v notification for %v failed, attempt %v, next at %v: %w",
+				v.Type, v.Domain, v.Attempts+1,
+				nextAttemptAt.Format(time.RFC3339), err,
+			)
+		}
+
+		if err := repo.MarkDelivered(ctx, v.Id); err != nil {
+			return err
+		}
+
+		log.Infof("%v notification for %v delivered", v.Type, v.Domain)
+	}
+
+	return nil
+}
+
+func (n *notificationDispatcher) GetPendingNotifications(
+	ctx context.Context,
+) ([]Notification, error) {
+	notifications, err := n.repositorySvc.NotificationRepository().
+		GetUndelivered(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Notification, 0, len(notifications))
+	for _, v := range notifications {
+		result = append(result, FromDomainNotificationToAppNotification(v))
+	}
+
+	return result, nil
+}
+
+func (n *notificationDispatcher) deliver(
+	ctx context.Context, notification domain.Notification,
+) error {
+	switch notification.Type {
+	case domain.NotificationDomainProvisioned:
+		return n.controllerdWrapper.DomainProvisioned(
+			ctx, notification.Email, notification.Domain, notification.AcmeCa,
+		)
+	default:
+		return fmt.Errorf("%w: %v", errUnknownNotification, notification.Type)
+	}
+}
+
+// retryBackoff doubles delay with every failed attempt
+func retryBackoff(attempts int) time.Duration {
+	backoff := minRetryBackoff
+	for i := 0; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return backoff
+}
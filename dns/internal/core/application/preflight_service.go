@@ -0,0 +1,635 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"strings"
+	"time"
+)
+
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+
+	PreflightCheckARecord        = "a_record"
+	PreflightCheckAaaaRecord     = "aaaa_record"
+	PreflightCheckWildcardRecord = "wildcard_record"
+	PreflightCheckCaa            = "caa"
+	PreflightCheckDnssec         = "dnssec"
+	PreflightCheckConflicts      = "conflicting_records"
+	PreflightCheckTtl            = "ttl"
+
+	// maxRecommendedTtl is ttl above which ip change, eg. by dynamic dns,
+	// takes too long to propagate
+	maxRecommendedTtl = 3600
+	// wildcardProbeLabel is looked up below the domain, services are served
+	// on subdomains so it must resolve through wildcard record
+	wildcardProbeLabel = "prem-gateway-preflight"
+)
+
+// acmeCaaIssuers maps host of known ACME directory to CAA issuer domain of
+// its CA
+var acmeCaaIssuers = map[string]string{
+	"acme-v02.api.letsencrypt.org":         DefaultCaaIssuer,
+	"acme-staging-v02.api.letsencrypt.org": DefaultCaaIssuer,
+	"acme.zerossl.com":                     "sectigo.com",
+	"dv.acme-v02.api.pki.goog":             "pki.goog",
+	"dv.acme-v02.test-api.pki.goog":        "pki.goog",
+	"api.buypass.com":                      "buypass.com",
+	"api.test4.buypass.no":                 "buypass.com",
+}
+
+// PreflightService checks, before certificate is requested, that public dns
+// of the domain lets Let's Encrypt issue it and points to the gateway
+type PreflightService interface {
+	Run(ctx context.Context, domainName string) (PreflightReport, error)
+}
+
+type preflightService struct {
+	repositorySvc domain.RepositoryService
+	ipSvc         port.IpService
+	resolver      port.DnsResolver
+	// caaIssuer is expected CA of domain which leaves CA to controllerd
+	caaIssuer string
+}
+
+func NewPreflightService(
+	repositorySvc domain.RepositoryService,
+	ipSvc port.IpService,
+	resolver port.DnsResolver,
+	caaIssuer string,
+) (PreflightService, error) {
+	if resolver == nil {
+		return nil, errors.New("preflight dns resolver not set")
+	}
+	if caaIssuer == "" {
+		caaIssuer = DefaultCaaIssuer
+	}
+
+	return &preflightService{
+		repositorySvc: repositorySvc,
+		ipSvc:         ipSvc,
+		resolver:      resolver,
+		caaIssuer:     caaIssuer,
+	}, nil
+}
+
+// Run checks domain against stored ip if domain is provisioned, otherwise
+// against current gateway ip, failed lookups are reported as warnings so
+// report is always returned for valid domain
+func (p *preflightService) Run(
+	ctx context.Context, domainName string,
+) (PreflightReport, error) {
+	domainName, err := domain.NormalizeDomain(domainName)
+	if err != nil {
+		return PreflightReport{}, err
+	}
+
+	dnsInfo, err := p.repositorySvc.DnsRepository().Get(ctx, domainName)
+	if err != nil && !errors.Is(err, domain.ErrEntityNotFound) {
+		return PreflightReport{}, err
+	}
+
+	expectedIp, err := p.expectedIp(ctx, dnsInfo)
+	if err != nil {
+		return PreflightReport{}, err
+	}
+
+	acmeCa := ""
+	if dnsInfo != nil {
+		acmeCa = dnsInfo.AcmeCa
+	}
+
+	a, aErr := p.resolver.Lookup(ctx, domainName, "A")
+	aaaa, aaaaErr := p.resolver.Lookup(ctx, domainName, "AAAA")
+	// records of gateway ip family are validated and their ttl checked
+	recordType := recordTypeForIp(expectedIp)
+	address, addressErr := a, aErr
+	if recordType == "AAAA" {
+		address, addressErr = aaaa, aaaaErr
+	}
+
+	checks := []PreflightCheck{
+		p.checkA(domainName, expectedIp, a, aErr),
+		p.checkAaaa(domainName, expectedIp, aaaa, aaaaErr),
+		p.checkWildcard(ctx, domainName, expectedIp),
+		p.checkCaa(ctx, domainName, p.caaIssuerOf(acmeCa)),
+		p.checkDnssec(domainName, address, addressErr),
+		p.checkConflicts(ctx, domainName),
+		p.checkTtl(recordType, address, addressErr),
+	}
+
+	return PreflightReport{
+		Domain:     domainName,
+		ExpectedIp: expectedIp,
+		Status:     worstStatus(checks),
+		Checks:     checks,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// expectedIp is ip of provisioned domain, current gateway ip if domain is
+// nil or has no ip
+func (p *preflightService) expectedIp(
+	ctx context.Context, dnsInfo *domain.DnsInfo,
+) (string, error) {
+	if dnsInfo != nil && dnsInfo.Ip != "" {
+		return dnsInfo.Ip, nil
+	}
+
+	hostIp, err := p.ipSvc.GetHostIp(ctx)
+	if err != nil {
+		return "", domain.ErrIpDiscoveryFailed.Wrap(err)
+	}
+
+	return hostIp.Ip, nil
+}
+
+// checkA compares A records with gateway IPv4 address, gateway with IPv6
+// address is validated by checkAaaa, A records pointing elsewhere only warn
+// then since IPv4 clients would reach another host
+func (p *preflightService) checkA(
+	name, expectedIp string,
+	result port.DnsLookupResult,
+	lookupErr error,
+) PreflightCheck {
+	if recordTypeForIp(expectedIp) == "A" {
+		return p.checkAddress(
+			PreflightCheckARecord, name, expectedIp, result, lookupErr,
+		)
+	}
+
+	if lookupErr != nil {
+		return lookupFailed(PreflightCheckARecord, name, lookupErr)
+	}
+
+	values := recordValues(result.Records)
+	if len(values) > 0 {
+		return PreflightCheck{
+			Id:     PreflightCheckARecord,
+			Status: PreflightWarn,
+			Message: fmt.Sprintf(
+				"%v has A record %v but gateway ip is %v",
+				name, strings.Join(values, ", "), expectedIp,
+			),
+			Remediation: fmt.Sprintf(
+				"Delete A records of %v, IPv4 clients reach another host", name,
+			),
+		}
+	}
+
+	return PreflightCheck{
+		Id:     PreflightCheckARecord,
+		Status: PreflightPass,
+		Message: fmt.Sprintf(
+			"%v has no A record, gateway ip is IPv6", name,
+		),
+	}
+}
+
+func (p *preflightService) checkAddress(
+	id, name, expectedIp string,
+	result port.DnsLookupResult,
+	lookupErr error,
+) PreflightCheck {
+	recordType := recordTypeForIp(expectedIp)
+
+	if lookupErr != nil {
+		return lookupFailed(id, name, lookupErr)
+	}
+
+	values := recordValues(result.Records)
+	if len(values) == 0 {
+		return PreflightCheck{
+			Id:     id,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"no %v record found for %v", recordType, name,
+			),
+			Remediation: fmt.Sprintf(
+				"Create %v record %v pointing to %v", recordType, name, expectedIp,
+			),
+		}
+	}
+
+	if !contains(values, expectedIp) {
+		return PreflightCheck{
+			Id:     id,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"%v resolves to %v, not to gateway ip %v",
+				name, strings.Join(values, ", "), expectedIp,
+			),
+			Remediation: fmt.Sprintf(
+				"Change %v record %v to %v", recordType, name, expectedIp,
+			),
+		}
+	}
+
+	if len(values) > 1 {
+		return PreflightCheck{
+			Id:     id,
+			Status: PreflightWarn,
+			Message: fmt.Sprintf(
+				"%v resolves to %v, only %v is gateway ip",
+				name, strings.Join(values, ", "), expectedIp,
+			),
+			Remediation: fmt.Sprintf(
+				"Remove other %v records of %v, HTTP challenge may reach another host",
+				recordType, name,
+			),
+		}
+	}
+
+	return PreflightCheck{
+		Id:      id,
+		Status:  PreflightPass,
+		Message: fmt.Sprintf("%v resolves to gateway ip %v", name, expectedIp),
+	}
+}
+
+// checkAaaa fails on AAAA record if gateway has IPv4 address, Let's Encrypt
+// prefers IPv6 so stale AAAA record breaks validation
+func (p *preflightService) checkAaaa(
+	name, expectedIp string,
+	result port.DnsLookupResult,
+	lookupErr error,
+) PreflightCheck {
+	if recordTypeForIp(expectedIp) == "AAAA" {
+		return p.checkAddress(
+			PreflightCheckAaaaRecord, name, expectedIp, result, lookupErr,
+		)
+	}
+
+	if lookupErr != nil {
+		return lookupFailed(PreflightCheckAaaaRecord, name, lookupErr)
+	}
+
+	values := recordValues(result.Records)
+	if len(values) > 0 {
+		return PreflightCheck{
+			Id:     PreflightCheckAaaaRecord,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"%v has AAAA record %v but gateway ip is %v",
+				name, strings.Join(values, ", "), expectedIp,
+			),
+			Remediation: fmt.Sprintf(
+				"Delete AAAA records of %v, Let's Encrypt prefers IPv6 and would validate against another host",
+				name,
+			),
+		}
+	}
+
+	return PreflightCheck{
+		Id:      PreflightCheckAaaaRecord,
+		Status:  PreflightPass,
+		Message: fmt.Sprintf("%v has no AAAA record", name),
+	}
+}
+
+func (p *preflightService) checkWildcard(
+	ctx context.Context, name, expectedIp string,
+) PreflightCheck {
+	probe := wildcardProbeLabel + "." + name
+	recordType := recordTypeForIp(expectedIp)
+
+	result, err := p.resolver.Lookup(ctx, probe, recordType)
+	check := p.checkAddress(
+		PreflightCheckWildcardRecord, probe, expectedIp, result, err,
+	)
+	if check.Status == PreflightFail {
+		check.Remediation = fmt.Sprintf(
+			"Create %v record *.%v pointing to %v, services are served on subdomains",
+			recordType, name, expectedIp,
+		)
+	}
+
+	return check
+}
+
+// caaIssuerOf returns CAA issuer domain of CA of acmeCa, empty if CA of
+// custom ACME directory is not known. Empty acmeCa leaves CA to controllerd,
+// configured issuer is expected then
+func (p *preflightService) caaIssuerOf(acmeCa string) string {
+	switch acmeCa {
+	case "":
+		return p.caaIssuer
+	case domain.AcmeCaStaging, domain.AcmeCaProduction:
+		return DefaultCaaIssuer
+	}
+
+	u, err := url.Parse(acmeCa)
+	if err != nil {
+		return ""
+	}
+
+	return acmeCaaIssuers[strings.ToLower(u.Hostname())]
+}
+
+// checkCaa looks for closest CAA record set, climbing from the domain to
+// its parents, as CA does. Empty issuer is unknown CA, restricting records
+// are only reported then
+func (p *preflightService) checkCaa(
+	ctx context.Context, name, issuer string,
+) PreflightCheck {
+	for _, v := range caaCandidates(name) {
+		result, err := p.resolver.Lookup(ctx, v, "CAA")
+		if err != nil {
+			return lookupFailed(PreflightCheckCaa, v, err)
+		}
+
+		if len(result.Records) == 0 {
+			continue
+		}
+
+		return evaluateCaa(v, issuer, result.Records)
+	}
+
+	return PreflightCheck{
+		Id:      PreflightCheckCaa,
+		Status:  PreflightPass,
+		Message: "no CAA record, any CA may issue certificates",
+	}
+}
+
+func evaluateCaa(
+	owner, issuer string, records []port.ResolvedRecord,
+) PreflightCheck {
+	issuers := make([]string, 0)
+	wildIssuers := make([]string, 0)
+	hasIssueWild := false
+	for _, v := range records {
+		tag := strings.ToLower(v.Tag)
+		switch tag {
+		case "issue":
+			issuers = append(issuers, caaIssuerDomain(v.Value))
+		case "issuewild":
+			hasIssueWild = true
+			wildIssuers = append(wildIssuers, caaIssuerDomain(v.Value))
+		case "iodef", "contactemail", "contactphone", "issuemail", "issuevmc":
+		default:
+			// critical flag on unknown tag forbids issuance
+			if v.Flag&128 != 0 {
+				return PreflightCheck{
+					Id:     PreflightCheckCaa,
+					Status: PreflightFail,
+					Message: fmt.Sprintf(
+						"CAA record of %v has unknown critical tag %v", owner, v.Tag,
+					),
+					Remediation: fmt.Sprintf(
+						"Remove critical flag, or the record, of CAA tag %v on %v",
+						v.Tag, owner,
+					),
+				}
+			}
+		}
+	}
+
+	if len(issuers) == 0 && !hasIssueWild {
+		return PreflightCheck{
+			Id:      PreflightCheckCaa,
+			Status:  PreflightPass,
+			Message: fmt.Sprintf("CAA record of %v does not restrict issuance", owner),
+		}
+	}
+
+	// CA of custom ACME directory is not known, records may allow it unless
+	// they forbid issuance with empty issuer
+	if issuer == "" {
+		allowed := make([]string, 0)
+		for _, v := range append(issuers, wildIssuers...) {
+			if v != "" && !contains(allowed, v) {
+				allowed = append(allowed, v)
+			}
+		}
+		if len(issuers) > 0 && len(allowed) == 0 {
+			return PreflightCheck{
+				Id:     PreflightCheckCaa,
+				Status: PreflightFail,
+				Message: fmt.Sprintf(
+					"CAA record of %v does not allow any CA to issue certificates", owner,
+				),
+				Remediation: fmt.Sprintf(
+					"Add CAA issue record of CA of the ACME directory to %v", owner,
+				),
+			}
+		}
+
+		return PreflightCheck{
+			Id:     PreflightCheckCaa,
+			Status: PreflightWarn,
+			Message: fmt.Sprintf(
+				"CAA record of %v allows only %v, CA of custom ACME directory is not known",
+				owner, strings.Join(allowed, ", "),
+			),
+			Remediation: fmt.Sprintf(
+				"Make sure CAA record of %v allows CA of the ACME directory", owner,
+			),
+		}
+	}
+
+	remediation := fmt.Sprintf(
+		"Add CAA record %v 0 issue \"%v\"", owner, issuer,
+	)
+	if len(issuers) > 0 && !contains(issuers, issuer) {
+		return PreflightCheck{
+			Id:     PreflightCheckCaa,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"CAA record of %v does not allow %v to issue certificates",
+				owner, issuer,
+			),
+			Remediation: remediation,
+		}
+	}
+
+	// issuewild overrides issue for wildcard certificates
+	if hasIssueWild && !contains(wildIssuers, issuer) {
+		return PreflightCheck{
+			Id:     PreflightCheckCaa,
+			Status: PreflightWarn,
+			Message: fmt.Sprintf(
+				"CAA record of %v does not allow %v to issue wildcard certificates",
+				owner, issuer,
+			),
+			Remediation: fmt.Sprintf(
+				"Add CAA record %v 0 issuewild \"%v\"", owner, issuer,
+			),
+		}
+	}
+
+	return PreflightCheck{
+		Id:     PreflightCheckCaa,
+		Status: PreflightPass,
+		Message: fmt.Sprintf(
+			"CAA record of %v allows %v", owner, issuer,
+		),
+	}
+}
+
+func (p *preflightService) checkDnssec(
+	name string, result port.DnsLookupResult, lookupErr error,
+) PreflightCheck {
+	if lookupErr != nil {
+		return lookupFailed(PreflightCheckDnssec, name, lookupErr)
+	}
+
+	if result.DnssecFailed {
+		return PreflightCheck{
+			Id:     PreflightCheckDnssec,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"DNSSEC validation of %v fails, validating resolvers, including Let's Encrypt, can not resolve it",
+				name,
+			),
+			Remediation: "Fix DS record at the registrar so it matches zone signing key, or remove it to disable DNSSEC",
+		}
+	}
+
+	if result.Authenticated {
+		return PreflightCheck{
+			Id:      PreflightCheckDnssec,
+			Status:  PreflightPass,
+			Message: fmt.Sprintf("%v is signed and DNSSEC validates", name),
+		}
+	}
+
+	return PreflightCheck{
+		Id:      PreflightCheckDnssec,
+		Status:  PreflightPass,
+		Message: fmt.Sprintf("%v is not signed with DNSSEC", name),
+	}
+}
+
+// checkConflicts fails on CNAME at the domain, it can not coexist with
+// A record and redirects validation elsewhere
+func (p *preflightService) checkConflicts(
+	ctx context.Context, name string,
+) PreflightCheck {
+	result, err := p.resolver.Lookup(ctx, name, "CNAME")
+	if err != nil {
+		return lookupFailed(PreflightCheckConflicts, name, err)
+	}
+
+	if values := recordValues(result.Records); len(values) > 0 {
+		return PreflightCheck{
+			Id:     PreflightCheckConflicts,
+			Status: PreflightFail,
+			Message: fmt.Sprintf(
+				"%v is CNAME to %v", name, strings.Join(values, ", "),
+			),
+			Remediation: fmt.Sprintf(
+				"Replace CNAME record of %v with A record pointing to the gateway", name,
+			),
+		}
+	}
+
+	return PreflightCheck{
+		Id:      PreflightCheckConflicts,
+		Status:  PreflightPass,
+		Message: fmt.Sprintf("no CNAME record conflicts with %v", name),
+	}
+}
+
+func (p *preflightService) checkTtl(
+	recordType string, result port.DnsLookupResult, lookupErr error,
+) PreflightCheck {
+	if lookupErr != nil || len(result.Records) == 0 {
+		return PreflightCheck{
+			Id:      PreflightCheckTtl,
+			Status:  PreflightWarn,
+			Message: fmt.Sprintf("ttl not checked, there is no %v record", recordType),
+		}
+	}
+
+	var maxTtl uint32
+	for _, v := range result.Records {
+		if v.Ttl > maxTtl {
+			maxTtl = v.Ttl
+		}
+	}
+
+	if maxTtl > maxRecommendedTtl {
+		return PreflightCheck{
+			Id:     PreflightCheckTtl,
+			Status: PreflightWarn,
+			Message: fmt.Sprintf(
+				"%v record ttl is %vs, ip changes take that long to propagate",
+				recordType, maxTtl,
+			),
+			Remediation: fmt.Sprintf("Lower ttl of %v records to 300 seconds", recordType),
+		}
+	}
+
+	return PreflightCheck{
+		Id:      PreflightCheckTtl,
+		Status:  PreflightPass,
+		Message: fmt.Sprintf("%v record ttl is %vs", recordType, maxTtl),
+	}
+}
+
+func lookupFailed(id, name string, err error) PreflightCheck {
+	return PreflightCheck{
+		Id:          id,
+		Status:      PreflightWarn,
+		Message:     fmt.Sprintf("lookup of %v failed: %v", name, err),
+		Remediation: "Check again later, name servers of the domain may be unreachable",
+	}
+}
+
+// caaCandidates returns name and its parents, without top level domain
+func caaCandidates(name string) []string {
+	labels := strings.Split(name, ".")
+	result := make([]string, 0, len(labels)-1)
+	for i := 0; i < len(labels)-1; i++ {
+		result = append(result, strings.Join(labels[i:], "."))
+	}
+
+	return result
+}
+
+// caaIssuerDomain strips parameters from issue value, eg.
+// "letsencrypt.org; validationmethods=dns-01"
+func caaIssuerDomain(value string) string {
+	issuer, _, _ := strings.Cut(value, ";")
+	return strings.ToLower(strings.TrimSpace(issuer))
+}
+
+func recordValues(records []port.ResolvedRecord) []string {
+	result := make([]string, 0, len(records))
+	for _, v := range records {
+		result = append(result, v.Value)
+	}
+
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func worstStatus(checks []PreflightCheck) string {
+	status := PreflightPass
+	for _, v := range checks {
+		switch {
+		case v.Status == PreflightFail:
+			return PreflightFail
+		case v.Status == PreflightWarn:
+			status = PreflightWarn
+		}
+	}
+
+	return status
+}
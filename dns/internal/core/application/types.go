@@ -2,14 +2,97 @@ package application
 
 import (
 	"fmt"
+	"net"
 	"prem-gateway/dns/internal/core/domain"
+	"time"
 )
 
+// DnsInfo is json encoded in audit events
 type DnsInfo struct {
-	Domain   string
-	Ip       string
-	NodeName string
-	Email    string
+	Domain   string `json:"domain"`
+	Ip       string `json:"ip"`
+	NodeName string `json:"node_name"`
+	Email    string `json:"email"`
+	AcmeCa   string `json:"acme_ca"`
+}
+
+type GatewayIp struct {
+	Ip     string
+	Source string
+}
+
+type IpChangeEvent struct {
+	OldIp         string
+	NewIp         string
+	Source        string
+	ProviderError string
+	CreatedAt     time.Time
+}
+
+// Notification is controllerd notification waiting in outbox
+type Notification struct {
+	Id            int64
+	Type          string
+	Domain        string
+	Email         string
+	AcmeCa        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	// DeadAt is set once delivery is given up
+	DeadAt *time.Time
+}
+
+// AuditEvent values Before and After are json encoded
+type AuditEvent struct {
+	Id        int64
+	Actor     string
+	Action    string
+	Resource  string
+	Before    string
+	After     string
+	Outcome   string
+	Error     string
+	CreatedAt time.Time
+}
+
+type AuditFilter struct {
+	From    time.Time
+	To      time.Time
+	Actions []string
+	Limit   int
+}
+
+// PreflightReport is checklist of dns issues preventing certificate
+// issuance, Status is the worst status of all checks
+type PreflightReport struct {
+	Domain     string
+	ExpectedIp string
+	Status     string
+	Checks     []PreflightCheck
+	CreatedAt  time.Time
+}
+
+// PreflightCheck Remediation is empty for passed checks
+type PreflightCheck struct {
+	Id          string
+	Status      string
+	Message     string
+	Remediation string
+}
+
+// ZoneAnswer holds records of a single name, Authoritative is false if name
+// is not in provisioned zone
+type ZoneAnswer struct {
+	Authoritative bool
+	Zone          string
+	Apex          bool
+	Ips           []net.IP
+	Txt           []string
+	NameServer    string
+	// CaaIssuer is set only for zone apex
+	CaaIssuer string
 }
 
 func FromAppDnsInfoToDomainDnsInfo(dnsInfo DnsInfo) domain.DnsInfo {
@@ -19,6 +102,7 @@ func FromAppDnsInfoToDomainDnsInfo(dnsInfo DnsInfo) domain.DnsInfo {
 		Ip:        dnsInfo.Ip,
 		NodeName:  dnsInfo.NodeName,
 		Email:     dnsInfo.Email,
+		AcmeCa:    dnsInfo.AcmeCa,
 	}
 }
 
@@ -28,5 +112,61 @@ func FromDomainDnsInfoToAppDnsInfo(dnsInfo domain.DnsInfo) DnsInfo {
 		Ip:       dnsInfo.Ip,
 		NodeName: dnsInfo.NodeName,
 		Email:    dnsInfo.Email,
+		AcmeCa:   dnsInfo.AcmeCa,
+	}
+}
+
+func FromDomainIpChangeEventToAppIpChangeEvent(
+	event domain.IpChangeEvent,
+) IpChangeEvent {
+	return IpChangeEvent{
+		OldIp:         event.OldIp,
+		NewIp:         event.NewIp,
+		Source:        event.Source,
+		ProviderError: event.ProviderError,
+		CreatedAt:     event.CreatedAt,
+	}
+}
+
+func FromDomainNotificationToAppNotification(
+	notification domain.Notification,
+) Notification {
+	return Notification{
+		Id:            notification.Id,
+		Type:          string(notification.Type),
+		Domain:        notification.Domain,
+		Email:         notification.Email,
+		AcmeCa:        notification.AcmeCa,
+		Attempts:      notification.Attempts,
+		LastError:     notification.LastError,
+		NextAttemptAt: notification.NextAttemptAt,
+		CreatedAt:     notification.CreatedAt,
+		DeadAt:        notification.DeadAt,
+	}
+}
+
+func FromAppAuditEventToDomainAuditEvent(event AuditEvent) domain.AuditEvent {
+	return domain.AuditEvent{
+		Actor:    event.Actor,
+		Action:   event.Action,
+		Resource: event.Resource,
+		Before:   event.Before,
+		After:    event.After,
+		Outcome:  event.Outcome,
+		Error:    event.Error,
+	}
+}
+
+func FromDomainAuditEventToAppAuditEvent(event domain.AuditEvent) AuditEvent {
+	return AuditEvent{
+		Id:        event.Id,
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Resource:  event.Resource,
+		Before:    event.Before,
+		After:     event.After,
+		Outcome:   event.Outcome,
+		Error:     event.Error,
+		CreatedAt: event.CreatedAt,
 	}
 }
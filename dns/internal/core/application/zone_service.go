@@ -0,0 +1,163 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"prem-gateway/dns/internal/core/domain"
+	"strings"
+)
+
+const (
+	DefaultCaaIssuer = "letsencrypt.org"
+)
+
+var (
+	ErrOutsideOfZone = &domain.Error{
+		Kind:    domain.KindValidation,
+		Code:    "outside_of_zone",
+		Message: "name is outside of provisioned zone",
+	}
+)
+
+// ZoneService provides data served by built-in authoritative dns server,
+// zone is the provisioned domain
+type ZoneService interface {
+	Resolve(ctx context.Context, name string) (ZoneAnswer, error)
+	PresentTxtRecord(ctx context.Context, fqdn, value string) error
+	CleanupTxtRecord(ctx context.Context, fqdn, value string) error
+}
+
+type zoneService struct {
+	repositorySvc domain.RepositoryService
+	// nsName is name server advertised for the zone, if empty ns1.<zone>
+	// is used
+	nsName    string
+	caaIssuer string
+}
+
+func NewZoneService(
+	repositorySvc domain.RepositoryService,
+	nsName string,
+	caaIssuer string,
+) (ZoneService, error) {
+	if caaIssuer == "" {
+		caaIssuer = DefaultCaaIssuer
+	}
+
+	return &zoneService{
+		repositorySvc: repositorySvc,
+		nsName:        normalizeName(nsName),
+		caaIssuer:     caaIssuer,
+	}, nil
+}
+
+// Resolve returns all records of the given name, apex and every name below
+// it(wildcard) resolve to the gateway ip
+func (z *zoneService) Resolve(
+	ctx context.Context, name string,
+) (ZoneAnswer, error) {
+	name = normalizeName(name)
+
+	dnsInfo, err := z.repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrEntityNotFound) {
+			return ZoneAnswer{}, nil
+		}
+
+		return ZoneAnswer{}, err
+	}
+
+	zone := normalizeName(dnsInfo.Domain)
+	if !inZone(name, zone) {
+		return ZoneAnswer{}, nil
+	}
+
+	answer := ZoneAnswer{
+		Authoritative: true,
+		Zone:          zone,
+		Apex:          name == zone,
+	}
+
+	if ip := net.ParseIP(dnsInfo.Ip); ip != nil {
+		answer.Ips = []net.IP{ip}
+	}
+
+	txtRecords, err := z.repositorySvc.TxtRecordRepository().GetAll(ctx, name)
+	if err != nil {
+		return ZoneAnswer{}, err
+	}
+	for _, v := range txtRecords {
+		answer.Txt = append(answer.Txt, v.Value)
+	}
+
+	answer.NameServer = z.nsName
+	if answer.NameServer == "" {
+		answer.NameServer = "ns1." + zone
+	}
+	if answer.Apex {
+		answer.CaaIssuer = z.caaIssuer
+	}
+
+	return answer, nil
+}
+
+func (z *zoneService) PresentTxtRecord(
+	ctx context.Context, fqdn, value string,
+) error {
+	record, err := z.txtRecord(ctx, fqdn, value)
+	if err != nil {
+		return err
+	}
+
+	return z.repositorySvc.TxtRecordRepository().Add(ctx, record)
+}
+
+func (z *zoneService) CleanupTxtRecord(
+	ctx context.Context, fqdn, value string,
+) error {
+	record, err := z.txtRecord(ctx, fqdn, value)
+	if err != nil {
+		return err
+	}
+
+	return z.repositorySvc.TxtRecordRepository().Delete(ctx, record)
+}
+
+func (z *zoneService) txtRecord(
+	ctx context.Context, fqdn, value string,
+) (domain.TxtRecord, error) {
+	fqdn = normalizeName(fqdn)
+	if fqdn == "" || value == "" {
+		return domain.TxtRecord{}, domain.NewValidationError(
+			"invalid_txt_record", "fqdn and value are required",
+		)
+	}
+
+	dnsInfo, err := z.repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrEntityNotFound) {
+			return domain.TxtRecord{}, fmt.Errorf("%w: %v", ErrOutsideOfZone, fqdn)
+		}
+
+		return domain.TxtRecord{}, err
+	}
+
+	if !inZone(fqdn, normalizeName(dnsInfo.Domain)) {
+		return domain.TxtRecord{}, fmt.Errorf("%w: %v", ErrOutsideOfZone, fqdn)
+	}
+
+	return domain.TxtRecord{
+		Fqdn:  fqdn,
+		Value: value,
+	}, nil
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+func inZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent records who changed domain or gateway configuration, events
+// are never updated or deleted
+type AuditEvent struct {
+	Id int64
+	// Actor is identity forwarded by authd, or name of the daemon for
+	// changes it made on its own
+	Actor    string
+	Action   string
+	Resource string
+	// Before and After are json encoded values, empty if there is none
+	Before    string
+	After     string
+	Outcome   string
+	Error     string
+	CreatedAt time.Time
+}
+
+// AuditFilter selects events created in [From, To), zero value of a field
+// means it is not applied
+type AuditFilter struct {
+	From    time.Time
+	To      time.Time
+	Actions []string
+	Limit   int
+}
+
+type AuditEventRepository interface {
+	Add(ctx context.Context, event AuditEvent) error
+	// GetAll returns events matching filter, oldest first
+	GetAll(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// Match reports if event satisfies filter, Limit is not considered
+func (f AuditFilter) Match(event AuditEvent) bool {
+	if !f.From.IsZero() && event.CreatedAt.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && !event.CreatedAt.Before(f.To) {
+		return false
+	}
+
+	if len(f.Actions) == 0 {
+		return true
+	}
+
+	for _, v := range f.Actions {
+		if v == event.Action {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,4 +6,7 @@ type DnsInfo struct {
 	Ip        string
 	NodeName  string
 	Email     string
+	// AcmeCa is AcmeCaStaging, AcmeCaProduction or custom ACME directory
+	// url, empty leaves CA to controllerd
+	AcmeCa string
 }
@@ -3,8 +3,15 @@ package domain
 import "context"
 
 type DnsRepository interface {
-	Create(ctx context.Context, dnsInfo DnsInfo) error
-	Delete(ctx context.Context, domainName string) error
+	// Create stores dnsInfo and notifications in one transaction
+	Create(ctx context.Context, dnsInfo DnsInfo, notifications ...Notification) error
+	// Update stores dnsInfo and notifications in one transaction,
+	// ErrEntityNotFound is returned and nothing is stored if domain does
+	// not exist
+	Update(ctx context.Context, dnsInfo DnsInfo, notifications ...Notification) error
+	// Delete removes domain with its undelivered notifications and stores
+	// notifications in one transaction
+	Delete(ctx context.Context, domainName string, notifications ...Notification) error
 	Get(ctx context.Context, domainName string) (*DnsInfo, error)
 	GetExistingDomain(ctx context.Context) (*DnsInfo, error)
 }
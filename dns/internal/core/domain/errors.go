@@ -1,8 +1,200 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies error independently of the layer it originated in, it is
+// used by the transport layer to pick response status
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	// KindNotFound is returned when requested entity does not exist
+	KindNotFound
+	// KindConflict is returned when entity already exists
+	KindConflict
+	// KindValidation is returned when request is well-formed but can not be
+	// processed, eg. A record does not point to the gateway
+	KindValidation
+	// KindUnavailable is returned when dependency(db, controllerd) can not
+	// be reached, request can be retried later
+	KindUnavailable
+	// KindUpstreamFailed is returned when external service(dns provider,
+	// resolver, ip discovery) failed
+	KindUpstreamFailed
+	// KindInvalidArgument is returned when input is malformed, eg. domain
+	// name with invalid characters, Fields describe each invalid field
+	KindInvalidArgument
+)
+
+// Error carries Kind and machine-readable Code through the layers, Err is
+// the underlying cause
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+	Fields  []FieldError
+}
+
+// FieldError describes why single input field is invalid
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%v: %v", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports errors with the same code as equal, so errors.Is works for
+// sentinel errors wrapped with cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return e.Code == t.Code
+}
+
+// Wrap returns copy of sentinel error with err set as cause
+func (e *Error) Wrap(err error) error {
+	return &Error{
+		Kind:    e.Kind,
+		Code:    e.Code,
+		Message: e.Message,
+		Err:     err,
+		Fields:  e.Fields,
+	}
+}
 
 var (
-	ErrEntityNotFound = errors.New("entity not found")
-	ErrAlreadyExists  = errors.New("entity already exists")
+	ErrEntityNotFound = &Error{
+		Kind:    KindNotFound,
+		Code:    "entity_not_found",
+		Message: "entity not found",
+	}
+	ErrAlreadyExists = &Error{
+		Kind:    KindConflict,
+		Code:    "already_exists",
+		Message: "entity already exists",
+	}
+	ErrDomainNotReachable = &Error{
+		Kind:    KindValidation,
+		Code:    "domain_not_reachable",
+		Message: "domain not reachable",
+	}
+	ErrDnsRecordNotFound = &Error{
+		Kind:    KindValidation,
+		Code:    "dns_record_not_found",
+		Message: "dns record not found, check if A record is set correctly",
+	}
+	ErrDnsRecordNotPropagated = &Error{
+		Kind:    KindUpstreamFailed,
+		Code:    "dns_record_not_propagated",
+		Message: "dns record not propagated",
+	}
+	ErrDnsLookupFailed = &Error{
+		Kind:    KindUpstreamFailed,
+		Code:    "dns_lookup_failed",
+		Message: "dns lookup failed",
+	}
+	ErrIpDiscoveryFailed = &Error{
+		Kind:    KindUpstreamFailed,
+		Code:    "ip_discovery_failed",
+		Message: "failed to discover gateway public ip",
+	}
+	ErrDnsProviderFailed = &Error{
+		Kind:    KindUpstreamFailed,
+		Code:    "dns_provider_failed",
+		Message: "dns provider request failed",
+	}
+	ErrStorageUnavailable = &Error{
+		Kind:    KindUnavailable,
+		Code:    "storage_unavailable",
+		Message: "storage unavailable",
+	}
+	ErrControllerdUnavailable = &Error{
+		Kind:    KindUnavailable,
+		Code:    "controllerd_unavailable",
+		Message: "controller daemon unavailable",
+	}
+	ErrControllerdFailed = &Error{
+		Kind:    KindUpstreamFailed,
+		Code:    "controllerd_failed",
+		Message: "controller daemon request failed",
+	}
 )
+
+// NewInvalidArgumentError returns error listing invalid fields, nil if
+// there are none
+func NewInvalidArgumentError(fields ...FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	message := "invalid argument"
+	for _, v := range fields {
+		message = fmt.Sprintf("%v, %v: %v", message, v.Field, v.Message)
+	}
+
+	return &Error{
+		Kind:    KindInvalidArgument,
+		Code:    "invalid_argument",
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// FieldsOf returns invalid fields of the first Error in err chain
+func FieldsOf(err error) []FieldError {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Fields
+	}
+
+	return nil
+}
+
+// NewValidationError returns validation error with the given code
+func NewValidationError(code, message string) error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// KindOf returns Kind of the first Error in err chain, KindInternal if
+// there is none
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindInternal
+}
+
+// CodeOf returns Code of the first Error in err chain, "internal" if there
+// is none
+func CodeOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return "internal"
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IpChangeEvent records public ip change of the gateway detected by dynamic
+// dns worker
+type IpChangeEvent struct {
+	Domain string
+	OldIp  string
+	NewIp  string
+	// Source is ip provider which discovered new ip
+	Source string
+	// ProviderError is set if pushing new ip to dns provider failed
+	ProviderError string
+	CreatedAt     time.Time
+}
+
+type IpChangeEventRepository interface {
+	Add(ctx context.Context, event IpChangeEvent) error
+	GetAll(ctx context.Context, domainName string, limit int) ([]IpChangeEvent, error)
+}
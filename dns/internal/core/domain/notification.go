@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationDomainProvisioned NotificationType = "domain_provisioned"
+)
+
+// Notification is pending controllerd notification stored in outbox in the
+// same transaction as domain change it announces
+type Notification struct {
+	Id     int64
+	Type   NotificationType
+	Domain string
+	Email  string
+	AcmeCa string
+	// Attempts is number of failed deliveries
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+	// DeadAt is set once delivery is given up, dead notification is kept
+	// undelivered for inspection but not retried
+	DeadAt *time.Time
+}
+
+type NotificationRepository interface {
+	// GetUndelivered returns all undelivered notifications, oldest first
+	GetUndelivered(ctx context.Context) ([]Notification, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error
+	// MarkDead records last failed attempt and gives up delivery
+	MarkDead(ctx context.Context, id int64, lastError string) error
+}
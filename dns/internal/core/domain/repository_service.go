@@ -2,4 +2,8 @@ package domain
 
 type RepositoryService interface {
 	DnsRepository() DnsRepository
+	IpChangeEventRepository() IpChangeEventRepository
+	TxtRecordRepository() TxtRecordRepository
+	NotificationRepository() NotificationRepository
+	AuditEventRepository() AuditEventRepository
 }
@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TxtRecord is served by built-in authoritative dns server, used for ACME
+// DNS-01 challenges
+type TxtRecord struct {
+	// Fqdn is lower case fully qualified name without trailing dot
+	Fqdn      string
+	Value     string
+	CreatedAt time.Time
+}
+
+type TxtRecordRepository interface {
+	Add(ctx context.Context, record TxtRecord) error
+	Delete(ctx context.Context, record TxtRecord) error
+	GetAll(ctx context.Context, fqdn string) ([]TxtRecord, error)
+}
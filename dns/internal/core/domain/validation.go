@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxDomainLength = 253
+	maxLabelLength  = 63
+	maxEmailLength  = 254
+	maxUrlLength    = 2048
+
+	// AcmeCaStaging is Let's Encrypt staging, its certificates are not
+	// trusted by browsers
+	AcmeCaStaging    = "staging"
+	AcmeCaProduction = "production"
+)
+
+var (
+	// domainProfile maps unicode names to lower case punycode, same as
+	// browsers do on lookup
+	domainProfile = idna.New(
+		idna.MapForLookup(),
+		idna.BidiRule(),
+		idna.ValidateLabels(true),
+		idna.StrictDomainName(true),
+		idna.Transitional(false),
+	)
+	labelRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	// emailLocalRegexp is stricter than RFC 5322, email ends up in Traefik
+	// command line so quoting and backticks are not allowed
+	emailLocalRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+$`)
+)
+
+// NormalizeDomain returns name as stored by dnsd: lower case, IDN labels
+// converted to punycode, without trailing dot. Name must have at least
+// one label below public suffix, so bare TLDs like com or co.uk and IP
+// literals are rejected
+func NormalizeDomain(name string) (string, error) {
+	ascii, fieldErr := normalizeDomain(name)
+	if fieldErr != nil {
+		fieldErr.Field = "domain"
+		return "", NewInvalidArgumentError(*fieldErr)
+	}
+
+	return ascii, nil
+}
+
+// NormalizeEmail returns email with domain part normalized, empty email is
+// allowed
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+
+	invalid := func(message string) error {
+		return NewInvalidArgumentError(FieldError{
+			Field:   "email",
+			Code:    "invalid_email",
+			Message: message,
+		})
+	}
+
+	if len(email) > maxEmailLength {
+		return "", invalid(
+			fmt.Sprintf("email is longer than %v characters", maxEmailLength),
+		)
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "", invalid("email must be in local@domain format")
+	}
+
+	local := email[:at]
+	if !emailLocalRegexp.MatchString(local) {
+		return "", invalid("email local part contains invalid characters")
+	}
+
+	host, fieldErr := normalizeDomain(email[at+1:])
+	if fieldErr != nil {
+		return "", invalid("email domain: " + fieldErr.Message)
+	}
+
+	return local + "@" + host, nil
+}
+
+// NormalizeAcmeCa returns AcmeCaStaging, AcmeCaProduction or https url of
+// custom ACME directory, empty CA is allowed
+func NormalizeAcmeCa(acmeCa string) (string, error) {
+	acmeCa = strings.TrimSpace(acmeCa)
+	switch strings.ToLower(acmeCa) {
+	case "":
+		return "", nil
+	case AcmeCaStaging, AcmeCaProduction:
+		return strings.ToLower(acmeCa), nil
+	}
+
+	invalid := func(message string) error {
+		return NewInvalidArgumentError(FieldError{
+			Field:   "acme_ca",
+			Code:    "invalid_acme_ca",
+			Message: message,
+		})
+	}
+
+	if len(acmeCa) > maxUrlLength {
+		return "", invalid(
+			fmt.Sprintf("acme ca is longer than %v characters", maxUrlLength),
+		)
+	}
+
+	// url ends up in Traefik command line
+	parsed, err := url.Parse(acmeCa)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" ||
+		parsed.User != nil || strings.ContainsAny(acmeCa, " \t\"'`") {
+		return "", invalid(fmt.Sprintf(
+			"acme ca must be %v, %v or https url of ACME directory",
+			AcmeCaStaging, AcmeCaProduction,
+		))
+	}
+
+	return parsed.String(), nil
+}
+
+// NormalizeIp returns ip in canonical form, empty ip is allowed
+func NormalizeIp(ip string) (string, error) {
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return "", nil
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", NewInvalidArgumentError(FieldError{
+			Field:   "ip",
+			Code:    "invalid_ip",
+			Message: "ip is not valid IPv4 or IPv6 address",
+		})
+	}
+
+	return parsed.String(), nil
+}
+
+func normalizeDomain(name string) (string, *FieldError) {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "", &FieldError{
+			Code:    "required",
+			Message: "domain is required",
+		}
+	}
+
+	if net.ParseIP(name) != nil {
+		return "", &FieldError{
+			Code:    "ip_literal",
+			Message: "ip address is not allowed, domain name is required",
+		}
+	}
+
+	ascii, err := domainProfile.ToASCII(name)
+	if err != nil {
+		return "", &FieldError{
+			Code:    "invalid_domain",
+			Message: err.Error(),
+		}
+	}
+
+	if len(ascii) > maxDomainLength {
+		return "", &FieldError{
+			Code:    "domain_too_long",
+			Message: fmt.Sprintf("domain is longer than %v characters", maxDomainLength),
+		}
+	}
+
+	labels := strings.Split(ascii, ".")
+	for _, v := range labels {
+		if len(v) > maxLabelLength {
+			return "", &FieldError{
+				Code:    "label_too_long",
+				Message: fmt.Sprintf("label %v is longer than %v characters", v, maxLabelLength),
+			}
+		}
+
+		if !labelRegexp.MatchString(v) {
+			return "", &FieldError{
+				Code:    "invalid_domain",
+				Message: fmt.Sprintf("label %q contains invalid characters", v),
+			}
+		}
+	}
+
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", &FieldError{
+			Code:    "invalid_domain",
+			Message: "top level domain can not be numeric",
+		}
+	}
+
+	if _, err := publicsuffix.EffectiveTLDPlusOne(ascii); err != nil {
+		return "", &FieldError{
+			Code:    "public_suffix",
+			Message: fmt.Sprintf("%v is public suffix, domain below it is required", ascii),
+		}
+	}
+
+	return ascii, nil
+}
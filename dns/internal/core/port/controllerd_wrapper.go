@@ -3,6 +3,8 @@ package port
 import "context"
 
 type ControllerdWrapper interface {
-	DomainProvisioned(ctx context.Context, email, domainName string) error
+	// DomainProvisioned asks controllerd to restart services with TLS for
+	// domain, empty acmeCa leaves CA to controllerd
+	DomainProvisioned(ctx context.Context, email, domainName, acmeCa string) error
 	DomainDeleted(ctx context.Context, domainName string) error
 }
@@ -27,13 +27,13 @@ func (_m *MockControllerdWrapper) DomainDeleted(ctx context.Context, domainName
 	return r0
 }
 
-// DomainProvisioned provides a mock function with given fields: ctx, email, domainName
-func (_m *MockControllerdWrapper) DomainProvisioned(ctx context.Context, email string, domainName string) error {
-	ret := _m.Called(ctx, email, domainName)
+// DomainProvisioned provides a mock function with given fields: ctx, email, domainName, acmeCa
+func (_m *MockControllerdWrapper) DomainProvisioned(ctx context.Context, email string, domainName string, acmeCa string) error {
+	ret := _m.Called(ctx, email, domainName, acmeCa)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
-		r0 = rf(ctx, email, domainName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, email, domainName, acmeCa)
 	} else {
 		r0 = ret.Error(0)
 	}
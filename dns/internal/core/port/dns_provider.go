@@ -0,0 +1,18 @@
+package port
+
+import "context"
+
+// DnsProvider manages records in the zone hosting gateway domain
+type DnsProvider interface {
+	Name() string
+	UpsertRecord(ctx context.Context, record DnsRecord) error
+	DeleteRecord(ctx context.Context, record DnsRecord) error
+}
+
+type DnsRecord struct {
+	// Name is fully qualified name without trailing dot, eg. *.example.com
+	Name string
+	// Type is A or AAAA
+	Type  string
+	Value string
+}
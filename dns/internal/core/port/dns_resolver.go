@@ -0,0 +1,30 @@
+package port
+
+import "context"
+
+// DnsResolver queries public dns, it is used to check how the rest of the
+// world, eg. Let's Encrypt, sees the domain
+type DnsResolver interface {
+	// Lookup returns records of recordType(A, AAAA, CAA, CNAME) of name,
+	// missing name or records is not an error
+	Lookup(ctx context.Context, name, recordType string) (DnsLookupResult, error)
+}
+
+type DnsLookupResult struct {
+	// Records holds only records of the requested type
+	Records []ResolvedRecord
+	// Authenticated is true if resolver validated answer with DNSSEC
+	Authenticated bool
+	// DnssecFailed is true if resolver rejected answer as bogus, Records
+	// are then taken from query with DNSSEC checking disabled
+	DnssecFailed bool
+}
+
+type ResolvedRecord struct {
+	Type  string
+	Value string
+	Ttl   uint32
+	// Flag and Tag are set only for CAA records
+	Flag uint8
+	Tag  string
+}
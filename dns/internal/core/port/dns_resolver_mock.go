@@ -0,0 +1,52 @@
+// Code generated by mockery v2.33.1. DO NOT EDIT.
+
+package port
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockDnsResolver is an autogenerated mock type for the DnsResolver type
+type MockDnsResolver struct {
+	mock.Mock
+}
+
+// Lookup provides a mock function with given fields: ctx, name, recordType
+func (_m *MockDnsResolver) Lookup(ctx context.Context, name string, recordType string) (DnsLookupResult, error) {
+	ret := _m.Called(ctx, name, recordType)
+
+	var r0 DnsLookupResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (DnsLookupResult, error)); ok {
+		return rf(ctx, name, recordType)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) DnsLookupResult); ok {
+		r0 = rf(ctx, name, recordType)
+	} else {
+		r0 = ret.Get(0).(DnsLookupResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, name, recordType)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewMockDnsResolver creates a new instance of MockDnsResolver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDnsResolver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDnsResolver {
+	mock := &MockDnsResolver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,5 +4,12 @@ import "context"
 
 type IpService interface {
 	VerifyDnsRecord(ctx context.Context, ip, domainName string) (bool, error)
-	GetHostIp(ctx context.Context) (string, error)
+	GetHostIp(ctx context.Context) (HostIp, error)
+}
+
+// HostIp is public ip of the gateway together with the provider it was
+// discovered by
+type HostIp struct {
+	Ip     string
+	Source string
 }
@@ -14,18 +14,18 @@ type MockIpService struct {
 }
 
 // GetHostIp provides a mock function with given fields: ctx
-func (_m *MockIpService) GetHostIp(ctx context.Context) (string, error) {
+func (_m *MockIpService) GetHostIp(ctx context.Context) (HostIp, error) {
 	ret := _m.Called(ctx)
 
-	var r0 string
+	var r0 HostIp
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) (HostIp, error)); ok {
 		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) HostIp); ok {
 		r0 = rf(ctx)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(HostIp)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
@@ -0,0 +1,7 @@
+package port
+
+import "context"
+
+type ReachabilityChecker interface {
+	CheckReachability(ctx context.Context, domainName, nonce, token string) error
+}
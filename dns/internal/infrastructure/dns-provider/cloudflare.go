@@ -0,0 +1,196 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"prem-gateway/dns/internal/core/port"
+)
+
+const (
+	cloudflareApiUrl = "https://api.cloudflare.com/client/v4"
+)
+
+type cloudflareProvider struct {
+	api  *apiClient
+	zone string
+	ttl  int
+}
+
+// NewCloudflareProvider returns provider managing records through Cloudflare
+// API at apiUrl, empty apiUrl is public API. Token needs Zone:Read and
+// DNS:Edit permissions
+func NewCloudflareProvider(apiUrl, apiToken, zone string, ttl int) port.DnsProvider {
+	if apiUrl == "" {
+		apiUrl = cloudflareApiUrl
+	}
+
+	return &cloudflareProvider{
+		api:  newApiClient(apiUrl, apiToken),
+		zone: zone,
+		ttl:  ttl,
+	}
+}
+
+type cloudflareZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cloudflareRecord struct {
+	Id      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Ttl     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareZonesResponse struct {
+	Result []cloudflareZone `json:"result"`
+}
+
+type cloudflareRecordsResponse struct {
+	Result []cloudflareRecord `json:"result"`
+}
+
+func (c *cloudflareProvider) Name() string {
+	return CloudflareProvider
+}
+
+func (c *cloudflareProvider) UpsertRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zoneId, err := c.findZoneId(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.findRecords(ctx, zoneId, record)
+	if err != nil {
+		return err
+	}
+
+	// traffic must reach the gateway directly for tls challenge to pass,
+	// hence records are not proxied
+	cfRecord := cloudflareRecord{
+		Type:    record.Type,
+		Name:    record.Name,
+		Content: record.Value,
+		Ttl:     c.ttl,
+		Proxied: false,
+	}
+
+	if len(existing) == 0 {
+		return c.api.do(
+			ctx,
+			http.MethodPost,
+			fmt.Sprintf("/zones/%s/dns_records", zoneId),
+			cfRecord,
+			nil,
+		)
+	}
+
+	if err := c.api.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/zones/%s/dns_records/%s", zoneId, existing[0].Id),
+		cfRecord,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	// record set should hold only gateway ip
+	for _, v := range existing[1:] {
+		if err := c.deleteRecord(ctx, zoneId, v.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *cloudflareProvider) DeleteRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zoneId, err := c.findZoneId(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.findRecords(ctx, zoneId, record)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range existing {
+		if err := c.deleteRecord(ctx, zoneId, v.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *cloudflareProvider) findZoneId(
+	ctx context.Context, name string,
+) (string, error) {
+	zones := candidateZones(name)
+	if c.zone != "" {
+		zones = []string{c.zone}
+	}
+
+	for _, v := range zones {
+		var resp cloudflareZonesResponse
+		if err := c.api.do(
+			ctx,
+			http.MethodGet,
+			"/zones?name="+url.QueryEscape(v),
+			nil,
+			&resp,
+		); err != nil {
+			return "", err
+		}
+
+		if len(resp.Result) > 0 {
+			return resp.Result[0].Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrZoneNotFound, name)
+}
+
+func (c *cloudflareProvider) findRecords(
+	ctx context.Context, zoneId string, record port.DnsRecord,
+) ([]cloudflareRecord, error) {
+	query := url.Values{}
+	query.Set("type", record.Type)
+	query.Set("name", record.Name)
+
+	var resp cloudflareRecordsResponse
+	if err := c.api.do(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/zones/%s/dns_records?%s", zoneId, query.Encode()),
+		nil,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return resp.Result, nil
+}
+
+func (c *cloudflareProvider) deleteRecord(
+	ctx context.Context, zoneId, recordId string,
+) error {
+	return c.api.do(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/zones/%s/dns_records/%s", zoneId, recordId),
+		nil,
+		nil,
+	)
+}
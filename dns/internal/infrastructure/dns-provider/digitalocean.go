@@ -0,0 +1,177 @@
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"prem-gateway/dns/internal/core/port"
+)
+
+const (
+	digitalOceanApiUrl = "https://api.digitalocean.com/v2"
+)
+
+type digitalOceanProvider struct {
+	api  *apiClient
+	zone string
+	ttl  int
+}
+
+// NewDigitalOceanProvider returns provider managing records through
+// DigitalOcean domains API at apiUrl, empty apiUrl is public API
+func NewDigitalOceanProvider(apiUrl, apiToken, zone string, ttl int) port.DnsProvider {
+	if apiUrl == "" {
+		apiUrl = digitalOceanApiUrl
+	}
+
+	return &digitalOceanProvider{
+		api:  newApiClient(apiUrl, apiToken),
+		zone: zone,
+		ttl:  ttl,
+	}
+}
+
+type digitalOceanRecord struct {
+	Id   int    `json:"id,omitempty"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+	Ttl  int    `json:"ttl"`
+}
+
+type digitalOceanRecordsResponse struct {
+	DomainRecords []digitalOceanRecord `json:"domain_records"`
+}
+
+func (d *digitalOceanProvider) Name() string {
+	return DigitalOceanProvider
+}
+
+func (d *digitalOceanProvider) UpsertRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zone, err := d.findZone(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.findRecords(ctx, zone, record)
+	if err != nil {
+		return err
+	}
+
+	doRecord := digitalOceanRecord{
+		Type: record.Type,
+		Name: relativeName(record.Name, zone),
+		Data: record.Value,
+		Ttl:  d.ttl,
+	}
+
+	if len(existing) == 0 {
+		return d.api.do(
+			ctx,
+			http.MethodPost,
+			fmt.Sprintf("/domains/%s/records", zone),
+			doRecord,
+			nil,
+		)
+	}
+
+	if err := d.api.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("/domains/%s/records/%d", zone, existing[0].Id),
+		doRecord,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	for _, v := range existing[1:] {
+		if err := d.deleteRecord(ctx, zone, v.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *digitalOceanProvider) DeleteRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zone, err := d.findZone(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.findRecords(ctx, zone, record)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range existing {
+		if err := d.deleteRecord(ctx, zone, v.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *digitalOceanProvider) findZone(
+	ctx context.Context, name string,
+) (string, error) {
+	if d.zone != "" {
+		return d.zone, nil
+	}
+
+	for _, v := range candidateZones(name) {
+		err := d.api.do(ctx, http.MethodGet, "/domains/"+v, nil, nil)
+		if err == nil {
+			return v, nil
+		}
+
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || apiErr.statusCode != http.StatusNotFound {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrZoneNotFound, name)
+}
+
+func (d *digitalOceanProvider) findRecords(
+	ctx context.Context, zone string, record port.DnsRecord,
+) ([]digitalOceanRecord, error) {
+	query := url.Values{}
+	query.Set("type", record.Type)
+	// api filters by fully qualified name
+	query.Set("name", record.Name)
+
+	var resp digitalOceanRecordsResponse
+	if err := d.api.do(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("/domains/%s/records?%s", zone, query.Encode()),
+		nil,
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+
+	return resp.DomainRecords, nil
+}
+
+func (d *digitalOceanProvider) deleteRecord(
+	ctx context.Context, zone string, recordId int,
+) error {
+	return d.api.do(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("/domains/%s/records/%d", zone, recordId),
+		nil,
+		nil,
+	)
+}
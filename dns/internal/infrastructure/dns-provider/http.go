@@ -0,0 +1,93 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	apiTimeout = 15 * time.Second
+)
+
+type apiError struct {
+	method     string
+	path       string
+	statusCode int
+	body       []byte
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf(
+		"%v %v returned status code: %v, response: %s",
+		e.method, e.path, e.statusCode, e.body,
+	)
+}
+
+type apiClient struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+func newApiClient(baseUrl, token string) *apiClient {
+	return &apiClient{
+		baseUrl: baseUrl,
+		token:   token,
+		client: &http.Client{
+			Timeout: apiTimeout,
+		},
+	}
+}
+
+// do sends json request authorized with bearer token and decodes response
+// into out if it is not nil
+func (a *apiClient) do(
+	ctx context.Context, method, path string, in, out interface{},
+) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseUrl+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &apiError{
+			method:     method,
+			path:       path,
+			statusCode: resp.StatusCode,
+			body:       respBody,
+		}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBody, out)
+}
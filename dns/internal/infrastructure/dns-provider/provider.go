@@ -0,0 +1,104 @@
+package dnsprovider
+
+import (
+	"errors"
+	"fmt"
+	"prem-gateway/dns/internal/core/port"
+	"strings"
+)
+
+const (
+	NoneProvider         = "none"
+	Rfc2136Provider      = "rfc2136"
+	CloudflareProvider   = "cloudflare"
+	DigitalOceanProvider = "digitalocean"
+
+	defaultTtl = 300
+)
+
+var (
+	ErrZoneNotFound = errors.New("dns zone not found")
+)
+
+type Config struct {
+	// Provider is one of none, rfc2136, cloudflare, digitalocean
+	Provider string
+	// Zone hosting the domain, if empty it is discovered by the provider
+	Zone string
+	Ttl  int
+
+	Rfc2136Server        string
+	Rfc2136TsigKey       string
+	Rfc2136TsigSecret    string
+	Rfc2136TsigAlgorithm string
+
+	CloudflareApiToken   string
+	DigitalOceanApiToken string
+}
+
+// FromConfig returns dns provider selected by config, nil provider is
+// returned if none is configured
+func FromConfig(cfg Config) (port.DnsProvider, error) {
+	ttl := cfg.Ttl
+	if ttl <= 0 {
+		ttl = defaultTtl
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", NoneProvider:
+		return nil, nil
+	case Rfc2136Provider:
+		if cfg.Rfc2136Server == "" {
+			return nil, errors.New("rfc2136 server is not set")
+		}
+
+		return NewRfc2136Provider(
+			cfg.Rfc2136Server,
+			cfg.Zone,
+			ttl,
+			cfg.Rfc2136TsigKey,
+			cfg.Rfc2136TsigSecret,
+			cfg.Rfc2136TsigAlgorithm,
+		), nil
+	case CloudflareProvider:
+		if cfg.CloudflareApiToken == "" {
+			return nil, errors.New("cloudflare api token is not set")
+		}
+
+		return NewCloudflareProvider("", cfg.CloudflareApiToken, cfg.Zone, ttl), nil
+	case DigitalOceanProvider:
+		if cfg.DigitalOceanApiToken == "" {
+			return nil, errors.New("digitalocean api token is not set")
+		}
+
+		return NewDigitalOceanProvider(
+			"", cfg.DigitalOceanApiToken, cfg.Zone, ttl,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown dns provider: %v", cfg.Provider)
+	}
+}
+
+// candidateZones returns all parent domains of the given name, which could
+// host it, starting from the longest one, eg. for *.a.example.com returns
+// a.example.com and example.com
+func candidateZones(name string) []string {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "*."), ".")
+	labels := strings.Split(name, ".")
+
+	zones := make([]string, 0, len(labels))
+	for i := 0; i < len(labels)-1; i++ {
+		zones = append(zones, strings.Join(labels[i:], "."))
+	}
+
+	return zones
+}
+
+// relativeName returns name relative to zone, @ is used for zone apex
+func relativeName(name, zone string) string {
+	if name == zone {
+		return "@"
+	}
+
+	return strings.TrimSuffix(name, "."+zone)
+}
@@ -0,0 +1,158 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"prem-gateway/dns/internal/core/port"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTsigAlgorithm = dns.HmacSHA256
+	rfc2136Timeout       = 10 * time.Second
+)
+
+type rfc2136Provider struct {
+	server        string
+	zone          string
+	ttl           int
+	tsigKey       string
+	tsigSecret    string
+	tsigAlgorithm string
+}
+
+// NewRfc2136Provider returns provider which sends dynamic updates(RFC 2136)
+// to the given server(host:port), updates are signed with TSIG if key is set
+func NewRfc2136Provider(
+	server, zone string,
+	ttl int,
+	tsigKey, tsigSecret, tsigAlgorithm string,
+) port.DnsProvider {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	if tsigAlgorithm == "" {
+		tsigAlgorithm = defaultTsigAlgorithm
+	}
+
+	return &rfc2136Provider{
+		server:        server,
+		zone:          zone,
+		ttl:           ttl,
+		tsigKey:       dns.Fqdn(tsigKey),
+		tsigSecret:    tsigSecret,
+		tsigAlgorithm: dns.Fqdn(tsigAlgorithm),
+	}
+}
+
+func (r *rfc2136Provider) Name() string {
+	return Rfc2136Provider
+}
+
+func (r *rfc2136Provider) UpsertRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zone, err := r.findZone(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf(
+		"%s %d IN %s %s",
+		dns.Fqdn(record.Name), r.ttl, record.Type, record.Value,
+	))
+	if err != nil {
+		return err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+	msg.RemoveRRset([]dns.RR{rr})
+	msg.Insert([]dns.RR{rr})
+
+	return r.exchange(ctx, msg)
+}
+
+func (r *rfc2136Provider) DeleteRecord(
+	ctx context.Context, record port.DnsRecord,
+) error {
+	zone, err := r.findZone(ctx, record.Name)
+	if err != nil {
+		return err
+	}
+
+	rrType, ok := dns.StringToType[strings.ToUpper(record.Type)]
+	if !ok {
+		return fmt.Errorf("unknown record type: %v", record.Type)
+	}
+
+	rr := &dns.ANY{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(record.Name),
+			Rrtype: rrType,
+			Class:  dns.ClassINET,
+		},
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+	msg.RemoveRRset([]dns.RR{rr})
+
+	return r.exchange(ctx, msg)
+}
+
+// findZone asks server for SOA of the name, zone is owner of the SOA
+// returned either as answer or as authority
+func (r *rfc2136Provider) findZone(
+	ctx context.Context, name string,
+) (string, error) {
+	if r.zone != "" {
+		return dns.Fqdn(r.zone), nil
+	}
+
+	for _, v := range candidateZones(name) {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(v), dns.TypeSOA)
+
+		client := &dns.Client{Timeout: rfc2136Timeout}
+		resp, _, err := client.ExchangeContext(ctx, msg, r.server)
+		if err != nil {
+			return "", err
+		}
+
+		for _, rr := range append(resp.Answer, resp.Ns...) {
+			if soa, ok := rr.(*dns.SOA); ok {
+				return soa.Hdr.Name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrZoneNotFound, name)
+}
+
+func (r *rfc2136Provider) exchange(ctx context.Context, msg *dns.Msg) error {
+	client := &dns.Client{
+		Net:     "tcp",
+		Timeout: rfc2136Timeout,
+	}
+	if r.tsigSecret != "" {
+		client.TsigSecret = map[string]string{r.tsigKey: r.tsigSecret}
+		msg.SetTsig(r.tsigKey, r.tsigAlgorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, r.server)
+	if err != nil {
+		return err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf(
+			"dns update rejected by %v: %v", r.server, dns.RcodeToString[resp.Rcode],
+		)
+	}
+
+	return nil
+}
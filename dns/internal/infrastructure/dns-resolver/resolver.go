@@ -0,0 +1,172 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"prem-gateway/dns/internal/core/port"
+	"strings"
+	"time"
+)
+
+const (
+	resolvConfPath = "/etc/resolv.conf"
+	defaultTimeout = 5 * time.Second
+	ednsBufferSize = 4096
+)
+
+var (
+	// DefaultServers are used if none are configured and resolv.conf can not
+	// be read
+	DefaultServers = []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+	ErrServerFailure = errors.New("dns server failure")
+)
+
+type resolver struct {
+	servers []string
+	timeout time.Duration
+}
+
+// NewResolver returns resolver which asks recursive servers(host:port), in
+// order, until one of them answers, if servers is empty ones from
+// resolv.conf are used
+func NewResolver(servers []string, timeout time.Duration) port.DnsResolver {
+	if len(servers) == 0 {
+		servers = systemServers()
+	}
+	for i, v := range servers {
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			servers[i] = net.JoinHostPort(v, "53")
+		}
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &resolver{
+		servers: servers,
+		timeout: timeout,
+	}
+}
+
+// Lookup asks for DNSSEC validated answer, if server fails it retries with
+// checking disabled, success then means DNSSEC of the name is broken
+func (r *resolver) Lookup(
+	ctx context.Context, name, recordType string,
+) (port.DnsLookupResult, error) {
+	qtype, ok := dns.StringToType[strings.ToUpper(recordType)]
+	if !ok {
+		return port.DnsLookupResult{}, fmt.Errorf("unknown record type: %v", recordType)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.SetEdns0(ednsBufferSize, true)
+	msg.AuthenticatedData = true
+
+	resp, err := r.exchange(ctx, msg)
+	if err != nil {
+		return port.DnsLookupResult{}, err
+	}
+
+	result := port.DnsLookupResult{}
+	if resp.Rcode == dns.RcodeServerFailure {
+		msg.CheckingDisabled = true
+		resp, err = r.exchange(ctx, msg)
+		if err != nil {
+			return port.DnsLookupResult{}, err
+		}
+		if resp.Rcode == dns.RcodeServerFailure {
+			return port.DnsLookupResult{}, fmt.Errorf(
+				"%w: %v %v", ErrServerFailure, name, recordType,
+			)
+		}
+
+		result.DnssecFailed = true
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return port.DnsLookupResult{}, fmt.Errorf(
+			"%w: %v %v: %v",
+			ErrServerFailure, name, recordType, dns.RcodeToString[resp.Rcode],
+		)
+	}
+
+	result.Authenticated = resp.AuthenticatedData && !result.DnssecFailed
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+
+		result.Records = append(result.Records, toRecord(rr))
+	}
+
+	return result, nil
+}
+
+func (r *resolver) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	errs := make([]error, 0, len(r.servers))
+	for _, v := range r.servers {
+		client := &dns.Client{Timeout: r.timeout}
+		resp, _, err := client.ExchangeContext(ctx, msg, v)
+		if err == nil && resp.Truncated {
+			client.Net = "tcp"
+			resp, _, err = client.ExchangeContext(ctx, msg, v)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			errs = append(errs, fmt.Errorf("%v: %w", v, err))
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func toRecord(rr dns.RR) port.ResolvedRecord {
+	record := port.ResolvedRecord{
+		Type: dns.TypeToString[rr.Header().Rrtype],
+		Ttl:  rr.Header().Ttl,
+	}
+
+	switch v := rr.(type) {
+	case *dns.A:
+		record.Value = v.A.String()
+	case *dns.AAAA:
+		record.Value = v.AAAA.String()
+	case *dns.CNAME:
+		record.Value = strings.TrimSuffix(v.Target, ".")
+	case *dns.CAA:
+		record.Value = v.Value
+		record.Flag = v.Flag
+		record.Tag = v.Tag
+	default:
+		record.Value = strings.TrimPrefix(
+			rr.String(), rr.Header().String(),
+		)
+	}
+
+	return record
+}
+
+func systemServers() []string {
+	conf, err := dns.ClientConfigFromFile(resolvConfPath)
+	if err != nil || len(conf.Servers) == 0 {
+		return append([]string{}, DefaultServers...)
+	}
+
+	servers := make([]string, 0, len(conf.Servers))
+	for _, v := range conf.Servers {
+		servers = append(servers, net.JoinHostPort(v, conf.Port))
+	}
+
+	return servers
+}
@@ -1,34 +1,59 @@
 package httpclients
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/core/port"
+	"prem-gateway/pkg/signing"
 	"time"
 )
 
 type controllerdWrapper struct {
 	controllerDaemonUrl string
+	// signer is nil if secret is not set, controllerd rejects such requests
+	signer *signing.Signer
 }
 
-func NewControllerdWrapper(controllerDaemonUrl string) port.ControllerdWrapper {
+// DomainProvisionedRequest is body of controllerd /v1/domain-provisioned
+type DomainProvisionedRequest struct {
+	Domain string `json:"domain"`
+	Email  string `json:"email"`
+	AcmeCa string `json:"acmeCa,omitempty"`
+}
+
+// NewControllerdWrapper returns client of controllerd signing requests with
+// secret shared with controllerd, empty secret sends unsigned requests
+func NewControllerdWrapper(
+	controllerDaemonUrl, secret string,
+) (port.ControllerdWrapper, error) {
+	var signer *signing.Signer
+	if secret != "" {
+		var err error
+		if signer, err = signing.NewSigner(secret); err != nil {
+			return nil, err
+		}
+	}
+
 	return &controllerdWrapper{
 		controllerDaemonUrl: controllerDaemonUrl,
-	}
+		signer:              signer,
+	}, nil
 }
 
 func (c *controllerdWrapper) DomainProvisioned(
-	ctx context.Context, email, domainName string,
+	ctx context.Context, email, domainName, acmeCa string,
 ) error {
-	url := fmt.Sprintf(
-		"%s/domain-provisioned?domain=%s&email=%s",
-		c.controllerDaemonUrl,
-		domainName,
-		email,
+	return c.sendReq(
+		ctx,
+		c.controllerDaemonUrl+"/v1/domain-provisioned",
+		http.MethodPost,
+		DomainProvisionedRequest{Domain: domainName, Email: email, AcmeCa: acmeCa},
 	)
-	return c.sendReq(ctx, url, http.MethodPost)
 }
 
 func (c *controllerdWrapper) DomainDeleted(
@@ -36,33 +61,47 @@ func (c *controllerdWrapper) DomainDeleted(
 ) error {
 	//TODO uncomment once implemented
 
-	//url := fmt.Sprintf(
-	//	"%s/domain-deleted?domain=%s",
-	//	c.controllerDaemonUrl,
-	//	domainName,
+	//return c.sendReq(
+	//	ctx,
+	//	c.controllerDaemonUrl+"/v1/domain-deleted",
+	//	http.MethodPost,
+	//	DomainDeletedRequest{Domain: domainName},
 	//)
-	//return c.sendReq(ctx, url, http.MethodPost)
 
 	return nil
 }
 
-func (c *controllerdWrapper) sendReq(ctx context.Context, url string, method string) error {
+func (c *controllerdWrapper) sendReq(
+	ctx context.Context, url string, method string, payload interface{},
+) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
 	req, err := http.NewRequestWithContext(
 		ctx,
 		method,
 		url,
-		nil,
+		bytes.NewReader(body),
 	)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.signer != nil {
+		if err := c.signer.Sign(req, body); err != nil {
+			return err
+		}
+	}
 
 	client := &http.Client{
 		Timeout: time.Second * 5,
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return domain.ErrControllerdUnavailable.Wrap(err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -70,19 +109,14 @@ func (c *controllerdWrapper) sendReq(ctx context.Context, url string, method str
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, readErr := io.ReadAll(resp.Body)
+	// controllerd answers 202 once restart job is started
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
-			return fmt.Errorf("controllerd returned status code: %v, and error reading body: %v", resp.StatusCode, readErr)
+			return domain.ErrControllerdFailed.Wrap(fmt.Errorf("controllerd returned status code: %v, and error reading body: %v", resp.StatusCode, readErr))
 		}
 
-		defer func() {
-			if closeErr := resp.Body.Close(); closeErr != nil {
-				err = fmt.Errorf("error closing response body: %v, previous error: %v", closeErr, err)
-			}
-		}()
-
-		return fmt.Errorf("controllerd returned status code: %v, response: %s", resp.StatusCode, body)
+		return domain.ErrControllerdFailed.Wrap(fmt.Errorf("controllerd returned status code: %v, response: %s", resp.StatusCode, respBody))
 	}
 
 	return nil
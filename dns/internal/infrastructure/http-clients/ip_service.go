@@ -4,58 +4,145 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	log "github.com/sirupsen/logrus"
 	"net"
-	"net/http"
+	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/core/port"
+	ipprovider "prem-gateway/dns/internal/infrastructure/ip-provider"
+	"sync"
 	"time"
 )
 
+const (
+	defaultProviderTimeout = 5 * time.Second
+	defaultCacheTTL        = 5 * time.Minute
+)
+
 type ipService struct {
+	providers       []ipprovider.Provider
+	providerTimeout time.Duration
+	cacheTTL        time.Duration
+
+	mtx       sync.Mutex
+	cached    *port.HostIp
+	expiresAt time.Time
 }
 
-func NewIpService() port.IpService {
-	return &ipService{}
+// NewIpService returns ip service which asks providers, in order, for host
+// ip until one of them succeeds, result is cached for cacheTTL(0 disables
+// caching)
+func NewIpService(
+	providers []ipprovider.Provider,
+	providerTimeout time.Duration,
+	cacheTTL time.Duration,
+) port.IpService {
+	return &ipService{
+		providers:       providers,
+		providerTimeout: providerTimeout,
+		cacheTTL:        cacheTTL,
+	}
+}
+
+// NewDefaultIpService returns ip service with default providers chain
+func NewDefaultIpService() port.IpService {
+	return NewIpService(
+		ipprovider.DefaultProviders(), defaultProviderTimeout, defaultCacheTTL,
+	)
 }
 
 func (i *ipService) VerifyDnsRecord(
 	ctx context.Context, expectedIP, domainName string,
 ) (bool, error) {
-	ips, err := net.LookupIP(domainName)
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", domainName)
 	if err != nil {
-		return false, fmt.Errorf("DNS record not found for domain: %v", domainName)
-	} else {
-		for _, ip := range ips {
-			if ip.String() == expectedIP {
-				return true, nil
-			}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, domain.ErrDnsRecordNotFound.Wrap(
+				fmt.Errorf("no record for domain: %v", domainName),
+			)
 		}
-		return false, errors.New("DNS record found, but IP does not match")
+
+		return false, domain.ErrDnsLookupFailed.Wrap(err)
 	}
+
+	for _, ip := range ips {
+		if ip.String() == expectedIP {
+			return true, nil
+		}
+	}
+
+	return false, domain.ErrDnsRecordNotFound.Wrap(
+		fmt.Errorf("record found, but ip does not match %v", expectedIP),
+	)
 }
 
-func (i *ipService) GetHostIp(ctx context.Context) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func (i *ipService) GetHostIp(ctx context.Context) (port.HostIp, error) {
+	if hostIp, ok := i.getCached(); ok {
+		return hostIp, nil
+	}
 
-	client := &http.Client{}
+	// providers are asked without holding the lock, so slow provider does
+	// not block callers, concurrent misses may ask providers more than once
+	errs := make([]error, 0, len(i.providers))
+	for _, v := range i.providers {
+		ip, err := i.getIp(ctx, v)
+		if err != nil {
+			if ctx.Err() != nil {
+				return port.HostIp{}, ctx.Err()
+			}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://ifconfig.io", nil)
-	if err != nil {
-		return "", err
+			log.Debugf("ip provider %v failed: %v", v.Name(), err)
+			errs = append(errs, fmt.Errorf("%v: %w", v.Name(), err))
+			continue
+		}
+
+		hostIp := port.HostIp{
+			Ip:     ip.String(),
+			Source: v.Name(),
+		}
+		i.setCached(hostIp)
+
+		return hostIp, nil
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	return port.HostIp{}, fmt.Errorf(
+		"%w: %v", ipprovider.ErrNoIpFound, errors.Join(errs...),
+	)
+}
+
+func (i *ipService) getCached() (port.HostIp, bool) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	if i.cached == nil || !time.Now().Before(i.expiresAt) {
+		return port.HostIp{}, false
+	}
+
+	return *i.cached, true
+}
+
+func (i *ipService) setCached(hostIp port.HostIp) {
+	if i.cacheTTL <= 0 {
+		return
 	}
 
-	defer resp.Body.Close()
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	i.cached = &hostIp
+	i.expiresAt = time.Now().Add(i.cacheTTL)
+}
+
+func (i *ipService) getIp(
+	ctx context.Context, provider ipprovider.Provider,
+) (net.IP, error) {
+	timeout := i.providerTimeout
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
 	}
 
-	return string(body), nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return provider.GetIp(ctx)
 }
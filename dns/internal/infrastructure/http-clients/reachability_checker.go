@@ -0,0 +1,81 @@
+package httpclients
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"prem-gateway/dns/internal/core/port"
+	"strings"
+	"time"
+)
+
+const (
+	// ChallengePathPrefix is the path under which dnsd serves one-time
+	// reachability tokens
+	ChallengePathPrefix = "/.well-known/prem-gateway/"
+
+	reachabilityTimeout = 10 * time.Second
+	maxTokenSize        = 1024
+)
+
+type reachabilityChecker struct {
+	client *http.Client
+}
+
+func NewReachabilityChecker() port.ReachabilityChecker {
+	return &reachabilityChecker{
+		client: &http.Client{
+			Timeout: reachabilityTimeout,
+		},
+	}
+}
+
+// CheckReachability fetches token served under well-known path of the given
+// domain and compares it with the expected one, if they match request was
+// served by this gateway
+func (r *reachabilityChecker) CheckReachability(
+	ctx context.Context, domainName, nonce, token string,
+) error {
+	challengeUrl := url.URL{
+		Scheme: "http",
+		Host:   domainName,
+		Path:   ChallengePathPrefix + nonce,
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, challengeUrl.String(), nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %v failed: %v", challengeUrl.String(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(
+			"%v returned status code: %v", challengeUrl.String(), resp.StatusCode,
+		)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenSize))
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(
+		[]byte(strings.TrimSpace(string(body))), []byte(token),
+	) != 1 {
+		return fmt.Errorf(
+			"%v is served by another host, token mismatch", domainName,
+		)
+	}
+
+	return nil
+}
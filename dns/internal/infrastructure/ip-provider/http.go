@@ -0,0 +1,68 @@
+package ipprovider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// maxEchoBodySize limits how much of echo service response is read, an
+	// ip address, even with trailing new line, is way shorter
+	maxEchoBodySize = 256
+)
+
+type httpProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHttpProvider returns provider which fetches ip from plain text echo
+// service, like https://ifconfig.io
+func NewHttpProvider(url string) Provider {
+	return &httpProvider{
+		url:    url,
+		client: &http.Client{},
+	}
+}
+
+func (h *httpProvider) Name() string {
+	u, err := url.Parse(h.url)
+	if err != nil || u.Host == "" {
+		return HttpProvider
+	}
+
+	return fmt.Sprintf("%s:%s", HttpProvider, u.Host)
+}
+
+func (h *httpProvider) GetIp(ctx context.Context) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// some echo services return html to browsers
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"%v returned status code: %v", h.url, resp.StatusCode,
+		)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEchoBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePublicIp(string(body))
+}
@@ -0,0 +1,56 @@
+package ipprovider
+
+import (
+	"context"
+	"net"
+)
+
+type interfaceProvider struct {
+	interfaceAddrs func() ([]net.Addr, error)
+}
+
+// NewInterfaceProvider returns provider which scans local network interfaces
+// for public address, useful when box is not behind NAT
+func NewInterfaceProvider() Provider {
+	return &interfaceProvider{
+		interfaceAddrs: net.InterfaceAddrs,
+	}
+}
+
+func (i *interfaceProvider) Name() string {
+	return InterfaceProvider
+}
+
+func (i *interfaceProvider) GetIp(ctx context.Context) (net.IP, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	addrs, err := i.interfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// prefer ipv4 since that is what A record check expects
+	var ipv6 net.IP
+	for _, v := range addrs {
+		ipNet, ok := v.(*net.IPNet)
+		if !ok || !isPublicIp(ipNet.IP) {
+			continue
+		}
+
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+
+		if ipv6 == nil {
+			ipv6 = ipNet.IP
+		}
+	}
+
+	if ipv6 != nil {
+		return ipv6, nil
+	}
+
+	return nil, ErrNoIpFound
+}
@@ -0,0 +1,118 @@
+package ipprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	StaticProvider    = "static"
+	HttpProvider      = "http"
+	StunProvider      = "stun"
+	InterfaceProvider = "interface"
+)
+
+var (
+	ErrNoIpFound = errors.New("no public ip found")
+)
+
+// Provider discovers the public IP address of the host.
+type Provider interface {
+	Name() string
+	GetIp(ctx context.Context) (net.IP, error)
+}
+
+type Config struct {
+	StaticIp    string
+	EchoUrls    []string
+	StunServers []string
+}
+
+// FromNames builds the provider chain in the order of the given names, one
+// provider per echo url/stun server. Static provider is skipped if no static
+// ip is configured.
+func FromNames(names []string, cfg Config) ([]Provider, error) {
+	providers := make([]Provider, 0, len(names))
+	for _, v := range names {
+		switch strings.TrimSpace(v) {
+		case StaticProvider:
+			if cfg.StaticIp == "" {
+				continue
+			}
+
+			p, err := NewStaticProvider(cfg.StaticIp)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, p)
+		case HttpProvider:
+			for _, u := range cfg.EchoUrls {
+				providers = append(providers, NewHttpProvider(u))
+			}
+		case StunProvider:
+			for _, s := range cfg.StunServers {
+				providers = append(providers, NewStunProvider(s))
+			}
+		case InterfaceProvider:
+			providers = append(providers, NewInterfaceProvider())
+		default:
+			return nil, fmt.Errorf("unknown ip provider: %v", v)
+		}
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("at least one ip provider must be configured")
+	}
+
+	return providers, nil
+}
+
+// DefaultProviders returns http echo services followed by stun servers.
+func DefaultProviders() []Provider {
+	providers, _ := FromNames(
+		[]string{HttpProvider, StunProvider},
+		Config{
+			EchoUrls: []string{
+				"https://ifconfig.io",
+				"https://api.ipify.org",
+				"https://icanhazip.com",
+			},
+			StunServers: []string{
+				"stun.l.google.com:19302",
+				"stun.cloudflare.com:3478",
+			},
+		},
+	)
+
+	return providers
+}
+
+// ParsePublicIp parses the given text as an IP address and makes sure it is
+// a globally routable one.
+func ParsePublicIp(text string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(text))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address: %q", truncate(text, 64))
+	}
+
+	if !isPublicIp(ip) {
+		return nil, fmt.Errorf("ip address %v is not public", ip)
+	}
+
+	return ip, nil
+}
+
+func isPublicIp(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
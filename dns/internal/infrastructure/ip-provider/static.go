@@ -0,0 +1,33 @@
+package ipprovider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type staticProvider struct {
+	ip net.IP
+}
+
+// NewStaticProvider returns provider which always returns configured ip,
+// private addresses are allowed since operator knows best
+func NewStaticProvider(ip string) (Provider, error) {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid static ip address: %q", ip)
+	}
+
+	return &staticProvider{
+		ip: parsed,
+	}, nil
+}
+
+func (s *staticProvider) Name() string {
+	return StaticProvider
+}
+
+func (s *staticProvider) GetIp(_ context.Context) (net.IP, error) {
+	return s.ip, nil
+}
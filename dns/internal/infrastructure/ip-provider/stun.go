@@ -0,0 +1,183 @@
+package ipprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// minimal STUN(RFC 5389) client, only binding request is supported
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunHeaderSize      = 20
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXorMappedAddress = 0x0020
+
+	stunFamilyIPv4 = 0x01
+	stunFamilyIPv6 = 0x02
+
+	stunDefaultTimeout = 5 * time.Second
+)
+
+type stunProvider struct {
+	server string
+}
+
+// NewStunProvider returns provider which asks STUN server(host:port) for
+// the server reflexive address of the host
+func NewStunProvider(server string) Provider {
+	return &stunProvider{
+		server: server,
+	}
+}
+
+func (s *stunProvider) Name() string {
+	return fmt.Sprintf("%s:%s", StunProvider, s.server)
+}
+
+func (s *stunProvider) GetIp(ctx context.Context) (net.IP, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(stunDefaultTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	transactionId := make([]byte, 12)
+	if _, err := rand.Read(transactionId); err != nil {
+		return nil, err
+	}
+
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(req[2:4], 0)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	copy(req[8:20], transactionId)
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 1024)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, err := parseStunResponse(resp[:n], transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isPublicIp(ip) {
+		return nil, fmt.Errorf("ip address %v is not public", ip)
+	}
+
+	return ip, nil
+}
+
+func parseStunResponse(msg, transactionId []byte) (net.IP, error) {
+	if len(msg) < stunHeaderSize {
+		return nil, errors.New("stun response too short")
+	}
+
+	if binary.BigEndian.Uint16(msg[0:2]) != stunBindingResponse {
+		return nil, errors.New("unexpected stun message type")
+	}
+
+	if binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie {
+		return nil, errors.New("invalid stun magic cookie")
+	}
+
+	if !bytes.Equal(msg[8:20], transactionId) {
+		return nil, errors.New("stun transaction id mismatch")
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if stunHeaderSize+length > len(msg) {
+		return nil, errors.New("stun response truncated")
+	}
+
+	var mapped net.IP
+	attrs := msg[stunHeaderSize : stunHeaderSize+length]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			return nil, errors.New("stun attribute truncated")
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXorMappedAddress:
+			return parseStunAddress(value, msg[4:20], true)
+		case stunAttrMappedAddress:
+			ip, err := parseStunAddress(value, nil, false)
+			if err != nil {
+				return nil, err
+			}
+			mapped = ip
+		}
+
+		// attributes are padded to 4 bytes boundary
+		padded := (attrLen + 3) &^ 3
+		if 4+padded > len(attrs) {
+			break
+		}
+		attrs = attrs[4+padded:]
+	}
+
+	if mapped != nil {
+		return mapped, nil
+	}
+
+	return nil, errors.New("stun response without mapped address")
+}
+
+// parseStunAddress parses (XOR-)MAPPED-ADDRESS value, xorKey is magic cookie
+// followed by transaction id
+func parseStunAddress(value, xorKey []byte, xored bool) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("stun address attribute too short")
+	}
+
+	var size int
+	switch value[1] {
+	case stunFamilyIPv4:
+		size = net.IPv4len
+	case stunFamilyIPv6:
+		size = net.IPv6len
+	default:
+		return nil, errors.New("unknown stun address family")
+	}
+
+	if len(value) < 4+size {
+		return nil, errors.New("stun address attribute too short")
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xored {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+
+	return ip, nil
+}
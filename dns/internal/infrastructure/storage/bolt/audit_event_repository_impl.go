@@ -0,0 +1,103 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+type auditEventRecord struct {
+	Id        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	Before    string    `json:"before"`
+	After     string    `json:"after"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type auditEventRepositoryImpl struct {
+	db *bolt.DB
+}
+
+// NewAuditEventRepositoryImpl returns append-only repository, there is no
+// code path which overwrites or removes stored event
+func NewAuditEventRepositoryImpl(db *bolt.DB) domain.AuditEventRepository {
+	return &auditEventRepositoryImpl{
+		db: db,
+	}
+}
+
+func (a *auditEventRepositoryImpl) Add(
+	_ context.Context, event domain.AuditEvent,
+) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditEventBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		v, err := json.Marshal(auditEventRecord{
+			Id:        int64(id),
+			Actor:     event.Actor,
+			Action:    event.Action,
+			Resource:  event.Resource,
+			Before:    event.Before,
+			After:     event.After,
+			Outcome:   event.Outcome,
+			Error:     event.Error,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(uint64ToBytes(id), v)
+	})
+}
+
+func (a *auditEventRepositoryImpl) GetAll(
+	_ context.Context, filter domain.AuditFilter,
+) ([]domain.AuditEvent, error) {
+	result := make([]domain.AuditEvent, 0)
+	if err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditEventBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if filter.Limit > 0 && len(result) >= filter.Limit {
+				break
+			}
+
+			var record auditEventRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+
+			event := domain.AuditEvent{
+				Id:        record.Id,
+				Actor:     record.Actor,
+				Action:    record.Action,
+				Resource:  record.Resource,
+				Before:    record.Before,
+				After:     record.After,
+				Outcome:   record.Outcome,
+				Error:     record.Error,
+				CreatedAt: record.CreatedAt,
+			}
+			if filter.Match(event) {
+				result = append(result, event)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
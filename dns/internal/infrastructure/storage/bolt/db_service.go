@@ -0,0 +1,157 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+const (
+	DbFileName = "dnsd.db"
+
+	openTimeout = 5 * time.Second
+)
+
+var (
+	metaBucket          = []byte("meta")
+	dnsInfoBucket       = []byte("dns_info")
+	ipChangeEventBucket = []byte("ip_change_event")
+	txtRecordBucket     = []byte("txt_record")
+	notificationBucket  = []byte("notification_outbox")
+	auditEventBucket    = []byte("audit_event")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migration mirrors pg migration with the same version order, each one is
+// applied once in a single transaction
+type migration struct {
+	version uint64
+	name    string
+	up      func(tx *bolt.Tx) error
+}
+
+var migrations = []migration{
+	{1, "init", createBuckets(dnsInfoBucket)},
+	{2, "ip_change_event", createBuckets(ipChangeEventBucket)},
+	{3, "txt_record", createBuckets(txtRecordBucket)},
+	{4, "notification_outbox", createBuckets(notificationBucket)},
+	{5, "audit_event", createBuckets(auditEventBucket)},
+}
+
+type Service struct {
+	db *bolt.DB
+
+	dnsRepository           domain.DnsRepository
+	ipChangeEventRepository domain.IpChangeEventRepository
+	txtRecordRepository     domain.TxtRecordRepository
+	notificationRepository  domain.NotificationRepository
+	auditEventRepository    domain.AuditEventRepository
+}
+
+type DbConfig struct {
+	// Datadir is directory in which db file is created
+	Datadir string
+}
+
+func NewDBService(dbConfig DbConfig) (*Service, error) {
+	if err := os.MkdirAll(dbConfig.Datadir, 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(
+		filepath.Join(dbConfig.Datadir, DbFileName),
+		0600,
+		&bolt.Options{Timeout: openTimeout},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateDb(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Service{
+		db:                      db,
+		dnsRepository:           NewDnsRepositoryImpl(db),
+		ipChangeEventRepository: NewIpChangeEventRepositoryImpl(db),
+		txtRecordRepository:     NewTxtRecordRepositoryImpl(db),
+		notificationRepository:  NewNotificationRepositoryImpl(db),
+		auditEventRepository:    NewAuditEventRepositoryImpl(db),
+	}, nil
+}
+
+func (s *Service) DnsRepository() domain.DnsRepository {
+	return s.dnsRepository
+}
+
+func (s *Service) IpChangeEventRepository() domain.IpChangeEventRepository {
+	return s.ipChangeEventRepository
+}
+
+func (s *Service) TxtRecordRepository() domain.TxtRecordRepository {
+	return s.txtRecordRepository
+}
+
+func (s *Service) NotificationRepository() domain.NotificationRepository {
+	return s.notificationRepository
+}
+
+func (s *Service) AuditEventRepository() domain.AuditEventRepository {
+	return s.auditEventRepository
+}
+
+func (s *Service) Close() {
+	s.db.Close()
+}
+
+func migrateDb(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		var version uint64
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+
+		for _, m := range migrations {
+			if m.version <= version {
+				continue
+			}
+
+			if err := m.up(tx); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.version, m.name, err)
+			}
+			version = m.version
+		}
+
+		return meta.Put(schemaVersionKey, uint64ToBytes(version))
+	})
+}
+
+func createBuckets(names ...[]byte) func(tx *bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		for _, v := range names {
+			if _, err := tx.CreateBucketIfNotExists(v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func uint64ToBytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,156 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"prem-gateway/dns/internal/core/domain"
+)
+
+type dnsInfoRecord struct {
+	Domain    string `json:"domain"`
+	SubDomain string `json:"sub_domain"`
+	Ip        string `json:"ip"`
+	NodeName  string `json:"node_name"`
+	Email     string `json:"email"`
+	AcmeCa    string `json:"acme_ca"`
+}
+
+type dnsRepositoryImpl struct {
+	db *bolt.DB
+}
+
+func NewDnsRepositoryImpl(db *bolt.DB) domain.DnsRepository {
+	return &dnsRepositoryImpl{
+		db: db,
+	}
+}
+
+func (d *dnsRepositoryImpl) Create(
+	_ context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
+) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dnsInfoBucket)
+
+		if bucket.Get([]byte(dnsInfo.Domain)) != nil {
+			return domain.ErrAlreadyExists
+		}
+
+		if err := putDnsInfo(bucket, dnsInfo); err != nil {
+			return err
+		}
+
+		return insertNotifications(tx, notifications)
+	})
+}
+
+func (d *dnsRepositoryImpl) Update(
+	_ context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
+) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dnsInfoBucket)
+		if bucket.Get([]byte(dnsInfo.Domain)) == nil {
+			return domain.ErrEntityNotFound
+		}
+
+		if err := putDnsInfo(bucket, dnsInfo); err != nil {
+			return err
+		}
+
+		return insertNotifications(tx, notifications)
+	})
+}
+
+func (d *dnsRepositoryImpl) Delete(
+	_ context.Context,
+	domainName string,
+	notifications ...domain.Notification,
+) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(dnsInfoBucket).Delete([]byte(domainName)); err != nil {
+			return err
+		}
+		// pending notification would provision domain which is gone
+		if err := deletePendingNotifications(tx, domainName); err != nil {
+			return err
+		}
+
+		return insertNotifications(tx, notifications)
+	})
+}
+
+func (d *dnsRepositoryImpl) Get(
+	_ context.Context, domainName string,
+) (*domain.DnsInfo, error) {
+	var dnsInfo *domain.DnsInfo
+	if err := d.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(dnsInfoBucket).Get([]byte(domainName))
+		if v == nil {
+			return domain.ErrEntityNotFound
+		}
+
+		info, err := toDomainDnsInfo(v)
+		dnsInfo = info
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return dnsInfo, nil
+}
+
+func (d *dnsRepositoryImpl) GetExistingDomain(
+	_ context.Context,
+) (*domain.DnsInfo, error) {
+	var dnsInfo *domain.DnsInfo
+	if err := d.db.View(func(tx *bolt.Tx) error {
+		_, v := tx.Bucket(dnsInfoBucket).Cursor().First()
+		if v == nil {
+			return domain.ErrEntityNotFound
+		}
+
+		info, err := toDomainDnsInfo(v)
+		dnsInfo = info
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return dnsInfo, nil
+}
+
+func putDnsInfo(bucket *bolt.Bucket, dnsInfo domain.DnsInfo) error {
+	v, err := json.Marshal(dnsInfoRecord{
+		Domain:    dnsInfo.Domain,
+		SubDomain: dnsInfo.SubDomain,
+		Ip:        dnsInfo.Ip,
+		NodeName:  dnsInfo.NodeName,
+		Email:     dnsInfo.Email,
+		AcmeCa:    dnsInfo.AcmeCa,
+	})
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(dnsInfo.Domain), v)
+}
+
+func toDomainDnsInfo(v []byte) (*domain.DnsInfo, error) {
+	var record dnsInfoRecord
+	if err := json.Unmarshal(v, &record); err != nil {
+		return nil, err
+	}
+
+	return &domain.DnsInfo{
+		Domain:    record.Domain,
+		SubDomain: record.SubDomain,
+		Ip:        record.Ip,
+		NodeName:  record.NodeName,
+		Email:     record.Email,
+		AcmeCa:    record.AcmeCa,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+type ipChangeEventRecord struct {
+	Domain        string    `json:"domain"`
+	OldIp         string    `json:"old_ip"`
+	NewIp         string    `json:"new_ip"`
+	Source        string    `json:"source"`
+	ProviderError string    `json:"provider_error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ipChangeEventRepositoryImpl struct {
+	db *bolt.DB
+}
+
+func NewIpChangeEventRepositoryImpl(db *bolt.DB) domain.IpChangeEventRepository {
+	return &ipChangeEventRepositoryImpl{
+		db: db,
+	}
+}
+
+// Add stores event under auto incremented key so cursor order is insertion
+// order
+func (i *ipChangeEventRepositoryImpl) Add(
+	_ context.Context, event domain.IpChangeEvent,
+) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ipChangeEventBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		v, err := json.Marshal(ipChangeEventRecord{
+			Domain:        event.Domain,
+			OldIp:         event.OldIp,
+			NewIp:         event.NewIp,
+			Source:        event.Source,
+			ProviderError: event.ProviderError,
+			CreatedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(uint64ToBytes(id), v)
+	})
+}
+
+func (i *ipChangeEventRepositoryImpl) GetAll(
+	_ context.Context, domainName string, limit int,
+) ([]domain.IpChangeEvent, error) {
+	result := make([]domain.IpChangeEvent, 0)
+	if err := i.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(ipChangeEventBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(result) < limit; k, v = c.Prev() {
+			var record ipChangeEventRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+
+			if record.Domain != domainName {
+				continue
+			}
+
+			result = append(result, domain.IpChangeEvent{
+				Domain:        record.Domain,
+				OldIp:         record.OldIp,
+				NewIp:         record.NewIp,
+				Source:        record.Source,
+				ProviderError: record.ProviderError,
+				CreatedAt:     record.CreatedAt,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,207 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+type notificationRecord struct {
+	Id            int64      `json:"id"`
+	Type          string     `json:"type"`
+	Domain        string     `json:"domain"`
+	Email         string     `json:"email"`
+	AcmeCa        string     `json:"acme_ca"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	DeadAt        *time.Time `json:"dead_at"`
+}
+
+type notificationRepositoryImpl struct {
+	db *bolt.DB
+}
+
+func NewNotificationRepositoryImpl(db *bolt.DB) domain.NotificationRepository {
+	return &notificationRepositoryImpl{
+		db: db,
+	}
+}
+
+func (n *notificationRepositoryImpl) GetUndelivered(
+	_ context.Context,
+) ([]domain.Notification, error) {
+	return n.find(func(v notificationRecord) bool {
+		return v.DeliveredAt == nil
+	})
+}
+
+func (n *notificationRepositoryImpl) MarkDelivered(
+	_ context.Context, id int64,
+) error {
+	return n.update(id, func(v *notificationRecord) {
+		deliveredAt := time.Now().UTC()
+		v.DeliveredAt = &deliveredAt
+	})
+}
+
+func (n *notificationRepositoryImpl) MarkFailed(
+	_ context.Context, id int64, lastError string, nextAttemptAt time.Time,
+) error {
+	return n.update(id, func(v *notificationRecord) {
+		v.Attempts++
+		v.LastError = lastError
+		v.NextAttemptAt = nextAttemptAt.UTC()
+	})
+}
+
+func (n *notificationRepositoryImpl) MarkDead(
+	_ context.Context, id int64, lastError string,
+) error {
+	return n.update(id, func(v *notificationRecord) {
+		deadAt := time.Now().UTC()
+		v.Attempts++
+		v.LastError = lastError
+		v.DeadAt = &deadAt
+	})
+}
+
+// find returns notifications matching filter in insertion order
+func (n *notificationRepositoryImpl) find(
+	filter func(v notificationRecord) bool,
+) ([]domain.Notification, error) {
+	result := make([]domain.Notification, 0)
+	if err := n.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(notificationBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var record notificationRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+
+			if filter(record) {
+				result = append(result, toDomainNotification(record))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (n *notificationRepositoryImpl) update(
+	id int64, apply func(v *notificationRecord),
+) error {
+	return n.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(notificationBucket)
+		key := uint64ToBytes(uint64(id))
+
+		v := bucket.Get(key)
+		if v == nil {
+			return nil
+		}
+
+		var record notificationRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		apply(&record)
+
+		return putNotification(bucket, key, record)
+	})
+}
+
+func insertNotifications(
+	tx *bolt.Tx, notifications []domain.Notification,
+) error {
+	bucket := tx.Bucket(notificationBucket)
+	now := time.Now().UTC()
+
+	for _, v := range notifications {
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		nextAttemptAt := v.NextAttemptAt
+		if nextAttemptAt.IsZero() {
+			nextAttemptAt = now
+		}
+
+		key := uint64ToBytes(id)
+		if err := putNotification(bucket, key, notificationRecord{
+			Id:            int64(id),
+			Type:          string(v.Type),
+			Domain:        v.Domain,
+			Email:         v.Email,
+			AcmeCa:        v.AcmeCa,
+			NextAttemptAt: nextAttemptAt.UTC(),
+			CreatedAt:     now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deletePendingNotifications removes undelivered notifications of domain
+func deletePendingNotifications(tx *bolt.Tx, domainName string) error {
+	bucket := tx.Bucket(notificationBucket)
+
+	// keys are collected first, deleting under cursor skips records
+	keys := make([][]byte, 0)
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var record notificationRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+
+		if record.Domain == domainName && record.DeliveredAt == nil {
+			keys = append(keys, append([]byte{}, k...))
+		}
+	}
+
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func putNotification(
+	bucket *bolt.Bucket, key []byte, record notificationRecord,
+) error {
+	v, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, v)
+}
+
+func toDomainNotification(record notificationRecord) domain.Notification {
+	return domain.Notification{
+		Id:            record.Id,
+		Type:          domain.NotificationType(record.Type),
+		Domain:        record.Domain,
+		Email:         record.Email,
+		AcmeCa:        record.AcmeCa,
+		Attempts:      record.Attempts,
+		LastError:     record.LastError,
+		NextAttemptAt: record.NextAttemptAt,
+		CreatedAt:     record.CreatedAt,
+		DeliveredAt:   record.DeliveredAt,
+		DeadAt:        record.DeadAt,
+	}
+}
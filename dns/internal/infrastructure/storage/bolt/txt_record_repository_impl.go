@@ -0,0 +1,98 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"prem-gateway/dns/internal/core/domain"
+	"sort"
+	"time"
+)
+
+type txtRecordRecord struct {
+	Fqdn      string    `json:"fqdn"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type txtRecordRepositoryImpl struct {
+	db *bolt.DB
+}
+
+func NewTxtRecordRepositoryImpl(db *bolt.DB) domain.TxtRecordRepository {
+	return &txtRecordRepositoryImpl{
+		db: db,
+	}
+}
+
+func (t *txtRecordRepositoryImpl) Add(
+	_ context.Context, record domain.TxtRecord,
+) error {
+	return t.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(txtRecordBucket)
+		key := txtRecordKey(record.Fqdn, record.Value)
+		if bucket.Get(key) != nil {
+			return nil
+		}
+
+		v, err := json.Marshal(txtRecordRecord{
+			Fqdn:      record.Fqdn,
+			Value:     record.Value,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, v)
+	})
+}
+
+func (t *txtRecordRepositoryImpl) Delete(
+	_ context.Context, record domain.TxtRecord,
+) error {
+	return t.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(txtRecordBucket).Delete(
+			txtRecordKey(record.Fqdn, record.Value),
+		)
+	})
+}
+
+func (t *txtRecordRepositoryImpl) GetAll(
+	_ context.Context, fqdn string,
+) ([]domain.TxtRecord, error) {
+	result := make([]domain.TxtRecord, 0)
+	if err := t.db.View(func(tx *bolt.Tx) error {
+		prefix := txtRecordKey(fqdn, "")
+		c := tx.Bucket(txtRecordBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var record txtRecordRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+
+			result = append(result, domain.TxtRecord{
+				Fqdn:      record.Fqdn,
+				Value:     record.Value,
+				CreatedAt: record.CreatedAt,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// txtRecordKey is fqdn and value separated by zero byte which can not be
+// part of domain name, so prefix scan by fqdn is exact
+func txtRecordKey(fqdn, value string) []byte {
+	return []byte(fqdn + "\x00" + value)
+}
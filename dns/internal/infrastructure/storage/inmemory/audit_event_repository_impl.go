@@ -0,0 +1,51 @@
+package inmemory
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"sync"
+	"time"
+)
+
+type auditEventRepositoryImpl struct {
+	mtx    sync.RWMutex
+	events []domain.AuditEvent
+}
+
+func NewAuditEventRepositoryImpl() domain.AuditEventRepository {
+	return &auditEventRepositoryImpl{
+		events: make([]domain.AuditEvent, 0),
+	}
+}
+
+func (a *auditEventRepositoryImpl) Add(
+	_ context.Context, event domain.AuditEvent,
+) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	event.Id = int64(len(a.events) + 1)
+	event.CreatedAt = time.Now().UTC()
+	a.events = append(a.events, event)
+	return nil
+}
+
+func (a *auditEventRepositoryImpl) GetAll(
+	_ context.Context, filter domain.AuditFilter,
+) ([]domain.AuditEvent, error) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	result := make([]domain.AuditEvent, 0)
+	for _, v := range a.events {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+
+		if filter.Match(v) {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package inmemory
+
+import (
+	"prem-gateway/dns/internal/core/domain"
+)
+
+// Service is in memory domain.RepositoryService, state is lost on restart
+// so it is meant for tests and previews
+type Service struct {
+	dnsRepository           domain.DnsRepository
+	ipChangeEventRepository domain.IpChangeEventRepository
+	txtRecordRepository     domain.TxtRecordRepository
+	notificationRepository  domain.NotificationRepository
+	auditEventRepository    domain.AuditEventRepository
+}
+
+func NewDBService() *Service {
+	outbox := newNotificationRepositoryImpl()
+
+	return &Service{
+		dnsRepository:           NewDnsRepositoryImpl(outbox),
+		ipChangeEventRepository: NewIpChangeEventRepositoryImpl(),
+		txtRecordRepository:     NewTxtRecordRepositoryImpl(),
+		notificationRepository:  outbox,
+		auditEventRepository:    NewAuditEventRepositoryImpl(),
+	}
+}
+
+func (s *Service) DnsRepository() domain.DnsRepository {
+	return s.dnsRepository
+}
+
+func (s *Service) IpChangeEventRepository() domain.IpChangeEventRepository {
+	return s.ipChangeEventRepository
+}
+
+func (s *Service) TxtRecordRepository() domain.TxtRecordRepository {
+	return s.txtRecordRepository
+}
+
+func (s *Service) NotificationRepository() domain.NotificationRepository {
+	return s.notificationRepository
+}
+
+func (s *Service) AuditEventRepository() domain.AuditEventRepository {
+	return s.auditEventRepository
+}
+
+func (s *Service) Close() {}
@@ -0,0 +1,108 @@
+package inmemory
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"sort"
+	"sync"
+)
+
+type dnsRepositoryImpl struct {
+	mtx      sync.RWMutex
+	dnsInfos map[string]domain.DnsInfo
+	outbox   *notificationRepositoryImpl
+}
+
+func NewDnsRepositoryImpl(
+	outbox *notificationRepositoryImpl,
+) domain.DnsRepository {
+	return &dnsRepositoryImpl{
+		dnsInfos: make(map[string]domain.DnsInfo),
+		outbox:   outbox,
+	}
+}
+
+func (d *dnsRepositoryImpl) Create(
+	_ context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
+) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if _, ok := d.dnsInfos[dnsInfo.Domain]; ok {
+		return domain.ErrAlreadyExists
+	}
+
+	d.dnsInfos[dnsInfo.Domain] = dnsInfo
+	d.outbox.add(notifications)
+	return nil
+}
+
+func (d *dnsRepositoryImpl) Update(
+	_ context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
+) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if _, ok := d.dnsInfos[dnsInfo.Domain]; !ok {
+		return domain.ErrEntityNotFound
+	}
+
+	d.dnsInfos[dnsInfo.Domain] = dnsInfo
+	d.outbox.add(notifications)
+
+	return nil
+}
+
+func (d *dnsRepositoryImpl) Delete(
+	_ context.Context,
+	domainName string,
+	notifications ...domain.Notification,
+) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	delete(d.dnsInfos, domainName)
+	// pending notification would provision domain which is gone
+	d.outbox.deletePending(domainName)
+	d.outbox.add(notifications)
+	return nil
+}
+
+func (d *dnsRepositoryImpl) Get(
+	_ context.Context, domainName string,
+) (*domain.DnsInfo, error) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	dnsInfo, ok := d.dnsInfos[domainName]
+	if !ok {
+		return nil, domain.ErrEntityNotFound
+	}
+
+	return &dnsInfo, nil
+}
+
+func (d *dnsRepositoryImpl) GetExistingDomain(
+	_ context.Context,
+) (*domain.DnsInfo, error) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	if len(d.dnsInfos) == 0 {
+		return nil, domain.ErrEntityNotFound
+	}
+
+	// deterministic choice in case there is more than one domain
+	domains := make([]string, 0, len(d.dnsInfos))
+	for k := range d.dnsInfos {
+		domains = append(domains, k)
+	}
+	sort.Strings(domains)
+
+	dnsInfo := d.dnsInfos[domains[0]]
+	return &dnsInfo, nil
+}
@@ -0,0 +1,46 @@
+package inmemory
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"sync"
+	"time"
+)
+
+type ipChangeEventRepositoryImpl struct {
+	mtx    sync.RWMutex
+	events []domain.IpChangeEvent
+}
+
+func NewIpChangeEventRepositoryImpl() domain.IpChangeEventRepository {
+	return &ipChangeEventRepositoryImpl{
+		events: make([]domain.IpChangeEvent, 0),
+	}
+}
+
+func (i *ipChangeEventRepositoryImpl) Add(
+	_ context.Context, event domain.IpChangeEvent,
+) error {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	event.CreatedAt = time.Now().UTC()
+	i.events = append(i.events, event)
+	return nil
+}
+
+func (i *ipChangeEventRepositoryImpl) GetAll(
+	_ context.Context, domainName string, limit int,
+) ([]domain.IpChangeEvent, error) {
+	i.mtx.RLock()
+	defer i.mtx.RUnlock()
+
+	result := make([]domain.IpChangeEvent, 0)
+	for j := len(i.events) - 1; j >= 0 && len(result) < limit; j-- {
+		if i.events[j].Domain == domainName {
+			result = append(result, i.events[j])
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package inmemory
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"sync"
+	"time"
+)
+
+type notificationRepositoryImpl struct {
+	mtx           sync.RWMutex
+	lastId        int64
+	notifications []domain.Notification
+}
+
+// newNotificationRepositoryImpl returns concrete type since dns repository
+// appends to outbox directly
+func newNotificationRepositoryImpl() *notificationRepositoryImpl {
+	return &notificationRepositoryImpl{
+		notifications: make([]domain.Notification, 0),
+	}
+}
+
+func (n *notificationRepositoryImpl) GetUndelivered(
+	_ context.Context,
+) ([]domain.Notification, error) {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+
+	result := make([]domain.Notification, 0)
+	for _, v := range n.notifications {
+		if v.DeliveredAt == nil {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
+
+func (n *notificationRepositoryImpl) MarkDelivered(
+	_ context.Context, id int64,
+) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for i := range n.notifications {
+		if n.notifications[i].Id == id {
+			deliveredAt := time.Now().UTC()
+			n.notifications[i].DeliveredAt = &deliveredAt
+		}
+	}
+
+	return nil
+}
+
+func (n *notificationRepositoryImpl) MarkFailed(
+	_ context.Context, id int64, lastError string, nextAttemptAt time.Time,
+) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for i := range n.notifications {
+		if n.notifications[i].Id == id {
+			n.notifications[i].Attempts++
+			n.notifications[i].LastError = lastError
+			n.notifications[i].NextAttemptAt = nextAttemptAt.UTC()
+		}
+	}
+
+	return nil
+}
+
+func (n *notificationRepositoryImpl) MarkDead(
+	_ context.Context, id int64, lastError string,
+) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for i := range n.notifications {
+		if n.notifications[i].Id == id {
+			deadAt := time.Now().UTC()
+			n.notifications[i].Attempts++
+			n.notifications[i].LastError = lastError
+			n.notifications[i].DeadAt = &deadAt
+		}
+	}
+
+	return nil
+}
+
+// deletePending removes undelivered notifications of domain
+func (n *notificationRepositoryImpl) deletePending(domainName string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	kept := make([]domain.Notification, 0, len(n.notifications))
+	for _, v := range n.notifications {
+		if v.Domain != domainName || v.DeliveredAt != nil {
+			kept = append(kept, v)
+		}
+	}
+	n.notifications = kept
+}
+
+func (n *notificationRepositoryImpl) add(notifications []domain.Notification) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	now := time.Now().UTC()
+	for _, v := range notifications {
+		n.lastId++
+		v.Id = n.lastId
+		v.CreatedAt = now
+		if v.NextAttemptAt.IsZero() {
+			v.NextAttemptAt = now
+		}
+
+		n.notifications = append(n.notifications, v)
+	}
+}
@@ -0,0 +1,68 @@
+package inmemory
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"sync"
+	"time"
+)
+
+type txtRecordRepositoryImpl struct {
+	mtx     sync.RWMutex
+	records []domain.TxtRecord
+}
+
+func NewTxtRecordRepositoryImpl() domain.TxtRecordRepository {
+	return &txtRecordRepositoryImpl{
+		records: make([]domain.TxtRecord, 0),
+	}
+}
+
+func (t *txtRecordRepositoryImpl) Add(
+	_ context.Context, record domain.TxtRecord,
+) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, v := range t.records {
+		if v.Fqdn == record.Fqdn && v.Value == record.Value {
+			return nil
+		}
+	}
+
+	record.CreatedAt = time.Now().UTC()
+	t.records = append(t.records, record)
+	return nil
+}
+
+func (t *txtRecordRepositoryImpl) Delete(
+	_ context.Context, record domain.TxtRecord,
+) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for j, v := range t.records {
+		if v.Fqdn == record.Fqdn && v.Value == record.Value {
+			t.records = append(t.records[:j], t.records[j+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (t *txtRecordRepositoryImpl) GetAll(
+	_ context.Context, fqdn string,
+) ([]domain.TxtRecord, error) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	result := make([]domain.TxtRecord, 0)
+	for _, v := range t.records {
+		if v.Fqdn == fqdn {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
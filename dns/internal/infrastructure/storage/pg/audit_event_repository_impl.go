@@ -0,0 +1,86 @@
+package pgdb
+
+import (
+	"context"
+	"math"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
+	"time"
+)
+
+var (
+	// maxTimestamp is upper bound used when filter has no end of range
+	maxTimestamp = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+type auditEventRepositoryImpl struct {
+	querier *queries.Queries
+}
+
+func NewAuditEventRepositoryImpl(
+	querier *queries.Queries,
+) domain.AuditEventRepository {
+	return &auditEventRepositoryImpl{
+		querier: querier,
+	}
+}
+
+func (a *auditEventRepositoryImpl) Add(
+	ctx context.Context, event domain.AuditEvent,
+) error {
+	return toDomainError(a.querier.InsertAuditEvent(ctx, queries.InsertAuditEventParams{
+		Actor:       event.Actor,
+		Action:      event.Action,
+		Resource:    toNullString(event.Resource),
+		BeforeValue: toNullString(event.Before),
+		AfterValue:  toNullString(event.After),
+		Outcome:     event.Outcome,
+		Error:       toNullString(event.Error),
+	}))
+}
+
+func (a *auditEventRepositoryImpl) GetAll(
+	ctx context.Context, filter domain.AuditFilter,
+) ([]domain.AuditEvent, error) {
+	createdTo := maxTimestamp
+	if !filter.To.IsZero() {
+		createdTo = filter.To.UTC()
+	}
+
+	maxRows := int32(math.MaxInt32)
+	if filter.Limit > 0 {
+		maxRows = int32(filter.Limit)
+	}
+
+	actions := filter.Actions
+	if actions == nil {
+		actions = make([]string, 0)
+	}
+
+	events, err := a.querier.GetAuditEvents(ctx, queries.GetAuditEventsParams{
+		CreatedFrom: filter.From.UTC(),
+		CreatedTo:   createdTo,
+		Actions:     actions,
+		MaxRows:     maxRows,
+	})
+	if err != nil {
+		return nil, toDomainError(err)
+	}
+
+	result := make([]domain.AuditEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, domain.AuditEvent{
+			Id:        v.ID,
+			Actor:     v.Actor,
+			Action:    v.Action,
+			Resource:  v.Resource.String,
+			Before:    v.BeforeValue.String,
+			After:     v.AfterValue.String,
+			Outcome:   v.Outcome,
+			Error:     v.Error.String,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
@@ -2,38 +2,60 @@ package pgdb
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	log "github.com/sirupsen/logrus"
+	"net"
+	"net/url"
 	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 const (
-	postgresDriver             = "pgx"
-	insecureDataSourceTemplate = "postgresql://%s:%s@%s:%d/%s?sslmode=disable"
+	postgresDriver = "pgx"
+	defaultSslMode = "disable"
 
 	uniqueViolation = "23505"
-	pgxNoRows       = "no rows in result set"
+
+	initialConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 10 * time.Second
 )
 
 type Service struct {
 	pgxPool *pgxpool.Pool
 	querier *queries.Queries
 
-	dnsRepository domain.DnsRepository
+	dnsRepository           domain.DnsRepository
+	ipChangeEventRepository domain.IpChangeEventRepository
+	txtRecordRepository     domain.TxtRecordRepository
+	notificationRepository  domain.NotificationRepository
+	auditEventRepository    domain.AuditEventRepository
 }
 
+// txExecutor runs txBody in transaction, querier passed to txBody is bound
+// to it
+type txExecutor func(
+	ctx context.Context, txBody func(*queries.Queries) error,
+) error
+
 func NewDBService(dbConfig DbConfig) (*Service, error) {
-	dataSource := insecureDataSourceStr(dbConfig)
+	dataSource, err := dbConfig.DataSource()
+	if err != nil {
+		return nil, err
+	}
 
-	pgxPool, err := connect(dataSource)
+	pgxPool, err := connect(dataSource, dbConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -47,9 +69,21 @@ func NewDBService(dbConfig DbConfig) (*Service, error) {
 		querier: queries.New(pgxPool),
 	}
 
-	dnsRepository := NewDnsRepositoryImpl(rm.querier)
+	dnsRepository := NewDnsRepositoryImpl(rm.querier, rm.execTx)
 	rm.dnsRepository = dnsRepository
 
+	ipChangeEventRepository := NewIpChangeEventRepositoryImpl(rm.querier)
+	rm.ipChangeEventRepository = ipChangeEventRepository
+
+	txtRecordRepository := NewTxtRecordRepositoryImpl(rm.querier)
+	rm.txtRecordRepository = txtRecordRepository
+
+	notificationRepository := NewNotificationRepositoryImpl(rm.querier)
+	rm.notificationRepository = notificationRepository
+
+	auditEventRepository := NewAuditEventRepositoryImpl(rm.querier)
+	rm.auditEventRepository = auditEventRepository
+
 	return rm, nil
 }
 
@@ -57,6 +91,22 @@ func (s *Service) DnsRepository() domain.DnsRepository {
 	return s.dnsRepository
 }
 
+func (s *Service) IpChangeEventRepository() domain.IpChangeEventRepository {
+	return s.ipChangeEventRepository
+}
+
+func (s *Service) TxtRecordRepository() domain.TxtRecordRepository {
+	return s.txtRecordRepository
+}
+
+func (s *Service) NotificationRepository() domain.NotificationRepository {
+	return s.notificationRepository
+}
+
+func (s *Service) AuditEventRepository() domain.AuditEventRepository {
+	return s.auditEventRepository
+}
+
 func (s *Service) Close() {
 	s.pgxPool.Close()
 }
@@ -101,16 +151,130 @@ func (s *Service) execTx(
 }
 
 type DbConfig struct {
-	DbUser             string
-	DbPassword         string
-	DbHost             string
-	DbPort             int
-	DbName             string
+	// Url is full connection url, if set it takes precedence over other
+	// connection and TLS settings
+	Url        string
+	DbUser     string
+	DbPassword string
+	DbHost     string
+	DbPort     int
+	DbName     string
+	// SslMode is libpq sslmode(disable, require, verify-ca, verify-full),
+	// disable if empty
+	SslMode string
+	// SslRootCert is path to CA certificate used to verify server
+	SslRootCert string
+	// SslCert and SslKey are paths to client certificate and its key
+	SslCert            string
+	SslKey             string
 	MigrationSourceURL string
+	// MaxConns and MinConns size connection pool, pgx defaults are used if 0
+	MaxConns int32
+	MinConns int32
+	// ConnectTimeout limits single connection attempt
+	ConnectTimeout time.Duration
+	// ConnectRetryTimeout is for how long connecting is retried, with
+	// backoff, while Postgres is starting, 0 disables retry
+	ConnectRetryTimeout time.Duration
 }
 
-func connect(dataSource string) (*pgxpool.Pool, error) {
-	return pgxpool.Connect(context.Background(), dataSource)
+// DataSource returns connection url, pool settings are not part of it
+// since migrations use it too
+func (c DbConfig) DataSource() (string, error) {
+	if c.Url != "" {
+		u, err := url.Parse(c.Url)
+		if err != nil {
+			return "", fmt.Errorf("invalid db url: %w", err)
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", fmt.Errorf("invalid db url scheme: %v", u.Scheme)
+		}
+
+		params := u.Query()
+		for k := range params {
+			if strings.HasPrefix(k, "pool_") {
+				return "", fmt.Errorf(
+					"db url param %v not supported, use pool settings instead", k,
+				)
+			}
+		}
+
+		return c.Url, nil
+	}
+
+	sslMode := c.SslMode
+	if sslMode == "" {
+		sslMode = defaultSslMode
+	}
+
+	params := url.Values{}
+	params.Set("sslmode", sslMode)
+	if c.SslRootCert != "" {
+		params.Set("sslrootcert", c.SslRootCert)
+	}
+	if c.SslCert != "" {
+		params.Set("sslcert", c.SslCert)
+	}
+	if c.SslKey != "" {
+		params.Set("sslkey", c.SslKey)
+	}
+	if c.ConnectTimeout > 0 {
+		params.Set(
+			"connect_timeout",
+			strconv.Itoa(int((c.ConnectTimeout+time.Second-1)/time.Second)),
+		)
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(c.DbUser, c.DbPassword),
+		Host:     net.JoinHostPort(c.DbHost, strconv.Itoa(c.DbPort)),
+		Path:     "/" + c.DbName,
+		RawQuery: params.Encode(),
+	}
+
+	return u.String(), nil
+}
+
+// connect retries, with exponential backoff, until Postgres accepts
+// connection or ConnectRetryTimeout passes, errors reported by Postgres
+// itself, eg. wrong password, are not retried
+func connect(dataSource string, dbConfig DbConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if dbConfig.MaxConns > 0 {
+		poolConfig.MaxConns = dbConfig.MaxConns
+	}
+	if dbConfig.MinConns > 0 {
+		poolConfig.MinConns = dbConfig.MinConns
+	}
+	if dbConfig.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = dbConfig.ConnectTimeout
+	}
+
+	deadline := time.Now().Add(dbConfig.ConnectRetryTimeout)
+	backoff := initialConnectBackoff
+	for {
+		pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+		if err == nil {
+			return pool, nil
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) || time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+		}
+
+		log.Warnf("postgres not ready, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
 }
 
 func migrateDb(dataSource, migrationSourceUrl string) error {
@@ -137,13 +301,9 @@ func migrateDb(dataSource, migrationSourceUrl string) error {
 	return nil
 }
 
-func insecureDataSourceStr(dbConfig DbConfig) string {
-	return fmt.Sprintf(
-		insecureDataSourceTemplate,
-		dbConfig.DbUser,
-		dbConfig.DbPassword,
-		dbConfig.DbHost,
-		dbConfig.DbPort,
-		dbConfig.DbName,
-	)
+func toNullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  s != "",
+	}
 }
@@ -3,23 +3,28 @@ package pgdb
 import (
 	"context"
 	"database/sql"
-	"github.com/jackc/pgconn"
 	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
 )
 
 type dnsRepositoryImpl struct {
 	querier *queries.Queries
+	execTx  txExecutor
 }
 
-func NewDnsRepositoryImpl(querier *queries.Queries) domain.DnsRepository {
+func NewDnsRepositoryImpl(
+	querier *queries.Queries, execTx txExecutor,
+) domain.DnsRepository {
 	return &dnsRepositoryImpl{
 		querier: querier,
+		execTx:  execTx,
 	}
 }
 
 func (d *dnsRepositoryImpl) Create(
-	ctx context.Context, dnsInfo domain.DnsInfo,
+	ctx context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
 ) error {
 	var subDomain, ip, nodeName, email sql.NullString
 	if dnsInfo.SubDomain != "" {
@@ -47,29 +52,64 @@ func (d *dnsRepositoryImpl) Create(
 		}
 	}
 
-	if err := d.querier.InsertDnsInfo(ctx, queries.InsertDnsInfoParams{
-		Domain:    dnsInfo.Domain,
-		SubDomain: subDomain,
-		Ip:        ip,
-		NodeName:  nodeName,
-		Email:     email,
-	}); err != nil {
-		if pqErr := err.(*pgconn.PgError); pqErr != nil {
-			if pqErr.Code == uniqueViolation {
-				return nil
-			} else {
-				return err
-			}
+	return toDomainError(d.execTx(ctx, func(querier *queries.Queries) error {
+		if err := querier.InsertDnsInfo(ctx, queries.InsertDnsInfoParams{
+			Domain:    dnsInfo.Domain,
+			SubDomain: subDomain,
+			Ip:        ip,
+			NodeName:  nodeName,
+			Email:     email,
+			AcmeCa:    toNullString(dnsInfo.AcmeCa),
+		}); err != nil {
+			return err
 		}
-	}
 
-	return nil
+		return insertNotifications(ctx, querier, notifications)
+	}))
+}
+
+func (d *dnsRepositoryImpl) Update(
+	ctx context.Context,
+	dnsInfo domain.DnsInfo,
+	notifications ...domain.Notification,
+) error {
+	return toDomainError(d.execTx(ctx, func(querier *queries.Queries) error {
+		rows, err := querier.UpdateDnsInfo(ctx, queries.UpdateDnsInfoParams{
+			SubDomain: toNullString(dnsInfo.SubDomain),
+			Ip:        toNullString(dnsInfo.Ip),
+			NodeName:  toNullString(dnsInfo.NodeName),
+			Email:     toNullString(dnsInfo.Email),
+			AcmeCa:    toNullString(dnsInfo.AcmeCa),
+			Domain:    dnsInfo.Domain,
+		})
+		if err != nil {
+			return err
+		}
+		// notification of missing domain would provision it in controllerd
+		if rows == 0 {
+			return domain.ErrEntityNotFound
+		}
+
+		return insertNotifications(ctx, querier, notifications)
+	}))
 }
 
 func (d *dnsRepositoryImpl) Delete(
-	ctx context.Context, domain string,
+	ctx context.Context,
+	domainName string,
+	notifications ...domain.Notification,
 ) error {
-	return d.querier.DeleteDnsInfo(ctx, domain)
+	return toDomainError(d.execTx(ctx, func(querier *queries.Queries) error {
+		if err := querier.DeleteDnsInfo(ctx, domainName); err != nil {
+			return err
+		}
+		// pending notification would provision domain which is gone
+		if err := querier.DeletePendingNotifications(ctx, domainName); err != nil {
+			return err
+		}
+
+		return insertNotifications(ctx, querier, notifications)
+	}))
 }
 
 func (d *dnsRepositoryImpl) Get(
@@ -78,13 +118,7 @@ func (d *dnsRepositoryImpl) Get(
 ) (*domain.DnsInfo, error) {
 	dnsInfo, err := d.querier.GetDnsInfo(ctx, domainName)
 	if err != nil {
-		if err != nil {
-			if err.Error() == pgxNoRows {
-				return nil, domain.ErrEntityNotFound
-			}
-
-			return nil, err
-		}
+		return nil, toDomainError(err)
 	}
 
 	var subDomain, ip, nodeName, email string
@@ -107,17 +141,14 @@ func (d *dnsRepositoryImpl) Get(
 		Ip:        ip,
 		NodeName:  nodeName,
 		Email:     email,
+		AcmeCa:    dnsInfo.AcmeCa.String,
 	}, nil
 }
 
 func (d *dnsRepositoryImpl) GetExistingDomain(ctx context.Context) (*domain.DnsInfo, error) {
 	dns, err := d.querier.GetExistDnsInfo(ctx)
 	if err != nil {
-		if err.Error() == pgxNoRows {
-			return nil, domain.ErrEntityNotFound
-		}
-
-		return nil, err
+		return nil, toDomainError(err)
 	}
 
 	var subDomain, ip, nodeName, email string
@@ -140,5 +171,6 @@ func (d *dnsRepositoryImpl) GetExistingDomain(ctx context.Context) (*domain.DnsI
 		Ip:        ip,
 		NodeName:  nodeName,
 		Email:     email,
+		AcmeCa:    dns.AcmeCa.String,
 	}, nil
 }
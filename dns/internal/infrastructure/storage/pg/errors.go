@@ -0,0 +1,38 @@
+package pgdb
+
+import (
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"net"
+	"prem-gateway/dns/internal/core/domain"
+)
+
+// toDomainError maps pg errors to domain errors, errors which are not
+// recognised are returned as they are
+func toDomainError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrEntityNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == uniqueViolation {
+			return domain.ErrAlreadyExists.Wrap(err)
+		}
+
+		return err
+	}
+
+	// request did not reach the db or timed out waiting for it
+	var netErr net.Error
+	if errors.As(err, &netErr) || pgconn.Timeout(err) || pgconn.SafeToRetry(err) {
+		return domain.ErrStorageUnavailable.Wrap(err)
+	}
+
+	return err
+}
@@ -0,0 +1,60 @@
+package pgdb
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
+)
+
+type ipChangeEventRepositoryImpl struct {
+	querier *queries.Queries
+}
+
+func NewIpChangeEventRepositoryImpl(
+	querier *queries.Queries,
+) domain.IpChangeEventRepository {
+	return &ipChangeEventRepositoryImpl{
+		querier: querier,
+	}
+}
+
+func (i *ipChangeEventRepositoryImpl) Add(
+	ctx context.Context, event domain.IpChangeEvent,
+) error {
+	return toDomainError(i.querier.InsertIpChangeEvent(ctx, queries.InsertIpChangeEventParams{
+		Domain:        event.Domain,
+		OldIp:         toNullString(event.OldIp),
+		NewIp:         event.NewIp,
+		Source:        toNullString(event.Source),
+		ProviderError: toNullString(event.ProviderError),
+	}))
+}
+
+func (i *ipChangeEventRepositoryImpl) GetAll(
+	ctx context.Context, domainName string, limit int,
+) ([]domain.IpChangeEvent, error) {
+	events, err := i.querier.GetIpChangeEvents(
+		ctx,
+		queries.GetIpChangeEventsParams{
+			Domain: domainName,
+			Limit:  int32(limit),
+		},
+	)
+	if err != nil {
+		return nil, toDomainError(err)
+	}
+
+	result := make([]domain.IpChangeEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, domain.IpChangeEvent{
+			Domain:        v.Domain,
+			OldIp:         v.OldIp.String,
+			NewIp:         v.NewIp,
+			Source:        v.Source.String,
+			ProviderError: v.ProviderError.String,
+			CreatedAt:     v.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ip_change_event;
\ No newline at end of file
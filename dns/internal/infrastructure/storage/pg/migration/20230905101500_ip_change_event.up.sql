@@ -0,0 +1,11 @@
+CREATE TABLE ip_change_event (
+  id SERIAL PRIMARY KEY,
+  domain VARCHAR(255) NOT NULL,
+  old_ip VARCHAR(255),
+  new_ip VARCHAR(255) NOT NULL,
+  source VARCHAR(255),
+  provider_error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ip_change_event_domain_idx ON ip_change_event(domain, created_at);
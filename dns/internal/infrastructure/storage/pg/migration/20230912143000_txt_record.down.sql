@@ -0,0 +1 @@
+DROP TABLE IF EXISTS txt_record;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE txt_record (
+  fqdn VARCHAR(255) NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (fqdn, value)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE notification_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  type VARCHAR(64) NOT NULL,
+  domain VARCHAR(255) NOT NULL,
+  email VARCHAR(255),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  delivered_at TIMESTAMP
+);
+
+CREATE INDEX notification_outbox_pending_idx ON notification_outbox(id) WHERE delivered_at IS NULL;
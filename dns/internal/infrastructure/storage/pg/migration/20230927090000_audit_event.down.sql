@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS audit_event_immutable ON audit_event;
+DROP FUNCTION IF EXISTS audit_event_immutable;
+DROP TABLE IF EXISTS audit_event;
@@ -0,0 +1,24 @@
+CREATE TABLE audit_event (
+  id BIGSERIAL PRIMARY KEY,
+  actor VARCHAR(255) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  resource VARCHAR(255),
+  before_value TEXT,
+  after_value TEXT,
+  outcome VARCHAR(16) NOT NULL,
+  error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_event_created_at_idx ON audit_event(created_at);
+
+-- audit log is append-only
+CREATE FUNCTION audit_event_immutable() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_event_immutable
+  BEFORE UPDATE OR DELETE ON audit_event
+  FOR EACH ROW EXECUTE FUNCTION audit_event_immutable();
@@ -0,0 +1,2 @@
+ALTER TABLE notification_outbox DROP COLUMN IF EXISTS acme_ca;
+ALTER TABLE dns_info DROP COLUMN IF EXISTS acme_ca;
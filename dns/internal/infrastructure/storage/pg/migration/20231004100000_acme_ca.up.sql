@@ -0,0 +1,2 @@
+ALTER TABLE dns_info ADD COLUMN acme_ca VARCHAR(2048);
+ALTER TABLE notification_outbox ADD COLUMN acme_ca VARCHAR(2048);
@@ -0,0 +1,2 @@
+-- notification which can not be delivered stops blocking later ones
+ALTER TABLE notification_outbox ADD COLUMN dead_at TIMESTAMP;
@@ -0,0 +1,126 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
+	"time"
+)
+
+type notificationRepositoryImpl struct {
+	querier *queries.Queries
+}
+
+func NewNotificationRepositoryImpl(
+	querier *queries.Queries,
+) domain.NotificationRepository {
+	return &notificationRepositoryImpl{
+		querier: querier,
+	}
+}
+
+func (n *notificationRepositoryImpl) GetUndelivered(
+	ctx context.Context,
+) ([]domain.Notification, error) {
+	notifications, err := n.querier.GetUndeliveredNotifications(ctx)
+	if err != nil {
+		return nil, toDomainError(err)
+	}
+
+	return toDomainNotifications(notifications), nil
+}
+
+func (n *notificationRepositoryImpl) MarkDelivered(
+	ctx context.Context, id int64,
+) error {
+	return toDomainError(n.querier.MarkNotificationDelivered(
+		ctx,
+		queries.MarkNotificationDeliveredParams{
+			DeliveredAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			ID:          id,
+		},
+	))
+}
+
+func (n *notificationRepositoryImpl) MarkFailed(
+	ctx context.Context, id int64, lastError string, nextAttemptAt time.Time,
+) error {
+	return toDomainError(n.querier.MarkNotificationFailed(
+		ctx,
+		queries.MarkNotificationFailedParams{
+			LastError:     toNullString(lastError),
+			NextAttemptAt: nextAttemptAt.UTC(),
+			ID:            id,
+		},
+	))
+}
+
+func (n *notificationRepositoryImpl) MarkDead(
+	ctx context.Context, id int64, lastError string,
+) error {
+	return toDomainError(n.querier.MarkNotificationDead(
+		ctx,
+		queries.MarkNotificationDeadParams{
+			LastError: toNullString(lastError),
+			DeadAt:    sql.NullTime{Time: time.Now().UTC(), Valid: true},
+			ID:        id,
+		},
+	))
+}
+
+func insertNotifications(
+	ctx context.Context,
+	querier *queries.Queries,
+	notifications []domain.Notification,
+) error {
+	for _, v := range notifications {
+		nextAttemptAt := v.NextAttemptAt
+		if nextAttemptAt.IsZero() {
+			nextAttemptAt = time.Now()
+		}
+
+		if err := querier.InsertNotification(ctx, queries.InsertNotificationParams{
+			Type:          string(v.Type),
+			Domain:        v.Domain,
+			Email:         toNullString(v.Email),
+			AcmeCa:        toNullString(v.AcmeCa),
+			NextAttemptAt: nextAttemptAt.UTC(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func toDomainNotifications(
+	notifications []queries.NotificationOutbox,
+) []domain.Notification {
+	result := make([]domain.Notification, 0, len(notifications))
+	for _, v := range notifications {
+		notification := domain.Notification{
+			Id:            v.ID,
+			Type:          domain.NotificationType(v.Type),
+			Domain:        v.Domain,
+			Email:         v.Email.String,
+			AcmeCa:        v.AcmeCa.String,
+			Attempts:      int(v.Attempts),
+			LastError:     v.LastError.String,
+			NextAttemptAt: v.NextAttemptAt,
+			CreatedAt:     v.CreatedAt,
+		}
+		if v.DeliveredAt.Valid {
+			deliveredAt := v.DeliveredAt.Time
+			notification.DeliveredAt = &deliveredAt
+		}
+		if v.DeadAt.Valid {
+			deadAt := v.DeadAt.Time
+			notification.DeadAt = &deadAt
+		}
+
+		result = append(result, notification)
+	}
+
+	return result
+}
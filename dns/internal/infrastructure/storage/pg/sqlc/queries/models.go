@@ -6,12 +6,56 @@ package queries
 
 import (
 	"database/sql"
+	"time"
 )
 
+type AuditEvent struct {
+	ID          int64
+	Actor       string
+	Action      string
+	Resource    sql.NullString
+	BeforeValue sql.NullString
+	AfterValue  sql.NullString
+	Outcome     string
+	Error       sql.NullString
+	CreatedAt   time.Time
+}
+
 type DnsInfo struct {
 	Domain    string
 	SubDomain sql.NullString
 	Ip        sql.NullString
 	NodeName  sql.NullString
 	Email     sql.NullString
+	AcmeCa    sql.NullString
+}
+
+type IpChangeEvent struct {
+	ID            int32
+	Domain        string
+	OldIp         sql.NullString
+	NewIp         string
+	Source        sql.NullString
+	ProviderError sql.NullString
+	CreatedAt     time.Time
+}
+
+type NotificationOutbox struct {
+	ID            int64
+	Type          string
+	Domain        string
+	Email         sql.NullString
+	Attempts      int32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DeliveredAt   sql.NullTime
+	AcmeCa        sql.NullString
+	DeadAt        sql.NullTime
+}
+
+type TxtRecord struct {
+	Fqdn      string
+	Value     string
+	CreatedAt time.Time
 }
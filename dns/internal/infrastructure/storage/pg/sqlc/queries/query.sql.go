@@ -8,6 +8,7 @@ package queries
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const deleteDnsInfo = `-- name: DeleteDnsInfo :exec
@@ -19,8 +20,81 @@ func (q *Queries) DeleteDnsInfo(ctx context.Context, domain string) error {
 	return err
 }
 
+const deletePendingNotifications = `-- name: DeletePendingNotifications :exec
+DELETE FROM notification_outbox WHERE domain = $1 AND delivered_at IS NULL
+`
+
+func (q *Queries) DeletePendingNotifications(ctx context.Context, domain string) error {
+	_, err := q.db.Exec(ctx, deletePendingNotifications, domain)
+	return err
+}
+
+const deleteTxtRecord = `-- name: DeleteTxtRecord :exec
+DELETE FROM txt_record WHERE fqdn = $1 AND value = $2
+`
+
+type DeleteTxtRecordParams struct {
+	Fqdn  string
+	Value string
+}
+
+func (q *Queries) DeleteTxtRecord(ctx context.Context, arg DeleteTxtRecordParams) error {
+	_, err := q.db.Exec(ctx, deleteTxtRecord, arg.Fqdn, arg.Value)
+	return err
+}
+
+const getAuditEvents = `-- name: GetAuditEvents :many
+SELECT id, actor, action, resource, before_value, after_value, outcome, error, created_at FROM audit_event
+WHERE created_at >= $1 AND created_at < $2
+  AND (cardinality($3::text[]) = 0 OR action = ANY($3::text[]))
+ORDER BY id
+LIMIT $4
+`
+
+type GetAuditEventsParams struct {
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Actions     []string
+	MaxRows     int32
+}
+
+func (q *Queries) GetAuditEvents(ctx context.Context, arg GetAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, getAuditEvents,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Actions,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.Action,
+			&i.Resource,
+			&i.BeforeValue,
+			&i.AfterValue,
+			&i.Outcome,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDnsInfo = `-- name: GetDnsInfo :one
-SELECT domain, sub_domain, ip, node_name, email FROM dns_info WHERE domain = $1
+SELECT domain, sub_domain, ip, node_name, email, acme_ca FROM dns_info WHERE domain = $1
 `
 
 func (q *Queries) GetDnsInfo(ctx context.Context, domain string) (DnsInfo, error) {
@@ -32,12 +106,13 @@ func (q *Queries) GetDnsInfo(ctx context.Context, domain string) (DnsInfo, error
 		&i.Ip,
 		&i.NodeName,
 		&i.Email,
+		&i.AcmeCa,
 	)
 	return i, err
 }
 
 const getExistDnsInfo = `-- name: GetExistDnsInfo :one
-SELECT domain, sub_domain, ip, node_name, email FROM dns_info
+SELECT domain, sub_domain, ip, node_name, email, acme_ca FROM dns_info
 `
 
 func (q *Queries) GetExistDnsInfo(ctx context.Context) (DnsInfo, error) {
@@ -49,13 +124,140 @@ func (q *Queries) GetExistDnsInfo(ctx context.Context) (DnsInfo, error) {
 		&i.Ip,
 		&i.NodeName,
 		&i.Email,
+		&i.AcmeCa,
 	)
 	return i, err
 }
 
+const getIpChangeEvents = `-- name: GetIpChangeEvents :many
+SELECT id, domain, old_ip, new_ip, source, provider_error, created_at FROM ip_change_event WHERE domain = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+`
+
+type GetIpChangeEventsParams struct {
+	Domain string
+	Limit  int32
+}
+
+func (q *Queries) GetIpChangeEvents(ctx context.Context, arg GetIpChangeEventsParams) ([]IpChangeEvent, error) {
+	rows, err := q.db.Query(ctx, getIpChangeEvents, arg.Domain, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []IpChangeEvent
+	for rows.Next() {
+		var i IpChangeEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Domain,
+			&i.OldIp,
+			&i.NewIp,
+			&i.Source,
+			&i.ProviderError,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTxtRecords = `-- name: GetTxtRecords :many
+SELECT fqdn, value, created_at FROM txt_record WHERE fqdn = $1 ORDER BY created_at
+`
+
+func (q *Queries) GetTxtRecords(ctx context.Context, fqdn string) ([]TxtRecord, error) {
+	rows, err := q.db.Query(ctx, getTxtRecords, fqdn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TxtRecord
+	for rows.Next() {
+		var i TxtRecord
+		if err := rows.Scan(&i.Fqdn, &i.Value, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUndeliveredNotifications = `-- name: GetUndeliveredNotifications :many
+SELECT id, type, domain, email, attempts, last_error, next_attempt_at, created_at, delivered_at, acme_ca, dead_at FROM notification_outbox WHERE delivered_at IS NULL ORDER BY id
+`
+
+func (q *Queries) GetUndeliveredNotifications(ctx context.Context) ([]NotificationOutbox, error) {
+	rows, err := q.db.Query(ctx, getUndeliveredNotifications)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationOutbox
+	for rows.Next() {
+		var i NotificationOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Type,
+			&i.Domain,
+			&i.Email,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+			&i.AcmeCa,
+			&i.DeadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertAuditEvent = `-- name: InsertAuditEvent :exec
+
+INSERT INTO audit_event(actor, action, resource, before_value, after_value, outcome, error) VALUES ($1, $2, $3, $4, $5, $6, $7)
+`
+
+type InsertAuditEventParams struct {
+	Actor       string
+	Action      string
+	Resource    sql.NullString
+	BeforeValue sql.NullString
+	AfterValue  sql.NullString
+	Outcome     string
+	Error       sql.NullString
+}
+
+// AUDIT_EVENT
+func (q *Queries) InsertAuditEvent(ctx context.Context, arg InsertAuditEventParams) error {
+	_, err := q.db.Exec(ctx, insertAuditEvent,
+		arg.Actor,
+		arg.Action,
+		arg.Resource,
+		arg.BeforeValue,
+		arg.AfterValue,
+		arg.Outcome,
+		arg.Error,
+	)
+	return err
+}
+
 const insertDnsInfo = `-- name: InsertDnsInfo :exec
 
-INSERT INTO dns_info(domain, sub_domain, ip, node_name, email) VALUES ($1, $2, $3, $4, $5)
+INSERT INTO dns_info(domain, sub_domain, ip, node_name, email, acme_ca) VALUES ($1, $2, $3, $4, $5, $6)
 `
 
 type InsertDnsInfoParams struct {
@@ -64,6 +266,7 @@ type InsertDnsInfoParams struct {
 	Ip        sql.NullString
 	NodeName  sql.NullString
 	Email     sql.NullString
+	AcmeCa    sql.NullString
 }
 
 // DNS_INFO
@@ -74,12 +277,123 @@ func (q *Queries) InsertDnsInfo(ctx context.Context, arg InsertDnsInfoParams) er
 		arg.Ip,
 		arg.NodeName,
 		arg.Email,
+		arg.AcmeCa,
 	)
 	return err
 }
 
-const updateDnsInfo = `-- name: UpdateDnsInfo :exec
-UPDATE dns_info SET sub_domain = $1, ip = $2, node_name = $3, email = $4 WHERE domain = $5
+const insertIpChangeEvent = `-- name: InsertIpChangeEvent :exec
+
+INSERT INTO ip_change_event(domain, old_ip, new_ip, source, provider_error) VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertIpChangeEventParams struct {
+	Domain        string
+	OldIp         sql.NullString
+	NewIp         string
+	Source        sql.NullString
+	ProviderError sql.NullString
+}
+
+// IP_CHANGE_EVENT
+func (q *Queries) InsertIpChangeEvent(ctx context.Context, arg InsertIpChangeEventParams) error {
+	_, err := q.db.Exec(ctx, insertIpChangeEvent,
+		arg.Domain,
+		arg.OldIp,
+		arg.NewIp,
+		arg.Source,
+		arg.ProviderError,
+	)
+	return err
+}
+
+const insertNotification = `-- name: InsertNotification :exec
+
+INSERT INTO notification_outbox(type, domain, email, acme_ca, next_attempt_at) VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertNotificationParams struct {
+	Type          string
+	Domain        string
+	Email         sql.NullString
+	AcmeCa        sql.NullString
+	NextAttemptAt time.Time
+}
+
+// NOTIFICATION_OUTBOX
+func (q *Queries) InsertNotification(ctx context.Context, arg InsertNotificationParams) error {
+	_, err := q.db.Exec(ctx, insertNotification,
+		arg.Type,
+		arg.Domain,
+		arg.Email,
+		arg.AcmeCa,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const insertTxtRecord = `-- name: InsertTxtRecord :exec
+
+INSERT INTO txt_record(fqdn, value) VALUES ($1, $2) ON CONFLICT DO NOTHING
+`
+
+type InsertTxtRecordParams struct {
+	Fqdn  string
+	Value string
+}
+
+// TXT_RECORD
+func (q *Queries) InsertTxtRecord(ctx context.Context, arg InsertTxtRecordParams) error {
+	_, err := q.db.Exec(ctx, insertTxtRecord, arg.Fqdn, arg.Value)
+	return err
+}
+
+const markNotificationDead = `-- name: MarkNotificationDead :exec
+UPDATE notification_outbox SET attempts = attempts + 1, last_error = $1, dead_at = $2 WHERE id = $3
+`
+
+type MarkNotificationDeadParams struct {
+	LastError sql.NullString
+	DeadAt    sql.NullTime
+	ID        int64
+}
+
+func (q *Queries) MarkNotificationDead(ctx context.Context, arg MarkNotificationDeadParams) error {
+	_, err := q.db.Exec(ctx, markNotificationDead, arg.LastError, arg.DeadAt, arg.ID)
+	return err
+}
+
+const markNotificationDelivered = `-- name: MarkNotificationDelivered :exec
+UPDATE notification_outbox SET delivered_at = $1 WHERE id = $2
+`
+
+type MarkNotificationDeliveredParams struct {
+	DeliveredAt sql.NullTime
+	ID          int64
+}
+
+func (q *Queries) MarkNotificationDelivered(ctx context.Context, arg MarkNotificationDeliveredParams) error {
+	_, err := q.db.Exec(ctx, markNotificationDelivered, arg.DeliveredAt, arg.ID)
+	return err
+}
+
+const markNotificationFailed = `-- name: MarkNotificationFailed :exec
+UPDATE notification_outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3
+`
+
+type MarkNotificationFailedParams struct {
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	ID            int64
+}
+
+func (q *Queries) MarkNotificationFailed(ctx context.Context, arg MarkNotificationFailedParams) error {
+	_, err := q.db.Exec(ctx, markNotificationFailed, arg.LastError, arg.NextAttemptAt, arg.ID)
+	return err
+}
+
+const updateDnsInfo = `-- name: UpdateDnsInfo :execrows
+UPDATE dns_info SET sub_domain = $1, ip = $2, node_name = $3, email = $4, acme_ca = $5 WHERE domain = $6
 `
 
 type UpdateDnsInfoParams struct {
@@ -87,16 +401,21 @@ type UpdateDnsInfoParams struct {
 	Ip        sql.NullString
 	NodeName  sql.NullString
 	Email     sql.NullString
+	AcmeCa    sql.NullString
 	Domain    string
 }
 
-func (q *Queries) UpdateDnsInfo(ctx context.Context, arg UpdateDnsInfoParams) error {
-	_, err := q.db.Exec(ctx, updateDnsInfo,
+func (q *Queries) UpdateDnsInfo(ctx context.Context, arg UpdateDnsInfoParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateDnsInfo,
 		arg.SubDomain,
 		arg.Ip,
 		arg.NodeName,
 		arg.Email,
+		arg.AcmeCa,
 		arg.Domain,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
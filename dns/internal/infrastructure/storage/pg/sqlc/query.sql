@@ -1,10 +1,10 @@
 /* DNS_INFO */
 
 -- name: InsertDnsInfo :exec
-INSERT INTO dns_info(domain, sub_domain, ip, node_name, email) VALUES ($1, $2, $3, $4, $5);
+INSERT INTO dns_info(domain, sub_domain, ip, node_name, email, acme_ca) VALUES ($1, $2, $3, $4, $5, $6);
 
--- name: UpdateDnsInfo :exec
-UPDATE dns_info SET sub_domain = $1, ip = $2, node_name = $3, email = $4 WHERE domain = $5;
+-- name: UpdateDnsInfo :execrows
+UPDATE dns_info SET sub_domain = $1, ip = $2, node_name = $3, email = $4, acme_ca = $5 WHERE domain = $6;
 
 -- name: DeleteDnsInfo :exec
 DELETE FROM dns_info WHERE domain = $1;
@@ -13,4 +13,56 @@ DELETE FROM dns_info WHERE domain = $1;
 SELECT * FROM dns_info WHERE domain = $1;
 
 -- name: GetExistDnsInfo :one
-SELECT * FROM dns_info;
\ No newline at end of file
+SELECT * FROM dns_info;
+
+/* IP_CHANGE_EVENT */
+
+-- name: InsertIpChangeEvent :exec
+INSERT INTO ip_change_event(domain, old_ip, new_ip, source, provider_error) VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetIpChangeEvents :many
+SELECT * FROM ip_change_event WHERE domain = $1 ORDER BY created_at DESC, id DESC LIMIT $2;
+
+
+/* TXT_RECORD */
+
+-- name: InsertTxtRecord :exec
+INSERT INTO txt_record(fqdn, value) VALUES ($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: DeleteTxtRecord :exec
+DELETE FROM txt_record WHERE fqdn = $1 AND value = $2;
+
+-- name: GetTxtRecords :many
+SELECT * FROM txt_record WHERE fqdn = $1 ORDER BY created_at;
+
+/* NOTIFICATION_OUTBOX */
+
+-- name: InsertNotification :exec
+INSERT INTO notification_outbox(type, domain, email, acme_ca, next_attempt_at) VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetUndeliveredNotifications :many
+SELECT * FROM notification_outbox WHERE delivered_at IS NULL ORDER BY id;
+
+-- name: MarkNotificationDelivered :exec
+UPDATE notification_outbox SET delivered_at = $1 WHERE id = $2;
+
+-- name: MarkNotificationDead :exec
+UPDATE notification_outbox SET attempts = attempts + 1, last_error = $1, dead_at = $2 WHERE id = $3;
+
+-- name: DeletePendingNotifications :exec
+DELETE FROM notification_outbox WHERE domain = $1 AND delivered_at IS NULL;
+
+-- name: MarkNotificationFailed :exec
+UPDATE notification_outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3;
+
+/* AUDIT_EVENT */
+
+-- name: InsertAuditEvent :exec
+INSERT INTO audit_event(actor, action, resource, before_value, after_value, outcome, error) VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetAuditEvents :many
+SELECT * FROM audit_event
+WHERE created_at >= sqlc.arg(created_from) AND created_at < sqlc.arg(created_to)
+  AND (cardinality(sqlc.arg(actions)::text[]) = 0 OR action = ANY(sqlc.arg(actions)::text[]))
+ORDER BY id
+LIMIT sqlc.arg(max_rows);
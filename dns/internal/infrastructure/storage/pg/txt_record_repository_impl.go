@@ -0,0 +1,57 @@
+package pgdb
+
+import (
+	"context"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/infrastructure/storage/pg/sqlc/queries"
+)
+
+type txtRecordRepositoryImpl struct {
+	querier *queries.Queries
+}
+
+func NewTxtRecordRepositoryImpl(
+	querier *queries.Queries,
+) domain.TxtRecordRepository {
+	return &txtRecordRepositoryImpl{
+		querier: querier,
+	}
+}
+
+func (t *txtRecordRepositoryImpl) Add(
+	ctx context.Context, record domain.TxtRecord,
+) error {
+	return toDomainError(t.querier.InsertTxtRecord(ctx, queries.InsertTxtRecordParams{
+		Fqdn:  record.Fqdn,
+		Value: record.Value,
+	}))
+}
+
+func (t *txtRecordRepositoryImpl) Delete(
+	ctx context.Context, record domain.TxtRecord,
+) error {
+	return toDomainError(t.querier.DeleteTxtRecord(ctx, queries.DeleteTxtRecordParams{
+		Fqdn:  record.Fqdn,
+		Value: record.Value,
+	}))
+}
+
+func (t *txtRecordRepositoryImpl) GetAll(
+	ctx context.Context, fqdn string,
+) ([]domain.TxtRecord, error) {
+	records, err := t.querier.GetTxtRecords(ctx, fqdn)
+	if err != nil {
+		return nil, toDomainError(err)
+	}
+
+	result := make([]domain.TxtRecord, 0, len(records))
+	for _, v := range records {
+		result = append(result, domain.TxtRecord{
+			Fqdn:      v.Fqdn,
+			Value:     v.Value,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,200 @@
+package dnsserver
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	"prem-gateway/dns/internal/core/application"
+	"time"
+)
+
+const (
+	resolveTimeout = 2 * time.Second
+	// soaSerial is constant since zone content is derived from the db and
+	// there are no secondaries to notify
+	soaSerial = 1
+)
+
+// Server is authoritative dns server for the provisioned domain, it answers
+// A/AAAA for apex and wildcard, TXT for ACME DNS-01 challenges, CAA, NS and
+// SOA
+type Server interface {
+	Start(ctx context.Context) <-chan error
+	Handler() dns.Handler
+}
+
+type server struct {
+	address string
+	ttl     uint32
+	zoneSvc application.ZoneService
+}
+
+func NewServer(
+	address string, ttl int, zoneSvc application.ZoneService,
+) Server {
+	return &server{
+		address: address,
+		ttl:     uint32(ttl),
+		zoneSvc: zoneSvc,
+	}
+}
+
+func (s *server) Start(ctx context.Context) <-chan error {
+	errCh := make(chan error, 2)
+
+	servers := []*dns.Server{
+		{Addr: s.address, Net: "udp", Handler: s},
+		{Addr: s.address, Net: "tcp", Handler: s},
+	}
+
+	for _, v := range servers {
+		go func(srv *dns.Server) {
+			log.Infof(
+				"prem-gateway authoritative dns listening at: %v/%v",
+				srv.Addr, srv.Net,
+			)
+
+			if err := srv.ListenAndServe(); err != nil {
+				errCh <- err
+			}
+		}(v)
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		for _, v := range servers {
+			if err := v.Shutdown(); err != nil {
+				log.Warnf("failed to shutdown dns server: %v", err)
+			}
+		}
+
+		log.Info("prem-gateway authoritative dns stopped")
+	}()
+
+	return errCh
+}
+
+func (s *server) Handler() dns.Handler {
+	return s
+}
+
+func (s *server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	resp := s.answer(r)
+	if err := w.WriteMsg(resp); err != nil {
+		log.Warnf("failed to write dns response: %v", err)
+	}
+}
+
+func (s *server) answer(r *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	resp.Compress = true
+
+	if len(r.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	q := r.Question[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	answer, err := s.zoneSvc.Resolve(ctx, q.Name)
+	if err != nil {
+		log.Errorf("failed to resolve %v: %v", q.Name, err)
+		resp.Rcode = dns.RcodeServerFailure
+		return resp
+	}
+
+	if !answer.Authoritative {
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+	resp.Authoritative = true
+
+	switch q.Qtype {
+	case dns.TypeA:
+		for _, v := range answer.Ips {
+			if ip4 := v.To4(); ip4 != nil {
+				resp.Answer = append(resp.Answer, &dns.A{
+					Hdr: s.header(q.Name, dns.TypeA),
+					A:   ip4,
+				})
+			}
+		}
+	case dns.TypeAAAA:
+		for _, v := range answer.Ips {
+			if v.To4() == nil {
+				resp.Answer = append(resp.Answer, &dns.AAAA{
+					Hdr:  s.header(q.Name, dns.TypeAAAA),
+					AAAA: v,
+				})
+			}
+		}
+	case dns.TypeTXT:
+		for _, v := range answer.Txt {
+			resp.Answer = append(resp.Answer, &dns.TXT{
+				// challenges must not be cached for long
+				Hdr: dns.RR_Header{
+					Name:   q.Name,
+					Rrtype: dns.TypeTXT,
+					Class:  dns.ClassINET,
+					Ttl:    0,
+				},
+				Txt: []string{v},
+			})
+		}
+	case dns.TypeCAA:
+		if answer.Apex {
+			for _, tag := range []string{"issue", "issuewild"} {
+				resp.Answer = append(resp.Answer, &dns.CAA{
+					Hdr:   s.header(q.Name, dns.TypeCAA),
+					Flag:  0,
+					Tag:   tag,
+					Value: answer.CaaIssuer,
+				})
+			}
+		}
+	case dns.TypeNS:
+		if answer.Apex {
+			resp.Answer = append(resp.Answer, &dns.NS{
+				Hdr: s.header(q.Name, dns.TypeNS),
+				Ns:  dns.Fqdn(answer.NameServer),
+			})
+		}
+	case dns.TypeSOA:
+		if answer.Apex {
+			resp.Answer = append(resp.Answer, s.soa(answer))
+		}
+	}
+
+	// NODATA response carries SOA so resolvers can cache negative answer
+	if len(resp.Answer) == 0 {
+		resp.Ns = append(resp.Ns, s.soa(answer))
+	}
+
+	return resp
+}
+
+func (s *server) header(name string, rrType uint16) dns.RR_Header {
+	return dns.RR_Header{
+		Name:   name,
+		Rrtype: rrType,
+		Class:  dns.ClassINET,
+		Ttl:    s.ttl,
+	}
+}
+
+func (s *server) soa(answer application.ZoneAnswer) dns.RR {
+	return &dns.SOA{
+		Hdr:     s.header(dns.Fqdn(answer.Zone), dns.TypeSOA),
+		Ns:      dns.Fqdn(answer.NameServer),
+		Mbox:    dns.Fqdn("hostmaster." + answer.Zone),
+		Serial:  soaSerial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  60,
+	}
+}
@@ -0,0 +1,121 @@
+package httphandler
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"prem-gateway/dns/internal/core/application"
+)
+
+const (
+	// AcmeUsername is basic auth username of ACME endpoints, lego httpreq
+	// provider sends it from HTTPREQ_USERNAME
+	AcmeUsername = "acme"
+
+	codeUnauthorized = "unauthorized"
+)
+
+// AcmeHandler implements lego httpreq DNS provider API, Traefik configured
+// with HTTPREQ_ENDPOINT pointing to dnsd/acme can solve DNS-01 challenges
+// through built-in authoritative dns server
+type AcmeHandler interface {
+	Present(c *gin.Context)
+	Cleanup(c *gin.Context)
+}
+
+type acmeHandler struct {
+	zoneSvc application.ZoneService
+}
+
+func NewAcmeHandler(zoneSvc application.ZoneService) (AcmeHandler, error) {
+	return &acmeHandler{
+		zoneSvc: zoneSvc,
+	}, nil
+}
+
+// AcmeAuthMiddleware rejects requests without basic auth of AcmeUsername
+// and secret, challenge records let caller obtain certificates for the
+// domain
+func AcmeAuthMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(AcmeUsername)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(secret)) != 1 {
+			c.Header("WWW-Authenticate", `Basic realm="acme"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Code:  codeUnauthorized,
+				Error: "invalid acme credentials",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Present godoc
+// @Summary Adds ACME DNS-01 challenge TXT record
+// @Description This endpoint adds TXT record served by built-in authoritative DNS server. It is served only if authoritative DNS server is enabled and requires basic auth of user acme and AUTH_DNS_ACME_SECRET
+// @Tags acme
+// @Accept json
+// @Produce json
+// @Param TxtRecord body TxtRecord true "challenge record"
+//
+//	@Success		200		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse	"Returns error message for missing or invalid basic auth"
+//	@Failure		422		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		503		{object}	ErrorResponse
+//
+// @Router /acme/present [post]
+func (a *acmeHandler) Present(c *gin.Context) {
+	var record TxtRecord
+	if err := c.ShouldBindJSON(&record); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if err := a.zoneSvc.PresentTxtRecord(
+		c.Request.Context(), record.Fqdn, record.Value,
+	); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Status: "success"})
+}
+
+// Cleanup godoc
+// @Summary Removes ACME DNS-01 challenge TXT record
+// @Description This endpoint removes TXT record served by built-in authoritative DNS server. It is served only if authoritative DNS server is enabled and requires basic auth of user acme and AUTH_DNS_ACME_SECRET
+// @Tags acme
+// @Accept json
+// @Produce json
+// @Param TxtRecord body TxtRecord true "challenge record"
+//
+//	@Success		200		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse	"Returns error message for missing or invalid basic auth"
+//	@Failure		422		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		503		{object}	ErrorResponse
+//
+// @Router /acme/cleanup [post]
+func (a *acmeHandler) Cleanup(c *gin.Context) {
+	var record TxtRecord
+	if err := c.ShouldBindJSON(&record); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if err := a.zoneSvc.CleanupTxtRecord(
+		c.Request.Context(), record.Fqdn, record.Value,
+	); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Status: "success"})
+}
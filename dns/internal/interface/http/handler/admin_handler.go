@@ -0,0 +1,39 @@
+package httphandler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type AdminHandler interface {
+	GetConfig(c *gin.Context)
+}
+
+type adminHandler struct {
+	effectiveConfig map[string]interface{}
+}
+
+func NewAdminHandler(effectiveConfig map[string]interface{}) (AdminHandler, error) {
+	if effectiveConfig == nil {
+		effectiveConfig = make(map[string]interface{})
+	}
+
+	return &adminHandler{
+		effectiveConfig: effectiveConfig,
+	}, nil
+}
+
+// GetConfig godoc
+// @Summary Retrieves effective configuration
+// @Description This endpoint retrieves configuration dnsd runs with, after defaults, config file, env vars and flags are applied, secrets are redacted
+// @Tags admin
+// @Accept json
+// @Produce json
+//
+//	@Success		200		{object}	map[string]interface{}	"Returns configuration by key"
+//	@Failure		401		{object}	ErrorResponse			"Returns error message when caller is not authenticated"
+//
+// @Router /admin/config [get]
+func (a *adminHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, a.effectiveConfig)
+}
@@ -0,0 +1,251 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/pkg/signing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ActorHeader is set by authd forward auth with identity of the caller
+	ActorHeader = "X-Auth-Actor"
+	// ActorSignatureHeader is signature of ActorHeader set by authd, actor
+	// without valid signature is not trusted
+	ActorSignatureHeader = "X-Auth-Actor-Signature"
+	// anonymousActor is recorded when request did not pass through authd
+	anonymousActor = "anonymous"
+	// authenticatedKey is set in gin context once authd signature of actor
+	// is verified
+	authenticatedKey = "authenticated"
+
+	contentTypeJsonLines = "application/x-ndjson"
+	formatJsonLines      = "jsonl"
+)
+
+// ActorMiddleware puts caller identity from ActorHeader into request
+// context so it is recorded in audit events. Header is trusted only if authd
+// signed it for this request with secret of authd verifier, nil verifier
+// records every caller as anonymous
+func ActorMiddleware(authd *signing.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := c.GetHeader(ActorHeader)
+		if authd == nil || authd.VerifyValue(
+			c.Request.Method, c.Request.URL.RequestURI(), actor,
+			c.GetHeader(ActorSignatureHeader),
+		) != nil {
+			actor = ""
+		} else {
+			c.Set(authenticatedKey, true)
+		}
+
+		withActor(c, actor)
+		c.Next()
+	}
+}
+
+// AuthenticatedMiddleware rejects requests with 401 unless they passed
+// through authd, actor signature is checked by ActorMiddleware, or are signed
+// by other gateway daemon with secret of daemon verifier
+func AuthenticatedMiddleware(daemon *signing.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(authenticatedKey) {
+			c.Next()
+			return
+		}
+
+		err := signing.ErrMissingSignature
+		if daemon != nil {
+			if _, err = daemon.Verify(c.Request); err == nil {
+				withActor(c, c.GetHeader(ActorHeader))
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+			Code:  codeUnauthorized,
+			Error: err.Error(),
+		})
+	}
+}
+
+// SignedMiddleware rejects requests which are not signed by other gateway
+// daemon, eg. controllerd, with 401. Signing daemon holds the shared secret
+// so it is trusted to name actor in ActorHeader
+func SignedMiddleware(verifier *signing.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := verifier.Verify(c.Request); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Code:  codeUnauthorized,
+				Error: err.Error(),
+			})
+			return
+		}
+
+		withActor(c, c.GetHeader(ActorHeader))
+		c.Next()
+	}
+}
+
+func withActor(c *gin.Context, actor string) {
+	actor = strings.TrimSpace(actor)
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	c.Request = c.Request.WithContext(
+		application.ContextWithActor(c.Request.Context(), actor),
+	)
+}
+
+type AuditHandler interface {
+	GetAuditEvents(c *gin.Context)
+	AddAuditEvent(c *gin.Context)
+}
+
+type auditHandler struct {
+	auditSvc application.AuditService
+}
+
+func NewAuditHandler(auditSvc application.AuditService) (AuditHandler, error) {
+	return &auditHandler{
+		auditSvc: auditSvc,
+	}, nil
+}
+
+// GetAuditEvents godoc
+// @Summary Retrieves audit log
+// @Description This endpoint retrieves audit events, oldest first, as JSON array or as JSON Lines export if format=jsonl or Accept is application/x-ndjson
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Produce application/x-ndjson
+// @Param from query string false "RFC3339 time, events created at or after"
+// @Param to query string false "RFC3339 time, events created before"
+// @Param action query []string false "Actions to include, repeated or comma separated" collectionFormat(multi)
+// @Param limit query int false "Max number of events"
+// @Param format query string false "json(default) or jsonl"
+//
+//	@Success		200		{array}		AuditEvent		"Returns audit events"
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for invalid input"
+//	@Failure		401		{object}	ErrorResponse	"Returns error message when caller is not authenticated"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		503		{object}	ErrorResponse	"Returns error message when storage is unavailable"
+//
+// @Router /audit [get]
+func (a *auditHandler) GetAuditEvents(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	events, err := a.auditSvc.GetEvents(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	result := make([]AuditEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, FromAppAuditEventToHandlerAuditEvent(v))
+	}
+
+	if c.Query("format") != formatJsonLines &&
+		c.GetHeader("Accept") != contentTypeJsonLines {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	c.Header("Content-Type", contentTypeJsonLines)
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for _, v := range result {
+		if err := encoder.Encode(v); err != nil {
+			return
+		}
+	}
+}
+
+// AddAuditEvent godoc
+// @Summary Records audit event
+// @Description This endpoint is used by other gateway daemons, eg. controllerd, to record changes they made. Request must be signed with secret shared with dnsd (X-Prem-Timestamp, X-Prem-Nonce and X-Prem-Signature headers), actor is taken from X-Auth-Actor header
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param AuditEventRequest body AuditEventRequest true "audit event"
+//
+//	@Success		201		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for malformed request"
+//	@Failure		401		{object}	ErrorResponse	"Returns error message when request is not signed"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		503		{object}	ErrorResponse	"Returns error message when storage is unavailable"
+//
+// @Router /audit [post]
+func (a *auditHandler) AddAuditEvent(c *gin.Context) {
+	var req AuditEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if err := a.auditSvc.RecordEvent(
+		c.Request.Context(),
+		FromHandlerAuditEventRequestToAppAuditEvent(req),
+	); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{Status: "success"})
+}
+
+func parseAuditFilter(c *gin.Context) (application.AuditFilter, error) {
+	var filter application.AuditFilter
+
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errInvalidQuery("from")
+		}
+		filter.From = from
+	}
+
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errInvalidQuery("to")
+		}
+		filter.To = to
+	}
+
+	for _, v := range c.QueryArray("action") {
+		for _, action := range strings.Split(v, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, action)
+			}
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, errInvalidQuery("limit")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func errInvalidQuery(param string) error {
+	return fmt.Errorf("invalid %v", param)
+}
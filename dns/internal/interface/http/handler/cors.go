@@ -0,0 +1,41 @@
+package httphandler
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/pkg/cors"
+)
+
+// CorsMiddleware applies CORS policy, preflight requests are answered
+// with 204 and not routed further
+func CorsMiddleware(policy *cors.Cors) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Apply(c.Writer, c.Request) {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ProvisionedDomainOrigin allows origins of the domain provisioned on the
+// gateway and of its subdomains, eg. premapp served from the domain
+func ProvisionedDomainOrigin(
+	dnsSvc application.DnsService,
+) func(r *http.Request, origin string) bool {
+	return func(r *http.Request, origin string) bool {
+		dnsInfo, err := dnsSvc.GetExistingDomain(r.Context())
+		if err != nil {
+			log.Warnf("cors: failed to get provisioned domain: %v", err)
+			return false
+		}
+		if dnsInfo == nil {
+			return false
+		}
+
+		return cors.MatchDomain(origin, dnsInfo.Domain)
+	}
+}
@@ -5,25 +5,43 @@ import (
 	"net/http"
 	"prem-gateway/dns/internal/core/application"
 	"prem-gateway/dns/internal/core/domain"
+	"strconv"
 )
 
 type DNSHandler interface {
 	CreateDnsInfo(c *gin.Context)
 	DeleteDnsInfo(c *gin.Context)
 	GetDnsInfo(c *gin.Context)
+	UpdateAcmeCa(c *gin.Context)
 	CheckDnsStatus(c *gin.Context)
 	GetGatewayIp(c *gin.Context)
+	GetGatewayIpDetails(c *gin.Context)
 	GetExistingDns(c *gin.Context)
 	Check(c *gin.Context)
+	ServeChallenge(c *gin.Context)
+	GetIpHistory(c *gin.Context)
+	GetPendingNotifications(c *gin.Context)
+	GetPreflightReport(c *gin.Context)
 }
 
 type dnsHandler struct {
-	dnsSvc application.DnsService
+	dnsSvc        application.DnsService
+	dynamicDnsSvc application.DynamicDnsService
+	dispatcher    application.NotificationDispatcher
+	preflightSvc  application.PreflightService
 }
 
-func NewDNSHandler(dnsSvc application.DnsService) (DNSHandler, error) {
+func NewDNSHandler(
+	dnsSvc application.DnsService,
+	dynamicDnsSvc application.DynamicDnsService,
+	dispatcher application.NotificationDispatcher,
+	preflightSvc application.PreflightService,
+) (DNSHandler, error) {
 	return &dnsHandler{
-		dnsSvc: dnsSvc,
+		dnsSvc:        dnsSvc,
+		dynamicDnsSvc: dynamicDnsSvc,
+		dispatcher:    dispatcher,
+		preflightSvc:  preflightSvc,
 	}, nil
 }
 
@@ -35,16 +53,19 @@ func NewDNSHandler(dnsSvc application.DnsService) (DNSHandler, error) {
 // @Produce json
 // @Param DnsInfo body DnsInfo true "dns information"
 //
-//	@Success		200		{object}	SuccessResponse
-//	@Failure		400		{object}	ErrorResponse
-//	@Failure		404		{object}	ErrorResponse
-//	@Failure		500		{object}	ErrorResponse
+//	@Success		201		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for malformed request or invalid fields"
+//	@Failure		409		{object}	ErrorResponse	"Returns error message when domain already exists"
+//	@Failure		422		{object}	ErrorResponse	"Returns error message when A record or reachability check fails"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message when dns provider, resolver or controllerd fails"
+//	@Failure		503		{object}	ErrorResponse	"Returns error message when storage or controllerd is unavailable"
 //
 // @Router /dns [post]
 func (d *dnsHandler) CreateDnsInfo(c *gin.Context) {
 	var info DnsInfo
 	if err := c.ShouldBindJSON(&info); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		writeBadRequest(c, err.Error())
 		return
 	}
 
@@ -52,7 +73,7 @@ func (d *dnsHandler) CreateDnsInfo(c *gin.Context) {
 		c.Request.Context(),
 		FromHandlerDnsInfoToAppDnsInfo(info),
 	); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -70,12 +91,13 @@ func (d *dnsHandler) CreateDnsInfo(c *gin.Context) {
 //	@Success		200		{object}	SuccessResponse	"Returns status of operation"
 //	@Failure		400		{object}	ErrorResponse	"Returns error message for invalid input"
 //	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message when dns provider fails"
 //
 // @Router /dns/{domain} [delete]
 func (d *dnsHandler) DeleteDnsInfo(c *gin.Context) {
 	domainName := c.Param("domain")
 	if domainName == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "domain is empty"})
+		writeBadRequest(c, "domain is empty")
 		return
 	}
 
@@ -83,7 +105,7 @@ func (d *dnsHandler) DeleteDnsInfo(c *gin.Context) {
 		c.Request.Context(),
 		domainName,
 	); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -107,18 +129,47 @@ func (d *dnsHandler) DeleteDnsInfo(c *gin.Context) {
 func (d *dnsHandler) GetDnsInfo(c *gin.Context) {
 	domainName := c.Param("domain")
 	if domainName == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "domain is empty"})
+		writeBadRequest(c, "domain is empty")
 		return
 	}
 
 	dnsInfo, err := d.dnsSvc.GetDomain(c.Request.Context(), domainName)
 	if err != nil {
-		if err == domain.ErrEntityNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
-			return
-		}
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FromAppDnsInfoToHandlerDnsInfo(dnsInfo))
+}
+
+// UpdateAcmeCa godoc
+// @Summary Selects ACME CA of domain certificates
+// @Description This endpoint switches domain between Let's Encrypt staging and production or custom ACME directory. If CA changed controllerd is notified to reconfigure Traefik and to drop certificates issued by previous CA
+// @Tags dns
+// @Accept json
+// @Produce json
+// @Param domain path string true "Domain Name"
+// @Param AcmeCaRequest body AcmeCaRequest true "ACME CA"
+//
+//	@Success		200		{object}	DnsInfo		"Returns the updated DNS record"
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for malformed request or invalid fields"
+//	@Failure		404		{object}	ErrorResponse	"Returns error message for record not found"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		503		{object}	ErrorResponse	"Returns error message when storage is unavailable"
+//
+// @Router /dns/{domain}/acme-ca [put]
+func (d *dnsHandler) UpdateAcmeCa(c *gin.Context) {
+	var req AcmeCaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	dnsInfo, err := d.dnsSvc.UpdateAcmeCa(
+		c.Request.Context(), c.Param("domain"), req.AcmeCa,
+	)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 
@@ -133,27 +184,29 @@ func (d *dnsHandler) GetDnsInfo(c *gin.Context) {
 // @Produce json
 // @Param domain path string true "Domain Name"
 //
-//	@Success		200		{object}	bool		"Returns true if the DNS record is valid, false otherwise"
+//	@Success		200		{object}	bool		"Returns true if the DNS record is valid"
 //	@Failure		400		{object}	ErrorResponse	"Returns error message for invalid input"
-//	@Failure		404		{object}	ErrorResponse	"Returns error message for record not found"
+//	@Failure		404		{object}	ErrorResponse	"Returns error message for unknown domain"
+//	@Failure		422		{object}	ErrorResponse	"Returns error message when A record does not point to the gateway"
 //	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message for dns lookup failure"
 //
 // @Router /dns/status/{domain} [get]
 func (d *dnsHandler) CheckDnsStatus(c *gin.Context) {
 	domainName := c.Param("domain")
 	if domainName == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "domain is empty"})
+		writeBadRequest(c, "domain is empty")
 		return
 	}
 
 	valid, err := d.dnsSvc.CheckDnsRecordStatus(c.Request.Context(), domainName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
 	if !valid {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "dns record not found"})
+		writeError(c, domain.ErrDnsRecordNotFound)
 		return
 	}
 
@@ -162,23 +215,137 @@ func (d *dnsHandler) CheckDnsStatus(c *gin.Context) {
 
 // GetGatewayIp godoc
 // @Summary Retrieves the IP address of the Gateway
-// @Description This endpoint retrieves the IP address of the Gateway
+// @Description This endpoint retrieves the public IP address of the Gateway
 // @Tags dns
 // @Accept json
 // @Produce json
 //
 //	@Success		200		{object}	string		"Returns IP address of the Gateway"
 //	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message when no ip provider succeeded"
 //
 // @Router /dns/ip [get]
 func (d *dnsHandler) GetGatewayIp(c *gin.Context) {
-	ipAddr, err := d.dnsSvc.GetGatewayIp(c.Request.Context())
+	gatewayIp, err := d.dnsSvc.GetGatewayIp(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gatewayIp.Ip)
+}
+
+// GetGatewayIpDetails godoc
+// @Summary Retrieves the IP address of the Gateway with its source
+// @Description This endpoint retrieves the public IP address of the Gateway together with the provider it was discovered by
+// @Tags dns
+// @Accept json
+// @Produce json
+//
+//	@Success		200		{object}	GatewayIp	"Returns IP address of the Gateway"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message when no ip provider succeeded"
+//
+// @Router /dns/ip/details [get]
+func (d *dnsHandler) GetGatewayIpDetails(c *gin.Context) {
+	gatewayIp, err := d.dnsSvc.GetGatewayIp(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FromAppGatewayIpToHandlerGatewayIp(gatewayIp))
+}
+
+// GetIpHistory godoc
+// @Summary Retrieves history of gateway IP changes
+// @Description This endpoint retrieves IP changes of the existing domain detected by dynamic DNS, newest first
+// @Tags dns
+// @Accept json
+// @Produce json
+// @Param limit query int false "Max number of events, defaults to 50"
+//
+//	@Success		200		{array}		IpChangeEvent	"Returns IP change events"
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for invalid input"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//
+// @Router /dns/ip/history [get]
+func (d *dnsHandler) GetIpHistory(c *gin.Context) {
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 0 {
+			writeBadRequest(c, "invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	events, err := d.dynamicDnsSvc.GetIpHistory(c.Request.Context(), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	result := make([]IpChangeEvent, 0, len(events))
+	for _, v := range events {
+		result = append(result, FromAppIpChangeEventToHandlerIpChangeEvent(v))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPendingNotifications godoc
+// @Summary Retrieves controllerd notifications not yet delivered
+// @Description This endpoint retrieves notifications waiting in outbox, oldest first, together with number of failed attempts and last error. Notification which failed 10 times or which controllerd can not handle is dead, it has dead_at set and is not retried
+// @Tags dns
+// @Accept json
+// @Produce json
+//
+//	@Success		200		{array}		Notification	"Returns pending notifications"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		503		{object}	ErrorResponse	"Returns error message when storage is unavailable"
+//
+// @Router /dns/notifications [get]
+func (d *dnsHandler) GetPendingNotifications(c *gin.Context) {
+	notifications, err := d.dispatcher.GetPendingNotifications(
+		c.Request.Context(),
+	)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	result := make([]Notification, 0, len(notifications))
+	for _, v := range notifications {
+		result = append(result, FromAppNotificationToHandlerNotification(v))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPreflightReport godoc
+// @Summary Checks public DNS of the domain before certificate is requested
+// @Description This endpoint checks A/AAAA/wildcard records, CAA, DNSSEC, conflicting records and TTLs and returns checklist of pass/warn/fail items with remediation
+// @Tags dns
+// @Accept json
+// @Produce json
+// @Param domain path string true "Domain name"
+//
+//	@Success		200		{object}	PreflightReport	"Returns preflight report"
+//	@Failure		400		{object}	ErrorResponse	"Returns error message for invalid domain"
+//	@Failure		500		{object}	ErrorResponse	"Returns error message for server error"
+//	@Failure		502		{object}	ErrorResponse	"Returns error message when gateway ip can not be discovered"
+//
+// @Router /dns/preflight/{domain} [get]
+func (d *dnsHandler) GetPreflightReport(c *gin.Context) {
+	report, err := d.preflightSvc.Run(c.Request.Context(), c.Param("domain"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ipAddr)
+	c.JSON(http.StatusOK, FromAppPreflightReportToHandlerPreflightReport(report))
 }
 
 // GetExistingDns godoc
@@ -195,7 +362,7 @@ func (d *dnsHandler) GetGatewayIp(c *gin.Context) {
 func (d *dnsHandler) GetExistingDns(c *gin.Context) {
 	dnsInfo, err := d.dnsSvc.GetExistingDomain(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -218,3 +385,26 @@ func (d *dnsHandler) GetExistingDns(c *gin.Context) {
 func (d *dnsHandler) Check(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
+
+// ServeChallenge godoc
+// @Summary Serves one-time reachability token
+// @Description This endpoint is fetched by dnsd itself, through the provisioned domain, to prove that ports 80/443 reach this gateway
+// @Tags dns
+// @Produce plain
+// @Param nonce path string true "Challenge nonce"
+//
+//	@Success		200		{string}	string		"Returns the token"
+//	@Failure		404		{object}	ErrorResponse	"Returns error message for unknown or expired nonce"
+//
+// @Router /.well-known/prem-gateway/{nonce} [get]
+func (d *dnsHandler) ServeChallenge(c *gin.Context) {
+	token, err := d.dnsSvc.GetChallengeToken(
+		c.Request.Context(), c.Param("nonce"),
+	)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.String(http.StatusOK, token)
+}
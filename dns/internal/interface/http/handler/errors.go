@@ -0,0 +1,57 @@
+package httphandler
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"prem-gateway/dns/internal/core/domain"
+)
+
+const (
+	codeInvalidRequest = "invalid_request"
+)
+
+var statusByKind = map[domain.Kind]int{
+	domain.KindInvalidArgument: http.StatusBadRequest,
+	domain.KindNotFound:        http.StatusNotFound,
+	domain.KindConflict:        http.StatusConflict,
+	domain.KindValidation:      http.StatusUnprocessableEntity,
+	domain.KindUpstreamFailed:  http.StatusBadGateway,
+	domain.KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+// writeError responds with status derived from domain error kind, errors
+// without kind are internal
+func writeError(c *gin.Context, err error) {
+	status, ok := statusByKind[domain.KindOf(err)]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	if status >= http.StatusInternalServerError {
+		log.Errorf("%v %v failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	var fields []FieldError
+	for _, v := range domain.FieldsOf(err) {
+		fields = append(fields, FieldError{
+			Field:   v.Field,
+			Code:    v.Code,
+			Message: v.Message,
+		})
+	}
+
+	c.JSON(status, ErrorResponse{
+		Code:   domain.CodeOf(err),
+		Error:  err.Error(),
+		Fields: fields,
+	})
+}
+
+// writeBadRequest responds to malformed request
+func writeBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, ErrorResponse{
+		Code:  codeInvalidRequest,
+		Error: message,
+	})
+}
@@ -1,12 +1,26 @@
 package httphandler
 
-import "prem-gateway/dns/internal/core/application"
+import (
+	"encoding/json"
+	"prem-gateway/dns/internal/core/application"
+	"time"
+)
 
 type DnsInfo struct {
 	Domain   string `json:"domain"`
 	Ip       string `json:"ip"`
 	NodeName string `json:"node_name"`
 	Email    string `json:"email"`
+	// AcmeCa is staging, production or https url of custom ACME directory,
+	// empty leaves CA to LETSENCRYPT_PROD of controllerd
+	AcmeCa string `json:"acme_ca" example:"production"`
+}
+
+// AcmeCaRequest selects ACME CA of domain certificates
+type AcmeCaRequest struct {
+	// AcmeCa is staging, production or https url of custom ACME directory,
+	// empty leaves CA to LETSENCRYPT_PROD of controllerd
+	AcmeCa string `json:"acme_ca" example:"production"`
 }
 
 func FromHandlerDnsInfoToAppDnsInfo(hdi DnsInfo) application.DnsInfo {
@@ -15,6 +29,7 @@ func FromHandlerDnsInfoToAppDnsInfo(hdi DnsInfo) application.DnsInfo {
 		Ip:       hdi.Ip,
 		NodeName: hdi.NodeName,
 		Email:    hdi.Email,
+		AcmeCa:   hdi.AcmeCa,
 	}
 }
 
@@ -24,13 +39,199 @@ func FromAppDnsInfoToHandlerDnsInfo(adi application.DnsInfo) DnsInfo {
 		Ip:       adi.Ip,
 		NodeName: adi.NodeName,
 		Email:    adi.Email,
+		AcmeCa:   adi.AcmeCa,
+	}
+}
+
+type GatewayIp struct {
+	Ip     string `json:"ip"`
+	Source string `json:"source"`
+}
+
+func FromAppGatewayIpToHandlerGatewayIp(agi application.GatewayIp) GatewayIp {
+	return GatewayIp{
+		Ip:     agi.Ip,
+		Source: agi.Source,
+	}
+}
+
+type IpChangeEvent struct {
+	OldIp         string    `json:"old_ip"`
+	NewIp         string    `json:"new_ip"`
+	Source        string    `json:"source"`
+	ProviderError string    `json:"provider_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at" format:"date-time"`
+}
+
+func FromAppIpChangeEventToHandlerIpChangeEvent(
+	aice application.IpChangeEvent,
+) IpChangeEvent {
+	return IpChangeEvent{
+		OldIp:         aice.OldIp,
+		NewIp:         aice.NewIp,
+		Source:        aice.Source,
+		ProviderError: aice.ProviderError,
+		CreatedAt:     aice.CreatedAt,
+	}
+}
+
+type Notification struct {
+	Id            int64     `json:"id" format:"int64"`
+	Type          string    `json:"type"`
+	Domain        string    `json:"domain"`
+	Email         string    `json:"email"`
+	AcmeCa        string    `json:"acme_ca"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" format:"date-time"`
+	CreatedAt     time.Time `json:"created_at" format:"date-time"`
+	// DeadAt is set once delivery is given up after max attempts or for
+	// notification controllerd can not handle, it is not retried anymore
+	DeadAt *time.Time `json:"dead_at,omitempty" format:"date-time"`
+}
+
+func FromAppNotificationToHandlerNotification(
+	an application.Notification,
+) Notification {
+	return Notification{
+		Id:            an.Id,
+		Type:          an.Type,
+		Domain:        an.Domain,
+		Email:         an.Email,
+		AcmeCa:        an.AcmeCa,
+		Attempts:      an.Attempts,
+		LastError:     an.LastError,
+		NextAttemptAt: an.NextAttemptAt,
+		CreatedAt:     an.CreatedAt,
+		DeadAt:        an.DeadAt,
+	}
+}
+
+// AuditEvent values Before and After are state of resource before and after
+// the action, null if there was none
+type AuditEvent struct {
+	Id        int64           `json:"id" format:"int64"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Resource  string          `json:"resource"`
+	Before    json.RawMessage `json:"before" swaggertype:"object"`
+	After     json.RawMessage `json:"after" swaggertype:"object"`
+	Outcome   string          `json:"outcome"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at" format:"date-time"`
+}
+
+func FromAppAuditEventToHandlerAuditEvent(
+	ae application.AuditEvent,
+) AuditEvent {
+	return AuditEvent{
+		Id:        ae.Id,
+		Actor:     ae.Actor,
+		Action:    ae.Action,
+		Resource:  ae.Resource,
+		Before:    toRawJson(ae.Before),
+		After:     toRawJson(ae.After),
+		Outcome:   ae.Outcome,
+		Error:     ae.Error,
+		CreatedAt: ae.CreatedAt,
+	}
+}
+
+type AuditEventRequest struct {
+	Action   string          `json:"action" binding:"required"`
+	Resource string          `json:"resource"`
+	Before   json.RawMessage `json:"before" swaggertype:"object"`
+	After    json.RawMessage `json:"after" swaggertype:"object"`
+	Outcome  string          `json:"outcome" binding:"required,oneof=success failure"`
+	Error    string          `json:"error"`
+}
+
+func FromHandlerAuditEventRequestToAppAuditEvent(
+	req AuditEventRequest,
+) application.AuditEvent {
+	return application.AuditEvent{
+		Action:   req.Action,
+		Resource: req.Resource,
+		Before:   fromRawJson(req.Before),
+		After:    fromRawJson(req.After),
+		Outcome:  req.Outcome,
+		Error:    req.Error,
+	}
+}
+
+// toRawJson returns stored json value, empty value is returned as null
+func toRawJson(v string) json.RawMessage {
+	if v == "" {
+		return json.RawMessage("null")
+	}
+
+	return json.RawMessage(v)
+}
+
+func fromRawJson(v json.RawMessage) string {
+	if len(v) == 0 || string(v) == "null" {
+		return ""
+	}
+
+	return string(v)
+}
+
+type PreflightReport struct {
+	Domain     string           `json:"domain"`
+	ExpectedIp string           `json:"expected_ip"`
+	Status     string           `json:"status" enums:"pass,warn,fail"`
+	Checks     []PreflightCheck `json:"checks"`
+	CreatedAt  time.Time        `json:"created_at" format:"date-time"`
+}
+
+type PreflightCheck struct {
+	Id          string `json:"id"`
+	Status      string `json:"status" enums:"pass,warn,fail"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func FromAppPreflightReportToHandlerPreflightReport(
+	ar application.PreflightReport,
+) PreflightReport {
+	checks := make([]PreflightCheck, 0, len(ar.Checks))
+	for _, v := range ar.Checks {
+		checks = append(checks, PreflightCheck{
+			Id:          v.Id,
+			Status:      v.Status,
+			Message:     v.Message,
+			Remediation: v.Remediation,
+		})
 	}
+
+	return PreflightReport{
+		Domain:     ar.Domain,
+		ExpectedIp: ar.ExpectedIp,
+		Status:     ar.Status,
+		Checks:     checks,
+		CreatedAt:  ar.CreatedAt,
+	}
+}
+
+type TxtRecord struct {
+	Fqdn  string `json:"fqdn" binding:"required"`
+	Value string `json:"value" binding:"required"`
 }
 
 type SuccessResponse struct {
 	Status string `json:"status"`
 }
 
+// ErrorResponse is body of every failed request, Code is machine-readable
+// and stable, Error is human-readable, Fields are set if input is invalid
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Code   string       `json:"code"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
@@ -9,12 +9,19 @@ import (
 	"net/http"
 	"prem-gateway/dns/internal/core/application"
 	"prem-gateway/dns/internal/core/domain"
+	httpclients "prem-gateway/dns/internal/infrastructure/http-clients"
 	httphandler "prem-gateway/dns/internal/interface/http/handler"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/signing"
 	"time"
 )
 
 const (
-	shutdownTimeout = time.Second * 5
+	shutdownTimeout                     = time.Second * 5
+	defaultDynamicDnsInterval           = time.Minute * 5
+	defaultNotificationDispatchInterval = time.Second * 5
+	// defaultCorsOrigin is premapp desktop dev server
+	defaultCorsOrigin = "http://localhost:1420"
 )
 
 type Server interface {
@@ -27,7 +34,19 @@ type server struct {
 	serverAddress string
 	opts          serverOptions
 	dnsHandler    httphandler.DNSHandler
+	acmeHandler   httphandler.AcmeHandler
+	auditHandler  httphandler.AuditHandler
+	adminHandler  httphandler.AdminHandler
+	cors          *cors.Cors
+	// daemonVerifier verifies requests of controllerd, nil if secret is
+	// not set
+	daemonVerifier *signing.Verifier
+	// authdVerifier verifies actor signed by authd, nil if secret is not
+	// set
+	authdVerifier *signing.Verifier
 	dnsSvc        application.DnsService
+	dynamicDnsSvc application.DynamicDnsService
+	dispatcher    application.NotificationDispatcher
 }
 
 func NewServer(
@@ -36,30 +55,136 @@ func NewServer(
 	controllerDaemonUrl string,
 	opts ...ServerOption,
 ) (Server, error) {
-	options := defaultServerOptions(controllerDaemonUrl)
+	options := defaultServerOptions()
 	for _, o := range opts {
 		if err := o.apply(&options); err != nil {
 			return nil, err
 		}
 	}
 
+	if options.controllerdWrapper == nil {
+		controllerdWrapper, err := httpclients.NewControllerdWrapper(
+			controllerDaemonUrl, options.controllerdSecret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		options.controllerdWrapper = controllerdWrapper
+	}
+
+	dispatcher, err := application.NewNotificationDispatcher(
+		repositorySvc,
+		options.controllerdWrapper,
+		options.notificationDispatchInterval,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	auditSvc := application.NewAuditService(repositorySvc)
+
 	dnsSvc, err := application.NewDnsService(
-		repositorySvc, options.ipSvc, options.controllerdWrapper,
+		repositorySvc,
+		options.ipSvc,
+		dispatcher,
+		options.reachabilityChecker,
+		options.dnsProvider,
+		auditSvc,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	dnsHandler, err := httphandler.NewDNSHandler(dnsSvc)
+	// interval is only used by the background check, history is served
+	// even if dynamic dns is disabled
+	interval := options.dynamicDnsInterval
+	if interval <= 0 {
+		interval = defaultDynamicDnsInterval
+	}
+	dynamicDnsSvc, err := application.NewDynamicDnsService(
+		repositorySvc, options.ipSvc, options.dnsProvider, auditSvc, interval,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	preflightSvc, err := application.NewPreflightService(
+		repositorySvc,
+		options.ipSvc,
+		options.preflightResolver,
+		options.preflightCaaIssuer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsHandler, err := httphandler.NewDNSHandler(
+		dnsSvc, dynamicDnsSvc, dispatcher, preflightSvc,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// ACME endpoints plant challenge records, they are served only by
+	// authoritative dns server and only to callers knowing the secret
+	var acmeHandler httphandler.AcmeHandler
+	if options.zoneSvc != nil && options.acmeSecret != "" {
+		acmeHandler, err = httphandler.NewAcmeHandler(options.zoneSvc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	auditHandler, err := httphandler.NewAuditHandler(auditSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	adminHandler, err := httphandler.NewAdminHandler(options.effectiveConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var daemonVerifier *signing.Verifier
+	if options.controllerdSecret != "" {
+		if daemonVerifier, err = signing.NewVerifier(
+			options.controllerdSecret, 0,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	var authdVerifier *signing.Verifier
+	if options.authdSecret != "" {
+		if authdVerifier, err = signing.NewVerifier(
+			options.authdSecret, 0,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	corsConfig := options.corsConfig
+	if options.corsAllowProvisionedDomain {
+		corsConfig.AllowOriginFunc = httphandler.ProvisionedDomainOrigin(dnsSvc)
+	}
+	corsPolicy, err := cors.New(corsConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	return &server{
-		serverAddress: serverAddress,
-		opts:          options,
-		dnsHandler:    dnsHandler,
-		dnsSvc:        dnsSvc,
+		serverAddress:  serverAddress,
+		opts:           options,
+		dnsHandler:     dnsHandler,
+		acmeHandler:    acmeHandler,
+		auditHandler:   auditHandler,
+		adminHandler:   adminHandler,
+		cors:           corsPolicy,
+		daemonVerifier: daemonVerifier,
+		authdVerifier:  authdVerifier,
+		dnsSvc:         dnsSvc,
+		dynamicDnsSvc:  dynamicDnsSvc,
+		dispatcher:     dispatcher,
 	}, nil
 }
 
@@ -92,6 +217,12 @@ func (s *server) Start(ctx context.Context, stop context.CancelFunc) <-chan erro
 		log.Info("prem-gateway dns daemon graceful shutdown completed")
 	}()
 
+	s.dispatcher.Start(ctx)
+
+	if s.opts.dynamicDnsInterval > 0 {
+		s.dynamicDnsSvc.Start(ctx)
+	}
+
 	go func() {
 		log.Infof("prem-gateway dns daemon listening and serving at: %v", s.serverAddress)
 
@@ -109,24 +240,41 @@ func (s *server) Stop() error {
 
 func (s *server) Router() http.Handler {
 	ginEngine := gin.Default()
-	ginEngine.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:1420") // Replace with your frontend origin
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
-			return
-		}
-		c.Next()
-	})
+	ginEngine.Use(httphandler.CorsMiddleware(s.cors))
+	ginEngine.Use(httphandler.ActorMiddleware(s.authdVerifier))
 
 	ginEngine.POST("/dns", s.dnsHandler.CreateDnsInfo)
 	ginEngine.DELETE("/dns/:domain", s.dnsHandler.DeleteDnsInfo)
 	ginEngine.GET("/dns/:domain", s.dnsHandler.GetDnsInfo)
+	ginEngine.PUT("/dns/:domain/acme-ca", s.dnsHandler.UpdateAcmeCa)
 	ginEngine.GET("/dns/status/:domain", s.dnsHandler.CheckDnsStatus)
+	ginEngine.GET("/dns/preflight/:domain", s.dnsHandler.GetPreflightReport)
 	ginEngine.GET("/dns/ip", s.dnsHandler.GetGatewayIp)
+	ginEngine.GET("/dns/ip/details", s.dnsHandler.GetGatewayIpDetails)
+	ginEngine.GET("/dns/ip/history", s.dnsHandler.GetIpHistory)
 	ginEngine.GET("/dns/check", s.dnsHandler.Check)
 	ginEngine.GET("/dns/existing", s.dnsHandler.GetExistingDns)
+	ginEngine.GET("/dns/notifications", s.dnsHandler.GetPendingNotifications)
+	// audit log and configuration are read only by authenticated callers
+	authenticated := httphandler.AuthenticatedMiddleware(s.daemonVerifier)
+	ginEngine.GET("/audit", authenticated, s.auditHandler.GetAuditEvents)
+	// audit events are recorded only by daemons sharing the secret
+	if s.daemonVerifier != nil {
+		ginEngine.POST(
+			"/audit",
+			httphandler.SignedMiddleware(s.daemonVerifier),
+			s.auditHandler.AddAuditEvent,
+		)
+	}
+	ginEngine.GET("/admin/config", authenticated, s.adminHandler.GetConfig)
+	if s.acmeHandler != nil {
+		acme := ginEngine.Group("/acme", httphandler.AcmeAuthMiddleware(s.opts.acmeSecret))
+		acme.POST("/present", s.acmeHandler.Present)
+		acme.POST("/cleanup", s.acmeHandler.Cleanup)
+	}
+	ginEngine.GET(
+		httpclients.ChallengePathPrefix+":nonce", s.dnsHandler.ServeChallenge,
+	)
 	ginEngine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return ginEngine
@@ -1,8 +1,14 @@
 package httpdnsd
 
 import (
+	"fmt"
+	"prem-gateway/dns/internal/core/application"
 	"prem-gateway/dns/internal/core/port"
+	dnsresolver "prem-gateway/dns/internal/infrastructure/dns-resolver"
 	httpclients "prem-gateway/dns/internal/infrastructure/http-clients"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/signing"
+	"time"
 )
 
 type ServerOption interface {
@@ -10,16 +16,52 @@ type ServerOption interface {
 }
 
 type serverOptions struct {
-	ipSvc              port.IpService
+	ipSvc port.IpService
+	// controllerdWrapper, if not set, is created for controller daemon url
+	// signing requests with controllerdSecret. POST /audit verifies
+	// requests of controllerd with the same secret, it is not served if
+	// secret is not set
 	controllerdWrapper port.ControllerdWrapper
+	controllerdSecret  string
+	// authdSecret is used to verify actor signed by authd, actor of
+	// requests is anonymous if it is not set
+	authdSecret         string
+	reachabilityChecker port.ReachabilityChecker
+	dnsProvider         port.DnsProvider
+	// dynamicDnsInterval is interval of public ip check, 0 disables it
+	dynamicDnsInterval time.Duration
+	// zoneSvc is shared with authoritative dns server, ACME endpoints are
+	// served only if it is set together with acmeSecret
+	zoneSvc    application.ZoneService
+	acmeSecret string
+	// notificationDispatchInterval is how often outbox is polled for
+	// notifications due for retry
+	notificationDispatchInterval time.Duration
+	// preflightResolver is used to check public dns of the domain
+	preflightResolver  port.DnsResolver
+	preflightCaaIssuer string
+	// effectiveConfig is served at admin endpoint, secrets must be redacted
+	effectiveConfig map[string]interface{}
+	corsConfig      cors.Config
+	// corsAllowProvisionedDomain allows origins of the provisioned domain
+	// and its subdomains on top of corsConfig ones
+	corsAllowProvisionedDomain bool
 }
 
-func defaultServerOptions(controllerDaemonUrl string) serverOptions {
-	ipSvc := httpclients.NewIpService()
-	controllerdWrapper := httpclients.NewControllerdWrapper(controllerDaemonUrl)
+func defaultServerOptions() serverOptions {
+	ipSvc := httpclients.NewDefaultIpService()
+	reachabilityChecker := httpclients.NewReachabilityChecker()
+	preflightResolver := dnsresolver.NewResolver(nil, 0)
 	return serverOptions{
-		ipSvc:              ipSvc,
-		controllerdWrapper: controllerdWrapper,
+		ipSvc:                        ipSvc,
+		reachabilityChecker:          reachabilityChecker,
+		notificationDispatchInterval: defaultNotificationDispatchInterval,
+		preflightResolver:            preflightResolver,
+		preflightCaaIssuer:           application.DefaultCaaIssuer,
+		corsConfig: cors.Config{
+			AllowedOrigins: []string{defaultCorsOrigin},
+		},
+		corsAllowProvisionedDomain: true,
 	}
 }
 
@@ -52,3 +94,134 @@ func WithControllerdWrapper(
 		return nil
 	})
 }
+
+// WithReachabilityChecker overrides default reachability checker, nil
+// disables reachability check
+func WithReachabilityChecker(
+	reachabilityChecker port.ReachabilityChecker,
+) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.reachabilityChecker = reachabilityChecker
+		return nil
+	})
+}
+
+func WithDnsProvider(dnsProvider port.DnsProvider) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.dnsProvider = dnsProvider
+		return nil
+	})
+}
+
+// WithDynamicDns enables periodic check of public ip which updates stored
+// record, and dns provider records, when ip changes
+func WithDynamicDns(interval time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if interval <= 0 {
+			return fmt.Errorf("invalid dynamic dns interval: %v", interval)
+		}
+
+		o.dynamicDnsInterval = interval
+		return nil
+	})
+}
+
+func WithZoneService(zoneSvc application.ZoneService) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.zoneSvc = zoneSvc
+		return nil
+	})
+}
+
+// WithAcmeSecret sets password ACME endpoints require in basic auth of
+// httphandler.AcmeUsername
+func WithAcmeSecret(secret string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if len(secret) < signing.MinSecretLength {
+			return fmt.Errorf(
+				"acme secret must be at least %v characters", signing.MinSecretLength,
+			)
+		}
+
+		o.acmeSecret = secret
+		return nil
+	})
+}
+
+func WithNotificationDispatchInterval(interval time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if interval <= 0 {
+			return fmt.Errorf("invalid notification dispatch interval: %v", interval)
+		}
+
+		o.notificationDispatchInterval = interval
+		return nil
+	})
+}
+
+// WithPreflight sets resolver used to check public dns of the domain and CA
+// which CAA records must allow, empty caaIssuer keeps the default one
+func WithPreflight(
+	resolver port.DnsResolver, caaIssuer string,
+) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if resolver == nil {
+			return fmt.Errorf("preflight dns resolver not set")
+		}
+
+		o.preflightResolver = resolver
+		if caaIssuer != "" {
+			o.preflightCaaIssuer = caaIssuer
+		}
+		return nil
+	})
+}
+
+// WithEffectiveConfig sets configuration served at /admin/config, caller
+// must redact secrets
+func WithEffectiveConfig(effectiveConfig map[string]interface{}) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.effectiveConfig = effectiveConfig
+		return nil
+	})
+}
+
+// WithCors sets CORS policy, AllowOriginFunc of cfg is replaced if
+// allowProvisionedDomain is set
+func WithCors(cfg cors.Config, allowProvisionedDomain bool) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if _, err := cors.New(cfg); err != nil {
+			return err
+		}
+
+		o.corsConfig = cfg
+		o.corsAllowProvisionedDomain = allowProvisionedDomain
+		return nil
+	})
+}
+
+// WithControllerdSecret sets secret shared with controllerd used to sign
+// requests to it and to verify audit events it records, signing has no
+// effect if WithControllerdWrapper is used
+func WithControllerdSecret(secret string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.controllerdSecret = secret
+		return nil
+	})
+}
+
+// WithAuthdSecret sets secret authd signs X-Auth-Actor header with, header
+// is ignored without it
+func WithAuthdSecret(secret string) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if len(secret) < signing.MinSecretLength {
+			return fmt.Errorf(
+				"authd secret must be at least %v characters",
+				signing.MinSecretLength,
+			)
+		}
+
+		o.authdSecret = secret
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+package configtest
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"prem-gateway/dns/internal/config"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	dnsdhttp "prem-gateway/dns/internal/interface/http"
+	"prem-gateway/pkg/signing"
+	"testing"
+	"time"
+)
+
+const controllerdSecret = "3f1c0a9e7b5d4c2a1908f7e6d5c4b3a2"
+
+func TestLoadConfigDefaults(t *testing.T) {
+	datadir := filepath.Join(t.TempDir(), "nested", "dnsd")
+
+	require.NoError(t, config.LoadConfig([]string{"--datadir", datadir}))
+	require.Equal(t, "127.0.0.1", config.GetString(config.DbHostKey))
+	require.Equal(t, 5432, config.GetInt(config.DbPortKey))
+	require.Equal(t, 5*time.Second, config.GetDuration(config.DbConnectTimeoutKey))
+	require.Equal(t, log.DebugLevel, config.GetLogLevel())
+
+	// data dir is created on load
+	info, err := os.Stat(datadir)
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "dnsd.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"db_host: file-host\n"+
+			"db_port: 6543\n"+
+			"db_name: file-db\n"+
+			"log_level: warn\n"+
+			"dynamic_dns_interval: 1m\n",
+	), 0600))
+
+	t.Setenv("PREM_GATEWAY_DNS_DB_HOST", "env-host")
+	t.Setenv("PREM_GATEWAY_DNS_DB_NAME", "env-db")
+
+	require.NoError(t, config.LoadConfig([]string{
+		"--config", configFile,
+		"--datadir", dir,
+		"--db-name", "flag-db",
+	}))
+
+	// flag over env over file over default
+	require.Equal(t, "flag-db", config.GetString(config.DbNameKey))
+	require.Equal(t, "env-host", config.GetString(config.DbHostKey))
+	require.Equal(t, 6543, config.GetInt(config.DbPortKey))
+	require.Equal(t, time.Minute, config.GetDuration(config.DynamicDnsIntervalKey))
+	require.Equal(t, "root", config.GetString(config.DbUserKey))
+
+	// log level is applied
+	require.Equal(t, log.WarnLevel, config.GetLogLevel())
+	require.Equal(t, log.WarnLevel, log.GetLevel())
+}
+
+func TestLoadConfigToml(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "dnsd.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(
+		"db_type = \"bolt\"\nauth_dns_enabled = true\n",
+	), 0600))
+
+	t.Setenv("PREM_GATEWAY_DNS_CONFIG_FILE", configFile)
+	require.NoError(t, config.LoadConfig([]string{"--datadir", dir}))
+	require.Equal(t, config.DbTypeBolt, config.GetString(config.DbTypeKey))
+	require.True(t, config.GetBool(config.AuthDnsEnabledKey))
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	err := config.LoadConfig([]string{
+		"--datadir", t.TempDir(),
+		"--port-port", "0",
+		"--log-level", "loud",
+		"--db-type", "mongo",
+		"--dynamic-dns-interval", "0s",
+		"--ip-cache-ttl", "soon",
+		"--controller-daemon-url", "controllerd:8080",
+		"--static-ip", "1.2.3",
+		"--db-max-conns", "2",
+		"--db-min-conns", "5",
+		"--controller-daemon-secret", "short",
+	})
+	require.Error(t, err)
+
+	for _, v := range []string{
+		config.PortKey,
+		config.LogLevelKey,
+		config.DbTypeKey,
+		config.DynamicDnsIntervalKey,
+		config.IpCacheTTLKey,
+		config.ControllerDaemonUrlKey,
+		config.StaticIpKey,
+		config.DbMinConnsKey,
+		config.ControllerDaemonSecretKey,
+	} {
+		require.Contains(t, err.Error(), "invalid "+v+":")
+	}
+
+	require.Error(t, config.LoadConfig([]string{"--unknown-flag", "x"}))
+	require.Error(t, config.LoadConfig([]string{"--config", "/does/not/exist.yaml"}))
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	require.NoError(t, config.LoadConfig([]string{
+		"--datadir", t.TempDir(),
+		"--db-pass", "db-secret",
+		"--db-url", "postgres://dnsd:url-secret@pg:5432/dnsd",
+		"--cloudflare-api-token", "cf-secret",
+	}))
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", inmemory.NewDBService(), "",
+		dnsdhttp.WithEffectiveConfig(config.Effective()),
+		dnsdhttp.WithControllerdSecret(controllerdSecret),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	// configuration is not served to unauthenticated callers
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/config", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	signer, err := signing.NewSigner(controllerdSecret)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/config", nil)
+	require.NoError(t, signer.Sign(req, nil))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "secret")
+
+	var effective map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &effective))
+	require.Equal(t, "******", effective[config.DbPassKey])
+	require.Equal(t, "******", effective[config.CloudflareApiTokenKey])
+	require.Equal(t, "postgres://dnsd:******@pg:5432/dnsd", effective[config.DbUrlKey])
+	require.Equal(t, "", effective[config.DigitalOceanApiTokenKey])
+	require.Equal(t, "127.0.0.1", effective[config.DbHostKey])
+}
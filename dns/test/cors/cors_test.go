@@ -0,0 +1,236 @@
+package corstest
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"prem-gateway/pkg/cors"
+	"testing"
+	"time"
+)
+
+func TestCors(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name    string
+		cfg     cors.Config
+		method  string
+		headers map[string]string
+		// status is 204 for answered preflight, 418 if request was passed on
+		status int
+		want   map[string]string
+	}{
+		{
+			name:   "no origin",
+			cfg:    cors.Config{AllowedOrigins: []string{"http://localhost:1420"}},
+			method: http.MethodGet,
+			status: http.StatusTeapot,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "exact origin",
+			cfg:     cors.Config{AllowedOrigins: []string{"http://localhost:1420"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "http://localhost:1420"},
+			status:  http.StatusTeapot,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "http://localhost:1420",
+				"Access-Control-Allow-Credentials": "",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:    "port must match",
+			cfg:     cors.Config{AllowedOrigins: []string{"http://localhost:1420"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "http://localhost:8085"},
+			status:  http.StatusTeapot,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "wildcard subdomain",
+			cfg:     cors.Config{AllowedOrigins: []string{"https://*.example.com"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://app.EXAMPLE.com"},
+			status:  http.StatusTeapot,
+			want: map[string]string{
+				"Access-Control-Allow-Origin": "https://app.EXAMPLE.com",
+			},
+		},
+		{
+			name:    "wildcard subdomain does not match parent",
+			cfg:     cors.Config{AllowedOrigins: []string{"https://*.example.com"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusTeapot,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "scheme must match",
+			cfg:     cors.Config{AllowedOrigins: []string{"https://*.example.com"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "http://app.example.com"},
+			status:  http.StatusTeapot,
+			want:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "any origin",
+			cfg:     cors.Config{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Request-Id"}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://any.org"},
+			status:  http.StatusTeapot,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "X-Request-Id",
+			},
+		},
+		{
+			name: "origin func",
+			cfg: cors.Config{AllowOriginFunc: func(r *http.Request, origin string) bool {
+				return cors.MatchDomain(origin, "gateway.me")
+			}},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://premapp.gateway.me:8443"},
+			status:  http.StatusTeapot,
+			want: map[string]string{
+				"Access-Control-Allow-Origin": "https://premapp.gateway.me:8443",
+			},
+		},
+		{
+			name: "preflight",
+			cfg: cors.Config{
+				AllowedOrigins:   []string{"http://localhost:1420"},
+				AllowCredentials: true,
+				MaxAge:           10 * time.Minute,
+			},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "http://localhost:1420",
+				"Access-Control-Request-Method":  "DELETE",
+				"Access-Control-Request-Headers": "authorization",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "http://localhost:1420",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, PATCH",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		{
+			name:   "preflight not allowed origin",
+			cfg:    cors.Config{AllowedOrigins: []string{"http://localhost:1420"}},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.org",
+				"Access-Control-Request-Method": "POST",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		{
+			name: "preflight any header",
+			cfg: cors.Config{
+				AllowedOrigins: []string{"http://localhost:1420"},
+				AllowedHeaders: []string{"*"},
+			},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "http://localhost:1420",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "x-custom, content-type",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Headers": "x-custom, content-type",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+		{
+			name:    "options without request method is not preflight",
+			cfg:     cors.Config{AllowedOrigins: []string{"http://localhost:1420"}},
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "http://localhost:1420"},
+			status:  http.StatusTeapot,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "http://localhost:1420",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := cors.New(tt.cfg)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/dns", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			policy.Handler(okHandler).ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			for k, v := range tt.want {
+				require.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := cors.New(cors.Config{
+		AllowedOrigins: []string{"*"}, AllowCredentials: true,
+	})
+	require.ErrorIs(t, err, cors.ErrWildcardWithCredentials)
+
+	for _, v := range []string{
+		"localhost:1420", "ftp://example.com", "https://example.com/app",
+		"https://app.*.example.com",
+	} {
+		_, err := cors.New(cors.Config{AllowedOrigins: []string{v}})
+		require.Error(t, err, v)
+	}
+
+	_, err = cors.New(cors.Config{MaxAge: -time.Second})
+	require.Error(t, err)
+
+	_, err = cors.New(cors.Config{
+		AllowedOrigins: []string{"https://*.example.com", "http://localhost:1420/", " "},
+	})
+	require.NoError(t, err)
+}
+
+func TestMatchDomain(t *testing.T) {
+	require.True(t, cors.MatchDomain("https://gateway.me", "gateway.me"))
+	require.True(t, cors.MatchDomain("http://premapp.gateway.me:8085", "gateway.me."))
+	require.False(t, cors.MatchDomain("https://evilgateway.me", "gateway.me"))
+	require.False(t, cors.MatchDomain("https://gateway.me.evil.org", "gateway.me"))
+	require.False(t, cors.MatchDomain("null", "gateway.me"))
+	require.False(t, cors.MatchDomain("https://gateway.me", ""))
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("AUTHD_CORS_ALLOWED_ORIGINS", "http://localhost:1420, https://*.gateway.me")
+	t.Setenv("AUTHD_CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("AUTHD_CORS_MAX_AGE", "5m")
+
+	cfg, err := cors.FromEnv("AUTHD_")
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://localhost:1420", "https://*.gateway.me"}, cfg.AllowedOrigins)
+	require.Empty(t, cfg.AllowedHeaders)
+	require.True(t, cfg.AllowCredentials)
+	require.Equal(t, 5*time.Minute, cfg.MaxAge)
+
+	t.Setenv("AUTHD_CORS_MAX_AGE", "5")
+	_, err = cors.FromEnv("AUTHD_")
+	require.Error(t, err)
+}
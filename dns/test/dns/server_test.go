@@ -0,0 +1,84 @@
+package dnstest
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"net"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	dnsserver "prem-gateway/dns/internal/interface/dns"
+	"testing"
+)
+
+func TestAuthoritativeDnsServer(t *testing.T) {
+	ctx := context.Background()
+	repositorySvc := inmemory.NewDBService()
+	require.NoError(t, repositorySvc.DnsRepository().Create(ctx, domain.DnsInfo{
+		Domain: "example.com",
+		Ip:     "1.2.3.4",
+	}))
+	zoneSvc, err := application.NewZoneService(repositorySvc, "", "")
+	require.NoError(t, err)
+	srv := dnsserver.NewServer("", 300, zoneSvc)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dnsSrv := &dns.Server{PacketConn: conn, Handler: srv.Handler()}
+	go func() { _ = dnsSrv.ActivateAndServe() }()
+	defer func() { _ = dnsSrv.Shutdown() }()
+
+	addr := conn.LocalAddr().String()
+	query := func(name string, qtype uint16) *dns.Msg {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(name), qtype)
+
+		resp, err := dns.Exchange(msg, addr)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := query("example.com", dns.TypeA)
+	require.True(t, resp.Authoritative)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, "1.2.3.4", resp.Answer[0].(*dns.A).A.String())
+
+	resp = query("premd.example.com", dns.TypeA)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, "premd.example.com.", resp.Answer[0].Header().Name)
+
+	resp = query("example.com", dns.TypeAAAA)
+	require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	require.Len(t, resp.Answer, 0)
+	require.Len(t, resp.Ns, 1)
+	require.IsType(t, &dns.SOA{}, resp.Ns[0])
+
+	resp = query("example.com", dns.TypeCAA)
+	require.Len(t, resp.Answer, 2)
+	require.Equal(t, "letsencrypt.org", resp.Answer[0].(*dns.CAA).Value)
+
+	resp = query("example.com", dns.TypeNS)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, "ns1.example.com.", resp.Answer[0].(*dns.NS).Ns)
+
+	require.NoError(t, zoneSvc.PresentTxtRecord(
+		ctx, "_acme-challenge.example.com.", "token",
+	))
+	resp = query("_ACME-challenge.example.com", dns.TypeTXT)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, []string{"token"}, resp.Answer[0].(*dns.TXT).Txt)
+
+	require.NoError(t, zoneSvc.CleanupTxtRecord(
+		ctx, "_acme-challenge.example.com", "token",
+	))
+	resp = query("_acme-challenge.example.com", dns.TypeTXT)
+	require.Len(t, resp.Answer, 0)
+
+	// challenge of other zone is rejected
+	err = zoneSvc.PresentTxtRecord(ctx, "_acme-challenge.example.org", "token")
+	require.ErrorIs(t, err, application.ErrOutsideOfZone)
+
+	resp = query("example.org", dns.TypeA)
+	require.Equal(t, dns.RcodeRefused, resp.Rcode)
+}
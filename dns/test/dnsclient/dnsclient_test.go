@@ -0,0 +1,211 @@
+package dnsclienttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"prem-gateway/dns/internal/core/port"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	dnsdhttp "prem-gateway/dns/internal/interface/http"
+	"prem-gateway/pkg/dnsclient"
+	"prem-gateway/pkg/dnsclient/gen"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const secret = "3f1c0a9e7b5d4c2a1908f7e6d5c4b3a2"
+
+// TestGeneratedUpToDate fails if swagger spec changed and go generate was
+// not run in pkg/dnsclient
+func TestGeneratedUpToDate(t *testing.T) {
+	spec, err := os.ReadFile("../../docs/swagger.json")
+	require.NoError(t, err)
+	generated, err := os.ReadFile("../../../pkg/dnsclient/types_gen.go")
+	require.NoError(t, err)
+
+	src, err := gen.Generate(spec, "dnsclient")
+	require.NoError(t, err)
+	require.Equal(t, string(src), string(generated))
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "gateway.me").
+		Return(true, nil)
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "admin@gateway.me", "gateway.me", "").
+		Return(nil)
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", inmemory.NewDBService(), "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithControllerdWrapper(controllerdWrapperMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+		dnsdhttp.WithEffectiveConfig(map[string]interface{}{"DB_TYPE": "inmemory"}),
+		dnsdhttp.WithControllerdSecret(secret),
+	)
+	require.NoError(t, err)
+	srv := httptest.NewServer(dnsd.Router())
+	defer srv.Close()
+
+	client, err := dnsclient.New(
+		srv.URL, dnsclient.WithActor("controllerd"), dnsclient.WithSecret(secret),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Check(ctx))
+
+	existing, err := client.GetExistingDomain(ctx)
+	require.NoError(t, err)
+	require.Nil(t, existing)
+
+	_, err = client.GetDomain(ctx, "gateway.me")
+	require.ErrorIs(t, err, dnsclient.ErrNotFound)
+
+	dnsInfo := dnsclient.DnsInfo{
+		Domain: "gateway.me",
+		Ip:     "100.27.28.72",
+		Email:  "admin@gateway.me",
+	}
+	require.NoError(t, client.CreateDomain(ctx, dnsInfo))
+
+	err = client.CreateDomain(ctx, dnsInfo)
+	require.ErrorIs(t, err, dnsclient.ErrAlreadyExists)
+	var apiErr *dnsclient.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "already_exists", apiErr.Code)
+
+	err = client.CreateDomain(ctx, dnsclient.DnsInfo{Domain: "co.uk", Ip: "1.2"})
+	require.ErrorIs(t, err, dnsclient.ErrInvalidArgument)
+	require.True(t, errors.As(err, &apiErr))
+	require.Len(t, apiErr.Fields, 2)
+
+	got, err := client.GetDomain(ctx, "gateway.me")
+	require.NoError(t, err)
+	require.Equal(t, dnsInfo, *got)
+
+	existing, err = client.GetExistingDomain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, dnsInfo, *existing)
+
+	ok, err := client.CheckDomainStatus(ctx, "gateway.me")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	notifications, err := client.GetPendingNotifications(ctx)
+	require.NoError(t, err)
+	require.Empty(t, notifications)
+
+	after, err := json.Marshal([]string{"premapp", "traefik"})
+	require.NoError(t, err)
+	require.NoError(t, client.RecordAuditEvent(ctx, dnsclient.AuditEventRequest{
+		Action:   "services.restart",
+		Resource: "gateway.me",
+		After:    after,
+		Outcome:  "success",
+	}))
+
+	// dnsd accepts audit events only from signed clients
+	unsigned, err := dnsclient.New(
+		srv.URL, dnsclient.WithActor("controllerd"), dnsclient.WithRetry(0, 0),
+	)
+	require.NoError(t, err)
+	err = unsigned.RecordAuditEvent(ctx, dnsclient.AuditEventRequest{
+		Action:  "services.restart",
+		Outcome: "success",
+	})
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = dnsclient.New(srv.URL, dnsclient.WithSecret("short"))
+	require.Error(t, err)
+
+	events, err := client.GetAuditEvents(ctx, dnsclient.AuditFilter{
+		Actions: []string{"services.restart"},
+		From:    time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "controllerd", events[0].Actor)
+	require.JSONEq(t, `["premapp","traefik"]`, string(events[0].After))
+	require.False(t, events[0].CreatedAt.IsZero())
+
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "admin@gateway.me", "gateway.me", "staging").
+		Return(nil)
+	got, err = client.UpdateAcmeCa(ctx, "gateway.me", "staging")
+	require.NoError(t, err)
+	require.Equal(t, "staging", got.AcmeCa)
+	_, err = client.UpdateAcmeCa(ctx, "gateway.me", "ftp://acme.example.com")
+	require.ErrorIs(t, err, dnsclient.ErrInvalidArgument)
+
+	config, err := client.GetConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "inmemory", config["DB_TYPE"])
+
+	require.NoError(t, client.DeleteDomain(ctx, "gateway.me"))
+	_, err = client.GetDomain(ctx, "gateway.me")
+	require.ErrorIs(t, err, dnsclient.ErrNotFound)
+}
+
+func TestClientRetry(t *testing.T) {
+	ctx := context.Background()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"code":"storage_unavailable","error":"db down"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ip":"100.27.28.72","source":"static"}`))
+	}))
+	defer srv.Close()
+
+	client, err := dnsclient.New(srv.URL, dnsclient.WithRetry(2, time.Millisecond))
+	require.NoError(t, err)
+
+	// idempotent request is retried
+	ip, err := client.GetGatewayIp(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "100.27.28.72", ip.Ip)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// request which reached dnsd is not retried if it is not idempotent
+	atomic.StoreInt32(&calls, 0)
+	err = client.CreateDomain(ctx, dnsclient.DnsInfo{Domain: "gateway.me"})
+	require.ErrorIs(t, err, dnsclient.ErrUnavailable)
+	require.Equal(t, "dnsd: 503 storage_unavailable: db down", err.Error())
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// retries give up
+	atomic.StoreInt32(&calls, -10)
+	client, err = dnsclient.New(srv.URL, dnsclient.WithRetry(1, time.Millisecond))
+	require.NoError(t, err)
+	_, err = client.GetGatewayIp(ctx)
+	require.ErrorIs(t, err, dnsclient.ErrUnavailable)
+	require.EqualValues(t, -8, atomic.LoadInt32(&calls))
+
+	// connection refused is retried until context is done
+	srv.Close()
+	client, err = dnsclient.New(srv.URL, dnsclient.WithRetry(100, 10*time.Millisecond))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, client.CreateDomain(ctx, dnsclient.DnsInfo{Domain: "gateway.me"}))
+	require.Less(t, time.Since(start), time.Second)
+
+	_, err = dnsclient.New("dnsd:8080")
+	require.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package dnsprovidertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"prem-gateway/dns/internal/core/port"
+	dnsprovider "prem-gateway/dns/internal/infrastructure/dns-provider"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	apiToken       = "token"
+	cloudflareZone = "zone-1"
+)
+
+type cloudflareRecord struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Ttl     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// cloudflareApi is fake Cloudflare API hosting example.com zone, it records
+// methods of requests changing records
+type cloudflareApi struct {
+	mtx     sync.Mutex
+	nextId  int
+	records map[string]cloudflareRecord
+	calls   []string
+}
+
+func (c *cloudflareApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+apiToken {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`)
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "zones":
+		result := make([]map[string]string, 0)
+		if r.URL.Query().Get("name") == "example.com" {
+			result = append(result, map[string]string{"id": cloudflareZone, "name": "example.com"})
+		}
+		writeJson(w, map[string]interface{}{"result": result})
+		return
+	case len(parts) < 3 || parts[1] != cloudflareZone || parts[2] != "dns_records":
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		c.calls = append(c.calls, r.Method)
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3:
+		result := make([]cloudflareRecord, 0)
+		for _, v := range c.records {
+			if v.Type == r.URL.Query().Get("type") && v.Name == r.URL.Query().Get("name") {
+				result = append(result, v)
+			}
+		}
+		writeJson(w, map[string]interface{}{"result": result})
+	case r.Method == http.MethodPost && len(parts) == 3:
+		var record cloudflareRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.nextId++
+		record.Id = fmt.Sprint(c.nextId)
+		c.records[record.Id] = record
+		writeJson(w, map[string]interface{}{"result": record})
+	case r.Method == http.MethodPut && len(parts) == 4:
+		if _, ok := c.records[parts[3]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var record cloudflareRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		record.Id = parts[3]
+		c.records[record.Id] = record
+		writeJson(w, map[string]interface{}{"result": record})
+	case r.Method == http.MethodDelete && len(parts) == 4:
+		if _, ok := c.records[parts[3]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(c.records, parts[3])
+		writeJson(w, map[string]interface{}{"result": map[string]string{"id": parts[3]}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *cloudflareApi) add(record cloudflareRecord) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.nextId++
+	record.Id = fmt.Sprint(c.nextId)
+	c.records[record.Id] = record
+}
+
+func (c *cloudflareApi) values(name string) []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	values := make([]string, 0)
+	for _, v := range c.records {
+		if v.Name == name {
+			values = append(values, v.Content)
+		}
+	}
+
+	return values
+}
+
+func (c *cloudflareApi) takeCalls() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	calls := c.calls
+	c.calls = nil
+
+	return calls
+}
+
+func writeJson(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestCloudflareProvider(t *testing.T) {
+	api := &cloudflareApi{records: make(map[string]cloudflareRecord)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	provider := dnsprovider.NewCloudflareProvider(server.URL, apiToken, "", 120)
+	ctx := context.Background()
+	apex := port.DnsRecord{Name: "gw.example.com", Type: "A", Value: "1.2.3.4"}
+
+	// record is created if it does not exist, zone is discovered
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.Equal(t, []string{http.MethodPost}, api.takeCalls())
+	require.Equal(t, []string{"1.2.3.4"}, api.values("gw.example.com"))
+
+	// existing record is updated, not duplicated
+	apex.Value = "5.6.7.8"
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.Equal(t, []string{http.MethodPut}, api.takeCalls())
+	require.Equal(t, []string{"5.6.7.8"}, api.values("gw.example.com"))
+
+	// records besides updated one are removed, record set holds gateway ip
+	api.add(cloudflareRecord{Type: "A", Name: "gw.example.com", Content: "9.9.9.9"})
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.Equal(t, []string{http.MethodPut, http.MethodDelete}, api.takeCalls())
+	require.Equal(t, []string{"5.6.7.8"}, api.values("gw.example.com"))
+
+	require.NoError(t, provider.DeleteRecord(ctx, apex))
+	require.Equal(t, []string{http.MethodDelete}, api.takeCalls())
+	require.Empty(t, api.values("gw.example.com"))
+
+	// deleting missing record is not an error
+	require.NoError(t, provider.DeleteRecord(ctx, apex))
+	require.Empty(t, api.takeCalls())
+}
+
+func TestCloudflareProviderErrors(t *testing.T) {
+	api := &cloudflareApi{records: make(map[string]cloudflareRecord)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	ctx := context.Background()
+	record := port.DnsRecord{Name: "gw.example.com", Type: "A", Value: "1.2.3.4"}
+
+	unauthorized := dnsprovider.NewCloudflareProvider(server.URL, "invalid", "", 120)
+	err := unauthorized.UpsertRecord(ctx, record)
+	require.ErrorContains(t, err, "403")
+	require.ErrorContains(t, err, "Invalid access token")
+	require.Error(t, unauthorized.DeleteRecord(ctx, record))
+
+	provider := dnsprovider.NewCloudflareProvider(server.URL, apiToken, "", 120)
+	err = provider.UpsertRecord(ctx, port.DnsRecord{Name: "gw.other.com", Type: "A", Value: "1.2.3.4"})
+	require.ErrorIs(t, err, dnsprovider.ErrZoneNotFound)
+
+	// configured zone is not discovered
+	misconfigured := dnsprovider.NewCloudflareProvider(server.URL, apiToken, "other.com", 120)
+	require.ErrorIs(t, misconfigured.UpsertRecord(ctx, record), dnsprovider.ErrZoneNotFound)
+	require.Empty(t, api.takeCalls())
+}
@@ -0,0 +1,212 @@
+package dnsprovidertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"prem-gateway/dns/internal/core/port"
+	dnsprovider "prem-gateway/dns/internal/infrastructure/dns-provider"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type digitalOceanRecord struct {
+	Id   int    `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+	Ttl  int    `json:"ttl"`
+}
+
+// digitalOceanApi is fake DigitalOcean domains API hosting example.com,
+// record names are relative to domain like in the real API
+type digitalOceanApi struct {
+	mtx     sync.Mutex
+	nextId  int
+	records map[int]digitalOceanRecord
+	calls   []string
+}
+
+func (d *digitalOceanApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+apiToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"id":"unauthorized","message":"Unable to authenticate you"}`)
+		return
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "domains" || parts[1] != "example.com" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"id":"not_found","message":"The resource you were accessing could not be found."}`)
+		return
+	}
+	if len(parts) == 2 {
+		writeJson(w, map[string]interface{}{"domain": map[string]string{"name": parts[1]}})
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		d.calls = append(d.calls, r.Method)
+	}
+	var id int
+	if len(parts) == 4 {
+		id, _ = strconv.Atoi(parts[3])
+		if _, ok := d.records[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3:
+		result := make([]digitalOceanRecord, 0)
+		for _, v := range d.records {
+			if v.Type == r.URL.Query().Get("type") &&
+				fqdn(v.Name) == r.URL.Query().Get("name") {
+				result = append(result, v)
+			}
+		}
+		writeJson(w, map[string]interface{}{"domain_records": result})
+	case r.Method == http.MethodPost && len(parts) == 3:
+		var record digitalOceanRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		d.nextId++
+		record.Id = d.nextId
+		d.records[record.Id] = record
+		w.WriteHeader(http.StatusCreated)
+		writeJson(w, map[string]interface{}{"domain_record": record})
+	case r.Method == http.MethodPut && len(parts) == 4:
+		var record digitalOceanRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		record.Id = id
+		d.records[id] = record
+		writeJson(w, map[string]interface{}{"domain_record": record})
+	case r.Method == http.MethodDelete && len(parts) == 4:
+		delete(d.records, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func fqdn(name string) string {
+	if name == "@" {
+		return "example.com"
+	}
+
+	return name + ".example.com"
+}
+
+func (d *digitalOceanApi) add(record digitalOceanRecord) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.nextId++
+	record.Id = d.nextId
+	d.records[record.Id] = record
+}
+
+// values returns data of records with relative name
+func (d *digitalOceanApi) values(name string) []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	values := make([]string, 0)
+	for _, v := range d.records {
+		if v.Name == name {
+			values = append(values, v.Data)
+		}
+	}
+
+	return values
+}
+
+func (d *digitalOceanApi) takeCalls() []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	calls := d.calls
+	d.calls = nil
+
+	return calls
+}
+
+func TestDigitalOceanProvider(t *testing.T) {
+	api := &digitalOceanApi{records: make(map[int]digitalOceanRecord)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	provider := dnsprovider.NewDigitalOceanProvider(server.URL, apiToken, "", 120)
+	ctx := context.Background()
+	wildcard := port.DnsRecord{Name: "*.gw.example.com", Type: "A", Value: "1.2.3.4"}
+	apex := port.DnsRecord{Name: "example.com", Type: "A", Value: "1.2.3.4"}
+
+	// records are created with name relative to discovered domain
+	require.NoError(t, provider.UpsertRecord(ctx, wildcard))
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.Equal(t, []string{http.MethodPost, http.MethodPost}, api.takeCalls())
+	require.Equal(t, []string{"1.2.3.4"}, api.values("*.gw"))
+	require.Equal(t, []string{"1.2.3.4"}, api.values("@"))
+
+	// existing record is updated, not duplicated
+	wildcard.Value = "5.6.7.8"
+	require.NoError(t, provider.UpsertRecord(ctx, wildcard))
+	require.Equal(t, []string{http.MethodPut}, api.takeCalls())
+	require.Equal(t, []string{"5.6.7.8"}, api.values("*.gw"))
+
+	// records besides updated one are removed
+	api.add(digitalOceanRecord{Type: "A", Name: "*.gw", Data: "9.9.9.9"})
+	require.NoError(t, provider.UpsertRecord(ctx, wildcard))
+	require.Equal(t, []string{http.MethodPut, http.MethodDelete}, api.takeCalls())
+	require.Equal(t, []string{"5.6.7.8"}, api.values("*.gw"))
+
+	require.NoError(t, provider.DeleteRecord(ctx, wildcard))
+	require.Equal(t, []string{http.MethodDelete}, api.takeCalls())
+	require.Empty(t, api.values("*.gw"))
+	require.Equal(t, []string{"1.2.3.4"}, api.values("@"))
+
+	// deleting missing record is not an error
+	require.NoError(t, provider.DeleteRecord(ctx, wildcard))
+	require.Empty(t, api.takeCalls())
+}
+
+func TestDigitalOceanProviderErrors(t *testing.T) {
+	api := &digitalOceanApi{records: make(map[int]digitalOceanRecord)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	ctx := context.Background()
+	record := port.DnsRecord{Name: "gw.example.com", Type: "A", Value: "1.2.3.4"}
+
+	// zone lookup fails on other errors than not found
+	unauthorized := dnsprovider.NewDigitalOceanProvider(server.URL, "invalid", "", 120)
+	err := unauthorized.UpsertRecord(ctx, record)
+	require.ErrorContains(t, err, "401")
+	require.ErrorContains(t, err, "Unable to authenticate you")
+	require.NotErrorIs(t, err, dnsprovider.ErrZoneNotFound)
+
+	unauthorized = dnsprovider.NewDigitalOceanProvider(server.URL, "invalid", "example.com", 120)
+	require.ErrorContains(t, unauthorized.DeleteRecord(ctx, record), "401")
+
+	provider := dnsprovider.NewDigitalOceanProvider(server.URL, apiToken, "", 120)
+	err = provider.UpsertRecord(ctx, port.DnsRecord{Name: "gw.other.com", Type: "A", Value: "1.2.3.4"})
+	require.ErrorIs(t, err, dnsprovider.ErrZoneNotFound)
+
+	// configured domain the account does not have
+	misconfigured := dnsprovider.NewDigitalOceanProvider(server.URL, apiToken, "other.com", 120)
+	require.ErrorContains(t, misconfigured.UpsertRecord(ctx, record), "404")
+	require.Empty(t, api.takeCalls())
+}
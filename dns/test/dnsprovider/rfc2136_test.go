@@ -0,0 +1,130 @@
+package dnsprovidertest
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"net"
+	"prem-gateway/dns/internal/core/port"
+	dnsprovider "prem-gateway/dns/internal/infrastructure/dns-provider"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	tsigKey    = "dnsd."
+	tsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+)
+
+// updateServer is fake authoritative server for example.com which applies
+// dynamic updates to in memory record set
+type updateServer struct {
+	mtx     sync.Mutex
+	records map[string]string
+}
+
+func (u *updateServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+
+	if r.Opcode == dns.OpcodeUpdate {
+		if r.IsTsig() == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeNotAuth
+		} else {
+			u.mtx.Lock()
+			for _, rr := range r.Ns {
+				switch {
+				case rr.Header().Class == dns.ClassANY:
+					delete(u.records, rr.Header().Name)
+				case rr.Header().Class == dns.ClassINET:
+					u.records[rr.Header().Name] = rr.(*dns.A).A.String()
+				}
+			}
+			u.mtx.Unlock()
+		}
+
+		resp.SetTsig(tsigKey, dns.HmacSHA256, 300, time.Now().Unix())
+		_ = w.WriteMsg(resp)
+		return
+	}
+
+	resp.Ns = []dns.RR{&dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   "example.com.",
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    300,
+		},
+		Ns:   "ns.example.com.",
+		Mbox: "admin.example.com.",
+	}}
+	_ = w.WriteMsg(resp)
+}
+
+func TestRfc2136Provider(t *testing.T) {
+	handler := &updateServer{
+		records: make(map[string]string),
+	}
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := tcpListener.Addr().String()
+	udpConn, err := net.ListenPacket("udp", addr)
+	require.NoError(t, err)
+
+	secrets := map[string]string{tsigKey: tsigSecret}
+	acceptUpdates := func(dh dns.Header) dns.MsgAcceptAction {
+		return dns.MsgAccept
+	}
+	tcpServer := &dns.Server{
+		Listener:      tcpListener,
+		Handler:       handler,
+		TsigSecret:    secrets,
+		MsgAcceptFunc: acceptUpdates,
+	}
+	udpServer := &dns.Server{
+		PacketConn:    udpConn,
+		Handler:       handler,
+		TsigSecret:    secrets,
+		MsgAcceptFunc: acceptUpdates,
+	}
+	go func() { _ = tcpServer.ActivateAndServe() }()
+	go func() { _ = udpServer.ActivateAndServe() }()
+	defer func() {
+		_ = tcpServer.Shutdown()
+		_ = udpServer.Shutdown()
+	}()
+
+	provider, err := dnsprovider.FromConfig(dnsprovider.Config{
+		Provider:          dnsprovider.Rfc2136Provider,
+		Rfc2136Server:     addr,
+		Rfc2136TsigKey:    tsigKey,
+		Rfc2136TsigSecret: tsigSecret,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	apex := port.DnsRecord{Name: "gw.example.com", Type: "A", Value: "1.2.3.4"}
+	wildcard := port.DnsRecord{Name: "*.gw.example.com", Type: "A", Value: "1.2.3.4"}
+
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.NoError(t, provider.UpsertRecord(ctx, wildcard))
+	require.Equal(t, "1.2.3.4", handler.records["gw.example.com."])
+	require.Equal(t, "1.2.3.4", handler.records["*.gw.example.com."])
+
+	apex.Value = "5.6.7.8"
+	require.NoError(t, provider.UpsertRecord(ctx, apex))
+	require.Equal(t, "5.6.7.8", handler.records["gw.example.com."])
+
+	require.NoError(t, provider.DeleteRecord(ctx, apex))
+	require.NoError(t, provider.DeleteRecord(ctx, wildcard))
+	require.Empty(t, handler.records)
+
+	unsigned, err := dnsprovider.FromConfig(dnsprovider.Config{
+		Provider:      dnsprovider.Rfc2136Provider,
+		Rfc2136Server: addr,
+	})
+	require.NoError(t, err)
+	require.Error(t, unsigned.UpsertRecord(ctx, apex))
+}
@@ -0,0 +1,91 @@
+package dnsresolvertest
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"net"
+	dnsresolver "prem-gateway/dns/internal/infrastructure/dns-resolver"
+	"testing"
+	"time"
+)
+
+// handler plays recursive resolver, bogus.example.com fails DNSSEC
+// validation so it is answered only with checking disabled
+func handler(w dns.ResponseWriter, r *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	q := r.Question[0]
+
+	switch q.Name {
+	case "example.com.":
+		msg.AuthenticatedData = true
+		switch q.Qtype {
+		case dns.TypeA:
+			rr, _ := dns.NewRR("example.com. 300 IN CNAME www.example.com.")
+			msg.Answer = append(msg.Answer, rr)
+			rr, _ = dns.NewRR("www.example.com. 600 IN A 1.2.3.4")
+			msg.Answer = append(msg.Answer, rr)
+		case dns.TypeCAA:
+			rr, _ := dns.NewRR(`example.com. 300 IN CAA 0 issue "letsencrypt.org"`)
+			msg.Answer = append(msg.Answer, rr)
+		}
+	case "bogus.example.com.":
+		if !r.CheckingDisabled {
+			msg.Rcode = dns.RcodeServerFailure
+			break
+		}
+		rr, _ := dns.NewRR("bogus.example.com. 300 IN A 5.6.7.8")
+		msg.Answer = append(msg.Answer, rr)
+	case "broken.example.com.":
+		msg.Rcode = dns.RcodeServerFailure
+	default:
+		msg.Rcode = dns.RcodeNameError
+	}
+
+	_ = w.WriteMsg(msg)
+}
+
+func TestResolver(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(handler)}
+	go func() { _ = srv.ActivateAndServe() }()
+	defer func() { _ = srv.Shutdown() }()
+
+	ctx := context.Background()
+	resolver := dnsresolver.NewResolver(
+		[]string{conn.LocalAddr().String()}, time.Second,
+	)
+
+	// only records of requested type are returned
+	result, err := resolver.Lookup(ctx, "example.com", "A")
+	require.NoError(t, err)
+	require.True(t, result.Authenticated)
+	require.False(t, result.DnssecFailed)
+	require.Len(t, result.Records, 1)
+	require.Equal(t, "1.2.3.4", result.Records[0].Value)
+	require.Equal(t, uint32(600), result.Records[0].Ttl)
+
+	result, err = resolver.Lookup(ctx, "example.com", "CAA")
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+	require.Equal(t, "issue", result.Records[0].Tag)
+	require.Equal(t, "letsencrypt.org", result.Records[0].Value)
+
+	result, err = resolver.Lookup(ctx, "missing.example.com", "A")
+	require.NoError(t, err)
+	require.Len(t, result.Records, 0)
+
+	result, err = resolver.Lookup(ctx, "bogus.example.com", "A")
+	require.NoError(t, err)
+	require.True(t, result.DnssecFailed)
+	require.False(t, result.Authenticated)
+	require.Equal(t, "5.6.7.8", result.Records[0].Value)
+
+	_, err = resolver.Lookup(ctx, "broken.example.com", "A")
+	require.ErrorIs(t, err, dnsresolver.ErrServerFailure)
+
+	_, err = resolver.Lookup(ctx, "example.com", "BOGUS")
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package dynamicdnstest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	"testing"
+	"time"
+)
+
+func record(name, ip string) port.DnsRecord {
+	return port.DnsRecord{Name: name, Type: "A", Value: ip}
+}
+
+func TestCheckIp(t *testing.T) {
+	ctx := context.Background()
+	repositorySvc := inmemory.NewDBService()
+	require.NoError(t, repositorySvc.DnsRepository().Create(ctx, domain.DnsInfo{
+		Domain: "example.com",
+		Ip:     "1.1.1.1",
+	}))
+
+	ipSvc := port.NewMockIpService(t)
+	provider := port.NewMockDnsProvider(t)
+	provider.On("Name").Return("mock").Maybe()
+	auditSvc := application.NewAuditService(repositorySvc)
+	svc, err := application.NewDynamicDnsService(
+		repositorySvc, ipSvc, provider, auditSvc, time.Minute,
+	)
+	require.NoError(t, err)
+
+	// unchanged ip is not pushed
+	ipSvc.On("GetHostIp", mock.Anything).
+		Return(port.HostIp{Ip: "1.1.1.1", Source: "mock"}, nil).Once()
+	require.NoError(t, svc.CheckIp(ctx))
+
+	// provider failure keeps stored ip
+	ipSvc.On("GetHostIp", mock.Anything).
+		Return(port.HostIp{Ip: "2.2.2.2", Source: "mock"}, nil)
+	provider.On("UpsertRecord", mock.Anything, record("example.com", "2.2.2.2")).
+		Return(errors.New("provider down")).Once()
+	require.ErrorContains(t, svc.CheckIp(ctx), "provider down")
+
+	dnsInfo, err := repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1.1.1.1", dnsInfo.Ip)
+
+	history, err := svc.GetIpHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "2.2.2.2", history[0].NewIp)
+	require.Contains(t, history[0].ProviderError, "provider down")
+
+	// next check retries the push and stores ip once it succeeds
+	provider.On("UpsertRecord", mock.Anything, record("example.com", "2.2.2.2")).
+		Return(nil).Once()
+	provider.On("UpsertRecord", mock.Anything, record("*.example.com", "2.2.2.2")).
+		Return(nil).Once()
+	require.NoError(t, svc.CheckIp(ctx))
+
+	dnsInfo, err = repositorySvc.DnsRepository().GetExistingDomain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2.2.2.2", dnsInfo.Ip)
+
+	history, err = svc.GetIpHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	providerErrors := []string{history[0].ProviderError, history[1].ProviderError}
+	require.Contains(t, providerErrors, "")
+
+	events, err := auditSvc.GetEvents(ctx, application.AuditFilter{
+		Actions: []string{application.AuditActionDomainIpChange},
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	outcomes := []string{events[0].Outcome, events[1].Outcome}
+	require.ElementsMatch(t, []string{domain.AuditOutcomeFailure, domain.AuditOutcomeSuccess}, outcomes)
+
+	// pushed ip is not pushed again
+	require.NoError(t, svc.CheckIp(ctx))
+	provider.AssertExpectations(t)
+}
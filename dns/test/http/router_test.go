@@ -2,29 +2,33 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
 	"prem-gateway/dns/internal/core/port"
-	pgdb "prem-gateway/dns/internal/infrastructure/storage/pg"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
 	dnsdhttp "prem-gateway/dns/internal/interface/http"
 	httphandler "prem-gateway/dns/internal/interface/http/handler"
+	"prem-gateway/pkg/cors"
+	"prem-gateway/pkg/signing"
 	"testing"
+	"time"
+)
+
+const (
+	controllerdSecret = "3f1c0a9e7b5d4c2a1908f7e6d5c4b3a2"
+	authdSecret       = "9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d"
 )
 
 func TestRouter(t *testing.T) {
-	svc, err := pgdb.NewDBService(pgdb.DbConfig{
-		DbUser:     "root",
-		DbPassword: "secret",
-		DbHost:     "127.0.0.1",
-		DbPort:     5432,
-		DbName:     "dnsd-db-test",
-		MigrationSourceURL: "file://../.." +
-			"/internal/infrastructure/storage/pg/migration",
-	})
-	require.NoError(t, err)
+	svc := inmemory.NewDBService()
 
 	serverAddress := ":8080"
 	ipSvcMock := new(port.MockIpService)
@@ -34,13 +38,22 @@ func TestRouter(t *testing.T) {
 	ipSvcOpt := dnsdhttp.WithIpService(ipSvcMock)
 	controllerdWrapperMock := new(port.MockControllerdWrapper)
 	controllerdWrapperMock.
-		On("DomainProvisioned", mock.Anything, "dusansekulic.me", "dusan.sekulic.mne@gmail.com").
+		On("DomainProvisioned", mock.Anything, "dusan.sekulic.mne@gmail.com", "dusansekulic.me", "").
+		Return(nil)
+	controllerdWrapperMock.
+		On("DomainDeleted", mock.Anything, "dusansekulic.me").
 		Return(nil)
 
 	controllerdWrapperOpt := dnsdhttp.WithControllerdWrapper(controllerdWrapperMock)
+	reachabilityCheckerMock := new(port.MockReachabilityChecker)
+	reachabilityCheckerMock.
+		On("CheckReachability", mock.Anything, "dusansekulic.me", mock.Anything, mock.Anything).
+		Return(nil)
+	reachabilityCheckerOpt := dnsdhttp.WithReachabilityChecker(reachabilityCheckerMock)
 	opts := []dnsdhttp.ServerOption{
 		ipSvcOpt,
 		controllerdWrapperOpt,
+		reachabilityCheckerOpt,
 	}
 
 	dnsd, err := dnsdhttp.NewServer(
@@ -105,3 +118,664 @@ func TestRouter(t *testing.T) {
 	ginRouter.ServeHTTP(w, req)
 	require.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestRouterErrors(t *testing.T) {
+	svc := inmemory.NewDBService()
+	require.NoError(t, svc.DnsRepository().Create(context.Background(), domain.DnsInfo{
+		Domain: "existing.me",
+		Ip:     "100.27.28.72",
+	}))
+
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "wrong-ip.me").
+		Return(false, domain.ErrDnsRecordNotFound)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "resolver-down.me").
+		Return(false, domain.ErrDnsLookupFailed.Wrap(errors.New("timeout")))
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithControllerdWrapper(controllerdWrapperMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           "not an object",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "domain exists",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           httphandler.DnsInfo{Domain: "existing.me", Ip: "100.27.28.72"},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   domain.ErrAlreadyExists.Code,
+		},
+		{
+			name:           "record points elsewhere",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           httphandler.DnsInfo{Domain: "wrong-ip.me", Ip: "100.27.28.72"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   domain.ErrDnsRecordNotFound.Code,
+		},
+		{
+			name:           "resolver failed",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           httphandler.DnsInfo{Domain: "resolver-down.me", Ip: "100.27.28.72"},
+			expectedStatus: http.StatusBadGateway,
+			expectedCode:   domain.ErrDnsLookupFailed.Code,
+		},
+		{
+			name:           "invalid domain",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           httphandler.DnsInfo{Domain: "foo bar", Ip: "100.27.28.72"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_argument",
+		},
+		{
+			name:           "existing domain in upper case",
+			method:         http.MethodPost,
+			path:           "/dns",
+			body:           httphandler.DnsInfo{Domain: "EXISTING.me.", Ip: "100.27.28.72"},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   domain.ErrAlreadyExists.Code,
+		},
+		{
+			name:           "bare tld",
+			method:         http.MethodGet,
+			path:           "/dns/me",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_argument",
+		},
+		{
+			name:           "preflight of public suffix",
+			method:         http.MethodGet,
+			path:           "/dns/preflight/co.uk",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_argument",
+		},
+		{
+			name:           "unknown domain",
+			method:         http.MethodGet,
+			path:           "/dns/unknown.me",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   domain.ErrEntityNotFound.Code,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if tt.body != nil {
+				body, err = json.Marshal(tt.body)
+				require.NoError(t, err)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			ginRouter.ServeHTTP(w, req)
+			require.Equal(t, tt.expectedStatus, w.Code)
+
+			var errResp httphandler.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			require.Equal(t, tt.expectedCode, errResp.Code)
+			require.NotEmpty(t, errResp.Error)
+		})
+	}
+
+	// every invalid field is reported
+	body, err := json.Marshal(httphandler.DnsInfo{
+		Domain: "example.com`) || Host(`evil.com",
+		Ip:     "not-an-ip",
+		Email:  "admin",
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/dns", bytes.NewReader(body))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errResp httphandler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	require.Len(t, errResp.Fields, 3)
+	require.Equal(t, "domain", errResp.Fields[0].Field)
+	require.Equal(t, "invalid_domain", errResp.Fields[0].Code)
+	require.Equal(t, "email", errResp.Fields[1].Field)
+	require.Equal(t, "ip", errResp.Fields[2].Field)
+}
+
+func TestRouterOutbox(t *testing.T) {
+	svc := inmemory.NewDBService()
+
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "controllerd-down.me").
+		Return(true, nil)
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "", "controllerd-down.me", "").
+		Return(domain.ErrControllerdUnavailable.Wrap(errors.New("connection refused")))
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithControllerdWrapper(controllerdWrapperMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	// domain is stored even though controllerd is down
+	body, err := json.Marshal(httphandler.DnsInfo{
+		Domain: "controllerd-down.me",
+		Ip:     "100.27.28.72",
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/dns", bytes.NewReader(body))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/dns/notifications", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var notifications []httphandler.Notification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &notifications))
+	require.Len(t, notifications, 1)
+	require.Equal(t, "domain_provisioned", notifications[0].Type)
+	require.Equal(t, "controllerd-down.me", notifications[0].Domain)
+	require.Equal(t, 1, notifications[0].Attempts)
+	require.Contains(t, notifications[0].LastError, "connection refused")
+	require.True(t, notifications[0].NextAttemptAt.After(time.Now()))
+
+	// deleted domain is not provisioned later by its pending notification,
+	// controllerd does not deprovision domains so deletion is not announced
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/dns/controllerd-down.me", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/dns/notifications", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &notifications))
+	require.Empty(t, notifications)
+	controllerdWrapperMock.AssertNotCalled(t, "DomainDeleted", mock.Anything, mock.Anything)
+}
+
+func TestRouterAcmeCa(t *testing.T) {
+	svc := inmemory.NewDBService()
+	require.NoError(t, svc.DnsRepository().Create(context.Background(), domain.DnsInfo{
+		Domain: "acme.me",
+		Ip:     "100.27.28.72",
+		Email:  "admin@acme.me",
+	}))
+
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "admin@acme.me", "acme.me", "production").
+		Return(nil)
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithIpService(new(port.MockIpService)),
+		dnsdhttp.WithControllerdWrapper(controllerdWrapperMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	putAcmeCa := func(domainName, acmeCa string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(httphandler.AcmeCaRequest{AcmeCa: acmeCa})
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(
+			http.MethodPut, "/dns/"+domainName+"/acme-ca", bytes.NewReader(body),
+		)
+		ginRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	w := putAcmeCa("acme.me", "Production")
+	require.Equal(t, http.StatusOK, w.Code)
+	var dnsInfo httphandler.DnsInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dnsInfo))
+	require.Equal(t, "production", dnsInfo.AcmeCa)
+	require.Equal(t, "admin@acme.me", dnsInfo.Email)
+	controllerdWrapperMock.AssertNumberOfCalls(t, "DomainProvisioned", 1)
+
+	// unchanged CA does not restart services again
+	w = putAcmeCa("acme.me", "production")
+	require.Equal(t, http.StatusOK, w.Code)
+	controllerdWrapperMock.AssertNumberOfCalls(t, "DomainProvisioned", 1)
+
+	stored, err := svc.DnsRepository().Get(context.Background(), "acme.me")
+	require.NoError(t, err)
+	require.Equal(t, "production", stored.AcmeCa)
+
+	w = putAcmeCa("acme.me", "http://acme.example.com/directory")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var errResp httphandler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	require.Equal(t, "acme_ca", errResp.Fields[0].Field)
+
+	w = putAcmeCa("missing.me", "staging")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	events, err := svc.AuditEventRepository().GetAll(context.Background(), domain.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "domain.acme_ca", events[0].Action)
+}
+
+func TestRouterGatewayIp(t *testing.T) {
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("GetHostIp", mock.Anything).
+		Return(port.HostIp{Ip: "100.27.28.72", Source: "static"}, nil)
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", inmemory.NewDBService(), "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	// response of /dns/ip stays plain JSON string for existing callers
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/dns/ip", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `"100.27.28.72"`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/dns/ip/details", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"ip":"100.27.28.72","source":"static"}`, w.Body.String())
+}
+
+func TestRouterAudit(t *testing.T) {
+	svc := inmemory.NewDBService()
+
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "audit.me").
+		Return(true, nil)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "not-pointed.me").
+		Return(false, nil)
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "", "audit.me", "").
+		Return(nil)
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithControllerdWrapper(controllerdWrapperMock),
+		dnsdhttp.WithReachabilityChecker(nil),
+		dnsdhttp.WithControllerdSecret(controllerdSecret),
+		dnsdhttp.WithAuthdSecret(authdSecret),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	authd, err := signing.NewSigner(authdSecret)
+	require.NoError(t, err)
+	var captured string
+	for _, v := range []string{"audit.me", "not-pointed.me"} {
+		body, err := json.Marshal(httphandler.DnsInfo{
+			Domain: v,
+			Ip:     "100.27.28.72",
+		})
+		require.NoError(t, err)
+		captured, err = authd.SignValue(http.MethodPost, "/dns", "apikey:1234")
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/dns", bytes.NewReader(body))
+		req.Header.Set(httphandler.ActorHeader, "apikey:1234")
+		req.Header.Set(httphandler.ActorSignatureHeader, captured)
+		ginRouter.ServeHTTP(w, req)
+	}
+
+	// actor signature captured from other request is not trusted
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/dns/not-pointed.me", nil)
+	req.Header.Set(httphandler.ActorHeader, "apikey:1234")
+	req.Header.Set(httphandler.ActorSignatureHeader, captured)
+	ginRouter.ServeHTTP(w, req)
+
+	// controllerd reports its own changes
+	body, err := json.Marshal(httphandler.AuditEventRequest{
+		Action:   "services.restart",
+		Resource: "audit.me",
+		Outcome:  "success",
+	})
+	require.NoError(t, err)
+	controllerd, err := signing.NewSigner(controllerdSecret)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/audit", bytes.NewReader(body))
+	req.Header.Set(httphandler.ActorHeader, "controllerd")
+	require.NoError(t, controllerd.Sign(req, body))
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(bytes.NewReader(body))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	ginRouter.ServeHTTP(w, replay)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// unsigned request can not forge actor
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/audit", bytes.NewReader(body))
+	req.Header.Set(httphandler.ActorHeader, "controllerd")
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	other, err := signing.NewSigner(authdSecret)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/audit", bytes.NewReader(body))
+	require.NoError(t, other.Sign(req, body))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	invalid := []byte(`{"action":"x","outcome":"maybe"}`)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/audit", bytes.NewReader(invalid))
+	require.NoError(t, controllerd.Sign(req, invalid))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// audit log is read only through authd or by signed daemon
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/audit", nil)
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/audit", nil)
+	require.NoError(t, controllerd.Sign(req, nil))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	readAudit := func(uri string) *httptest.ResponseRecorder {
+		signature, err := authd.SignValue(http.MethodGet, uri, "apikey:1234")
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, uri, nil)
+		req.Header.Set(httphandler.ActorHeader, "apikey:1234")
+		req.Header.Set(httphandler.ActorSignatureHeader, signature)
+		ginRouter.ServeHTTP(w, req)
+		return w
+	}
+	w = readAudit("/audit")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var events []httphandler.AuditEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	require.Len(t, events, 4)
+	require.Equal(t, "domain.create", events[0].Action)
+	require.Equal(t, "apikey:1234", events[0].Actor)
+	require.Equal(t, "success", events[0].Outcome)
+	require.JSONEq(t, "null", string(events[0].Before))
+	require.Contains(t, string(events[0].After), `"domain":"audit.me"`)
+	require.Equal(t, "not-pointed.me", events[1].Resource)
+	require.Equal(t, "failure", events[1].Outcome)
+	require.NotEmpty(t, events[1].Error)
+	require.Equal(t, "domain.delete", events[2].Action)
+	require.Equal(t, "anonymous", events[2].Actor)
+	require.Equal(t, "controllerd", events[3].Actor)
+
+	w = readAudit("/audit?action=services.restart,domain.delete&format=jsonl")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var event httphandler.AuditEvent
+	require.NoError(t, json.Unmarshal(lines[0], &event))
+	require.Equal(t, "domain.delete", event.Action)
+	require.NoError(t, json.Unmarshal(lines[1], &event))
+	require.Equal(t, "services.restart", event.Action)
+
+	w = readAudit("/audit?from=yesterday")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = readAudit("/audit?to=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, "[]", w.Body.String())
+}
+
+func TestRouterAuditWithoutSecret(t *testing.T) {
+	dnsd, err := dnsdhttp.NewServer(":8080", inmemory.NewDBService(), "")
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	body := []byte(`{"action":"services.restart","outcome":"success"}`)
+	controllerd, err := signing.NewSigner(controllerdSecret)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/audit", bytes.NewReader(body))
+	require.NoError(t, controllerd.Sign(req, body))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// without secrets no caller is authenticated to read audit log
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/audit", nil)
+	require.NoError(t, controllerd.Sign(req, nil))
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	_, err = dnsdhttp.NewServer(
+		":8080", inmemory.NewDBService(), "", dnsdhttp.WithControllerdSecret("short"),
+	)
+	require.Error(t, err)
+	_, err = dnsdhttp.NewServer(
+		":8080", inmemory.NewDBService(), "", dnsdhttp.WithAuthdSecret("short"),
+	)
+	require.Error(t, err)
+}
+
+func TestRouterCors(t *testing.T) {
+	svc := inmemory.NewDBService()
+	require.NoError(t, svc.DnsRepository().Create(
+		context.Background(), domain.DnsInfo{Domain: "gateway.me", Ip: "100.27.28.72"},
+	))
+
+	dnsd, err := dnsdhttp.NewServer(":8080", svc, "")
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "http://localhost:1420", allowed: true},
+		{origin: "https://gateway.me", allowed: true},
+		{origin: "http://premapp.gateway.me:8085", allowed: true},
+		{origin: "https://evil.org", allowed: false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodOptions, "/dns/gateway.me", nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		ginRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNoContent, w.Code, tt.origin)
+
+		allowOrigin := ""
+		if tt.allowed {
+			allowOrigin = tt.origin
+		}
+		require.Equal(t, allowOrigin, w.Header().Get("Access-Control-Allow-Origin"), tt.origin)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/dns/gateway.me", nil)
+	req.Header.Set("Origin", "https://gateway.me")
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://gateway.me", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// provisioned domain is not allowed if disabled
+	dnsd, err = dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithCors(cors.Config{AllowedOrigins: []string{"https://*.other.me"}}, false),
+	)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/dns/gateway.me", nil)
+	req.Header.Set("Origin", "https://gateway.me")
+	dnsd.Router().ServeHTTP(w, req)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	_, err = dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithCors(cors.Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false),
+	)
+	require.ErrorIs(t, err, cors.ErrWildcardWithCredentials)
+}
+
+func TestRouterAcme(t *testing.T) {
+	svc := inmemory.NewDBService()
+	require.NoError(t, svc.DnsRepository().Create(
+		context.Background(), domain.DnsInfo{Domain: "gateway.me", Ip: "100.27.28.72"},
+	))
+	zoneSvc, err := application.NewZoneService(svc, "", "")
+	require.NoError(t, err)
+	secret := "0123456789abcdef0123456789abcdef"
+
+	present := func(
+		router http.Handler, username, password string,
+	) *httptest.ResponseRecorder {
+		body, err := json.Marshal(httphandler.TxtRecord{
+			Fqdn:  "_acme-challenge.gateway.me.",
+			Value: "token",
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/acme/present", bytes.NewReader(body))
+		if password != "" {
+			req.SetBasicAuth(username, password)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// endpoints are not served without authoritative dns server or secret
+	dnsd, err := dnsdhttp.NewServer(":8080", svc, "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, present(dnsd.Router(), "", "").Code)
+
+	dnsd, err = dnsdhttp.NewServer(":8080", svc, "", dnsdhttp.WithZoneService(zoneSvc))
+	require.NoError(t, err)
+	require.Equal(
+		t, http.StatusNotFound, present(dnsd.Router(), httphandler.AcmeUsername, secret).Code,
+	)
+
+	_, err = dnsdhttp.NewServer(
+		":8080", svc, "", dnsdhttp.WithZoneService(zoneSvc), dnsdhttp.WithAcmeSecret("short"),
+	)
+	require.Error(t, err)
+
+	dnsd, err = dnsdhttp.NewServer(
+		":8080", svc, "", dnsdhttp.WithZoneService(zoneSvc), dnsdhttp.WithAcmeSecret(secret),
+	)
+	require.NoError(t, err)
+	router := dnsd.Router()
+
+	for _, v := range []struct{ username, password string }{
+		{"", ""},
+		{httphandler.AcmeUsername, "0123456789abcdef0123456789abcdeX"},
+		{"admin", secret},
+	} {
+		w := present(router, v.username, v.password)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	}
+	answer, err := zoneSvc.Resolve(context.Background(), "_acme-challenge.gateway.me")
+	require.NoError(t, err)
+	require.Empty(t, answer.Txt)
+
+	require.Equal(t, http.StatusOK, present(router, httphandler.AcmeUsername, secret).Code)
+	answer, err = zoneSvc.Resolve(context.Background(), "_acme-challenge.gateway.me")
+	require.NoError(t, err)
+	require.Equal(t, []string{"token"}, answer.Txt)
+}
+
+func TestRouterDnsProviderCleanup(t *testing.T) {
+	svc := inmemory.NewDBService()
+
+	ipSvcMock := new(port.MockIpService)
+	ipSvcMock.
+		On("VerifyDnsRecord", mock.Anything, "100.27.28.72", "unreachable.me").
+		Return(true, nil)
+	reachabilityCheckerMock := new(port.MockReachabilityChecker)
+	reachabilityCheckerMock.
+		On("CheckReachability", mock.Anything, "unreachable.me", mock.Anything, mock.Anything).
+		Return(errors.New("connection refused"))
+	providerMock := port.NewMockDnsProvider(t)
+	providerMock.On("Name").Return("mock").Maybe()
+	records := []port.DnsRecord{
+		{Name: "unreachable.me", Type: "A", Value: "100.27.28.72"},
+		{Name: "*.unreachable.me", Type: "A", Value: "100.27.28.72"},
+	}
+	for _, v := range records {
+		providerMock.On("UpsertRecord", mock.Anything, v).Return(nil).Once()
+		providerMock.On("DeleteRecord", mock.Anything, v).Return(nil).Once()
+	}
+
+	dnsd, err := dnsdhttp.NewServer(
+		":8080", svc, "",
+		dnsdhttp.WithIpService(ipSvcMock),
+		dnsdhttp.WithReachabilityChecker(reachabilityCheckerMock),
+		dnsdhttp.WithDnsProvider(providerMock),
+	)
+	require.NoError(t, err)
+	ginRouter := dnsd.Router()
+
+	// records upserted for domain which fails reachability are removed
+	body, err := json.Marshal(httphandler.DnsInfo{
+		Domain: "unreachable.me",
+		Ip:     "100.27.28.72",
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/dns", bytes.NewReader(body))
+	ginRouter.ServeHTTP(w, req)
+	require.NotEqual(t, http.StatusCreated, w.Code)
+
+	providerMock.AssertNumberOfCalls(t, "DeleteRecord", 2)
+	_, err = svc.DnsRepository().Get(context.Background(), "unreachable.me")
+	require.ErrorIs(t, err, domain.ErrEntityNotFound)
+}
@@ -0,0 +1,198 @@
+package ipprovidertest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	httpclients "prem-gateway/dns/internal/infrastructure/http-clients"
+	ipprovider "prem-gateway/dns/internal/infrastructure/ip-provider"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIpServiceFallback(t *testing.T) {
+	invalid := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "<html>not an ip</html>")
+		},
+	))
+	defer invalid.Close()
+
+	private := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "192.168.1.10\n")
+		},
+	))
+	defer private.Close()
+
+	var hits int32
+	valid := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			fmt.Fprint(w, "8.8.8.8\n")
+		},
+	))
+	defer valid.Close()
+
+	ipSvc := httpclients.NewIpService(
+		[]ipprovider.Provider{
+			ipprovider.NewHttpProvider(invalid.URL),
+			ipprovider.NewHttpProvider(private.URL),
+			ipprovider.NewHttpProvider(valid.URL),
+		},
+		time.Second,
+		time.Minute,
+	)
+
+	hostIp, err := ipSvc.GetHostIp(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "8.8.8.8", hostIp.Ip)
+	require.Equal(t, "http:"+valid.Listener.Addr().String(), hostIp.Source)
+
+	// second call is served from cache
+	_, err = ipSvc.GetHostIp(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestIpServiceProviderTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		},
+	))
+	defer slow.Close()
+
+	static, err := ipprovider.NewStaticProvider("10.0.0.1")
+	require.NoError(t, err)
+
+	ipSvc := httpclients.NewIpService(
+		[]ipprovider.Provider{ipprovider.NewHttpProvider(slow.URL), static},
+		100*time.Millisecond,
+		0,
+	)
+
+	hostIp, err := ipSvc.GetHostIp(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", hostIp.Ip)
+	require.Equal(t, ipprovider.StaticProvider, hostIp.Source)
+}
+
+func TestIpServiceAllProvidersFail(t *testing.T) {
+	invalid := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	))
+	defer invalid.Close()
+
+	ipSvc := httpclients.NewIpService(
+		[]ipprovider.Provider{ipprovider.NewHttpProvider(invalid.URL)},
+		time.Second,
+		time.Minute,
+	)
+
+	_, err := ipSvc.GetHostIp(context.Background())
+	require.ErrorIs(t, err, ipprovider.ErrNoIpFound)
+}
+
+// blockingProvider blocks until release is closed or its context is done
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) Name() string {
+	return "blocking"
+}
+
+func (b *blockingProvider) GetIp(ctx context.Context) (net.IP, error) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	select {
+	case <-b.release:
+		return net.ParseIP("1.2.3.4"), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestIpServiceSlowProviderDoesNotBlockCallers(t *testing.T) {
+	provider := &blockingProvider{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	ipSvc := httpclients.NewIpService(
+		[]ipprovider.Provider{provider}, time.Minute, time.Minute,
+	)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := ipSvc.GetHostIp(context.Background())
+		first <- err
+	}()
+	<-provider.started
+
+	// caller which gives up is not stuck behind running discovery
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ipSvc.GetHostIp(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(provider.release)
+	require.NoError(t, <-first)
+
+	// result of finished discovery is cached
+	hostIp, err := ipSvc.GetHostIp(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", hostIp.Ip)
+}
+
+func TestStunProvider(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	go serveStun(conn, net.ParseIP("1.2.3.4").To4())
+
+	provider := ipprovider.NewStunProvider(conn.LocalAddr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ip, err := provider.GetIp(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", ip.String())
+}
+
+// serveStun answers single binding request with XOR-MAPPED-ADDRESS
+func serveStun(conn net.PacketConn, ip net.IP) {
+	req := make([]byte, 1024)
+	n, addr, err := conn.ReadFrom(req)
+	if err != nil || n < 20 {
+		return
+	}
+
+	resp := make([]byte, 32)
+	binary.BigEndian.PutUint16(resp[0:2], 0x0101)
+	binary.BigEndian.PutUint16(resp[2:4], 12)
+	copy(resp[4:20], req[4:20])
+	binary.BigEndian.PutUint16(resp[20:22], 0x0020)
+	binary.BigEndian.PutUint16(resp[22:24], 8)
+	resp[25] = 0x01
+	binary.BigEndian.PutUint16(resp[26:28], 3478^0x2112)
+	for i := 0; i < 4; i++ {
+		resp[28+i] = ip[i] ^ req[4+i]
+	}
+
+	_, _ = conn.WriteTo(resp, addr)
+}
@@ -0,0 +1,142 @@
+package outboxtest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	"testing"
+	"time"
+)
+
+func TestNotificationDispatcher(t *testing.T) {
+	ctx := context.Background()
+	svc := inmemory.NewDBService()
+
+	require.NoError(t, svc.DnsRepository().Create(
+		ctx,
+		domain.DnsInfo{Domain: "example.com"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "example.com",
+			Email:  "test@gmail.com",
+		},
+	))
+	require.NoError(t, svc.DnsRepository().Create(
+		ctx,
+		domain.DnsInfo{Domain: "example.org"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "example.org",
+			Email:  "test@gmail.com",
+		},
+	))
+
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "test@gmail.com", "example.com", "").
+		Return(domain.ErrControllerdUnavailable.Wrap(errors.New("connection refused"))).
+		Once()
+
+	dispatcher, err := application.NewNotificationDispatcher(
+		svc, controllerdWrapperMock, time.Minute,
+	)
+	require.NoError(t, err)
+
+	// first notification fails, second must not overtake it
+	require.ErrorIs(t, dispatcher.Dispatch(ctx), domain.ErrControllerdUnavailable)
+	controllerdWrapperMock.AssertNotCalled(
+		t, "DomainProvisioned", mock.Anything, "test@gmail.com", "example.org", "",
+	)
+
+	pending, err := dispatcher.GetPendingNotifications(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, 1, pending[0].Attempts)
+	require.Contains(t, pending[0].LastError, "connection refused")
+	require.True(t, pending[0].NextAttemptAt.After(time.Now()))
+
+	// failed notification is not retried before backoff elapses
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	controllerdWrapperMock.AssertNumberOfCalls(t, "DomainProvisioned", 1)
+
+	// make it due and let controllerd acknowledge both
+	require.NoError(t, svc.NotificationRepository().MarkFailed(
+		ctx, pending[0].Id, pending[0].LastError, time.Now(),
+	))
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "test@gmail.com", "example.com", "").
+		Return(nil)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "test@gmail.com", "example.org", "").
+		Return(nil)
+
+	require.NoError(t, dispatcher.Dispatch(ctx))
+
+	pending, err = dispatcher.GetPendingNotifications(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 0)
+}
+
+func TestNotificationDispatcherDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	svc := inmemory.NewDBService()
+
+	for _, v := range []domain.Notification{
+		{Type: domain.NotificationDomainProvisioned, Domain: "example.com"},
+		{Type: "domain_renamed", Domain: "example.net"},
+		{Type: domain.NotificationDomainProvisioned, Domain: "example.org"},
+	} {
+		require.NoError(t, svc.DnsRepository().Create(
+			ctx, domain.DnsInfo{Domain: v.Domain}, v,
+		))
+	}
+
+	controllerdWrapperMock := new(port.MockControllerdWrapper)
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "", "example.com", "").
+		Return(domain.ErrControllerdFailed.Wrap(errors.New("400 invalid domain")))
+	controllerdWrapperMock.
+		On("DomainProvisioned", mock.Anything, "", "example.org", "").
+		Return(nil)
+
+	dispatcher, err := application.NewNotificationDispatcher(
+		svc, controllerdWrapperMock, time.Minute,
+	)
+	require.NoError(t, err)
+
+	// failing notification blocks later ones until its last attempt
+	pending, err := dispatcher.GetPendingNotifications(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 9; i++ {
+		require.NoError(t, svc.NotificationRepository().MarkFailed(
+			ctx, pending[0].Id, "400 invalid domain", time.Now(),
+		))
+	}
+	require.NoError(t, dispatcher.Dispatch(ctx))
+
+	// example.com is dead after 10th attempt, notification of unknown type
+	// right away, both stop blocking example.org
+	pending, err = dispatcher.GetPendingNotifications(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, "example.com", pending[0].Domain)
+	require.Equal(t, 10, pending[0].Attempts)
+	require.Contains(t, pending[0].LastError, "invalid domain")
+	require.NotNil(t, pending[0].DeadAt)
+	require.Equal(t, "example.net", pending[1].Domain)
+	require.Equal(t, 1, pending[1].Attempts)
+	require.Contains(t, pending[1].LastError, "unknown notification type")
+	require.NotNil(t, pending[1].DeadAt)
+	controllerdWrapperMock.AssertCalled(
+		t, "DomainProvisioned", mock.Anything, "", "example.org", "",
+	)
+
+	// dead notifications are not retried
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	controllerdWrapperMock.AssertNumberOfCalls(t, "DomainProvisioned", 2)
+}
@@ -0,0 +1,54 @@
+package pgtest
+
+import (
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+func (p *PgDbTestSuite) TestAuditEventRepository() {
+	start := time.Now().Add(-time.Minute)
+
+	err := dbSvc.AuditEventRepository().Add(ctx, domain.AuditEvent{
+		Actor:    "apikey:1234",
+		Action:   "domain.create",
+		Resource: "audit.com",
+		After:    `{"domain":"audit.com"}`,
+		Outcome:  domain.AuditOutcomeSuccess,
+	})
+	p.NoError(err)
+
+	err = dbSvc.AuditEventRepository().Add(ctx, domain.AuditEvent{
+		Actor:    "controllerd",
+		Action:   "services.restart",
+		Resource: "audit.com",
+		Outcome:  domain.AuditOutcomeFailure,
+		Error:    "container not found",
+	})
+	p.NoError(err)
+
+	events, err := dbSvc.AuditEventRepository().GetAll(ctx, domain.AuditFilter{})
+	p.NoError(err)
+	p.Len(events, 2)
+	p.Equal("domain.create", events[0].Action)
+	p.Equal(`{"domain":"audit.com"}`, events[0].After)
+	p.Equal("", events[0].Before)
+	p.Equal("container not found", events[1].Error)
+
+	events, err = dbSvc.AuditEventRepository().GetAll(ctx, domain.AuditFilter{
+		From:    start,
+		Actions: []string{"services.restart"},
+	})
+	p.NoError(err)
+	p.Len(events, 1)
+	p.Equal("controllerd", events[0].Actor)
+
+	events, err = dbSvc.AuditEventRepository().GetAll(ctx, domain.AuditFilter{
+		To: start,
+	})
+	p.NoError(err)
+	p.Len(events, 0)
+
+	// audit log is append-only
+	_, err = DB.ExecContext(ctx, "DELETE FROM audit_event")
+	p.Error(err)
+}
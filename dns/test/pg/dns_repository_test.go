@@ -35,7 +35,31 @@ func (p *PgDbTestSuite) TestDnsRepository() {
 	p.Equal("test@gmail.com", dnsInfo.Email)
 
 	err = dbSvc.DnsRepository().Create(ctx, *dnsInfo)
+	p.ErrorIs(err, domain.ErrAlreadyExists)
+
+	dnsInfo.AcmeCa = domain.AcmeCaProduction
+	err = dbSvc.DnsRepository().Update(ctx, *dnsInfo)
+	p.NoError(err)
+
+	dnsInfo, err = dbSvc.DnsRepository().Get(ctx, "example.com")
 	p.NoError(err)
+	p.Equal(domain.AcmeCaProduction, dnsInfo.AcmeCa)
+
+	// update of missing domain stores no notification
+	err = dbSvc.DnsRepository().Update(
+		ctx,
+		domain.DnsInfo{Domain: "missing.com"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "missing.com",
+		},
+	)
+	p.ErrorIs(err, domain.ErrEntityNotFound)
+	undelivered, err := dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	for _, v := range undelivered {
+		p.NotEqual("missing.com", v.Domain)
+	}
 
 	err = dbSvc.DnsRepository().Delete(ctx, "dummy")
 	p.NoError(err)
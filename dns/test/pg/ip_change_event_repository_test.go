@@ -0,0 +1,61 @@
+package pgtest
+
+import "prem-gateway/dns/internal/core/domain"
+
+func (p *PgDbTestSuite) TestIpChangeEventRepository() {
+	err := dbSvc.DnsRepository().Create(ctx, domain.DnsInfo{
+		Domain:    "example.com",
+		SubDomain: "*.example.com",
+		Ip:        "10.10.10.10",
+		NodeName:  "node1",
+		Email:     "test@gmail.com",
+	})
+	p.NoError(err)
+
+	err = dbSvc.DnsRepository().Update(ctx, domain.DnsInfo{
+		Domain:    "example.com",
+		SubDomain: "*.example.com",
+		Ip:        "20.20.20.20",
+		NodeName:  "node1",
+		Email:     "test@gmail.com",
+	})
+	p.NoError(err)
+
+	dnsInfo, err := dbSvc.DnsRepository().Get(ctx, "example.com")
+	p.NoError(err)
+	p.Equal("20.20.20.20", dnsInfo.Ip)
+
+	events, err := dbSvc.IpChangeEventRepository().GetAll(ctx, "example.com", 10)
+	p.NoError(err)
+	p.Len(events, 0)
+
+	err = dbSvc.IpChangeEventRepository().Add(ctx, domain.IpChangeEvent{
+		Domain: "example.com",
+		OldIp:  "10.10.10.10",
+		NewIp:  "20.20.20.20",
+		Source: "static",
+	})
+	p.NoError(err)
+
+	err = dbSvc.IpChangeEventRepository().Add(ctx, domain.IpChangeEvent{
+		Domain:        "example.com",
+		OldIp:         "20.20.20.20",
+		NewIp:         "30.30.30.30",
+		Source:        "http:ifconfig.io",
+		ProviderError: "zone not found",
+	})
+	p.NoError(err)
+
+	events, err = dbSvc.IpChangeEventRepository().GetAll(ctx, "example.com", 10)
+	p.NoError(err)
+	p.Len(events, 2)
+	p.Equal("30.30.30.30", events[0].NewIp)
+	p.Equal("zone not found", events[0].ProviderError)
+	p.Equal("20.20.20.20", events[1].NewIp)
+	p.Equal("10.10.10.10", events[1].OldIp)
+	p.False(events[1].CreatedAt.IsZero())
+
+	events, err = dbSvc.IpChangeEventRepository().GetAll(ctx, "example.com", 1)
+	p.NoError(err)
+	p.Len(events, 1)
+}
@@ -0,0 +1,85 @@
+package pgtest
+
+import (
+	"prem-gateway/dns/internal/core/domain"
+	"time"
+)
+
+func (p *PgDbTestSuite) TestNotificationRepository() {
+	err := dbSvc.DnsRepository().Create(
+		ctx,
+		domain.DnsInfo{Domain: "outbox.com"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "outbox.com",
+			Email:  "test@gmail.com",
+		},
+	)
+	p.NoError(err)
+
+	// failed insert rolls back notification
+	err = dbSvc.DnsRepository().Create(
+		ctx,
+		domain.DnsInfo{Domain: "outbox.com"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "outbox.com",
+		},
+	)
+	p.ErrorIs(err, domain.ErrAlreadyExists)
+
+	undelivered, err := dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 1)
+	p.Equal(domain.NotificationDomainProvisioned, undelivered[0].Type)
+	p.Equal("test@gmail.com", undelivered[0].Email)
+
+	err = dbSvc.NotificationRepository().MarkFailed(
+		ctx, undelivered[0].Id, "refused", time.Now().Add(time.Hour),
+	)
+	p.NoError(err)
+
+	undelivered, err = dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 1)
+	p.Equal(1, undelivered[0].Attempts)
+	p.Equal("refused", undelivered[0].LastError)
+	p.True(undelivered[0].NextAttemptAt.After(time.Now()))
+
+	err = dbSvc.NotificationRepository().MarkDead(ctx, undelivered[0].Id, "rejected")
+	p.NoError(err)
+
+	undelivered, err = dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 1)
+	p.Equal(2, undelivered[0].Attempts)
+	p.Equal("rejected", undelivered[0].LastError)
+	p.NotNil(undelivered[0].DeadAt)
+
+	err = dbSvc.NotificationRepository().MarkDelivered(ctx, undelivered[0].Id)
+	p.NoError(err)
+
+	undelivered, err = dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 0)
+
+	// pending notification of deleted domain is dropped with it
+	err = dbSvc.DnsRepository().Update(
+		ctx,
+		domain.DnsInfo{Domain: "outbox.com"},
+		domain.Notification{
+			Type:   domain.NotificationDomainProvisioned,
+			Domain: "outbox.com",
+		},
+	)
+	p.NoError(err)
+	undelivered, err = dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 1)
+
+	err = dbSvc.DnsRepository().Delete(ctx, "outbox.com")
+	p.NoError(err)
+	undelivered, err = dbSvc.NotificationRepository().GetUndelivered(ctx)
+	p.NoError(err)
+	p.Len(undelivered, 0)
+}
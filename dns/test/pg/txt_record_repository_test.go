@@ -0,0 +1,31 @@
+package pgtest
+
+import "prem-gateway/dns/internal/core/domain"
+
+func (p *PgDbTestSuite) TestTxtRecordRepository() {
+	fqdn := "_acme-challenge.example.com"
+
+	records, err := dbSvc.TxtRecordRepository().GetAll(ctx, fqdn)
+	p.NoError(err)
+	p.Len(records, 0)
+
+	err = dbSvc.TxtRecordRepository().Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token1"})
+	p.NoError(err)
+	err = dbSvc.TxtRecordRepository().Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token2"})
+	p.NoError(err)
+	// adding same record twice is no-op
+	err = dbSvc.TxtRecordRepository().Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token2"})
+	p.NoError(err)
+
+	records, err = dbSvc.TxtRecordRepository().GetAll(ctx, fqdn)
+	p.NoError(err)
+	p.Len(records, 2)
+
+	err = dbSvc.TxtRecordRepository().Delete(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token1"})
+	p.NoError(err)
+
+	records, err = dbSvc.TxtRecordRepository().GetAll(ctx, fqdn)
+	p.NoError(err)
+	p.Len(records, 1)
+	p.Equal("token2", records[0].Value)
+}
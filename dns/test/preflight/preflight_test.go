@@ -0,0 +1,432 @@
+package preflighttest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"prem-gateway/dns/internal/core/application"
+	"prem-gateway/dns/internal/core/domain"
+	"prem-gateway/dns/internal/core/port"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	"testing"
+)
+
+const (
+	gatewayIp = "100.27.28.72"
+)
+
+// resolverStub answers from records keyed by "name type", missing key is
+// empty answer
+type resolverStub struct {
+	answers map[string]port.DnsLookupResult
+	errs    map[string]error
+}
+
+func (r *resolverStub) Lookup(
+	_ context.Context, name, recordType string,
+) (port.DnsLookupResult, error) {
+	key := name + " " + recordType
+	if err, ok := r.errs[key]; ok {
+		return port.DnsLookupResult{}, err
+	}
+
+	return r.answers[key], nil
+}
+
+func a(ips ...string) port.DnsLookupResult {
+	result := port.DnsLookupResult{}
+	for _, v := range ips {
+		result.Records = append(result.Records, port.ResolvedRecord{
+			Type: "A", Value: v, Ttl: 300,
+		})
+	}
+
+	return result
+}
+
+func caa(tag, value string) port.DnsLookupResult {
+	return port.DnsLookupResult{
+		Records: []port.ResolvedRecord{{Type: "CAA", Tag: tag, Value: value}},
+	}
+}
+
+// healthyAnswers are answers of correctly configured example.com
+func healthyAnswers() map[string]port.DnsLookupResult {
+	return map[string]port.DnsLookupResult{
+		"example.com A":                        a(gatewayIp),
+		"prem-gateway-preflight.example.com A": a(gatewayIp),
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(answers map[string]port.DnsLookupResult, errs map[string]error)
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "healthy",
+			modify:         func(map[string]port.DnsLookupResult, map[string]error) {},
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name: "a record points elsewhere",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com A"] = a("1.1.1.1")
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckARecord: application.PreflightFail,
+			},
+		},
+		{
+			name: "additional a record",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com A"] = a(gatewayIp, "1.1.1.1")
+			},
+			expectedStatus: application.PreflightWarn,
+			expectedChecks: map[string]string{
+				application.PreflightCheckARecord: application.PreflightWarn,
+			},
+		},
+		{
+			name: "stale aaaa record",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com AAAA"] = port.DnsLookupResult{
+					Records: []port.ResolvedRecord{{Type: "AAAA", Value: "2001:db8::1"}},
+				}
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckAaaaRecord: application.PreflightFail,
+			},
+		},
+		{
+			name: "missing wildcard",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				delete(answers, "prem-gateway-preflight.example.com A")
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckWildcardRecord: application.PreflightFail,
+			},
+		},
+		{
+			name: "caa allows configured ca",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com CAA"] = caa("issue", "letsencrypt.org; validationmethods=http-01")
+			},
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name: "caa of parent forbids configured ca",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com A"] = port.DnsLookupResult{}
+				answers["gw.example.com A"] = a(gatewayIp)
+				answers["prem-gateway-preflight.gw.example.com A"] = a(gatewayIp)
+				answers["example.com CAA"] = caa("issue", "digicert.com")
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckCaa: application.PreflightFail,
+			},
+		},
+		{
+			name: "caa forbids wildcard",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com CAA"] = caa("issuewild", ";")
+			},
+			expectedStatus: application.PreflightWarn,
+			expectedChecks: map[string]string{
+				application.PreflightCheckCaa: application.PreflightWarn,
+			},
+		},
+		{
+			name: "broken dnssec",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				result := a(gatewayIp)
+				result.DnssecFailed = true
+				answers["example.com A"] = result
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckDnssec: application.PreflightFail,
+			},
+		},
+		{
+			name: "cname at domain",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				answers["example.com CNAME"] = port.DnsLookupResult{
+					Records: []port.ResolvedRecord{{Type: "CNAME", Value: "other.net"}},
+				}
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckConflicts: application.PreflightFail,
+			},
+		},
+		{
+			name: "high ttl",
+			modify: func(answers map[string]port.DnsLookupResult, _ map[string]error) {
+				result := a(gatewayIp)
+				result.Records[0].Ttl = 86400
+				answers["example.com A"] = result
+			},
+			expectedStatus: application.PreflightWarn,
+			expectedChecks: map[string]string{
+				application.PreflightCheckTtl: application.PreflightWarn,
+			},
+		},
+		{
+			name: "lookup failed",
+			modify: func(_ map[string]port.DnsLookupResult, errs map[string]error) {
+				errs["example.com CAA"] = errors.New("timeout")
+			},
+			expectedStatus: application.PreflightWarn,
+			expectedChecks: map[string]string{
+				application.PreflightCheckCaa: application.PreflightWarn,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := healthyAnswers()
+			errs := make(map[string]error)
+			tt.modify(answers, errs)
+
+			ipSvcMock := new(port.MockIpService)
+			ipSvcMock.
+				On("GetHostIp", mock.Anything).
+				Return(port.HostIp{Ip: gatewayIp, Source: "static"}, nil)
+
+			svc, err := application.NewPreflightService(
+				inmemory.NewDBService(),
+				ipSvcMock,
+				&resolverStub{answers: answers, errs: errs},
+				"",
+			)
+			require.NoError(t, err)
+
+			domainName := "example.com"
+			if _, ok := answers["gw.example.com A"]; ok {
+				domainName = "GW.example.com."
+			}
+
+			report, err := svc.Run(context.Background(), domainName)
+			require.NoError(t, err)
+			require.Equal(t, gatewayIp, report.ExpectedIp)
+			require.Len(t, report.Checks, 7)
+			require.Equal(t, tt.expectedStatus, report.Status)
+
+			for _, v := range report.Checks {
+				expected, ok := tt.expectedChecks[v.Id]
+				if !ok {
+					expected = application.PreflightPass
+				}
+				require.Equal(t, expected, v.Status, v.Id+": "+v.Message)
+				if v.Status != application.PreflightPass {
+					require.NotEmpty(t, v.Remediation, v.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestPreflightUsesStoredIp(t *testing.T) {
+	repositorySvc := inmemory.NewDBService()
+	require.NoError(t, repositorySvc.DnsRepository().Create(
+		context.Background(),
+		domain.DnsInfo{Domain: "example.com", Ip: "5.5.5.5"},
+	))
+
+	answers := map[string]port.DnsLookupResult{
+		"example.com A":                        a("5.5.5.5"),
+		"prem-gateway-preflight.example.com A": a("5.5.5.5"),
+	}
+	svc, err := application.NewPreflightService(
+		repositorySvc,
+		new(port.MockIpService),
+		&resolverStub{answers: answers},
+		"",
+	)
+	require.NoError(t, err)
+
+	report, err := svc.Run(context.Background(), "example.com")
+	require.NoError(t, err)
+	require.Equal(t, "5.5.5.5", report.ExpectedIp)
+	require.Equal(t, application.PreflightPass, report.Status)
+
+	_, err = svc.Run(context.Background(), "com")
+	require.Equal(t, domain.KindInvalidArgument, domain.KindOf(err))
+}
+
+func TestPreflightIpv6(t *testing.T) {
+	const gatewayIpv6 = "2001:db8::1"
+	aaaa := func(ips ...string) port.DnsLookupResult {
+		result := port.DnsLookupResult{}
+		for _, v := range ips {
+			result.Records = append(result.Records, port.ResolvedRecord{
+				Type: "AAAA", Value: v, Ttl: 300,
+			})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name           string
+		answers        map[string]port.DnsLookupResult
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name: "healthy",
+			answers: map[string]port.DnsLookupResult{
+				"example.com AAAA":                        aaaa(gatewayIpv6),
+				"prem-gateway-preflight.example.com AAAA": aaaa(gatewayIpv6),
+			},
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name: "aaaa record points elsewhere",
+			answers: map[string]port.DnsLookupResult{
+				"example.com AAAA":                        aaaa("2001:db8::2"),
+				"prem-gateway-preflight.example.com AAAA": aaaa(gatewayIpv6),
+			},
+			expectedStatus: application.PreflightFail,
+			expectedChecks: map[string]string{
+				application.PreflightCheckAaaaRecord: application.PreflightFail,
+			},
+		},
+		{
+			name: "stale a record",
+			answers: map[string]port.DnsLookupResult{
+				"example.com A":    a(gatewayIp),
+				"example.com AAAA": aaaa(gatewayIpv6),
+				"prem-gateway-preflight.example.com AAAA": aaaa(gatewayIpv6),
+			},
+			expectedStatus: application.PreflightWarn,
+			expectedChecks: map[string]string{
+				application.PreflightCheckARecord: application.PreflightWarn,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipSvcMock := new(port.MockIpService)
+			ipSvcMock.
+				On("GetHostIp", mock.Anything).
+				Return(port.HostIp{Ip: gatewayIpv6, Source: "static"}, nil)
+
+			svc, err := application.NewPreflightService(
+				inmemory.NewDBService(),
+				ipSvcMock,
+				&resolverStub{answers: tt.answers},
+				"",
+			)
+			require.NoError(t, err)
+
+			report, err := svc.Run(context.Background(), "example.com")
+			require.NoError(t, err)
+			require.Equal(t, gatewayIpv6, report.ExpectedIp)
+			require.Equal(t, tt.expectedStatus, report.Status)
+
+			for _, v := range report.Checks {
+				expected, ok := tt.expectedChecks[v.Id]
+				if !ok {
+					expected = application.PreflightPass
+				}
+				require.Equal(t, expected, v.Status, v.Id+": "+v.Message)
+			}
+		})
+	}
+}
+
+func TestPreflightCaaOfAcmeCa(t *testing.T) {
+	tests := []struct {
+		name           string
+		acmeCa         string
+		caaIssuer      string
+		caa            port.DnsLookupResult
+		expectedStatus string
+	}{
+		{
+			name:           "ca left to controllerd uses configured issuer",
+			caaIssuer:      "sectigo.com",
+			caa:            caa("issue", "sectigo.com"),
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name:           "production is let's encrypt",
+			acmeCa:         domain.AcmeCaProduction,
+			caaIssuer:      "sectigo.com",
+			caa:            caa("issue", "letsencrypt.org"),
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name:           "known directory",
+			acmeCa:         "https://acme.zerossl.com/v2/DV90",
+			caa:            caa("issue", "sectigo.com"),
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name:           "known directory forbidden",
+			acmeCa:         "https://acme.zerossl.com/v2/DV90",
+			caa:            caa("issue", "letsencrypt.org"),
+			expectedStatus: application.PreflightFail,
+		},
+		{
+			name:           "unknown directory is not judged",
+			acmeCa:         "https://ca.internal.example/acme/directory",
+			caa:            caa("issue", "letsencrypt.org"),
+			expectedStatus: application.PreflightWarn,
+		},
+		{
+			name:           "unknown directory without caa",
+			acmeCa:         "https://ca.internal.example/acme/directory",
+			expectedStatus: application.PreflightPass,
+		},
+		{
+			name:           "unknown directory forbidden by empty issuer",
+			acmeCa:         "https://ca.internal.example/acme/directory",
+			caa:            caa("issue", ";"),
+			expectedStatus: application.PreflightFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositorySvc := inmemory.NewDBService()
+			require.NoError(t, repositorySvc.DnsRepository().Create(
+				context.Background(),
+				domain.DnsInfo{Domain: "example.com", Ip: gatewayIp, AcmeCa: tt.acmeCa},
+			))
+
+			answers := healthyAnswers()
+			answers["example.com CAA"] = tt.caa
+			svc, err := application.NewPreflightService(
+				repositorySvc,
+				new(port.MockIpService),
+				&resolverStub{answers: answers},
+				tt.caaIssuer,
+			)
+			require.NoError(t, err)
+
+			report, err := svc.Run(context.Background(), "example.com")
+			require.NoError(t, err)
+			for _, v := range report.Checks {
+				if v.Id == application.PreflightCheckCaa {
+					require.Equal(t, tt.expectedStatus, v.Status, v.Message)
+					if v.Status != application.PreflightPass {
+						require.NotEmpty(t, v.Remediation)
+					}
+					continue
+				}
+				require.Equal(t, application.PreflightPass, v.Status, v.Id+": "+v.Message)
+			}
+		})
+	}
+}
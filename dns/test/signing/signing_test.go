@@ -0,0 +1,255 @@
+package signingtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"prem-gateway/dns/internal/core/domain"
+	httpclients "prem-gateway/dns/internal/infrastructure/http-clients"
+	"prem-gateway/pkg/signing"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const secret = "3f1c0a9e7b5d4c2a1908f7e6d5c4b3a2"
+
+func signedRequest(t *testing.T, signer *signing.Signer, body string) *http.Request {
+	req := httptest.NewRequest(
+		http.MethodPost, "/domain-provisioned", bytes.NewReader([]byte(body)),
+	)
+	require.NoError(t, signer.Sign(req, []byte(body)))
+
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	signer, err := signing.NewSigner(secret)
+	require.NoError(t, err)
+	verifier, err := signing.NewVerifier(secret, time.Minute)
+	require.NoError(t, err)
+
+	body := `{"domain":"gateway.me","email":"admin@gateway.me"}`
+
+	req := signedRequest(t, signer, body)
+	got, err := verifier.Verify(req)
+	require.NoError(t, err)
+	require.Equal(t, body, string(got))
+	// body can be read again by handler
+	again, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, string(again))
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) *http.Request
+		err    error
+	}{
+		{
+			name: "unsigned",
+			modify: func(req *http.Request) *http.Request {
+				req.Header.Del(signing.HeaderSignature)
+				return req
+			},
+			err: signing.ErrMissingSignature,
+		},
+		{
+			name: "replayed",
+			modify: func(req *http.Request) *http.Request {
+				_, err := verifier.Verify(req.Clone(context.Background()))
+				require.NoError(t, err)
+				req.Body = io.NopCloser(bytes.NewReader([]byte(body)))
+				return req
+			},
+			err: signing.ErrReplayed,
+		},
+		{
+			name: "tampered body",
+			modify: func(req *http.Request) *http.Request {
+				req.Body = io.NopCloser(bytes.NewReader(
+					[]byte(`{"domain":"gateway.me","email":"evil@evil.org"}`),
+				))
+				return req
+			},
+			err: signing.ErrInvalidSignature,
+		},
+		{
+			name: "tampered uri",
+			modify: func(req *http.Request) *http.Request {
+				req.URL.Path = "/domain-deleted"
+				return req
+			},
+			err: signing.ErrInvalidSignature,
+		},
+		{
+			name: "tampered nonce",
+			modify: func(req *http.Request) *http.Request {
+				req.Header.Set(signing.HeaderNonce, "00")
+				return req
+			},
+			err: signing.ErrInvalidSignature,
+		},
+		{
+			name: "old timestamp",
+			modify: func(req *http.Request) *http.Request {
+				req.Header.Set(signing.HeaderTimestamp, strconv.FormatInt(
+					time.Now().Add(-2*time.Minute).Unix(), 10,
+				))
+				return req
+			},
+			err: signing.ErrExpired,
+		},
+		{
+			name: "future timestamp",
+			modify: func(req *http.Request) *http.Request {
+				req.Header.Set(signing.HeaderTimestamp, strconv.FormatInt(
+					time.Now().Add(2*time.Minute).Unix(), 10,
+				))
+				return req
+			},
+			err: signing.ErrExpired,
+		},
+		{
+			name: "other secret",
+			modify: func(req *http.Request) *http.Request {
+				other, err := signing.NewSigner(secret + "x")
+				require.NoError(t, err)
+				return signedRequest(t, other, body)
+			},
+			err: signing.ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(tt.modify(signedRequest(t, signer, body)))
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestVerifyValue(t *testing.T) {
+	signer, err := signing.NewSigner(secret)
+	require.NoError(t, err)
+	verifier, err := signing.NewVerifier(secret, time.Minute)
+	require.NoError(t, err)
+	other, err := signing.NewSigner(secret + "x")
+	require.NoError(t, err)
+
+	sign := func(signer *signing.Signer, method, uri, value string) string {
+		signature, err := signer.SignValue(method, uri, value)
+		require.NoError(t, err)
+		return signature
+	}
+
+	signature := sign(signer, http.MethodPost, "/dns", "apikey:1234")
+	require.NoError(t, verifier.VerifyValue(http.MethodPost, "/dns", "apikey:1234", signature))
+	// captured signature can not be replayed
+	require.ErrorIs(t, verifier.VerifyValue(
+		http.MethodPost, "/dns", "apikey:1234", signature,
+	), signing.ErrReplayed)
+
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		value     string
+		signature string
+		err       error
+	}{
+		{
+			name: "other value", method: http.MethodPost, uri: "/dns", value: "apikey:5678",
+			signature: sign(signer, http.MethodPost, "/dns", "apikey:1234"),
+			err:       signing.ErrInvalidSignature,
+		},
+		{
+			name: "other method", method: http.MethodDelete, uri: "/dns", value: "apikey:1234",
+			signature: sign(signer, http.MethodPost, "/dns", "apikey:1234"),
+			err:       signing.ErrInvalidSignature,
+		},
+		{
+			name: "other uri", method: http.MethodPost, uri: "/dns/gateway.me", value: "apikey:1234",
+			signature: sign(signer, http.MethodPost, "/dns", "apikey:1234"),
+			err:       signing.ErrInvalidSignature,
+		},
+		{
+			name: "other secret", method: http.MethodPost, uri: "/dns", value: "apikey:1234",
+			signature: sign(other, http.MethodPost, "/dns", "apikey:1234"),
+			err:       signing.ErrInvalidSignature,
+		},
+		{
+			name: "old timestamp", method: http.MethodPost, uri: "/dns", value: "apikey:1234",
+			signature: regexp.MustCompile(`t=\d+`).ReplaceAllString(
+				sign(signer, http.MethodPost, "/dns", "apikey:1234"),
+				"t="+strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10),
+			),
+			err: signing.ErrExpired,
+		},
+		{
+			name: "unsigned", method: http.MethodPost, uri: "/dns", value: "apikey:1234",
+			err: signing.ErrMissingSignature,
+		},
+		{
+			name: "malformed", method: http.MethodPost, uri: "/dns", value: "apikey:1234",
+			signature: "v1=zz",
+			err:       signing.ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, verifier.VerifyValue(
+				tt.method, tt.uri, tt.value, tt.signature,
+			), tt.err)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := signing.NewSigner("short")
+	require.Error(t, err)
+	_, err = signing.NewVerifier("", 0)
+	require.Error(t, err)
+	_, err = signing.NewVerifier(secret, -time.Second)
+	require.Error(t, err)
+}
+
+func TestControllerdWrapper(t *testing.T) {
+	verifier, err := signing.NewVerifier(secret, 0)
+	require.NoError(t, err)
+
+	var got httpclients.DomainProvisionedRequest
+	srv := httptest.NewServer(verifier.Middleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/domain-provisioned", r.URL.Path)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			w.WriteHeader(http.StatusAccepted)
+		},
+	)))
+	defer srv.Close()
+
+	wrapper, err := httpclients.NewControllerdWrapper(srv.URL, secret)
+	require.NoError(t, err)
+	// email and domain are sent as json so query syntax can not leak into them
+	require.NoError(t, wrapper.DomainProvisioned(
+		context.Background(), "a+b@gateway.me", "gateway.me", "production",
+	))
+	require.Equal(t, httpclients.DomainProvisionedRequest{
+		Domain: "gateway.me", Email: "a+b@gateway.me", AcmeCa: "production",
+	}, got)
+
+	// controllerd rejects unsigned requests
+	unsigned, err := httpclients.NewControllerdWrapper(srv.URL, "")
+	require.NoError(t, err)
+	err = unsigned.DomainProvisioned(context.Background(), "", "gateway.me", "")
+	require.ErrorIs(t, err, domain.ErrControllerdFailed)
+	require.Contains(t, err.Error(), "401")
+
+	_, err = httpclients.NewControllerdWrapper(srv.URL, "short")
+	require.Error(t, err)
+}
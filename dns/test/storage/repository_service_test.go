@@ -0,0 +1,340 @@
+package storagetest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"prem-gateway/dns/internal/core/domain"
+	boltdb "prem-gateway/dns/internal/infrastructure/storage/bolt"
+	"prem-gateway/dns/internal/infrastructure/storage/inmemory"
+	"testing"
+	"time"
+)
+
+// backends which do not need external services, pg is covered by test/pg
+func backends(t *testing.T) map[string]domain.RepositoryService {
+	boltSvc, err := boltdb.NewDBService(boltdb.DbConfig{
+		Datadir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(boltSvc.Close)
+
+	return map[string]domain.RepositoryService{
+		"inmemory": inmemory.NewDBService(),
+		"bolt":     boltSvc,
+	}
+}
+
+func TestDnsRepository(t *testing.T) {
+	ctx := context.Background()
+
+	for name, svc := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := svc.DnsRepository()
+
+			dnsInfo, err := repo.Get(ctx, "dummy")
+			require.ErrorIs(t, err, domain.ErrEntityNotFound)
+			require.Nil(t, dnsInfo)
+
+			_, err = repo.GetExistingDomain(ctx)
+			require.ErrorIs(t, err, domain.ErrEntityNotFound)
+
+			info := domain.DnsInfo{
+				Domain:    "example.com",
+				SubDomain: "*example.com",
+				Ip:        "10.10.10.10",
+				NodeName:  "node1",
+				Email:     "test@gmail.com",
+			}
+			require.NoError(t, repo.Create(ctx, info))
+
+			dnsInfo, err = repo.Get(ctx, "example.com")
+			require.NoError(t, err)
+			require.Equal(t, info, *dnsInfo)
+
+			dnsInfo, err = repo.GetExistingDomain(ctx)
+			require.NoError(t, err)
+			require.Equal(t, info, *dnsInfo)
+
+			// creating existing domain keeps stored record
+			duplicate := info
+			duplicate.Ip = "20.20.20.20"
+			require.ErrorIs(t, repo.Create(ctx, duplicate), domain.ErrAlreadyExists)
+			dnsInfo, err = repo.Get(ctx, "example.com")
+			require.NoError(t, err)
+			require.Equal(t, "10.10.10.10", dnsInfo.Ip)
+
+			// update of missing domain stores no notification
+			require.ErrorIs(t, repo.Update(
+				ctx,
+				domain.DnsInfo{Domain: "missing.com"},
+				domain.Notification{
+					Type:   domain.NotificationDomainProvisioned,
+					Domain: "missing.com",
+				},
+			), domain.ErrEntityNotFound)
+			notifications, err := svc.NotificationRepository().GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Empty(t, notifications)
+
+			require.NoError(t, repo.Update(ctx, duplicate))
+			dnsInfo, err = repo.Get(ctx, "example.com")
+			require.NoError(t, err)
+			require.Equal(t, "20.20.20.20", dnsInfo.Ip)
+
+			// acme ca change is stored with controllerd notification
+			duplicate.AcmeCa = domain.AcmeCaProduction
+			require.NoError(t, repo.Update(ctx, duplicate, domain.Notification{
+				Type:   domain.NotificationDomainProvisioned,
+				Domain: "example.com",
+				AcmeCa: domain.AcmeCaProduction,
+			}))
+			dnsInfo, err = repo.Get(ctx, "example.com")
+			require.NoError(t, err)
+			require.Equal(t, domain.AcmeCaProduction, dnsInfo.AcmeCa)
+			notifications, err = svc.NotificationRepository().GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			require.Equal(t, domain.AcmeCaProduction, notifications[0].AcmeCa)
+
+			// delivered notification and other domain ones are kept
+			require.NoError(t, repo.Create(ctx, domain.DnsInfo{Domain: "other.com"},
+				domain.Notification{
+					Type:   domain.NotificationDomainProvisioned,
+					Domain: "other.com",
+				},
+			))
+			require.NoError(t, repo.Update(ctx, duplicate, domain.Notification{
+				Type:   domain.NotificationDomainProvisioned,
+				Domain: "example.com",
+			}))
+			require.NoError(t, svc.NotificationRepository().MarkDelivered(
+				ctx, notifications[0].Id,
+			))
+
+			require.NoError(t, repo.Delete(ctx, "dummy"))
+			require.NoError(t, repo.Delete(ctx, "example.com"))
+
+			_, err = repo.Get(ctx, "example.com")
+			require.ErrorIs(t, err, domain.ErrEntityNotFound)
+			// pending notifications of deleted domain are dropped with it
+			notifications, err = svc.NotificationRepository().GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			require.Equal(t, "other.com", notifications[0].Domain)
+		})
+	}
+}
+
+func TestIpChangeEventRepository(t *testing.T) {
+	ctx := context.Background()
+
+	for name, svc := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := svc.IpChangeEventRepository()
+
+			events, err := repo.GetAll(ctx, "example.com", 10)
+			require.NoError(t, err)
+			require.Len(t, events, 0)
+
+			for _, v := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+				require.NoError(t, repo.Add(ctx, domain.IpChangeEvent{
+					Domain: "example.com",
+					NewIp:  v,
+					Source: "static",
+				}))
+			}
+			require.NoError(t, repo.Add(ctx, domain.IpChangeEvent{
+				Domain: "other.com",
+				NewIp:  "4.4.4.4",
+			}))
+
+			events, err = repo.GetAll(ctx, "example.com", 2)
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			require.Equal(t, "3.3.3.3", events[0].NewIp)
+			require.Equal(t, "2.2.2.2", events[1].NewIp)
+			require.False(t, events[0].CreatedAt.IsZero())
+		})
+	}
+}
+
+func TestTxtRecordRepository(t *testing.T) {
+	ctx := context.Background()
+	fqdn := "_acme-challenge.example.com"
+
+	for name, svc := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := svc.TxtRecordRepository()
+
+			require.NoError(t, repo.Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token1"}))
+			require.NoError(t, repo.Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token2"}))
+			require.NoError(t, repo.Add(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token2"}))
+			require.NoError(t, repo.Add(ctx, domain.TxtRecord{Fqdn: fqdn + ".sub", Value: "token3"}))
+
+			records, err := repo.GetAll(ctx, fqdn)
+			require.NoError(t, err)
+			require.Len(t, records, 2)
+
+			require.NoError(t, repo.Delete(ctx, domain.TxtRecord{Fqdn: fqdn, Value: "token1"}))
+
+			records, err = repo.GetAll(ctx, fqdn)
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			require.Equal(t, "token2", records[0].Value)
+		})
+	}
+}
+
+func TestNotificationRepository(t *testing.T) {
+	ctx := context.Background()
+
+	for name, svc := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := svc.NotificationRepository()
+
+			require.NoError(t, svc.DnsRepository().Create(
+				ctx,
+				domain.DnsInfo{Domain: "example.com"},
+				domain.Notification{
+					Type:   domain.NotificationDomainProvisioned,
+					Domain: "example.com",
+					Email:  "test@gmail.com",
+				},
+			))
+
+			// notification is not stored if domain change fails
+			require.ErrorIs(t, svc.DnsRepository().Create(
+				ctx,
+				domain.DnsInfo{Domain: "example.com"},
+				domain.Notification{
+					Type:   domain.NotificationDomainProvisioned,
+					Domain: "example.com",
+				},
+			), domain.ErrAlreadyExists)
+
+			require.NoError(t, svc.DnsRepository().Create(
+				ctx,
+				domain.DnsInfo{Domain: "example.org"},
+				domain.Notification{
+					Type:   domain.NotificationDomainProvisioned,
+					Domain: "example.org",
+				},
+			))
+
+			undelivered, err := repo.GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Len(t, undelivered, 2)
+			require.Equal(t, domain.NotificationDomainProvisioned, undelivered[0].Type)
+			require.Equal(t, "test@gmail.com", undelivered[0].Email)
+			require.False(t, undelivered[0].NextAttemptAt.After(time.Now()))
+			require.Equal(t, "example.org", undelivered[1].Domain)
+
+			nextAttemptAt := time.Now().Add(time.Hour)
+			require.NoError(t, repo.MarkFailed(ctx, undelivered[0].Id, "refused", nextAttemptAt))
+			require.NoError(t, repo.MarkDelivered(ctx, undelivered[1].Id))
+
+			undelivered, err = repo.GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Len(t, undelivered, 1)
+			require.Equal(t, 1, undelivered[0].Attempts)
+			require.Equal(t, "refused", undelivered[0].LastError)
+			require.True(t, undelivered[0].NextAttemptAt.After(time.Now()))
+			require.Nil(t, undelivered[0].DeliveredAt)
+			require.Nil(t, undelivered[0].DeadAt)
+
+			// dead notification stays undelivered for inspection
+			require.NoError(t, repo.MarkDead(ctx, undelivered[0].Id, "rejected"))
+			undelivered, err = repo.GetUndelivered(ctx)
+			require.NoError(t, err)
+			require.Len(t, undelivered, 1)
+			require.Equal(t, 2, undelivered[0].Attempts)
+			require.Equal(t, "rejected", undelivered[0].LastError)
+			require.NotNil(t, undelivered[0].DeadAt)
+		})
+	}
+}
+
+func TestAuditEventRepository(t *testing.T) {
+	ctx := context.Background()
+
+	for name, svc := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := svc.AuditEventRepository()
+
+			events, err := repo.GetAll(ctx, domain.AuditFilter{})
+			require.NoError(t, err)
+			require.Len(t, events, 0)
+
+			start := time.Now().Add(-time.Second)
+			require.NoError(t, repo.Add(ctx, domain.AuditEvent{
+				Actor:    "apikey:1234",
+				Action:   "domain.create",
+				Resource: "example.com",
+				After:    `{"domain":"example.com"}`,
+				Outcome:  domain.AuditOutcomeSuccess,
+			}))
+			require.NoError(t, repo.Add(ctx, domain.AuditEvent{
+				Actor:    "system",
+				Action:   "domain.ip_change",
+				Resource: "example.com",
+				Before:   `{"ip":"10.10.10.10"}`,
+				After:    `{"ip":"20.20.20.20"}`,
+				Outcome:  domain.AuditOutcomeFailure,
+				Error:    "storage unavailable",
+			}))
+
+			events, err = repo.GetAll(ctx, domain.AuditFilter{})
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			require.Equal(t, "domain.create", events[0].Action)
+			require.Equal(t, "apikey:1234", events[0].Actor)
+			require.Equal(t, "", events[0].Before)
+			require.Equal(t, `{"domain":"example.com"}`, events[0].After)
+			require.False(t, events[0].CreatedAt.Before(start))
+			require.Equal(t, domain.AuditOutcomeFailure, events[1].Outcome)
+			require.Equal(t, "storage unavailable", events[1].Error)
+
+			events, err = repo.GetAll(ctx, domain.AuditFilter{
+				Actions: []string{"domain.ip_change"},
+			})
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			require.Equal(t, "system", events[0].Actor)
+
+			events, err = repo.GetAll(ctx, domain.AuditFilter{Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			require.Equal(t, "domain.create", events[0].Action)
+
+			events, err = repo.GetAll(ctx, domain.AuditFilter{To: start})
+			require.NoError(t, err)
+			require.Len(t, events, 0)
+
+			events, err = repo.GetAll(ctx, domain.AuditFilter{From: start})
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+		})
+	}
+}
+
+func TestBoltReopen(t *testing.T) {
+	ctx := context.Background()
+	datadir := t.TempDir()
+
+	svc, err := boltdb.NewDBService(boltdb.DbConfig{Datadir: datadir})
+	require.NoError(t, err)
+	require.NoError(t, svc.DnsRepository().Create(ctx, domain.DnsInfo{
+		Domain: "example.com",
+		Ip:     "10.10.10.10",
+	}))
+	svc.Close()
+
+	// migrations are not reapplied and state survives restart
+	svc, err = boltdb.NewDBService(boltdb.DbConfig{Datadir: datadir})
+	require.NoError(t, err)
+	defer svc.Close()
+
+	dnsInfo, err := svc.DnsRepository().Get(ctx, "example.com")
+	require.NoError(t, err)
+	require.Equal(t, "10.10.10.10", dnsInfo.Ip)
+}
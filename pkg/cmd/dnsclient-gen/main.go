@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"prem-gateway/pkg/dnsclient/gen"
+)
+
+// dnsclient-gen writes dnsclient types and paths generated from swagger
+// spec, it is run with go generate in pkg/dnsclient
+func main() {
+	specPath := flag.String("spec", "../dns/docs/swagger.json", "swagger spec of dnsd")
+	out := flag.String("out", "dnsclient/types_gen.go", "output file")
+	pkg := flag.String("pkg", "dnsclient", "package of output file")
+	flag.Parse()
+
+	spec, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("failed to read spec: %v", err)
+	}
+
+	src, err := gen.Generate(spec, *pkg)
+	if err != nil {
+		log.Fatalf("failed to generate client: %v", err)
+	}
+
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("failed to write client: %v", err)
+	}
+}
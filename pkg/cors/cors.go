@@ -0,0 +1,314 @@
+// Package cors implements CORS policy as net/http middleware, it depends
+// only on standard library so authd and controllerd can use it too
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerOrigin           = "Origin"
+	headerVary             = "Vary"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+
+	wildcard = "*"
+
+	EnvAllowedOrigins   = "CORS_ALLOWED_ORIGINS"
+	EnvAllowedHeaders   = "CORS_ALLOWED_HEADERS"
+	EnvExposedHeaders   = "CORS_EXPOSED_HEADERS"
+	EnvAllowCredentials = "CORS_ALLOW_CREDENTIALS"
+	EnvMaxAge           = "CORS_MAX_AGE"
+)
+
+var (
+	DefaultAllowedMethods = []string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete,
+		http.MethodPatch,
+	}
+	DefaultAllowedHeaders = []string{"Content-Type", "Authorization"}
+
+	ErrWildcardWithCredentials = errors.New(
+		"wildcard origin can not be used with credentials",
+	)
+)
+
+// Config is CORS policy. Allowed origin is either exact origin, eg.
+// https://app.example.com, origin with wildcard subdomain, eg.
+// https://*.example.com, or * which allows any origin
+type Config struct {
+	AllowedOrigins []string
+	// AllowOriginFunc is consulted for origins not matched by
+	// AllowedOrigins, eg. to allow origins known only at runtime
+	AllowOriginFunc func(r *http.Request, origin string) bool
+	// AllowedMethods defaults to DefaultAllowedMethods
+	AllowedMethods []string
+	// AllowedHeaders defaults to DefaultAllowedHeaders, * allows any header
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is for how long preflight response is cached, 0 leaves it to
+	// browser default
+	MaxAge time.Duration
+}
+
+type originPattern struct {
+	scheme string
+	// host is exact host with optional port, or parent domain if wildcard
+	host     string
+	wildcard bool
+}
+
+type Cors struct {
+	cfg            Config
+	anyOrigin      bool
+	origins        []originPattern
+	anyHeader      bool
+	allowedMethods string
+	allowedHeaders string
+	exposedHeaders string
+	maxAge         string
+}
+
+// New validates cfg and returns middleware applying it
+func New(cfg Config) (*Cors, error) {
+	c := &Cors{cfg: cfg}
+
+	for _, v := range cfg.AllowedOrigins {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if v == wildcard {
+			c.anyOrigin = true
+			continue
+		}
+
+		pattern, err := parseOrigin(v)
+		if err != nil {
+			return nil, err
+		}
+		c.origins = append(c.origins, pattern)
+	}
+
+	if c.anyOrigin && cfg.AllowCredentials {
+		return nil, ErrWildcardWithCredentials
+	}
+
+	if cfg.MaxAge < 0 {
+		return nil, fmt.Errorf("max age %v is negative", cfg.MaxAge)
+	}
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultAllowedMethods
+	}
+	c.allowedMethods = strings.ToUpper(strings.Join(methods, ", "))
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultAllowedHeaders
+	}
+	for _, v := range headers {
+		if strings.TrimSpace(v) == wildcard {
+			c.anyHeader = true
+		}
+	}
+	c.allowedHeaders = strings.Join(headers, ", ")
+	c.exposedHeaders = strings.Join(cfg.ExposedHeaders, ", ")
+
+	return c, nil
+}
+
+// Handler wraps next, preflight requests are answered without calling it
+func (c *Cors) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Apply(w, r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Apply sets CORS response headers and reports if r is preflight request,
+// which caller must answer without processing it further. It is meant for
+// routers with own middleware signature, eg. gin
+func (c *Cors) Apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get(headerOrigin)
+	preflight := r.Method == http.MethodOptions &&
+		r.Header.Get(headerRequestMethod) != ""
+
+	header := w.Header()
+	if preflight {
+		header.Add(headerVary, headerOrigin)
+		header.Add(headerVary, headerRequestMethod)
+		header.Add(headerVary, headerRequestHeaders)
+	} else {
+		header.Add(headerVary, headerOrigin)
+	}
+
+	if origin == "" || !c.allowOrigin(r, origin) {
+		return preflight
+	}
+
+	if c.anyOrigin && !c.cfg.AllowCredentials {
+		header.Set(headerAllowOrigin, wildcard)
+	} else {
+		header.Set(headerAllowOrigin, origin)
+	}
+	if c.cfg.AllowCredentials {
+		header.Set(headerAllowCredentials, "true")
+	}
+
+	if !preflight {
+		if c.exposedHeaders != "" {
+			header.Set(headerExposeHeaders, c.exposedHeaders)
+		}
+		return false
+	}
+
+	header.Set(headerAllowMethods, c.allowedMethods)
+	if c.anyHeader {
+		// * is literal header name for requests with credentials so
+		// requested headers are echoed back
+		if requested := r.Header.Get(headerRequestHeaders); requested != "" {
+			header.Set(headerAllowHeaders, requested)
+		}
+	} else {
+		header.Set(headerAllowHeaders, c.allowedHeaders)
+	}
+	if c.maxAge != "" {
+		header.Set(headerMaxAge, c.maxAge)
+	}
+
+	return true
+}
+
+func (c *Cors) allowOrigin(r *http.Request, origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && u.Scheme != "" && u.Host != "" {
+		scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+		for _, v := range c.origins {
+			if v.match(scheme, host) {
+				return true
+			}
+		}
+	}
+
+	return c.cfg.AllowOriginFunc != nil && c.cfg.AllowOriginFunc(r, origin)
+}
+
+func (p originPattern) match(scheme, host string) bool {
+	if p.scheme != scheme {
+		return false
+	}
+	if !p.wildcard {
+		return p.host == host
+	}
+
+	return strings.HasSuffix(host, "."+p.host)
+}
+
+func parseOrigin(origin string) (originPattern, error) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil {
+		return originPattern{}, fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+		return originPattern{}, fmt.Errorf(
+			"invalid origin %q, must be scheme://host[:port]", origin,
+		)
+	}
+
+	pattern := originPattern{scheme: u.Scheme, host: u.Host}
+	if strings.HasPrefix(u.Host, "*.") {
+		pattern.wildcard = true
+		pattern.host = strings.TrimPrefix(u.Host, "*.")
+	}
+	if strings.Contains(pattern.host, wildcard) {
+		return originPattern{}, fmt.Errorf(
+			"invalid origin %q, only leading *. is supported", origin,
+		)
+	}
+
+	return pattern, nil
+}
+
+// FromEnv reads Config from prefix+CORS_* env vars, list values are comma
+// separated. It is used by daemons without own config loader
+func FromEnv(prefix string) (Config, error) {
+	cfg := Config{
+		AllowedOrigins: splitEnv(prefix + EnvAllowedOrigins),
+		AllowedHeaders: splitEnv(prefix + EnvAllowedHeaders),
+		ExposedHeaders: splitEnv(prefix + EnvExposedHeaders),
+	}
+
+	if v := os.Getenv(prefix + EnvAllowCredentials); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %v%v: %w", prefix, EnvAllowCredentials, err)
+		}
+		cfg.AllowCredentials = allow
+	}
+
+	if v := os.Getenv(prefix + EnvMaxAge); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %v%v: %w", prefix, EnvMaxAge, err)
+		}
+		cfg.MaxAge = maxAge
+	}
+
+	return cfg, nil
+}
+
+func splitEnv(key string) []string {
+	result := make([]string, 0)
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// MatchDomain reports if origin host is domain or its subdomain, port is
+// ignored. It is meant for AllowOriginFunc
+func MatchDomain(origin, domain string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if host == "" || domain == "" {
+		return false
+	}
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
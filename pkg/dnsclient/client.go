@@ -0,0 +1,347 @@
+// Package dnsclient is Go client of dnsd api. Types and paths are generated
+// from dns/docs/swagger.json, run go generate after swagger is updated
+package dnsclient
+
+//go:generate go run ../cmd/dnsclient-gen -spec ../../dns/docs/swagger.json -out types_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ActorHeader identifies caller in dnsd audit log when request does not
+	// pass through authd, eg. calls between gateway daemons, dnsd trusts it
+	// only on requests signed with WithSecret
+	ActorHeader = "X-Auth-Actor"
+
+	userAgent = "prem-gateway-dnsclient/" + SpecVersion
+)
+
+type Client struct {
+	baseUrl string
+	opts    options
+}
+
+// New returns client of dnsd listening at baseUrl, eg. http://dnsd:8080
+func New(baseUrl string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid dnsd url %q", baseUrl)
+	}
+
+	options := defaultOptions()
+	for _, o := range opts {
+		if err := o.apply(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		opts:    options,
+	}, nil
+}
+
+// CreateDomain provisions domain on the gateway
+func (c *Client) CreateDomain(ctx context.Context, dnsInfo DnsInfo) error {
+	return c.do(ctx, http.MethodPost, PathDns, nil, dnsInfo, nil)
+}
+
+func (c *Client) DeleteDomain(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodDelete, withDomain(PathDnsDomain, domain), nil, nil, nil)
+}
+
+// GetDomain returns domain, error matching ErrNotFound if it does not exist
+func (c *Client) GetDomain(ctx context.Context, domain string) (*DnsInfo, error) {
+	var result DnsInfo
+	if err := c.do(
+		ctx, http.MethodGet, withDomain(PathDnsDomain, domain), nil, nil, &result,
+	); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UpdateAcmeCa selects ACME CA of domain certificates, staging, production
+// or https url of custom ACME directory
+func (c *Client) UpdateAcmeCa(
+	ctx context.Context, domain, acmeCa string,
+) (*DnsInfo, error) {
+	var result DnsInfo
+	if err := c.do(
+		ctx,
+		http.MethodPut,
+		withDomain(PathDnsDomainAcmeCa, domain),
+		nil,
+		AcmeCaRequest{AcmeCa: acmeCa},
+		&result,
+	); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetExistingDomain returns domain provisioned on the gateway, nil if there
+// is none
+func (c *Client) GetExistingDomain(ctx context.Context) (*DnsInfo, error) {
+	var result *DnsInfo
+	if err := c.do(ctx, http.MethodGet, PathDnsExisting, nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CheckDomainStatus reports if A record of domain points to the gateway
+func (c *Client) CheckDomainStatus(ctx context.Context, domain string) (bool, error) {
+	var result bool
+	err := c.do(
+		ctx, http.MethodGet, withDomain(PathDnsStatusDomain, domain), nil, nil, &result,
+	)
+
+	return result, err
+}
+
+func (c *Client) GetPreflightReport(
+	ctx context.Context, domain string,
+) (*PreflightReport, error) {
+	var result PreflightReport
+	if err := c.do(
+		ctx, http.MethodGet, withDomain(PathDnsPreflightDomain, domain), nil, nil, &result,
+	); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetGatewayIp returns gateway ip together with provider it was discovered by
+func (c *Client) GetGatewayIp(ctx context.Context) (*GatewayIp, error) {
+	var result GatewayIp
+	if err := c.do(ctx, http.MethodGet, PathDnsIpDetails, nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetIpHistory returns gateway ip changes, newest first, limit 0 leaves it
+// to dnsd default
+func (c *Client) GetIpHistory(ctx context.Context, limit int) ([]IpChangeEvent, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	result := make([]IpChangeEvent, 0)
+	err := c.do(ctx, http.MethodGet, PathDnsIpHistory, query, nil, &result)
+
+	return result, err
+}
+
+func (c *Client) GetPendingNotifications(ctx context.Context) ([]Notification, error) {
+	result := make([]Notification, 0)
+	err := c.do(ctx, http.MethodGet, PathDnsNotifications, nil, nil, &result)
+
+	return result, err
+}
+
+// Check returns nil if dnsd is up
+func (c *Client) Check(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, PathDnsCheck, nil, nil, nil)
+}
+
+type AuditFilter struct {
+	From    time.Time
+	To      time.Time
+	Actions []string
+	Limit   int
+}
+
+// GetAuditEvents lists audit events matching filter, client must be created
+// with WithSecret
+func (c *Client) GetAuditEvents(
+	ctx context.Context, filter AuditFilter,
+) ([]AuditEvent, error) {
+	query := url.Values{}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	for _, v := range filter.Actions {
+		query.Add("action", v)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	result := make([]AuditEvent, 0)
+	err := c.do(ctx, http.MethodGet, PathAudit, query, nil, &result)
+
+	return result, err
+}
+
+// RecordAuditEvent appends event to dnsd audit log, client must be created
+// with WithSecret, actor is set with WithActor
+func (c *Client) RecordAuditEvent(ctx context.Context, event AuditEventRequest) error {
+	return c.do(ctx, http.MethodPost, PathAudit, nil, event, nil)
+}
+
+// GetConfig returns effective dnsd configuration, secrets are redacted,
+// client must be created with WithSecret
+func (c *Client) GetConfig(ctx context.Context) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	err := c.do(ctx, http.MethodGet, PathAdminConfig, nil, nil, &result)
+
+	return result, err
+}
+
+// PresentTxtRecord serves ACME DNS-01 challenge record, credentials are set
+// with WithBasicAuth
+func (c *Client) PresentTxtRecord(ctx context.Context, record TxtRecord) error {
+	return c.do(ctx, http.MethodPost, PathAcmePresent, nil, record, nil)
+}
+
+func (c *Client) CleanupTxtRecord(ctx context.Context, record TxtRecord) error {
+	return c.do(ctx, http.MethodPost, PathAcmeCleanup, nil, record, nil)
+}
+
+// do sends request and decodes response into result if it is not nil,
+// failed attempts are retried if retry is safe, see retryable
+func (c *Client) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	body interface{},
+	result interface{},
+) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	reqUrl := c.baseUrl + path
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+
+	backoff := c.opts.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, reqUrl, payload, result)
+		if err == nil || attempt >= c.opts.maxRetries || !retryable(method, err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(
+	ctx context.Context, method, reqUrl string, payload []byte, result interface{},
+) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.actor != "" {
+		req.Header.Set(ActorHeader, c.opts.actor)
+	}
+	if c.opts.apiKey != "" {
+		req.Header.Set("Authorization", c.opts.apiKey)
+	}
+	if c.opts.password != "" {
+		req.SetBasicAuth(c.opts.username, c.opts.password)
+	}
+	// every attempt is signed with new nonce, dnsd rejects replayed ones
+	if c.opts.signer != nil {
+		if err := c.opts.signer.Sign(req, payload); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newError(resp.StatusCode, respBody)
+	}
+
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// retryable reports if request may be sent again, request which may have
+// reached dnsd is only retried if method is idempotent
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	if method != http.MethodGet && method != http.MethodDelete {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func withDomain(path, domain string) string {
+	return strings.Replace(path, "{domain}", url.PathEscape(domain), 1)
+}
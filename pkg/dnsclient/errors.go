@@ -0,0 +1,74 @@
+package dnsclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrInvalidArgument matches errors of malformed or invalid input,
+	// invalid fields are in Error.Fields
+	ErrInvalidArgument = &Error{StatusCode: http.StatusBadRequest}
+	ErrNotFound        = &Error{StatusCode: http.StatusNotFound}
+	ErrAlreadyExists   = &Error{StatusCode: http.StatusConflict}
+	// ErrUnprocessable matches errors of requests dnsd can not fulfill as
+	// is, eg. A record does not point to the gateway
+	ErrUnprocessable = &Error{StatusCode: http.StatusUnprocessableEntity}
+	ErrUnavailable   = &Error{StatusCode: http.StatusServiceUnavailable}
+)
+
+// Error is failed response of dnsd, Code is stable machine-readable code,
+// eg. already_exists, see dns/README.md
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     []FieldError
+}
+
+func newError(statusCode int, body []byte) *Error {
+	e := &Error{StatusCode: statusCode}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		e.Code = resp.Code
+		e.Message = resp.Error
+		e.Fields = resp.Fields
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(statusCode)
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("dnsd: %v %v", e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("dnsd: %v %v: %v", e.StatusCode, e.Code, e.Message)
+}
+
+// Is matches sentinel errors by status code, eg.
+// errors.Is(err, dnsclient.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return t.StatusCode == e.StatusCode && (t.Code == "" || t.Code == e.Code)
+}
+
+// Temporary reports if request may succeed if retried later
+func (e *Error) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,206 @@
+// Package gen generates dnsclient types and api paths from dnsd swagger
+// spec, so the client follows the api it is built against
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+const (
+	definitionPrefix = "httphandler."
+	refPrefix        = "#/definitions/"
+)
+
+type spec struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]schema                     `json:"definitions"`
+}
+
+type schema struct {
+	Type       string            `json:"type"`
+	Format     string            `json:"format"`
+	Ref        string            `json:"$ref"`
+	Enum       []string          `json:"enum"`
+	Required   []string          `json:"required"`
+	Items      *schema           `json:"items"`
+	Properties map[string]schema `json:"properties"`
+}
+
+// Generate returns gofmt-ed source of package pkg holding one struct per
+// spec definition, one Path constant per spec path and SpecVersion
+func Generate(specJson []byte, pkg string) ([]byte, error) {
+	var s spec
+	if err := json.Unmarshal(specJson, &s); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if s.Info.Version == "" {
+		return nil, fmt.Errorf("spec has no info.version")
+	}
+
+	g := &generator{imports: make(map[string]struct{})}
+
+	g.line("const (")
+	g.line("// SpecVersion is version of dnsd api the client is generated from")
+	g.line("SpecVersion = %q", s.Info.Version)
+	g.line("")
+	for _, v := range sortedKeys(s.Paths) {
+		methods := make([]string, 0, len(s.Paths[v]))
+		for _, m := range sortedKeys(s.Paths[v]) {
+			methods = append(methods, strings.ToUpper(m))
+		}
+		g.line("// %v supports %v", pathName(v), strings.Join(methods, ", "))
+		g.line("%v = %q", pathName(v), v)
+	}
+	g.line(")")
+
+	for _, v := range sortedKeys(s.Definitions) {
+		if err := g.definition(v, s.Definitions[v]); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by dnsclient-gen from dns/docs/swagger.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %v\n\n", pkg)
+	if len(g.imports) > 0 {
+		src.WriteString("import (\n")
+		for _, v := range sortedKeys(g.imports) {
+			fmt.Fprintf(&src, "%q\n", v)
+		}
+		src.WriteString(")\n\n")
+	}
+	src.Write(g.body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+type generator struct {
+	body    bytes.Buffer
+	imports map[string]struct{}
+}
+
+func (g *generator) line(f string, args ...interface{}) {
+	fmt.Fprintf(&g.body, f+"\n", args...)
+}
+
+func (g *generator) definition(name string, s schema) error {
+	if s.Type != "object" {
+		return fmt.Errorf("definition %v: unsupported type %q", name, s.Type)
+	}
+
+	required := make(map[string]bool, len(s.Required))
+	for _, v := range s.Required {
+		required[v] = true
+	}
+
+	g.line("")
+	g.line("type %v struct {", typeName(name))
+	for _, v := range sortedKeys(s.Properties) {
+		prop := s.Properties[v]
+		goType, err := g.goType(prop)
+		if err != nil {
+			return fmt.Errorf("definition %v, property %v: %w", name, v, err)
+		}
+
+		if len(prop.Enum) > 0 {
+			g.line("// %v is one of: %v", camelCase(v), strings.Join(prop.Enum, ", "))
+		}
+		tag := v
+		// omitempty has no effect on struct values
+		if !required[v] && goType != "time.Time" {
+			tag += ",omitempty"
+		}
+		g.line("%v %v `json:%q`", camelCase(v), goType, tag)
+	}
+	g.line("}")
+
+	return nil
+}
+
+func (g *generator) goType(s schema) (string, error) {
+	if s.Ref != "" {
+		return typeName(strings.TrimPrefix(s.Ref, refPrefix)), nil
+	}
+
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = struct{}{}
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "object":
+		// free form json, eg. audit before/after values
+		g.imports["encoding/json"] = struct{}{}
+		return "json.RawMessage", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(*s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	}
+
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+func typeName(definition string) string {
+	return strings.TrimPrefix(definition, definitionPrefix)
+}
+
+// pathName names path after its segments, eg. /dns/ip/history is
+// PathDnsIpHistory and /dns/{domain} is PathDnsDomain
+func pathName(path string) string {
+	name := "Path"
+	for _, v := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '.' || r == '-' || r == '{' || r == '}'
+	}) {
+		name += camelCase(v)
+	}
+
+	return name
+}
+
+// camelCase follows naming of the repo, eg. node_name is NodeName and ip
+// is Ip
+func camelCase(v string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(v, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return b.String()
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
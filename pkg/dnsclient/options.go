@@ -0,0 +1,122 @@
+package dnsclient
+
+import (
+	"fmt"
+	"net/http"
+	"prem-gateway/pkg/signing"
+	"time"
+)
+
+const (
+	defaultTimeout      = time.Second * 30
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = time.Millisecond * 500
+)
+
+type Option interface {
+	apply(*options) error
+}
+
+type options struct {
+	httpClient *http.Client
+	// actor is sent in ActorHeader, empty if not set
+	actor string
+	// apiKey is sent in Authorization header, used when dnsd is called
+	// through traefik and authd
+	apiKey string
+	// signer signs requests, POST /audit is accepted only if it is set
+	signer *signing.Signer
+	// username and password are sent as basic auth if password is set
+	username string
+	password string
+	// maxRetries is number of retries after first attempt
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		httpClient:   &http.Client{Timeout: defaultTimeout},
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+}
+
+type funcOption struct {
+	f func(*options) error
+}
+
+func (fo *funcOption) apply(o *options) error {
+	return fo.f(o)
+}
+
+func newFuncOption(f func(*options) error) *funcOption {
+	return &funcOption{
+		f: f,
+	}
+}
+
+func WithHttpClient(httpClient *http.Client) Option {
+	return newFuncOption(func(o *options) error {
+		if httpClient == nil {
+			return fmt.Errorf("http client not set")
+		}
+
+		o.httpClient = httpClient
+		return nil
+	})
+}
+
+// WithActor sets identity recorded in dnsd audit log for changes made
+// through the client
+func WithActor(actor string) Option {
+	return newFuncOption(func(o *options) error {
+		o.actor = actor
+		return nil
+	})
+}
+
+// WithSecret signs requests with secret shared with dnsd, it is
+// CONTROLLER_DAEMON_SECRET of dnsd. Actor of signed requests is trusted
+func WithSecret(secret string) Option {
+	return newFuncOption(func(o *options) error {
+		signer, err := signing.NewSigner(secret)
+		if err != nil {
+			return err
+		}
+
+		o.signer = signer
+		return nil
+	})
+}
+
+func WithApiKey(apiKey string) Option {
+	return newFuncOption(func(o *options) error {
+		o.apiKey = apiKey
+		return nil
+	})
+}
+
+// WithBasicAuth sets credentials of ACME endpoints, username is acme and
+// password is AUTH_DNS_ACME_SECRET of dnsd
+func WithBasicAuth(username, password string) Option {
+	return newFuncOption(func(o *options) error {
+		o.username = username
+		o.password = password
+		return nil
+	})
+}
+
+// WithRetry sets number of retries after failed attempt and delay before
+// first retry, delay doubles with each retry. maxRetries 0 disables retry
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return newFuncOption(func(o *options) error {
+		if maxRetries < 0 || backoff < 0 {
+			return fmt.Errorf("retry settings can not be negative")
+		}
+
+		o.maxRetries = maxRetries
+		o.retryBackoff = backoff
+		return nil
+	})
+}
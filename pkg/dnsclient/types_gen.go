@@ -0,0 +1,144 @@
+// Code generated by dnsclient-gen from dns/docs/swagger.json. DO NOT EDIT.
+
+package dnsclient
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// SpecVersion is version of dnsd api the client is generated from
+	SpecVersion = "1.0"
+
+	// PathWellKnownPremGatewayNonce supports GET
+	PathWellKnownPremGatewayNonce = "/.well-known/prem-gateway/{nonce}"
+	// PathAcmeCleanup supports POST
+	PathAcmeCleanup = "/acme/cleanup"
+	// PathAcmePresent supports POST
+	PathAcmePresent = "/acme/present"
+	// PathAdminConfig supports GET
+	PathAdminConfig = "/admin/config"
+	// PathAudit supports GET, POST
+	PathAudit = "/audit"
+	// PathDns supports POST
+	PathDns = "/dns"
+	// PathDnsCheck supports GET
+	PathDnsCheck = "/dns/check"
+	// PathDnsExisting supports GET
+	PathDnsExisting = "/dns/existing"
+	// PathDnsIp supports GET
+	PathDnsIp = "/dns/ip"
+	// PathDnsIpDetails supports GET
+	PathDnsIpDetails = "/dns/ip/details"
+	// PathDnsIpHistory supports GET
+	PathDnsIpHistory = "/dns/ip/history"
+	// PathDnsNotifications supports GET
+	PathDnsNotifications = "/dns/notifications"
+	// PathDnsPreflightDomain supports GET
+	PathDnsPreflightDomain = "/dns/preflight/{domain}"
+	// PathDnsStatusDomain supports GET
+	PathDnsStatusDomain = "/dns/status/{domain}"
+	// PathDnsDomain supports DELETE, GET
+	PathDnsDomain = "/dns/{domain}"
+	// PathDnsDomainAcmeCa supports PUT
+	PathDnsDomainAcmeCa = "/dns/{domain}/acme-ca"
+)
+
+type AcmeCaRequest struct {
+	AcmeCa string `json:"acme_ca,omitempty"`
+}
+
+type AuditEvent struct {
+	Action    string          `json:"action,omitempty"`
+	Actor     string          `json:"actor,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Error     string          `json:"error,omitempty"`
+	Id        int64           `json:"id,omitempty"`
+	Outcome   string          `json:"outcome,omitempty"`
+	Resource  string          `json:"resource,omitempty"`
+}
+
+type AuditEventRequest struct {
+	Action string          `json:"action"`
+	After  json.RawMessage `json:"after,omitempty"`
+	Before json.RawMessage `json:"before,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Outcome is one of: success, failure
+	Outcome  string `json:"outcome"`
+	Resource string `json:"resource,omitempty"`
+}
+
+type DnsInfo struct {
+	AcmeCa   string `json:"acme_ca,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Ip       string `json:"ip,omitempty"`
+	NodeName string `json:"node_name,omitempty"`
+}
+
+type ErrorResponse struct {
+	Code   string       `json:"code,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Code    string `json:"code,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type GatewayIp struct {
+	Ip     string `json:"ip,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+type IpChangeEvent struct {
+	CreatedAt     time.Time `json:"created_at"`
+	NewIp         string    `json:"new_ip,omitempty"`
+	OldIp         string    `json:"old_ip,omitempty"`
+	ProviderError string    `json:"provider_error,omitempty"`
+	Source        string    `json:"source,omitempty"`
+}
+
+type Notification struct {
+	AcmeCa        string    `json:"acme_ca,omitempty"`
+	Attempts      int       `json:"attempts,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	DeadAt        time.Time `json:"dead_at"`
+	Domain        string    `json:"domain,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	Id            int64     `json:"id,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	Type          string    `json:"type,omitempty"`
+}
+
+type PreflightCheck struct {
+	Id          string `json:"id,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	// Status is one of: pass, warn, fail
+	Status string `json:"status,omitempty"`
+}
+
+type PreflightReport struct {
+	Checks     []PreflightCheck `json:"checks,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Domain     string           `json:"domain,omitempty"`
+	ExpectedIp string           `json:"expected_ip,omitempty"`
+	// Status is one of: pass, warn, fail
+	Status string `json:"status,omitempty"`
+}
+
+type SuccessResponse struct {
+	Status string `json:"status,omitempty"`
+}
+
+type TxtRecord struct {
+	Fqdn  string `json:"fqdn"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,3 @@
+module prem-gateway/pkg
+
+go 1.24.0
@@ -0,0 +1,316 @@
+// Package signing authenticates requests between gateway daemons with
+// HMAC-SHA256 over method, uri, timestamp, nonce and body, signed with
+// shared secret. Timestamp and nonce protect against replay. It depends only
+// on standard library so controllerd can use it
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderTimestamp = "X-Prem-Timestamp"
+	HeaderNonce     = "X-Prem-Nonce"
+	HeaderSignature = "X-Prem-Signature"
+
+	// MinSecretLength is min length of shared secret, eg. output of
+	// openssl rand -hex 32
+	MinSecretLength = 32
+	// DefaultMaxSkew is max difference between timestamp of request and
+	// clock of the verifier
+	DefaultMaxSkew = time.Minute * 5
+	// MaxBodySize limits body read by the verifier
+	MaxBodySize = 1 << 20
+
+	signatureVersion = "v1"
+	nonceLength      = 16
+)
+
+var (
+	ErrMissingSignature = errors.New("request is not signed")
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrExpired          = errors.New("request timestamp is out of allowed window")
+	ErrReplayed         = errors.New("request nonce was already used")
+	ErrBodyTooLarge     = errors.New("request body is too large")
+)
+
+func checkSecret(secret string) error {
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf(
+			"secret must be at least %v characters", MinSecretLength,
+		)
+	}
+
+	return nil
+}
+
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) (*Signer, error) {
+	if err := checkSecret(secret); err != nil {
+		return nil, err
+	}
+
+	return &Signer{secret: []byte(secret)}, nil
+}
+
+// Sign sets signature headers of req, body must be the body req sends
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	nonce := make([]byte, nonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonceHex)
+	req.Header.Set(HeaderSignature, signatureVersion+"="+hex.EncodeToString(
+		sign(s.secret, req.Method, req.URL.RequestURI(), timestamp, nonceHex, body),
+	))
+
+	return nil
+}
+
+// SignValue returns signature of value, eg. of header set by authd, for
+// request with method and uri, so receiver holding the secret can check
+// value was set by the signer for that request. Signature carries its
+// timestamp and nonce, so it is accepted once and only within max skew
+func (s *Signer) SignValue(method, uri, value string) (string, error) {
+	nonce := make([]byte, nonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+
+	return strings.Join([]string{
+		"t=" + timestamp,
+		"n=" + nonceHex,
+		signatureVersion + "=" + hex.EncodeToString(
+			signValue(s.secret, method, uri, timestamp, nonceHex, value),
+		),
+	}, ","), nil
+}
+
+// Verifier checks signed requests, nonces are remembered for the window in
+// which timestamp is accepted so each request is accepted once
+type Verifier struct {
+	secret  []byte
+	maxSkew time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewVerifier returns verifier accepting requests with timestamp within
+// maxSkew, 0 maxSkew is DefaultMaxSkew
+func NewVerifier(secret string, maxSkew time.Duration) (*Verifier, error) {
+	if err := checkSecret(secret); err != nil {
+		return nil, err
+	}
+	if maxSkew < 0 {
+		return nil, fmt.Errorf("max skew %v is negative", maxSkew)
+	}
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+
+	return &Verifier{
+		secret:  []byte(secret),
+		maxSkew: maxSkew,
+		nonces:  make(map[string]time.Time),
+	}, nil
+}
+
+// Verify checks signature of r and returns its body, r.Body is replaced so
+// it can be read again
+func (v *Verifier) Verify(r *http.Request) ([]byte, error) {
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return nil, ErrMissingSignature
+	}
+
+	now := time.Now()
+	if err := v.checkTimestamp(timestamp, now); err != nil {
+		return nil, err
+	}
+	expected, err := parseMac(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		if len(body) > MaxBodySize {
+			return nil, ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !hmac.Equal(expected, sign(
+		v.secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body,
+	)) {
+		return nil, ErrInvalidSignature
+	}
+
+	// nonce is recorded only for authentic requests so it can not be
+	// used to fill the cache
+	if !v.useNonce(nonce, now) {
+		return nil, ErrReplayed
+	}
+
+	return body, nil
+}
+
+// Middleware rejects requests which fail verification with 401
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// VerifyValue checks signature returned by SignValue of value for request
+// with method and uri, signature is accepted once
+func (v *Verifier) VerifyValue(method, uri, value, signature string) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp, nonce, mac string
+	for _, part := range strings.Split(signature, ",") {
+		key, val, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = val
+		case "n":
+			nonce = val
+		case signatureVersion:
+			mac = part
+		}
+	}
+	if timestamp == "" || nonce == "" || mac == "" {
+		return ErrInvalidSignature
+	}
+
+	now := time.Now()
+	if err := v.checkTimestamp(timestamp, now); err != nil {
+		return err
+	}
+	expected, err := parseMac(mac)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signValue(v.secret, method, uri, timestamp, nonce, value)) {
+		return ErrInvalidSignature
+	}
+	if !v.useNonce(nonce, now) {
+		return ErrReplayed
+	}
+
+	return nil
+}
+
+// checkTimestamp returns ErrExpired if unix timestamp is out of max skew
+// from now
+func (v *Verifier) checkTimestamp(timestamp string, now time.Time) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+func (v *Verifier) useNonce(nonce string, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// request older than 2*maxSkew can not pass timestamp check anymore
+	for k, usedAt := range v.nonces {
+		if now.Sub(usedAt) > 2*v.maxSkew {
+			delete(v.nonces, k)
+		}
+	}
+
+	if _, ok := v.nonces[nonce]; ok {
+		return false
+	}
+	v.nonces[nonce] = now
+
+	return true
+}
+
+func sign(secret []byte, method, uri, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method),
+		uri,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+
+	return mac.Sum(nil)
+}
+
+// parseMac decodes mac of versioned signature, eg. v1=<hex>
+func parseMac(signature string) ([]byte, error) {
+	version, mac, ok := strings.Cut(signature, "=")
+	if !ok || version != signatureVersion {
+		return nil, ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(mac)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return expected, nil
+}
+
+// signValue is prefixed so value signature can not be used as request one
+func signValue(secret []byte, method, uri, timestamp, nonce, value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		"value",
+		strings.ToUpper(method),
+		uri,
+		timestamp,
+		nonce,
+		value,
+	}, "\n")))
+
+	return mac.Sum(nil)
+}